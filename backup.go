@@ -4,6 +4,7 @@
 package winio
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -60,21 +61,49 @@ type win32StreamID struct {
 	NameSize   uint32
 }
 
+// UnknownStreamFunc is called by a [BackupStreamReader] for each stream with an ID that is not
+// one of the Backup* constants, such as a stream type added by a newer version of Windows. r
+// reads the contents of the stream; any part of it that is not read is skipped.
+type UnknownStreamFunc func(hdr *BackupHeader, r io.Reader) error
+
 // BackupStreamReader reads from a stream produced by the BackupRead Win32 API and produces a series
 // of BackupHeader values.
 type BackupStreamReader struct {
 	r         io.Reader
 	bytesLeft int64
+	unknown   UnknownStreamFunc
 }
 
 // NewBackupStreamReader produces a BackupStreamReader from any io.Reader.
 func NewBackupStreamReader(r io.Reader) *BackupStreamReader {
-	return &BackupStreamReader{r, 0}
+	return &BackupStreamReader{r: r}
+}
+
+// SetUnknownStreamFunc sets a function to handle streams with unknown IDs. Next passes
+// such streams to fn, instead of returning them, and returns the error if fn fails. If fn is
+// nil, Next returns all streams.
+func (r *BackupStreamReader) SetUnknownStreamFunc(fn UnknownStreamFunc) {
+	r.unknown = fn
 }
 
 // Next returns the next backup stream and prepares for calls to Read(). It skips the remainder of the current stream if
 // it was not completely read.
 func (r *BackupStreamReader) Next() (*BackupHeader, error) {
+	for {
+		hdr, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		if r.unknown == nil || (hdr.Id >= BackupData && hdr.Id <= BackupTxfsData) {
+			return hdr, nil
+		}
+		if err := r.unknown(hdr, r); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (r *BackupStreamReader) next() (*BackupHeader, error) {
 	if r.bytesLeft > 0 { //nolint:nestif // todo: flatten this
 		if s, ok := r.r.(io.Seeker); ok {
 			// Make sure Seek on io.SeekCurrent sometimes succeeds
@@ -188,22 +217,49 @@ func (w *BackupStreamWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// BackupProgressFunc is called by a [BackupFileReader] or [BackupFileWriter] after each
+// successful read or write, with the total number of bytes processed so far.
+type BackupProgressFunc func(total int64)
+
 // BackupFileReader provides an io.ReadCloser interface on top of the BackupRead Win32 API.
 type BackupFileReader struct {
 	f               *os.File
 	includeSecurity bool
 	ctx             uintptr
+
+	cctx     context.Context //nolint:containedctx // checked between BackupRead calls
+	progress BackupProgressFunc
+	total    int64
 }
 
 // NewBackupFileReader returns a new BackupFileReader from a file handle. If includeSecurity is true,
 // Read will attempt to read the security descriptor of the file.
 func NewBackupFileReader(f *os.File, includeSecurity bool) *BackupFileReader {
-	r := &BackupFileReader{f, includeSecurity, 0}
-	return r
+	return NewBackupFileReaderContext(context.Background(), f, includeSecurity, nil)
+}
+
+// NewBackupFileReaderContext returns a new BackupFileReader from a file handle, similar to
+// [NewBackupFileReader].
+//
+// If ctx is cancelled, subsequent Read calls abort the backup operation, releasing the associated
+// Win32 resources, and return ctx.Err(). Since BackupRead is synchronous, a Read that is in progress
+// is not interrupted.
+// If progress is not nil, it is called after every Read with the total number of bytes read.
+func NewBackupFileReaderContext(ctx context.Context, f *os.File, includeSecurity bool, progress BackupProgressFunc) *BackupFileReader {
+	return &BackupFileReader{
+		f:               f,
+		includeSecurity: includeSecurity,
+		cctx:            ctx,
+		progress:        progress,
+	}
 }
 
 // Read reads a backup stream from the file by calling the Win32 API BackupRead().
 func (r *BackupFileReader) Read(b []byte) (int, error) {
+	if err := r.cctx.Err(); err != nil {
+		_ = r.Close()
+		return 0, err
+	}
 	var bytesRead uint32
 	err := backupRead(windows.Handle(r.f.Fd()), b, &bytesRead, false, r.includeSecurity, &r.ctx)
 	if err != nil {
@@ -213,6 +269,10 @@ func (r *BackupFileReader) Read(b []byte) (int, error) {
 	if bytesRead == 0 {
 		return 0, io.EOF
 	}
+	r.total += int64(bytesRead)
+	if r.progress != nil {
+		r.progress(r.total)
+	}
 	return int(bytesRead), nil
 }
 
@@ -232,23 +292,50 @@ type BackupFileWriter struct {
 	f               *os.File
 	includeSecurity bool
 	ctx             uintptr
+
+	cctx     context.Context //nolint:containedctx // checked between BackupWrite calls
+	progress BackupProgressFunc
+	total    int64
 }
 
 // NewBackupFileWriter returns a new BackupFileWriter from a file handle. If includeSecurity is true,
 // Write() will attempt to restore the security descriptor from the stream.
 func NewBackupFileWriter(f *os.File, includeSecurity bool) *BackupFileWriter {
-	w := &BackupFileWriter{f, includeSecurity, 0}
-	return w
+	return NewBackupFileWriterContext(context.Background(), f, includeSecurity, nil)
+}
+
+// NewBackupFileWriterContext returns a new BackupFileWriter from a file handle, similar to
+// [NewBackupFileWriter].
+//
+// If ctx is cancelled, subsequent Write calls abort the restore operation, releasing the associated
+// Win32 resources, and return ctx.Err(). Since BackupWrite is synchronous, a Write that is in progress
+// is not interrupted.
+// If progress is not nil, it is called after every Write with the total number of bytes written.
+func NewBackupFileWriterContext(ctx context.Context, f *os.File, includeSecurity bool, progress BackupProgressFunc) *BackupFileWriter {
+	return &BackupFileWriter{
+		f:               f,
+		includeSecurity: includeSecurity,
+		cctx:            ctx,
+		progress:        progress,
+	}
 }
 
 // Write restores a portion of the file using the provided backup stream.
 func (w *BackupFileWriter) Write(b []byte) (int, error) {
+	if err := w.cctx.Err(); err != nil {
+		_ = w.Close()
+		return 0, err
+	}
 	var bytesWritten uint32
 	err := backupWrite(windows.Handle(w.f.Fd()), b, &bytesWritten, false, w.includeSecurity, &w.ctx)
 	if err != nil {
 		return 0, &os.PathError{Op: "BackupWrite", Path: w.f.Name(), Err: err}
 	}
 	runtime.KeepAlive(w.f)
+	w.total += int64(bytesWritten)
+	if w.progress != nil {
+		w.progress(w.total)
+	}
 	if int(bytesWritten) != len(b) {
 		return int(bytesWritten), errors.New("not all bytes could be written")
 	}
@@ -285,3 +372,53 @@ func OpenForBackup(path string, access uint32, share uint32, createmode uint32)
 	}
 	return os.NewFile(uintptr(h), path), nil
 }
+
+// CloneFileMetadata copies the metadata of src to dst, without copying the file contents:
+// the security descriptor, extended attributes, reparse data, attributes, and timestamps.
+//
+// The metadata is read from and restored with backup streams, so src should be opened with
+// READ_CONTROL access (and ACCESS_SYSTEM_SECURITY to copy the SACL), and dst with WRITE_DAC,
+// WRITE_OWNER, FILE_WRITE_EA, and FILE_WRITE_ATTRIBUTES access (and ACCESS_SYSTEM_SECURITY
+// to restore the SACL). Restoring an arbitrary owner requires the restore privilege (see
+// [EnableProcessPrivileges]). [OpenForBackup] can be used to open both files.
+//
+// The data streams of src are read through but not copied, and the contents and alternate
+// data streams of dst are left as they are.
+func CloneFileMetadata(src, dst *os.File) error {
+	br := NewBackupFileReader(src, true)
+	defer br.Close()
+	bw := NewBackupFileWriter(dst, true)
+	defer bw.Close()
+
+	r := NewBackupStreamReader(br)
+	w := NewBackupStreamWriter(bw)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF { //nolint:errorlint
+			break
+		} else if err != nil {
+			return err
+		}
+		switch hdr.Id {
+		case BackupSecurity, BackupEaData, BackupReparseData:
+		default:
+			continue
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, r); err != nil {
+			return err
+		}
+	}
+
+	bi, err := GetFileBasicInfo(src)
+	if err != nil {
+		return err
+	}
+	// These attributes are set by the backup streams, or cannot be set directly.
+	bi.FileAttributes &^= windows.FILE_ATTRIBUTE_REPARSE_POINT | windows.FILE_ATTRIBUTE_SPARSE_FILE |
+		windows.FILE_ATTRIBUTE_COMPRESSED | windows.FILE_ATTRIBUTE_ENCRYPTED
+	// Set the timestamps last, since restoring the other metadata may update them.
+	return SetFileBasicInfo(dst, bi)
+}
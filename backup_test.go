@@ -4,9 +4,14 @@
 package winio
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"golang.org/x/sys/windows"
 )
@@ -71,6 +76,59 @@ func TestBackupRead(t *testing.T) {
 	}
 }
 
+func TestBackupReadContextProgress(t *testing.T) {
+	err := makeTestFile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var total int64
+	r := NewBackupFileReaderContext(context.Background(), f, false, func(n int64) { total = n })
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != int64(len(b)) {
+		t.Fatalf("progress reported %d bytes, read %d", total, len(b))
+	}
+}
+
+func TestBackupReadContextCancel(t *testing.T) {
+	err := makeTestFile(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewBackupFileReaderContext(ctx, f, false, nil)
+	defer r.Close()
+
+	b := make([]byte, 8)
+	if _, err := r.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if _, err := r.Read(b); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+	if r.ctx != 0 {
+		t.Fatal("backup context was not released")
+	}
+}
+
 func TestBackupStreamRead(t *testing.T) {
 	err := makeTestFile(true)
 	if err != nil {
@@ -193,6 +251,72 @@ func TestBackupStreamWrite(t *testing.T) {
 	}
 }
 
+func TestBackupStreamUnknownStream(t *testing.T) {
+	const unknownID = BackupTxfsData + 10
+	var buf bytes.Buffer
+	w := NewBackupStreamWriter(&buf)
+	for _, s := range []struct {
+		id   uint32
+		data string
+	}{
+		{BackupData, "data"},
+		{unknownID, "unknown stream"},
+		{unknownID, "skipped"},
+		{BackupEaData, "ea"},
+	} {
+		if err := w.WriteHeader(&BackupHeader{Id: s.id, Size: int64(len(s.data))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(s.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := NewBackupStreamReader(bytes.NewReader(buf.Bytes()))
+	var unknown []string
+	r.SetUnknownStreamFunc(func(hdr *BackupHeader, sr io.Reader) error {
+		if hdr.Id != unknownID {
+			t.Errorf("unexpected stream ID %d", hdr.Id)
+		}
+		// only read part of the second stream
+		b := make([]byte, 4)
+		if len(unknown) == 0 {
+			b = make([]byte, hdr.Size)
+		}
+		if _, err := io.ReadFull(sr, b); err != nil {
+			return err
+		}
+		unknown = append(unknown, string(b))
+		return nil
+	})
+	var ids []uint32
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF { //nolint:errorlint
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, hdr.Id)
+	}
+	if len(ids) != 2 || ids[0] != BackupData || ids[1] != BackupEaData {
+		t.Fatalf("unexpected streams %v", ids)
+	}
+	if len(unknown) != 2 || unknown[0] != "unknown stream" || unknown[1] != "skip" {
+		t.Fatalf("unexpected unknown stream contents %q", unknown)
+	}
+
+	errStop := errors.New("stop")
+	r = NewBackupStreamReader(bytes.NewReader(buf.Bytes()))
+	r.SetUnknownStreamFunc(func(*BackupHeader, io.Reader) error { return errStop })
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); !errors.Is(err, errStop) {
+		t.Fatalf("expected %v, got %v", errStop, err)
+	}
+}
+
 func makeSparseFile() error {
 	os.Remove(testFileName)
 	f, err := os.Create(testFileName)
@@ -201,7 +325,7 @@ func makeSparseFile() error {
 	}
 	defer f.Close()
 
-	err = windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_SPARSE, nil, 0, nil, 0, nil, nil)
+	err = SetSparse(f)
 	if err != nil {
 		return err
 	}
@@ -251,3 +375,76 @@ func TestBackupSparseFile(t *testing.T) {
 		t.Log(hdr)
 	}
 }
+
+func TestCloneFileMetadata(t *testing.T) {
+	err := makeTestFile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	if err := os.Chtimes(testFileName, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	p16, err := windows.UTF16PtrFromString(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := windows.SetFileAttributes(p16, windows.FILE_ATTRIBUTE_HIDDEN); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := OpenForBackup(testFileName, windows.GENERIC_READ, windows.FILE_SHARE_READ, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+
+	dstName := filepath.Join(t.TempDir(), "dst")
+	if err := os.WriteFile(dstName, []byte("new contents"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := OpenForBackup(dstName, windows.GENERIC_READ|windows.GENERIC_WRITE|WRITE_DAC|WRITE_OWNER, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	if err := CloneFileMetadata(src, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	sbi, err := GetFileBasicInfo(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbi, err := GetFileBasicInfo(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dbi.FileAttributes&windows.FILE_ATTRIBUTE_HIDDEN == 0 {
+		t.Errorf("attributes not copied: 0x%x", dbi.FileAttributes)
+	}
+	if dbi.LastWriteTime != sbi.LastWriteTime || dbi.CreationTime != sbi.CreationTime {
+		t.Errorf("timestamps not copied: got %+v, want %+v", dbi, sbi)
+	}
+
+	ssd, err := windows.GetSecurityInfo(windows.Handle(src.Fd()), windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsd, err := windows.GetSecurityInfo(windows.Handle(dst.Fd()), windows.SE_FILE_OBJECT, windows.OWNER_SECURITY_INFORMATION|windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ssd.String() != dsd.String() {
+		t.Errorf("security descriptor not copied: got %s, want %s", dsd, ssd)
+	}
+
+	b, err := io.ReadAll(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new contents" {
+		t.Errorf("contents changed: %q", b)
+	}
+}
@@ -0,0 +1,120 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+// Media types of the tar streams written by [CompressedTarWriter], as used for OCI image layers.
+const (
+	MediaTypeTar     = "application/vnd.oci.image.layer.v1.tar"
+	MediaTypeTarGzip = MediaTypeTar + "+gzip"
+	MediaTypeTarZstd = MediaTypeTar + "+zstd"
+)
+
+// compressedTarBufferSize is the size of the buffer between the compressor and the
+// underlying writer, so that the compressor's small writes are coalesced.
+const compressedTarBufferSize = 256 * 1024
+
+// Compressor returns a writer that compresses the data written to it into w. Closing the
+// writer must write any buffered data and the end of the compressed stream to w, but must not
+// close w. If the writer has a Flush() error method, it is used by [CompressedTarWriter.Flush].
+//
+// For example, with github.com/klauspost/compress/zstd:
+//
+//	func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) }
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+// CompressedTarWriter is a [tar.Writer] whose output is compressed. The embedded tar.Writer can
+// be passed to functions such as [WriteTarFileFromBackupStream]; the stream must be finished
+// with [CompressedTarWriter.Close], rather than the tar.Writer's Close.
+type CompressedTarWriter struct {
+	*tar.Writer
+	c         io.WriteCloser
+	bw        *bufio.Writer
+	mediaType string
+}
+
+// NewCompressedTarWriter returns a [CompressedTarWriter] that compresses the tar stream with a
+// writer returned by newCompressor, and writes it to w. mediaType is returned by
+// [CompressedTarWriter.MediaType]. If newCompressor is nil, the stream is not compressed.
+func NewCompressedTarWriter(w io.Writer, mediaType string, newCompressor Compressor) (*CompressedTarWriter, error) {
+	bw := bufio.NewWriterSize(w, compressedTarBufferSize)
+	var c io.WriteCloser = nopWriteCloser{bw}
+	if newCompressor != nil {
+		var err error
+		if c, err = newCompressor(bw); err != nil {
+			return nil, err
+		}
+	}
+	return &CompressedTarWriter{
+		Writer:    tar.NewWriter(c),
+		c:         c,
+		bw:        bw,
+		mediaType: mediaType,
+	}, nil
+}
+
+// NewGzipTarWriter returns a [CompressedTarWriter] that writes a gzip-compressed tar stream to
+// w, at the specified compression level, such as gzip.DefaultCompression.
+func NewGzipTarWriter(w io.Writer, level int) (*CompressedTarWriter, error) {
+	return NewCompressedTarWriter(w, MediaTypeTarGzip, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+}
+
+// NewZstdTarWriter returns a [CompressedTarWriter] that writes a zstd-compressed tar stream to
+// w, using an encoder returned by newEncoder, as this package does not implement zstd itself.
+// newEncoder must not be nil.
+func NewZstdTarWriter(w io.Writer, newEncoder Compressor) (*CompressedTarWriter, error) {
+	if newEncoder == nil {
+		return nil, errors.New("no zstd encoder")
+	}
+	return NewCompressedTarWriter(w, MediaTypeTarZstd, newEncoder)
+}
+
+// MediaType returns the media type of the stream.
+func (t *CompressedTarWriter) MediaType() string {
+	return t.mediaType
+}
+
+// Flush writes the data written so far, up to the end of the current file's padding, through
+// the compressor to the underlying writer, so that a reader can decompress it. As with
+// [tar.Writer.Flush], it fails if the current file has not been completely written. Frequent
+// flushes reduce the compression ratio.
+func (t *CompressedTarWriter) Flush() error {
+	if err := t.Writer.Flush(); err != nil {
+		return err
+	}
+	if f, ok := t.c.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return t.bw.Flush()
+}
+
+// Close writes the tar trailer, finishes the compressed stream, and writes any buffered data to
+// the underlying writer. It does not close the underlying writer.
+func (t *CompressedTarWriter) Close() error {
+	err := t.Writer.Close()
+	if cerr := t.c.Close(); err == nil {
+		err = cerr
+	}
+	if ferr := t.bw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
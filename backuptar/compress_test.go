@@ -0,0 +1,142 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+)
+
+func writeCompressedTarFile(t *testing.T, tw *CompressedTarWriter, name string, data []byte) {
+	t.Helper()
+
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTarFile(t *testing.T, tr *tar.Reader, name string, data []byte) {
+	t.Helper()
+
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Name != name {
+		t.Fatalf("expected %s, got %s", name, hdr.Name)
+	}
+	b, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatalf("unexpected contents for %s", name)
+	}
+}
+
+func TestGzipTarWriter(t *testing.T) {
+	var buf bytes.Buffer
+	tw, err := NewGzipTarWriter(&buf, gzip.BestSpeed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tw.MediaType() != MediaTypeTarGzip {
+		t.Fatalf("unexpected media type %s", tw.MediaType())
+	}
+
+	data := bytes.Repeat([]byte("winio"), 1000)
+	writeCompressedTarFile(t, tw, "a.txt", data)
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	// the first file can be read back before the stream is finished
+	zr, err := gzip.NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readTarFile(t, tar.NewReader(zr), "a.txt", data)
+
+	writeCompressedTarFile(t, tw, "b.txt", data[:10])
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err = gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	readTarFile(t, tr, "a.txt", data)
+	readTarFile(t, tr, "b.txt", data[:10])
+	if _, err := tr.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
+
+func TestGzipTarWriterInvalidLevel(t *testing.T) {
+	if _, err := NewGzipTarWriter(io.Discard, 100); err == nil {
+		t.Fatal("expected an error for an invalid compression level")
+	}
+}
+
+type testCompressor struct {
+	w       io.Writer
+	flushes int
+	closed  bool
+}
+
+func (c *testCompressor) Write(b []byte) (int, error) { return c.w.Write(b) }
+
+func (c *testCompressor) Flush() error {
+	c.flushes++
+	return nil
+}
+
+func (c *testCompressor) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestZstdTarWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var c *testCompressor
+	tw, err := NewZstdTarWriter(&buf, func(w io.Writer) (io.WriteCloser, error) {
+		c = &testCompressor{w: w}
+		return c, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tw.MediaType() != MediaTypeTarZstd {
+		t.Fatalf("unexpected media type %s", tw.MediaType())
+	}
+
+	writeCompressedTarFile(t, tw, "a.txt", []byte("hello"))
+	if err := tw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if c.flushes != 1 || buf.Len() == 0 {
+		t.Fatalf("expected the compressor and buffer to be flushed, got %d flushes and %d bytes", c.flushes, buf.Len())
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !c.closed {
+		t.Fatal("expected the compressor to be closed")
+	}
+
+	readTarFile(t, tar.NewReader(&buf), "a.txt", []byte("hello"))
+}
+
+func TestZstdTarWriterNoEncoder(t *testing.T) {
+	if _, err := NewZstdTarWriter(io.Discard, nil); err == nil {
+		t.Fatal("expected an error without an encoder")
+	}
+}
@@ -0,0 +1,247 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// ErrUnsafePath is returned by [ExtractTar] when a tar entry's name or link target is
+// absolute, refers to a location outside of the destination directory, or is under a reparse
+// point (such as a mount point restored by an earlier entry) in the destination directory.
+var ErrUnsafePath = errors.New("path escapes the destination directory")
+
+// ExtractOptions contains options for [ExtractTar].
+type ExtractOptions struct {
+	// SkipSecurityDescriptors does not restore the files' security descriptors, so that the
+	// extracted files inherit their permissions from the destination directory.
+	SkipSecurityDescriptors bool
+
+	// NoPrivileges does not enable the restore and security privileges while extracting.
+	// Restoring security descriptors with arbitrary owners or SACLs will fail, as will
+	// writing into directories whose restored permissions deny access to the caller.
+	NoPrivileges bool
+
+	// RestoreObjectIDs restores the files' NTFS object IDs, which are used by services such
+	// as DFS Replication and distributed link tracking. Object IDs must be unique on a
+	// volume, so this fails if the original files are still present on the destination
+	// volume. It requires the restore privilege.
+	RestoreObjectIDs bool
+}
+
+// ExtractTar extracts the files in t, written by [WriteTarFileFromBackupStream], into the
+// directory destRoot, restoring their data, alternate data streams, security descriptors,
+// extended attributes, reparse points, attributes, and timestamps, and, if opts.RestoreObjectIDs
+// is set, their object IDs. Hard links between files (tar.TypeLink entries) are recreated.
+//
+// Unless opts.NoPrivileges is set, the restore and security privileges are enabled for the
+// duration of the extraction (see [winio.RunWithPrivileges]), which usually requires running
+// as an administrator.
+//
+// Existing directories in destRoot are reused, but extracting over an existing file fails.
+// Entries are never extracted through reparse points below destRoot, since a mount point or
+// symbolic link restored by one entry could otherwise redirect later entries outside of it.
+func ExtractTar(t *tar.Reader, destRoot string, opts *ExtractOptions) error {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	root, err := filepath.Abs(destRoot)
+	if err != nil {
+		return err
+	}
+	x := &extractor{t: t, root: root, opts: opts}
+	if opts.NoPrivileges {
+		return x.run()
+	}
+	return winio.RunWithPrivileges([]string{winio.SeRestorePrivilege, winio.SeSecurityPrivilege}, x.run)
+}
+
+type extractor struct {
+	t    *tar.Reader
+	root string
+	opts *ExtractOptions
+
+	// dirs are the directories extracted so far, whose timestamps are set after all of
+	// their contents have been extracted
+	dirs []extractedDir
+}
+
+type extractedDir struct {
+	path string
+	info *winio.FileBasicInfo
+}
+
+func (x *extractor) run() error {
+	hdr, err := x.t.Next()
+	for err == nil {
+		hdr, err = x.extract(hdr)
+	}
+	if err != io.EOF { //nolint:errorlint
+		return err
+	}
+
+	// set the directory timestamps in reverse order, so children are processed before
+	// their parents
+	for i := len(x.dirs) - 1; i >= 0; i-- {
+		d := x.dirs[i]
+		if err := x.setDirInfo(d.path, d.info); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extract extracts the file described by hdr, and returns the next header in the tar.
+func (x *extractor) extract(hdr *tar.Header) (*tar.Header, error) {
+	if err := ValidateTarHeader(hdr); err != nil {
+		return nil, err
+	}
+	p, err := x.path(hdr.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := x.checkReparsePoints(p, false); err != nil {
+		return nil, err
+	}
+
+	if hdr.Typeflag == tar.TypeLink {
+		target, err := x.path(hdr.Linkname)
+		if err != nil {
+			return nil, err
+		}
+		if err := x.checkReparsePoints(target, true); err != nil {
+			return nil, err
+		}
+		if err := os.Link(target, p); err != nil {
+			return nil, err
+		}
+		return x.t.Next()
+	}
+
+	_, _, fileInfo, err := FileInfoFromHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	isDir := fileInfo.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY != 0
+
+	createMode := uint32(windows.CREATE_NEW)
+	if isDir {
+		if err := os.Mkdir(p, 0777); err != nil && !os.IsExist(err) {
+			return nil, err
+		}
+		createMode = windows.OPEN_EXISTING
+	}
+
+	if x.opts.SkipSecurityDescriptors {
+		delete(hdr.PAXRecords, hdrSecurityDescriptor)
+		delete(hdr.PAXRecords, hdrRawSecurityDescriptor)
+	}
+	// the object ID is set explicitly, rather than through the backup stream, so that
+	// failures are reported
+	oid, err := ObjectIDFromTarHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	access := uint32(windows.GENERIC_READ | windows.GENERIC_WRITE | winio.WRITE_DAC | winio.WRITE_OWNER)
+	if !x.opts.NoPrivileges && !x.opts.SkipSecurityDescriptors {
+		access |= winio.ACCESS_SYSTEM_SECURITY
+	}
+	f, err := winio.OpenForBackup(p, access, 0, createMode)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bw := winio.NewBackupFileWriter(f, !x.opts.SkipSecurityDescriptors)
+	next, err := WriteBackupStreamFromTarFile(bw, x.t, hdr)
+	bw.Close()
+	if err != nil && err != io.EOF { //nolint:errorlint
+		return nil, fmt.Errorf("%s: %w", hdr.Name, err)
+	}
+	if oid != nil && x.opts.RestoreObjectIDs {
+		if err := winio.SetFileObjectID(f, oid); err != nil {
+			return nil, err
+		}
+	}
+
+	if isDir {
+		x.dirs = append(x.dirs, extractedDir{path: p, info: fileInfo})
+	} else if err := setBasicInfo(f, fileInfo); err != nil {
+		return nil, err
+	}
+	return next, err
+}
+
+// path returns the location of the tar entry name in the destination directory.
+func (x *extractor) path(name string) (string, error) {
+	name = filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, string(filepath.Separator)) ||
+		name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", &os.PathError{Op: "extract", Path: name, Err: ErrUnsafePath}
+	}
+	return filepath.Join(x.root, name), nil
+}
+
+// checkReparsePoints returns an error matching [ErrUnsafePath] if one of the directories
+// between the destination directory and p, or p itself if final is set, is a reparse point.
+// The paths are opened without following reparse points; a missing component ends the check,
+// as nothing can be created below it.
+func (x *extractor) checkReparsePoints(p string, final bool) error {
+	rel, err := filepath.Rel(x.root, p)
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if !final {
+		parts = parts[:len(parts)-1]
+	}
+	cur := x.root
+	for _, part := range parts {
+		cur = filepath.Join(cur, part)
+		f, err := winio.OpenForBackup(cur, windows.FILE_READ_ATTRIBUTES,
+			windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, windows.OPEN_EXISTING)
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		bi, err := winio.GetFileBasicInfo(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		if bi.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT != 0 {
+			return &os.PathError{Op: "extract", Path: rel, Err: ErrUnsafePath}
+		}
+	}
+	return nil
+}
+
+func (x *extractor) setDirInfo(p string, info *winio.FileBasicInfo) error {
+	f, err := winio.OpenForBackup(p, windows.FILE_WRITE_ATTRIBUTES, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return setBasicInfo(f, info)
+}
+
+func setBasicInfo(f *os.File, info *winio.FileBasicInfo) error {
+	bi := *info
+	// These attributes are set by the backup streams, or cannot be set directly.
+	bi.FileAttributes &^= windows.FILE_ATTRIBUTE_REPARSE_POINT | windows.FILE_ATTRIBUTE_SPARSE_FILE |
+		windows.FILE_ATTRIBUTE_COMPRESSED | windows.FILE_ATTRIBUTE_ENCRYPTED
+	return winio.SetFileBasicInfo(f, &bi)
+}
@@ -0,0 +1,321 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// writeTarFile adds the file at root\name to tw.
+func writeTarFile(t *testing.T, tw *tar.Writer, root, name string) {
+	t.Helper()
+
+	f, err := winio.OpenForBackup(filepath.Join(root, name), windows.GENERIC_READ, windows.FILE_SHARE_READ, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bi, err := winio.GetFileBasicInfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := fi.Size()
+	if fi.IsDir() {
+		size = 0
+	}
+	br := winio.NewBackupFileReader(f, true)
+	defer br.Close()
+	if err := WriteTarFileFromBackupStream(tw, br, filepath.ToSlash(name), size, bi); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(filepath.Join(src, "dir", "foo.txt"), []byte("foo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(filepath.Join(src, "dir", "foo.txt:ads"), []byte("ads"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	for _, p := range []string{filepath.Join(src, "dir", "foo.txt"), filepath.Join(src, "dir")} {
+		if err := os.Chtimes(p, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTarFile(t, tw, src, "dir")
+	writeTarFile(t, tw, src, filepath.Join("dir", "foo.txt"))
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeLink, Name: "dir/bar.txt", Linkname: "dir/foo.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := t.TempDir()
+	opts := &ExtractOptions{SkipSecurityDescriptors: true, NoPrivileges: true}
+	if err := ExtractTar(tar.NewReader(&buf), dst, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]string{
+		"dir/foo.txt":     "foo",
+		"dir/foo.txt:ads": "ads",
+		"dir/bar.txt":     "foo",
+	} {
+		b, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Errorf("%s: got %q, want %q", name, b, want)
+		}
+	}
+	for _, name := range []string{"dir", "dir/foo.txt"} {
+		fi, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.ModTime().Equal(mtime) {
+			t.Errorf("%s: got modification time %v, want %v", name, fi.ModTime(), mtime)
+		}
+	}
+}
+
+func TestExtractTarUnsafePath(t *testing.T) {
+	for _, name := range []string{"../foo.txt", "dir/../../foo.txt", "/foo.txt", "C:/foo.txt"} {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name}); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		err := ExtractTar(tar.NewReader(&buf), t.TempDir(), &ExtractOptions{NoPrivileges: true})
+		if !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%s: expected %v, got %v", name, ErrUnsafePath, err)
+		}
+	}
+}
+
+func TestExtractTarDirectoryADS(t *testing.T) {
+	src := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "dir"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(filepath.Join(src, "dir:ads"), []byte("ads"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTarFile(t, tw, src, "dir")
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	var names []string
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		names = append(names, hdr.Name)
+	}
+	if len(names) != 2 || names[0] != "dir" || names[1] != "dir:ads" {
+		t.Fatalf("got tar entries %v, want [dir dir:ads]", names)
+	}
+
+	dst := t.TempDir()
+	opts := &ExtractOptions{SkipSecurityDescriptors: true, NoPrivileges: true}
+	if err := ExtractTar(tar.NewReader(&buf), dst, opts); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dst, "dir:ads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ads" {
+		t.Errorf("got %q, want %q", b, "ads")
+	}
+}
+
+// setMountPoint makes the empty directory p a mount point (junction) to target.
+func setMountPoint(t *testing.T, p, target string) {
+	t.Helper()
+
+	f, err := winio.OpenForBackup(p, windows.GENERIC_WRITE, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rp := winio.EncodeReparsePoint(&winio.ReparsePoint{Target: target, IsMountPoint: true})
+	if err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_REPARSE_POINT,
+		&rp[0], uint32(len(rp)), nil, 0, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// getReparsePoint returns the reparse point of the file at p.
+func getReparsePoint(t *testing.T, p string) *winio.ReparsePoint {
+	t.Helper()
+
+	f, err := winio.OpenForBackup(p, windows.GENERIC_READ, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	b := make([]byte, windows.MAXIMUM_REPARSE_DATA_BUFFER_SIZE)
+	var n uint32
+	if err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_GET_REPARSE_POINT,
+		nil, 0, &b[0], uint32(len(b)), &n, nil); err != nil {
+		t.Fatal(err)
+	}
+	rp, err := winio.DecodeReparsePoint(b[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rp
+}
+
+func TestExtractTarMountPoint(t *testing.T) {
+	// a layer containing a directory that is a mount point to another location, with an
+	// alternate data stream of its own
+	src := t.TempDir()
+	target := t.TempDir()
+	for _, name := range []string{"layer", filepath.Join("layer", "mnt")} {
+		if err := os.Mkdir(filepath.Join(src, name), 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(filepath.Join(src, "layer", "mnt:ads"), []byte("ads"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setMountPoint(t, filepath.Join(src, "layer", "mnt"), target)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTarFile(t, tw, src, "layer")
+	writeTarFile(t, tw, src, filepath.Join("layer", "mnt"))
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	hdr, err := tr.Next()
+	for err == nil && hdr.Name != "layer/mnt" {
+		hdr, err = tr.Next()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdr.Typeflag != tar.TypeSymlink || hdr.PAXRecords[hdrMountPoint] != "1" {
+		t.Errorf("got type %c and mount point record %q, want a mount point", hdr.Typeflag, hdr.PAXRecords[hdrMountPoint])
+	}
+	_, _, bi, err := FileInfoFromHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bi.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY == 0 {
+		t.Errorf("got attributes %#x, want a directory", bi.FileAttributes)
+	}
+
+	dst := t.TempDir()
+	opts := &ExtractOptions{SkipSecurityDescriptors: true, NoPrivileges: true}
+	if err := ExtractTar(tar.NewReader(&buf), dst, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	mnt := filepath.Join(dst, "layer", "mnt")
+	rp := getReparsePoint(t, mnt)
+	if !rp.IsMountPoint || rp.Target != target {
+		t.Errorf("got reparse point %+v, want a mount point to %s", rp, target)
+	}
+	f, err := winio.OpenForBackup(mnt, windows.GENERIC_READ, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bi, err = winio.GetFileBasicInfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = windows.FILE_ATTRIBUTE_DIRECTORY | windows.FILE_ATTRIBUTE_REPARSE_POINT
+	if bi.FileAttributes&want != want {
+		t.Errorf("got attributes %#x, want %#x", bi.FileAttributes, want)
+	}
+	// open the stream without following the mount point
+	af, err := winio.OpenForBackup(mnt+":ads", windows.GENERIC_READ, 0, windows.OPEN_EXISTING)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer af.Close()
+	b, err := io.ReadAll(af)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "ads" {
+		t.Errorf("got %q, want %q", b, "ads")
+	}
+}
+
+func TestExtractTarUnderMountPoint(t *testing.T) {
+	// a mount point to a directory outside of the destination, followed by a file under it
+	src := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(src, "mnt"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	setMountPoint(t, filepath.Join(src, "mnt"), outside)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	writeTarFile(t, tw, src, "mnt")
+	data := []byte("data")
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "mnt/file.txt", Size: int64(len(data))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &ExtractOptions{SkipSecurityDescriptors: true, NoPrivileges: true}
+	err := ExtractTar(tar.NewReader(&buf), t.TempDir(), opts)
+	if !errors.Is(err, ErrUnsafePath) {
+		t.Fatalf("expected %v, got %v", ErrUnsafePath, err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "file.txt")); !os.IsNotExist(err) {
+		t.Fatalf("file was extracted through the mount point: %v", err)
+	}
+}
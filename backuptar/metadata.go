@@ -0,0 +1,162 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	// ErrUnsupportedRecord is returned when a tar header contains a Win32 (MSWINDOWS.*) PAX
+	// record that this package does not understand, such as one written by a newer version
+	// of the metadata schema.
+	ErrUnsupportedRecord = errors.New("unsupported Win32 PAX record")
+
+	// ErrInvalidSecurityDescriptor is returned when the security descriptor stored in a tar
+	// header is malformed.
+	ErrInvalidSecurityDescriptor = errors.New("invalid security descriptor")
+)
+
+// isKnownRecord returns true if k is a Win32 PAX record understood by this package.
+func isKnownRecord(k string) bool {
+	switch k {
+	case hdrFileAttributes, hdrSecurityDescriptor, hdrRawSecurityDescriptor, hdrMountPoint, hdrObjectID:
+		return true
+	}
+	return strings.HasPrefix(k, hdrEaPrefix)
+}
+
+// ValidateTarHeader checks that the Win32 metadata stored in hdr by [WriteTarFileFromBackupStream]
+// is well-formed, without restoring the file.
+//
+// It returns an error wrapping [ErrUnsupportedRecord] if hdr contains unknown MSWINDOWS.*
+// records, and [ErrInvalidSecurityDescriptor] if the security descriptor is malformed.
+func ValidateTarHeader(hdr *tar.Header) error {
+	for k := range hdr.PAXRecords {
+		if strings.HasPrefix(k, hdrPrefix) && !isKnownRecord(k) {
+			return fmt.Errorf("%s: record %q: %w", hdr.Name, k, ErrUnsupportedRecord)
+		}
+	}
+	if _, _, _, err := FileInfoFromHeader(hdr); err != nil {
+		return fmt.Errorf("%s: file information: %w", hdr.Name, err)
+	}
+	if _, err := SecurityDescriptorFromTarHeader(hdr); err != nil {
+		return fmt.Errorf("%s: security descriptor: %w", hdr.Name, err)
+	}
+	if _, err := ExtendedAttributesFromTarHeader(hdr); err != nil {
+		return fmt.Errorf("%s: extended attributes: %w", hdr.Name, err)
+	}
+	if _, err := ReparsePointFromTarHeader(hdr); err != nil {
+		return fmt.Errorf("%s: reparse point: %w", hdr.Name, err)
+	}
+	if _, err := ObjectIDFromTarHeader(hdr); err != nil {
+		return fmt.Errorf("%s: object ID: %w", hdr.Name, err)
+	}
+	return nil
+}
+
+// SddlFromTarHeader returns the security descriptor of the file described by hdr, in
+// SDDL format. It returns an empty string if hdr does not contain a security descriptor.
+func SddlFromTarHeader(hdr *tar.Header) (string, error) {
+	// avoid a round-trip through the binary format for older headers
+	if _, ok := hdr.PAXRecords[hdrRawSecurityDescriptor]; !ok {
+		if sddl, ok := hdr.PAXRecords[hdrSecurityDescriptor]; ok {
+			if _, err := winio.SddlToSecurityDescriptor(sddl); err != nil {
+				return "", err
+			}
+			return sddl, nil
+		}
+	}
+
+	sd, err := SecurityDescriptorFromTarHeader(hdr)
+	if err != nil || len(sd) == 0 {
+		return "", err
+	}
+	return winio.SecurityDescriptorToSddl(sd)
+}
+
+// DecodeExtendedAttributesFromTarHeader returns the EAs of the file described by hdr,
+// sorted by name.
+func DecodeExtendedAttributesFromTarHeader(hdr *tar.Header) ([]winio.ExtendedAttribute, error) {
+	var eas []winio.ExtendedAttribute //nolint:prealloc // len(eas) <= len(hdr.PAXRecords); prealloc is wasteful
+	for k, v := range hdr.PAXRecords {
+		if !strings.HasPrefix(k, hdrEaPrefix) {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return nil, fmt.Errorf("extended attribute %q: %w", k[len(hdrEaPrefix):], err)
+		}
+		eas = append(eas, winio.ExtendedAttribute{
+			Name:  k[len(hdrEaPrefix):],
+			Value: data,
+		})
+	}
+	sort.Slice(eas, func(i, j int) bool { return eas[i].Name < eas[j].Name })
+	return eas, nil
+}
+
+// ReparsePointFromTarHeader returns the reparse point (symlink or mount point) described by hdr,
+// or nil if hdr is not a symlink.
+func ReparsePointFromTarHeader(hdr *tar.Header) (*winio.ReparsePoint, error) {
+	if hdr.Typeflag != tar.TypeSymlink {
+		return nil, nil
+	}
+	if hdr.Linkname == "" {
+		return nil, errors.New("symlink has an empty target")
+	}
+	_, isMountPoint := hdr.PAXRecords[hdrMountPoint]
+	return &winio.ReparsePoint{
+		Target:       filepath.FromSlash(hdr.Linkname),
+		IsMountPoint: isMountPoint,
+	}, nil
+}
+
+// ObjectIDFromTarHeader returns the NTFS object ID of the file described by hdr, or nil if
+// hdr does not contain one.
+func ObjectIDFromTarHeader(hdr *tar.Header) (*winio.FileObjectID, error) {
+	v, ok := hdr.PAXRecords[hdrObjectID]
+	if !ok {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, err
+	}
+	return winio.DecodeFileObjectID(b)
+}
+
+// validateSecurityDescriptor checks that sd is a well-formed, self-relative security descriptor.
+func validateSecurityDescriptor(sd []byte) error {
+	// SECURITY_DESCRIPTOR_RELATIVE: the owner, group, SACL, and DACL are stored as offsets
+	// https://learn.microsoft.com/en-us/windows-hardware/drivers/ddi/ntifs/ns-ntifs-_security_descriptor_relative
+	const relativeSize = 20
+	if len(sd) < relativeSize {
+		return fmt.Errorf("length %d is smaller than minimum (%d): %w", len(sd), relativeSize, ErrInvalidSecurityDescriptor)
+	}
+	control := binary.LittleEndian.Uint16(sd[2:])
+	if control&windows.SE_SELF_RELATIVE == 0 {
+		return fmt.Errorf("not self-relative: %w", ErrInvalidSecurityDescriptor)
+	}
+	for i := 4; i < relativeSize; i += 4 {
+		if off := binary.LittleEndian.Uint32(sd[i:]); off >= uint32(len(sd)) {
+			return fmt.Errorf("offset %d is out of bounds (%d): %w", off, len(sd), ErrInvalidSecurityDescriptor)
+		}
+	}
+	if !(*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&sd[0])).IsValid() {
+		return ErrInvalidSecurityDescriptor
+	}
+	return nil
+}
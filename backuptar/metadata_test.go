@@ -0,0 +1,176 @@
+//go:build windows
+// +build windows
+
+package backuptar
+
+import (
+	"archive/tar"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+const testSddl = "O:BAG:BAD:(A;;GA;;;BA)(A;;GR;;;WD)"
+
+func rawSDRecord(t *testing.T, sddl string) string {
+	t.Helper()
+
+	sd, err := winio.SddlToSecurityDescriptor(sddl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(sd)
+}
+
+func TestSddlFromTarHeader(t *testing.T) {
+	for name, records := range map[string]map[string]string{
+		"raw":  {hdrRawSecurityDescriptor: rawSDRecord(t, testSddl)},
+		"sddl": {hdrSecurityDescriptor: testSddl},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hdr := &tar.Header{Name: "file", Typeflag: tar.TypeReg, PAXRecords: records}
+			sddl, err := SddlFromTarHeader(hdr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sddl != testSddl {
+				t.Fatalf("got %q, want %q", sddl, testSddl)
+			}
+			if err := ValidateTarHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+
+	sddl, err := SddlFromTarHeader(&tar.Header{Name: "file"})
+	if err != nil || sddl != "" {
+		t.Fatalf("expected no security descriptor, got %q, %v", sddl, err)
+	}
+}
+
+func TestDecodeExtendedAttributesFromTarHeader(t *testing.T) {
+	hdr := &tar.Header{
+		Name:     "file",
+		Typeflag: tar.TypeReg,
+		PAXRecords: map[string]string{
+			hdrEaPrefix + "foo": base64.StdEncoding.EncodeToString([]byte("bar")),
+			hdrEaPrefix + "baz": base64.StdEncoding.EncodeToString([]byte("qux")),
+		},
+	}
+	eas, err := DecodeExtendedAttributesFromTarHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(eas) != 2 || eas[0].Name != "baz" || string(eas[0].Value) != "qux" ||
+		eas[1].Name != "foo" || string(eas[1].Value) != "bar" {
+		t.Fatalf("unexpected extended attributes %+v", eas)
+	}
+}
+
+func TestReparsePointFromTarHeader(t *testing.T) {
+	hdr := &tar.Header{
+		Name:       "link",
+		Typeflag:   tar.TypeSymlink,
+		Linkname:   "C:/target",
+		PAXRecords: map[string]string{hdrMountPoint: "1"},
+	}
+	rp, err := ReparsePointFromTarHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rp == nil || rp.Target != `C:\target` || !rp.IsMountPoint {
+		t.Fatalf("unexpected reparse point %+v", rp)
+	}
+
+	rp, err = ReparsePointFromTarHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg})
+	if err != nil || rp != nil {
+		t.Fatalf("expected no reparse point, got %+v, %v", rp, err)
+	}
+}
+
+func TestObjectIDFromTarHeader(t *testing.T) {
+	want := winio.FileObjectID{ObjectID: guid.GUID{Data1: 1}, BirthObjectID: guid.GUID{Data1: 1}}
+	hdr := &tar.Header{
+		Name:       "file",
+		Typeflag:   tar.TypeReg,
+		PAXRecords: map[string]string{hdrObjectID: base64.StdEncoding.EncodeToString(winio.EncodeFileObjectID(&want))},
+	}
+	oid, err := ObjectIDFromTarHeader(hdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oid == nil || *oid != want {
+		t.Fatalf("got object ID %+v, want %+v", oid, want)
+	}
+	if err := ValidateTarHeader(hdr); err != nil {
+		t.Fatal(err)
+	}
+
+	oid, err = ObjectIDFromTarHeader(&tar.Header{Name: "file", Typeflag: tar.TypeReg})
+	if err != nil || oid != nil {
+		t.Fatalf("expected no object ID, got %+v, %v", oid, err)
+	}
+}
+
+func TestValidateTarHeaderInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		hdr     *tar.Header
+		wantErr error
+	}{
+		{
+			name: "unknown record",
+			hdr: &tar.Header{
+				Name:       "file",
+				PAXRecords: map[string]string{"MSWINDOWS.future": "1"},
+			},
+			wantErr: ErrUnsupportedRecord,
+		},
+		{
+			name: "truncated security descriptor",
+			hdr: &tar.Header{
+				Name:       "file",
+				PAXRecords: map[string]string{hdrRawSecurityDescriptor: base64.StdEncoding.EncodeToString([]byte{1, 0, 4, 0x80})},
+			},
+			wantErr: ErrInvalidSecurityDescriptor,
+		},
+		{
+			name: "bad attributes",
+			hdr: &tar.Header{
+				Name:       "file",
+				PAXRecords: map[string]string{hdrFileAttributes: "not a number"},
+			},
+		},
+		{
+			name: "bad extended attribute",
+			hdr: &tar.Header{
+				Name:       "file",
+				PAXRecords: map[string]string{hdrEaPrefix + "foo": "!!!"},
+			},
+		},
+		{
+			name: "truncated object ID",
+			hdr: &tar.Header{
+				Name:       "file",
+				PAXRecords: map[string]string{hdrObjectID: base64.StdEncoding.EncodeToString(make([]byte, 16))},
+			},
+		},
+		{
+			name: "empty symlink",
+			hdr:  &tar.Header{Name: "link", Typeflag: tar.TypeSymlink},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateTarHeader(tc.hdr)
+			if err == nil {
+				t.Fatal("expected validation to fail")
+			}
+			if tc.wantErr != nil && !errors.Is(err, tc.wantErr) {
+				t.Fatalf("expected %v, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
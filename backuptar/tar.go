@@ -5,8 +5,10 @@ package backuptar
 
 import (
 	"archive/tar"
+	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"hash"
 	"io"
 	"path/filepath"
 	"strconv"
@@ -32,10 +34,12 @@ const (
 )
 
 const (
+	hdrPrefix                = "MSWINDOWS." // prefix of all Win32 metadata PAX records
 	hdrFileAttributes        = "MSWINDOWS.fileattr"
 	hdrSecurityDescriptor    = "MSWINDOWS.sd"
 	hdrRawSecurityDescriptor = "MSWINDOWS.rawsd"
 	hdrMountPoint            = "MSWINDOWS.mountpoint"
+	hdrObjectID              = "MSWINDOWS.objectid"
 	hdrEaPrefix              = "MSWINDOWS.xattr."
 
 	hdrCreationTime = "LIBARCHIVE.creationtime"
@@ -51,43 +55,60 @@ func (zeroReader) Read(b []byte) (int, error) {
 	return len(b), nil
 }
 
-func copySparse(t *tar.Writer, br *winio.BackupStreamReader) error {
+// copySparse copies the sparse block streams in br to t, and returns the number of bytes
+// copied from them (excluding the zeros written for unallocated ranges).
+func copySparse(t io.Writer, br *winio.BackupStreamReader) (int64, error) {
 	curOffset := int64(0)
+	total := int64(0)
 	for {
 		bhdr, err := br.Next()
 		if err == io.EOF { //nolint:errorlint
 			err = io.ErrUnexpectedEOF
 		}
 		if err != nil {
-			return err
+			return total, err
 		}
 		if bhdr.Id != winio.BackupSparseBlock {
-			return fmt.Errorf("unexpected stream %d", bhdr.Id)
+			return total, fmt.Errorf("unexpected stream %d", bhdr.Id)
 		}
 
 		// We can't seek backwards, since we have already written that data to the tar.Writer.
 		if bhdr.Offset < curOffset {
-			return fmt.Errorf("cannot seek back from %d to %d", curOffset, bhdr.Offset)
+			return total, fmt.Errorf("cannot seek back from %d to %d", curOffset, bhdr.Offset)
 		}
 		// archive/tar does not support writing sparse files
 		// so just write zeroes to catch up to the current offset.
 		if _, err = io.CopyN(t, zeroReader{}, bhdr.Offset-curOffset); err != nil {
-			return fmt.Errorf("seek to offset %d: %w", bhdr.Offset, err)
+			return total, fmt.Errorf("seek to offset %d: %w", bhdr.Offset, err)
 		}
 		if bhdr.Size == 0 {
 			// A sparse block with size = 0 is used to mark the end of the sparse blocks.
 			break
 		}
 		n, err := io.Copy(t, br)
+		total += n
 		if err != nil {
-			return err
+			return total, err
 		}
 		if n != bhdr.Size {
-			return fmt.Errorf("copied %d bytes instead of %d at offset %d", n, bhdr.Size, bhdr.Offset)
+			return total, fmt.Errorf("copied %d bytes instead of %d at offset %d", n, bhdr.Size, bhdr.Offset)
 		}
 		curOffset = bhdr.Offset + n
 	}
-	return nil
+	return total, nil
+}
+
+// FileReport describes the contents of a file written by [WriteTarFileFromBackupStreamReport].
+type FileReport struct {
+	// DataSHA256 is the SHA256 digest of the file's primary data stream, as written to the
+	// tar (unallocated ranges of sparse files are read as zeros). It is nil unless the digest
+	// was requested.
+	DataSHA256 []byte
+
+	// StreamBytes is the number of bytes stored in the tar from each kind of backup stream,
+	// keyed by stream ID (such as [winio.BackupData] or [winio.BackupAlternateData]).
+	// Streams that are not stored in the tar are not counted.
+	StreamBytes map[uint32]int64
 }
 
 // BasicInfoHeader creates a tar header from basic file information.
@@ -124,6 +145,9 @@ func SecurityDescriptorFromTarHeader(hdr *tar.Header) ([]byte, error) {
 			// of a failure: https://github.com/golang/go/blob/go1.17.7/src/encoding/base64/base64.go#L382-L387
 			return nil, err
 		}
+		if err := validateSecurityDescriptor(sd); err != nil {
+			return nil, err
+		}
 		return sd, nil
 	}
 	// Maintaining old SDDL-based behavior for backward compatibility. All new
@@ -138,22 +162,11 @@ func SecurityDescriptorFromTarHeader(hdr *tar.Header) ([]byte, error) {
 // ExtendedAttributesFromTarHeader reads the EAs associated with the header of the
 // current file from the tar header and returns it as a byte slice.
 func ExtendedAttributesFromTarHeader(hdr *tar.Header) ([]byte, error) {
-	var eas []winio.ExtendedAttribute //nolint:prealloc // len(eas) <= len(hdr.PAXRecords); prealloc is wasteful
-	for k, v := range hdr.PAXRecords {
-		if !strings.HasPrefix(k, hdrEaPrefix) {
-			continue
-		}
-		data, err := base64.StdEncoding.DecodeString(v)
-		if err != nil {
-			return nil, err
-		}
-		eas = append(eas, winio.ExtendedAttribute{
-			Name:  k[len(hdrEaPrefix):],
-			Value: data,
-		})
+	eas, err := DecodeExtendedAttributesFromTarHeader(hdr)
+	if err != nil {
+		return nil, err
 	}
 	var eaData []byte
-	var err error
 	if len(eas) != 0 {
 		eaData, err = winio.EncodeExtendedAttributes(eas)
 		if err != nil {
@@ -184,9 +197,90 @@ func EncodeReparsePointFromTarHeader(hdr *tar.Header) []byte {
 //   - MSWINDOWS.fileattr: The Win32 file attributes, as a decimal value
 //   - MSWINDOWS.rawsd: The Win32 security descriptor, in raw binary format
 //   - MSWINDOWS.mountpoint: If present, this is a mount point and not a symlink, even though the type is '2' (symlink)
+//   - MSWINDOWS.objectid: The NTFS object ID, in raw binary format (FILE_OBJECTID_BUFFER), only
+//     if [WriteOptions.IncludeObjectIDs] is set
 func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size int64, fileInfo *winio.FileBasicInfo) error {
+	_, err := WriteTarFileFromBackupStreamReport(t, r, name, size, fileInfo, false)
+	return err
+}
+
+// WriteTarFileFromBackupStreamReport is like [WriteTarFileFromBackupStream], but also returns a
+// report of the number of bytes stored from each stream. If digest is true, the SHA256 digest
+// of the primary data stream is computed as it is written, so callers that need it do not
+// have to read the file or the tar a second time.
+func WriteTarFileFromBackupStreamReport(t *tar.Writer, r io.Reader, name string, size int64, fileInfo *winio.FileBasicInfo, digest bool) (*FileReport, error) {
+	return WriteTarFileFromBackupStreamWithOptions(t, r, name, size, fileInfo, &WriteOptions{Digest: digest})
+}
+
+// WriteOptions contains options for [WriteTarFileFromBackupStreamWithOptions]. The
+// normalization options allow image builders to produce byte-identical tars when the same
+// files are written again, such as when a layer is rebuilt.
+type WriteOptions struct {
+	// Digest computes the SHA256 digest of the primary data stream (see [FileReport]).
+	Digest bool
+
+	// ZeroTimestamps sets all of the file's timestamps to the Unix epoch.
+	ZeroTimestamps bool
+
+	// ClampTimestamps, if not zero, replaces the file's timestamps that are later than it
+	// with it, as is done with SOURCE_DATE_EPOCH. It is ignored if ZeroTimestamps is set.
+	ClampTimestamps time.Time
+
+	// DropAttributes are the file attributes to clear in the MSWINDOWS.fileattr record, such
+	// as FILE_ATTRIBUTE_ARCHIVE (which is set whenever a file is modified) and
+	// FILE_ATTRIBUTE_HIDDEN. The directory and reparse point attributes are never dropped.
+	DropAttributes uint32
+
+	// OmitNondeterministic omits metadata that differs between otherwise identical files:
+	// the access and change times, and the NTFS object ID even if IncludeObjectIDs is set.
+	OmitNondeterministic bool
+
+	// IncludeObjectIDs stores the files' NTFS object IDs in MSWINDOWS.objectid records. Object
+	// IDs must be unique on a volume, so they are not stored by default.
+	IncludeObjectIDs bool
+
+	// UnknownStream, if not nil, is called for each backup stream with an unknown ID, which
+	// are otherwise an error. The stream is not written to the tar file.
+	UnknownStream winio.UnknownStreamFunc
+}
+
+// WriteTarFileFromBackupStreamWithOptions is like [WriteTarFileFromBackupStreamReport], with
+// options to normalize the file's metadata.
+func WriteTarFileFromBackupStreamWithOptions(t *tar.Writer, r io.Reader, name string, size int64, fileInfo *winio.FileBasicInfo, opts *WriteOptions) (*FileReport, error) {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	report := &FileReport{StreamBytes: make(map[uint32]int64)}
+	if err := writeTarFileFromBackupStream(t, r, name, size, normalizeFileInfo(fileInfo, opts), opts, report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// normalizeFileInfo returns a copy of fileInfo with its timestamps and attributes normalized
+// as specified by opts.
+func normalizeFileInfo(fileInfo *winio.FileBasicInfo, opts *WriteOptions) *winio.FileBasicInfo {
+	fi := *fileInfo
+	fi.FileAttributes &^= opts.DropAttributes &^ (windows.FILE_ATTRIBUTE_DIRECTORY | windows.FILE_ATTRIBUTE_REPARSE_POINT)
+	for _, ft := range []*windows.Filetime{&fi.CreationTime, &fi.LastAccessTime, &fi.LastWriteTime, &fi.ChangeTime} {
+		switch {
+		case opts.ZeroTimestamps:
+			*ft = windows.NsecToFiletime(0)
+		case !opts.ClampTimestamps.IsZero() && ft.Nanoseconds() > opts.ClampTimestamps.UnixNano():
+			*ft = windows.NsecToFiletime(opts.ClampTimestamps.UnixNano())
+		}
+	}
+	return &fi
+}
+
+func writeTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size int64, fileInfo *winio.FileBasicInfo, opts *WriteOptions, report *FileReport) error {
 	name = filepath.ToSlash(name)
 	hdr := BasicInfoHeader(name, size, fileInfo)
+	if opts.OmitNondeterministic {
+		// archive/tar omits the PAX records for zero times
+		hdr.AccessTime = time.Time{}
+		hdr.ChangeTime = time.Time{}
+	}
 
 	// If r can be seeked, then this function is two-pass: pass 1 collects the
 	// tar header data, and pass 2 copies the data stream. If r cannot be
@@ -203,8 +297,14 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 	}
 
 	br := winio.NewBackupStreamReader(r)
-	var dataHdr *winio.BackupHeader
-	for dataHdr == nil {
+	br.SetUnknownStreamFunc(opts.UnknownStream)
+	var (
+		dataHdr *winio.BackupHeader
+		// altHdr is the first alternate data stream, if it was read before the data stream
+		// was found. This happens for files without a data stream, such as directories.
+		altHdr *winio.BackupHeader
+	)
+	for dataHdr == nil && altHdr == nil {
 		bhdr, err := br.Next()
 		if err == io.EOF { //nolint:errorlint
 			break
@@ -223,12 +323,16 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			if err != nil {
 				return err
 			}
+			report.StreamBytes[bhdr.Id] += int64(len(sd))
 			hdr.PAXRecords[hdrRawSecurityDescriptor] = base64.StdEncoding.EncodeToString(sd)
 
 		case winio.BackupReparseData:
-			hdr.Mode |= cISLNK
+			// directories with reparse points (such as junctions) are stored as symlinks,
+			// with the directory attribute in the MSWINDOWS.fileattr record
+			hdr.Mode = hdr.Mode&^cISDIR | cISLNK
 			hdr.Typeflag = tar.TypeSymlink
 			reparseBuffer, _ := io.ReadAll(br)
+			report.StreamBytes[bhdr.Id] += int64(len(reparseBuffer))
 			rp, err := winio.DecodeReparsePoint(reparseBuffer)
 			if err != nil {
 				return err
@@ -243,6 +347,7 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			if err != nil {
 				return err
 			}
+			report.StreamBytes[bhdr.Id] += int64(len(eab))
 			eas, err := winio.DecodeExtendedAttributes(eab)
 			if err != nil {
 				return err
@@ -254,7 +359,27 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 				hdr.PAXRecords[hdrEaPrefix+ea.Name] = base64.StdEncoding.EncodeToString(ea.Value)
 			}
 
-		case winio.BackupAlternateData, winio.BackupLink, winio.BackupPropertyData, winio.BackupObjectId, winio.BackupTxfsData:
+		case winio.BackupObjectId:
+			oid, err := io.ReadAll(br)
+			if err != nil {
+				return err
+			}
+			if _, err := winio.DecodeFileObjectID(oid); err != nil {
+				return fmt.Errorf("%s: object ID: %w", name, err)
+			}
+			if !opts.IncludeObjectIDs || opts.OmitNondeterministic {
+				break
+			}
+			report.StreamBytes[bhdr.Id] += int64(len(oid))
+			hdr.PAXRecords[hdrObjectID] = base64.StdEncoding.EncodeToString(oid)
+
+		case winio.BackupAlternateData:
+			// alternate data streams are copied after the tar header is written; if r
+			// will be read again, they are found in the second pass
+			if !readTwice {
+				altHdr = bhdr
+			}
+		case winio.BackupLink, winio.BackupPropertyData, winio.BackupTxfsData:
 			// ignore these streams
 		default:
 			return fmt.Errorf("%s: unknown stream ID %d", name, bhdr.Id)
@@ -271,7 +396,9 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 		if _, err = sr.Seek(restartPos, io.SeekStart); err != nil {
 			return err
 		}
-		for dataHdr == nil {
+		// unknown streams before the data stream were handled in the first pass
+		br.SetUnknownStreamFunc(nil)
+		for dataHdr == nil && altHdr == nil {
 			bhdr, err := br.Next()
 			if err == io.EOF { //nolint:errorlint
 				break
@@ -279,10 +406,14 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			if err != nil {
 				return err
 			}
-			if bhdr.Id == winio.BackupData {
+			switch bhdr.Id {
+			case winio.BackupData:
 				dataHdr = bhdr
+			case winio.BackupAlternateData:
+				altHdr = bhdr
 			}
 		}
+		br.SetUnknownStreamFunc(opts.UnknownStream)
 	}
 
 	// The logic for copying file contents is fairly complicated due to the need for handling sparse files,
@@ -308,6 +439,12 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 	//     range of the file containing the range contents. Finally there is a sparse block stream with
 	//     size = 0 and offset = <file size>.
 
+	var dataWriter io.Writer = t
+	var dataHash hash.Hash
+	if opts.Digest {
+		dataHash = sha256.New()
+		dataWriter = io.MultiWriter(t, dataHash)
+	}
 	if dataHdr != nil { //nolint:nestif // todo: reduce nesting complexity
 		// A data stream was found. Copy the data.
 		// We assume that we will either have a data stream size > 0 XOR have sparse block streams.
@@ -315,27 +452,35 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			if size != dataHdr.Size {
 				return fmt.Errorf("%s: mismatch between file size %d and header size %d", name, size, dataHdr.Size)
 			}
-			if _, err = io.Copy(t, br); err != nil {
+			n, err := io.Copy(dataWriter, br)
+			report.StreamBytes[winio.BackupData] += n
+			if err != nil {
 				return fmt.Errorf("%s: copying contents from data stream: %w", name, err)
 			}
 		} else if size > 0 {
 			// As of a recent OS change, BackupRead now returns a data stream for empty sparse files.
 			// These files have no sparse block streams, so skip the copySparse call if file size = 0.
-			if err = copySparse(t, br); err != nil {
+			n, err := copySparse(dataWriter, br)
+			report.StreamBytes[winio.BackupSparseBlock] += n
+			if err != nil {
 				return fmt.Errorf("%s: copying contents from sparse block stream: %w", name, err)
 			}
 		}
 	}
+	if dataHash != nil {
+		report.DataSHA256 = dataHash.Sum(nil)
+	}
 
 	// Look for streams after the data stream. The only ones we handle are alternate data streams.
 	// Other streams may have metadata that could be serialized, but the tar header has already
 	// been written. In practice, this means that we don't get EA or TXF metadata.
 	for {
-		bhdr, err := br.Next()
-		if err == io.EOF { //nolint:errorlint
+		bhdr := altHdr
+		if bhdr != nil {
+			altHdr = nil
+		} else if bhdr, err = br.Next(); err == io.EOF { //nolint:errorlint
 			break
-		}
-		if err != nil {
+		} else if err != nil {
 			return err
 		}
 		switch bhdr.Id {
@@ -349,7 +494,7 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			hdr = &tar.Header{
 				Format:     hdr.Format,
 				Name:       name + altName,
-				Mode:       hdr.Mode,
+				Mode:       hdr.Mode&^(cISDIR|cISLNK) | cISREG,
 				Typeflag:   tar.TypeReg,
 				Size:       bhdr.Size,
 				ModTime:    hdr.ModTime,
@@ -360,11 +505,13 @@ func WriteTarFileFromBackupStream(t *tar.Writer, r io.Reader, name string, size
 			if err != nil {
 				return err
 			}
-			_, err = io.Copy(t, br)
+			n, err := io.Copy(t, br)
+			report.StreamBytes[bhdr.Id] += n
 			if err != nil {
 				return err
 			}
-		case winio.BackupEaData, winio.BackupLink, winio.BackupPropertyData, winio.BackupObjectId, winio.BackupTxfsData:
+		case winio.BackupEaData, winio.BackupSecurity, winio.BackupReparseData,
+			winio.BackupLink, winio.BackupPropertyData, winio.BackupObjectId, winio.BackupTxfsData:
 			// ignore these streams
 		default:
 			return fmt.Errorf("%s: unknown stream ID %d after data", name, bhdr.Id)
@@ -411,7 +558,26 @@ func FileInfoFromHeader(hdr *tar.Header) (name string, size int64, fileInfo *win
 // WriteBackupStreamFromTarFile writes a Win32 backup stream from the current tar file. Since this function may process multiple
 // tar file entries in order to collect all the alternate data streams for the file, it returns the next
 // tar file that was not processed, or io.EOF is there are no more.
+//
+// The file's object ID is not restored; see [WriteBackupStreamFromTarFileWithOptions].
 func WriteBackupStreamFromTarFile(w io.Writer, t *tar.Reader, hdr *tar.Header) (*tar.Header, error) {
+	return WriteBackupStreamFromTarFileWithOptions(w, t, hdr, nil)
+}
+
+// RestoreOptions contains options for [WriteBackupStreamFromTarFileWithOptions].
+type RestoreOptions struct {
+	// RestoreObjectIDs writes the file's MSWINDOWS.objectid record, if any, as a
+	// BackupObjectId stream. Object IDs must be unique on a volume, so restoring them fails
+	// if the original file is still present on the destination volume.
+	RestoreObjectIDs bool
+}
+
+// WriteBackupStreamFromTarFileWithOptions is like [WriteBackupStreamFromTarFile], with options
+// for the metadata that is restored.
+func WriteBackupStreamFromTarFileWithOptions(w io.Writer, t *tar.Reader, hdr *tar.Header, opts *RestoreOptions) (*tar.Header, error) {
+	if opts == nil {
+		opts = &RestoreOptions{}
+	}
 	bw := winio.NewBackupStreamWriter(w)
 
 	sd, err := SecurityDescriptorFromTarHeader(hdr)
@@ -452,6 +618,26 @@ func WriteBackupStreamFromTarFile(w io.Writer, t *tar.Reader, hdr *tar.Header) (
 		}
 	}
 
+	oid, err := ObjectIDFromTarHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if oid != nil && opts.RestoreObjectIDs {
+		oidData := winio.EncodeFileObjectID(oid)
+		bhdr := winio.BackupHeader{
+			Id:   winio.BackupObjectId,
+			Size: int64(len(oidData)),
+		}
+		err = bw.WriteHeader(&bhdr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = bw.Write(oidData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if hdr.Typeflag == tar.TypeSymlink {
 		reparse := EncodeReparsePointFromTarHeader(hdr)
 		bhdr := winio.BackupHeader{
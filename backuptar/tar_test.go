@@ -6,13 +6,16 @@ package backuptar
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/sha256"
 	"io"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
 	"golang.org/x/sys/windows"
 )
 
@@ -29,7 +32,7 @@ func ensurePresent(t *testing.T, m map[string]string, keys ...string) {
 func setSparse(t *testing.T, f *os.File) {
 	t.Helper()
 
-	if err := windows.DeviceIoControl(windows.Handle(f.Fd()), windows.FSCTL_SET_SPARSE, nil, 0, nil, 0, nil, nil); err != nil {
+	if err := winio.SetSparse(f); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -219,6 +222,209 @@ func TestRoundTrip(t *testing.T) {
 	}
 }
 
+func TestWriteTarFileReport(t *testing.T) {
+	data := []byte("testing 1 2 3\n")
+	ads := []byte("alternate data\n")
+	path := filepath.Join(t.TempDir(), "foo.txt")
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(path+":ads", ads, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	bi, err := winio.GetFileBasicInfo(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	br := winio.NewBackupFileReader(f, true)
+	defer br.Close()
+	tw := tar.NewWriter(io.Discard)
+	report, err := WriteTarFileFromBackupStreamReport(tw, br, f.Name(), int64(len(data)), bi, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(report.DataSHA256, sum[:]) {
+		t.Errorf("got digest %x, expected %x", report.DataSHA256, sum)
+	}
+	if n := report.StreamBytes[winio.BackupData]; n != int64(len(data)) {
+		t.Errorf("got %d data bytes, expected %d", n, len(data))
+	}
+	if n := report.StreamBytes[winio.BackupAlternateData]; n != int64(len(ads)) {
+		t.Errorf("got %d alternate data bytes, expected %d", n, len(ads))
+	}
+	if report.StreamBytes[winio.BackupSecurity] == 0 {
+		t.Error("security descriptor bytes were not counted")
+	}
+}
+
+func TestWriteTarFileNormalized(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "foo.txt")
+	//nolint:gosec // G306: Expect WriteFile permissions to be 0600 or less
+	if err := os.WriteFile(path, []byte("testing 1 2 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &WriteOptions{
+		ZeroTimestamps:       true,
+		DropAttributes:       windows.FILE_ATTRIBUTE_ARCHIVE | windows.FILE_ATTRIBUTE_HIDDEN,
+		OmitNondeterministic: true,
+	}
+	writeTar := func(attrs uint32, mtime time.Time) []byte {
+		t.Helper()
+
+		f, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		bi, err := winio.GetFileBasicInfo(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bi.FileAttributes = attrs
+		bi.LastWriteTime = windows.NsecToFiletime(mtime.UnixNano())
+		if err := winio.SetFileBasicInfo(f, bi); err != nil {
+			t.Fatal(err)
+		}
+		if bi, err = winio.GetFileBasicInfo(f); err != nil {
+			t.Fatal(err)
+		}
+
+		var b bytes.Buffer
+		tw := tar.NewWriter(&b)
+		br := winio.NewBackupFileReader(f, true)
+		defer br.Close()
+		if _, err := WriteTarFileFromBackupStreamWithOptions(tw, br, "foo.txt", 14, bi, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return b.Bytes()
+	}
+
+	tar1 := writeTar(windows.FILE_ATTRIBUTE_ARCHIVE, time.Now().Add(-time.Hour))
+	tar2 := writeTar(windows.FILE_ATTRIBUTE_HIDDEN, time.Now())
+	if !bytes.Equal(tar1, tar2) {
+		t.Fatal("normalized tars differ")
+	}
+
+	hdr, err := tar.NewReader(bytes.NewReader(tar1)).Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hdr.ModTime.Equal(time.Unix(0, 0)) {
+		t.Errorf("got modification time %v, expected the Unix epoch", hdr.ModTime)
+	}
+	if !hdr.AccessTime.IsZero() || !hdr.ChangeTime.IsZero() {
+		t.Errorf("access or change time was not omitted")
+	}
+}
+
+func TestObjectIDOptIn(t *testing.T) {
+	oid := winio.EncodeFileObjectID(&winio.FileObjectID{ObjectID: guid.GUID{Data1: 1}, BirthObjectID: guid.GUID{Data1: 2}})
+	data := []byte("testing 1 2 3\n")
+	var stream bytes.Buffer
+	bw := winio.NewBackupStreamWriter(&stream)
+	for _, s := range []struct {
+		id uint32
+		b  []byte
+	}{
+		{winio.BackupObjectId, oid},
+		{winio.BackupData, data},
+	} {
+		if err := bw.WriteHeader(&winio.BackupHeader{Id: s.id, Size: int64(len(s.b))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bw.Write(s.b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeTar := func(opts *WriteOptions) *tar.Header {
+		t.Helper()
+
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		fi := &winio.FileBasicInfo{FileAttributes: windows.FILE_ATTRIBUTE_NORMAL}
+		if _, err := WriteTarFileFromBackupStreamWithOptions(tw, bytes.NewReader(stream.Bytes()), "foo.txt", int64(len(data)), fi, opts); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		hdr, err := tar.NewReader(&buf).Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hdr
+	}
+	if _, ok := writeTar(nil).PAXRecords[hdrObjectID]; ok {
+		t.Error("object ID was stored by default")
+	}
+	if _, ok := writeTar(&WriteOptions{IncludeObjectIDs: true, OmitNondeterministic: true}).PAXRecords[hdrObjectID]; ok {
+		t.Error("object ID was stored with OmitNondeterministic")
+	}
+	hdr := writeTar(&WriteOptions{IncludeObjectIDs: true})
+	if _, ok := hdr.PAXRecords[hdrObjectID]; !ok {
+		t.Fatal("object ID was not stored with IncludeObjectIDs")
+	}
+
+	restore := func(opts *RestoreOptions) bool {
+		t.Helper()
+
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := tw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(&buf)
+		h, err := tr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out bytes.Buffer
+		if _, err := WriteBackupStreamFromTarFileWithOptions(&out, tr, h, opts); err != io.EOF { //nolint:errorlint
+			t.Fatalf("expected EOF, got %v", err)
+		}
+		br := winio.NewBackupStreamReader(&out)
+		for {
+			bhdr, err := br.Next()
+			if err == io.EOF { //nolint:errorlint
+				return false
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if bhdr.Id == winio.BackupObjectId {
+				return true
+			}
+		}
+	}
+	if restore(nil) {
+		t.Error("object ID was restored by default")
+	}
+	if !restore(&RestoreOptions{RestoreObjectIDs: true}) {
+		t.Error("object ID was not restored with RestoreObjectIDs")
+	}
+}
+
 func TestZeroReader(t *testing.T) {
 	const size = 512
 	var b [size]byte
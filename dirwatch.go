@@ -0,0 +1,179 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/internal/fs"
+)
+
+// dirWatchBufferSize is the size of the buffer used to receive change notifications.
+// ReadDirectoryChangesW fails for buffers larger than 64KiB when watching a network share.
+const dirWatchBufferSize = 64 * 1024
+
+// ErrDirectoryChangesOverflow is returned by [DirectoryWatcher.Read] when too many changes
+// occurred between calls to Read, and some were lost. The caller should rescan the directory.
+var ErrDirectoryChangesOverflow = errors.New("directory change notifications overflowed")
+
+// ChangeFilter specifies the kinds of changes a [DirectoryWatcher] is notified of.
+type ChangeFilter uint32
+
+const (
+	ChangeFileName   ChangeFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME
+	ChangeDirName    ChangeFilter = windows.FILE_NOTIFY_CHANGE_DIR_NAME
+	ChangeAttributes ChangeFilter = windows.FILE_NOTIFY_CHANGE_ATTRIBUTES
+	ChangeSize       ChangeFilter = windows.FILE_NOTIFY_CHANGE_SIZE
+	ChangeLastWrite  ChangeFilter = windows.FILE_NOTIFY_CHANGE_LAST_WRITE
+	ChangeLastAccess ChangeFilter = windows.FILE_NOTIFY_CHANGE_LAST_ACCESS
+	ChangeCreation   ChangeFilter = windows.FILE_NOTIFY_CHANGE_CREATION
+	ChangeSecurity   ChangeFilter = windows.FILE_NOTIFY_CHANGE_SECURITY
+
+	// ChangeDefault reports files and directories being added, removed, renamed, or written to.
+	ChangeDefault = ChangeFileName | ChangeDirName | ChangeLastWrite
+)
+
+// ChangeAction is the kind of change reported in a [DirectoryChange].
+type ChangeAction uint32
+
+const (
+	ChangeActionAdded          ChangeAction = windows.FILE_ACTION_ADDED
+	ChangeActionRemoved        ChangeAction = windows.FILE_ACTION_REMOVED
+	ChangeActionModified       ChangeAction = windows.FILE_ACTION_MODIFIED
+	ChangeActionRenamedOldName ChangeAction = windows.FILE_ACTION_RENAMED_OLD_NAME
+	ChangeActionRenamedNewName ChangeAction = windows.FILE_ACTION_RENAMED_NEW_NAME
+)
+
+func (a ChangeAction) String() string {
+	switch a {
+	case ChangeActionAdded:
+		return "added"
+	case ChangeActionRemoved:
+		return "removed"
+	case ChangeActionModified:
+		return "modified"
+	case ChangeActionRenamedOldName:
+		return "renamed from"
+	case ChangeActionRenamedNewName:
+		return "renamed to"
+	}
+	return fmt.Sprintf("ChangeAction(%d)", uint32(a))
+}
+
+// DirectoryChange is a single change to a file or directory within the watched directory.
+type DirectoryChange struct {
+	Action ChangeAction
+	// Name is the path of the changed file, relative to the watched directory.
+	Name string
+}
+
+// DirectoryWatcher reports changes to the files in a directory, using ReadDirectoryChangesW.
+//
+// The system starts recording changes on the first call to Read, and buffers changes that
+// occur between subsequent calls.
+type DirectoryWatcher struct {
+	f         *win32File
+	path      string
+	recursive bool
+	filter    ChangeFilter
+	buf       []byte
+}
+
+// WatchDirectory starts watching the directory at path for the changes specified by filter.
+// If recursive is true, changes in all subdirectories are reported as well.
+func WatchDirectory(path string, recursive bool, filter ChangeFilter) (*DirectoryWatcher, error) {
+	h, err := fs.CreateFile(path,
+		fs.FILE_LIST_DIRECTORY,
+		fs.FILE_SHARE_READ|fs.FILE_SHARE_WRITE|fs.FILE_SHARE_DELETE,
+		nil, // security attributes
+		fs.OPEN_EXISTING,
+		fs.FILE_FLAG_BACKUP_SEMANTICS|fs.FILE_FLAG_OVERLAPPED,
+		fs.NullHandle,
+	)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	f, err := makeWin32File(h)
+	if err != nil {
+		windows.Close(h)
+		return nil, err
+	}
+	return &DirectoryWatcher{
+		f:         f,
+		path:      path,
+		recursive: recursive,
+		filter:    filter,
+		buf:       make([]byte, dirWatchBufferSize),
+	}, nil
+}
+
+// Read blocks until changes occur in the watched directory, and returns them.
+//
+// It returns [ErrDirectoryChangesOverflow] if changes were lost, and [ErrTimeout] if the
+// read deadline passes. Read must not be called concurrently.
+func (w *DirectoryWatcher) Read() ([]DirectoryChange, error) {
+	c, err := w.f.prepareIO()
+	if err != nil {
+		return nil, err
+	}
+	defer w.f.wg.Done()
+
+	var bytes uint32
+	err = windows.ReadDirectoryChanges(w.f.handle, &w.buf[0], uint32(len(w.buf)), w.recursive, uint32(w.filter), &bytes, &c.o, 0)
+	n, err := w.f.asyncIO(c, &w.f.readDeadline, bytes, err)
+	if err != nil {
+		if errors.Is(err, windows.ERROR_NOTIFY_ENUM_DIR) {
+			return nil, ErrDirectoryChangesOverflow
+		}
+		var eno windows.Errno
+		if errors.As(err, &eno) {
+			err = &os.PathError{Op: "ReadDirectoryChangesW", Path: w.path, Err: eno}
+		}
+		return nil, err
+	}
+	if n == 0 {
+		// the changes did not fit in the buffer
+		return nil, ErrDirectoryChangesOverflow
+	}
+	return parseDirectoryChanges(w.buf[:n]), nil
+}
+
+// parseDirectoryChanges parses a buffer of FILE_NOTIFY_INFORMATION structures.
+func parseDirectoryChanges(b []byte) []DirectoryChange {
+	var changes []DirectoryChange
+	const nameOffset = unsafe.Offsetof(windows.FileNotifyInformation{}.FileName)
+	for off := uint32(0); int(off)+int(nameOffset) <= len(b); {
+		info := (*windows.FileNotifyInformation)(unsafe.Pointer(&b[off]))
+		nameLen := info.FileNameLength / 2
+		if int(off)+int(nameOffset)+int(info.FileNameLength) > len(b) {
+			break
+		}
+		name := unsafe.Slice(&info.FileName, nameLen)
+		changes = append(changes, DirectoryChange{
+			Action: ChangeAction(info.Action),
+			Name:   windows.UTF16ToString(name),
+		})
+		if info.NextEntryOffset == 0 {
+			break
+		}
+		off += info.NextEntryOffset
+	}
+	return changes
+}
+
+// SetReadDeadline sets the deadline for pending and future calls to Read.
+func (w *DirectoryWatcher) SetReadDeadline(t time.Time) error {
+	return w.f.SetReadDeadline(t)
+}
+
+// Close stops watching the directory, failing any pending call to Read.
+func (w *DirectoryWatcher) Close() error {
+	return w.f.Close()
+}
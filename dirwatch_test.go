@@ -0,0 +1,93 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestParseDirectoryChanges(t *testing.T) {
+	// encode FILE_NOTIFY_INFORMATION entries for "a" (added) and "dir\bc" (removed)
+	var b []byte
+	for i, c := range []DirectoryChange{{ChangeActionAdded, "a"}, {ChangeActionRemoved, `dir\bc`}} {
+		name := utf16.Encode([]rune(c.Name))
+		entry := make([]byte, 12+2*len(name))
+		entry = append(entry, make([]byte, (4-len(entry)%4)%4)...) // entries are DWORD aligned
+		if i == 0 {
+			binary.LittleEndian.PutUint32(entry[0:], uint32(len(entry)))
+		}
+		binary.LittleEndian.PutUint32(entry[4:], uint32(c.Action))
+		binary.LittleEndian.PutUint32(entry[8:], uint32(2*len(name)))
+		for j, r := range name {
+			binary.LittleEndian.PutUint16(entry[12+2*j:], r)
+		}
+		b = append(b, entry...)
+	}
+
+	changes := parseDirectoryChanges(b)
+	if len(changes) != 2 ||
+		changes[0] != (DirectoryChange{ChangeActionAdded, "a"}) ||
+		changes[1] != (DirectoryChange{ChangeActionRemoved, `dir\bc`}) {
+		t.Fatalf("unexpected changes %+v", changes)
+	}
+}
+
+func TestWatchDirectory(t *testing.T) {
+	d := t.TempDir()
+	if err := os.Mkdir(filepath.Join(d, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	w, err := WatchDirectory(d, true, ChangeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if err := w.SetReadDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		// wait for Read to start monitoring the directory
+		time.Sleep(100 * time.Millisecond)
+		_ = os.WriteFile(filepath.Join(d, "sub", "file.txt"), []byte("data"), 0644)
+	}()
+
+	want := DirectoryChange{ChangeActionAdded, filepath.Join("sub", "file.txt")}
+	for found := false; !found; {
+		changes, err := w.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range changes {
+			t.Logf("%s %s", c.Action, c.Name)
+			found = found || c == want
+		}
+	}
+}
+
+func TestWatchDirectoryClose(t *testing.T) {
+	w, err := WatchDirectory(t.TempDir(), false, ChangeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Read(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected %v, got %v", ErrTimeout, err)
+	}
+
+	w.Close()
+	if _, err := w.Read(); !errors.Is(err, ErrFileClosed) {
+		t.Fatalf("expected %v, got %v", ErrFileClosed, err)
+	}
+}
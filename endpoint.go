@@ -0,0 +1,83 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+// Schemes understood by [ListenPipeOrUnix] and [DialPipeOrUnix].
+const (
+	NamedPipeScheme  = "npipe"
+	UnixSocketScheme = "unix"
+)
+
+// ErrUnsupportedScheme is returned when an endpoint address does not have a supported scheme.
+var ErrUnsupportedScheme = errors.New("unsupported endpoint scheme")
+
+// ListenPipeOrUnix creates a listener on the endpoint addr, which must be of the form
+// "npipe://<path>" or "unix://<path>".
+//
+// Named pipe paths may use forward slashes in place of back slashes, so that
+// "npipe:////./pipe/mypipe" listens on the pipe `\\.\pipe\mypipe`. The pipe is created
+// using c (which may be nil) as with [ListenPipe]; c is ignored for Unix domain sockets.
+//
+// Unix domain socket paths are passed to [net.Listen] using the AF_UNIX support
+// available since Windows 10 1803.
+func ListenPipeOrUnix(addr string, c *PipeConfig) (net.Listener, error) {
+	scheme, path, err := parseEndpoint(addr)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == NamedPipeScheme {
+		return ListenPipe(path, c)
+	}
+	return net.Listen("unix", path)
+}
+
+// DialPipeOrUnix connects to the endpoint addr until ctx cancellation or timeout.
+//
+// See [ListenPipeOrUnix] for the supported address formats.
+func DialPipeOrUnix(ctx context.Context, addr string) (net.Conn, error) {
+	scheme, path, err := parseEndpoint(addr)
+	if err != nil {
+		return nil, err
+	}
+	if scheme == NamedPipeScheme {
+		return DialPipeContext(ctx, path)
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", path)
+}
+
+// parseEndpoint splits addr into its scheme and the (OS-specific) path for that scheme.
+func parseEndpoint(addr string) (scheme, path string, err error) {
+	i := strings.Index(addr, "://")
+	if i < 0 {
+		return "", "", fmt.Errorf("endpoint %q: %w", addr, ErrUnsupportedScheme)
+	}
+	scheme, path = strings.ToLower(addr[:i]), addr[i+len("://"):]
+	if path == "" {
+		return "", "", fmt.Errorf("endpoint %q: empty path", addr)
+	}
+
+	switch scheme {
+	case NamedPipeScheme:
+		path = strings.ReplaceAll(path, "/", `\`)
+	case UnixSocketScheme:
+		// Allow "unix:///C:/path/to/sock", in addition to "unix://C:/path/to/sock".
+		if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+			path = path[1:]
+		}
+		path = filepath.FromSlash(path)
+	default:
+		return "", "", fmt.Errorf("endpoint %q: %w", addr, ErrUnsupportedScheme)
+	}
+	return scheme, path, nil
+}
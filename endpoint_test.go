@@ -0,0 +1,125 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseEndpoint(t *testing.T) {
+	for _, tc := range []struct {
+		addr, scheme, path string
+	}{
+		{`npipe:////./pipe/docker_engine`, NamedPipeScheme, `\\.\pipe\docker_engine`},
+		{`npipe://\\.\pipe\docker_engine`, NamedPipeScheme, `\\.\pipe\docker_engine`},
+		{`NPIPE:////./pipe/docker_engine`, NamedPipeScheme, `\\.\pipe\docker_engine`},
+		{`unix://C:/temp/sock`, UnixSocketScheme, `C:\temp\sock`},
+		{`unix:///C:/temp/sock`, UnixSocketScheme, `C:\temp\sock`},
+		{`unix://relative/sock`, UnixSocketScheme, `relative\sock`},
+	} {
+		t.Run(tc.addr, func(t *testing.T) {
+			scheme, path, err := parseEndpoint(tc.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if scheme != tc.scheme || path != tc.path {
+				t.Fatalf("got (%q, %q), want (%q, %q)", scheme, path, tc.scheme, tc.path)
+			}
+		})
+	}
+
+	for _, addr := range []string{
+		`\\.\pipe\docker_engine`,
+		`tcp://localhost:2375`,
+		`npipe://`,
+	} {
+		t.Run(addr, func(t *testing.T) {
+			if _, _, err := parseEndpoint(addr); err == nil {
+				t.Fatalf("expected parsing %q to fail", addr)
+			}
+		})
+	}
+}
+
+func TestPipeOrUnixUnsupportedScheme(t *testing.T) {
+	if _, err := ListenPipeOrUnix("tcp://localhost:0", nil); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedScheme, err)
+	}
+	if _, err := DialPipeOrUnix(context.Background(), "tcp://localhost:0"); !errors.Is(err, ErrUnsupportedScheme) {
+		t.Fatalf("expected %v, got %v", ErrUnsupportedScheme, err)
+	}
+}
+
+func testPipeOrUnixRoundTrip(t *testing.T, addr string) {
+	t.Helper()
+
+	l, err := ListenPipeOrUnix(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer c.Close()
+		_, err = io.Copy(c, c)
+		ch <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialPipeOrUnix(ctx, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	msg := []byte("hello world")
+	if _, err := c.Write(msg); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, len(msg))
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(msg) {
+		t.Fatalf("got %q, want %q", b, msg)
+	}
+	if cw, ok := c.(interface{ CloseWrite() error }); ok {
+		if err := cw.CloseWrite(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-ch; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPipeOrUnixNamedPipe(t *testing.T) {
+	testPipeOrUnixRoundTrip(t, "npipe:////./pipe/winiotestendpoint")
+}
+
+func TestPipeOrUnixUnixSocket(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "winio.sock")
+	l, err := net.Listen("unix", p)
+	if err != nil {
+		t.Skipf("AF_UNIX is not supported: %v", err)
+	}
+	l.Close()
+	os.Remove(p)
+
+	testPipeOrUnixRoundTrip(t, "unix://"+filepath.ToSlash(p))
+}
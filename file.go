@@ -6,6 +6,7 @@ package winio
 import (
 	"errors"
 	"io"
+	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -20,6 +21,7 @@ import (
 //sys getQueuedCompletionStatus(port windows.Handle, bytes *uint32, key *uintptr, o **ioOperation, timeout uint32) (err error) = GetQueuedCompletionStatus
 //sys setFileCompletionNotificationModes(h windows.Handle, flags uint8) (err error) = SetFileCompletionNotificationModes
 //sys wsaGetOverlappedResult(h windows.Handle, o *windows.Overlapped, bytes *uint32, wait bool, flags *uint32) (err error) = ws2_32.WSAGetOverlappedResult
+//sys getHandleInformation(h windows.Handle, flags *uint32) (err error) = GetHandleInformation
 
 //todo (go1.19): switch to [atomic.Bool]
 
@@ -43,17 +45,36 @@ var (
 	ErrTimeout    = &timeoutError{}
 )
 
+var errNegativeOffset = errors.New("negative offset")
+
+// timeoutError is the type of ErrTimeout. It also matches os.ErrDeadlineExceeded, the error
+// returned by the os and net packages when a deadline passes, so that code handling both can
+// check for either.
 type timeoutError struct{}
 
 func (*timeoutError) Error() string   { return "i/o timeout" }
 func (*timeoutError) Timeout() bool   { return true }
 func (*timeoutError) Temporary() bool { return true }
 
+func (*timeoutError) Is(target error) bool {
+	return target == os.ErrDeadlineExceeded //nolint:errorlint // comparing sentinel values
+}
+
 type timeoutChan chan struct{}
 
 var ioInitOnce sync.Once
 var ioCompletionPort windows.Handle
 
+// maxWriteChunk is the largest write issued with a single WriteFile call, unless the handle is
+// a pipe whose buffer size is known. Larger writes are split, so that a write that times out
+// reports the data written by the completed chunks, and the write deadline is checked between
+// chunks.
+const maxWriteChunk = 1 << 20
+
+// skipCompletionPortOnSuccess controls whether new files set FILE_SKIP_COMPLETION_PORT_ON_SUCCESS.
+// It is only disabled by benchmarks, to measure the difference.
+var skipCompletionPortOnSuccess = true
+
 // ioResult contains the result of an asynchronous IO operation.
 type ioResult struct {
 	bytes uint32
@@ -64,6 +85,8 @@ type ioResult struct {
 type ioOperation struct {
 	o  windows.Overlapped
 	ch chan ioResult
+	// complete, if set, is called with the result instead of sending it on ch
+	complete func(ioResult)
 }
 
 func initIO() {
@@ -83,8 +106,12 @@ type win32File struct {
 	wgLock        sync.RWMutex
 	closing       atomicBool
 	socket        bool
+	skipSyncIOCP  bool // synchronous completions are not queued to the completion port
+	messageWrites bool // each Write is a message, and must not be split
+	writeChunk    int  // the largest write issued with a single WriteFile call, if not maxWriteChunk
 	readDeadline  deadlineHandler
 	writeDeadline deadlineHandler
+	stats         *ioStats // nil unless IO statistics are enabled
 }
 
 type deadlineHandler struct {
@@ -98,14 +125,20 @@ type deadlineHandler struct {
 // makeWin32File makes a new win32File from an existing file handle.
 func makeWin32File(h windows.Handle) (*win32File, error) {
 	f := &win32File{handle: h}
+	if ioStatsEnabled.isSet() {
+		f.stats = &ioStats{}
+	}
 	ioInitOnce.Do(initIO)
 	_, err := createIoCompletionPort(h, ioCompletionPort, 0, 0xffffffff)
 	if err != nil {
 		return nil, err
 	}
-	err = setFileCompletionNotificationModes(h, windows.FILE_SKIP_COMPLETION_PORT_ON_SUCCESS|windows.FILE_SKIP_SET_EVENT_ON_HANDLE)
-	if err != nil {
-		return nil, err
+	// Skipping the completion port for IO that completes synchronously lets asyncIO return
+	// immediately, without a round trip through ioCompletionProcessor. This is an optimization,
+	// so carry on without it if the handle does not support it.
+	if skipCompletionPortOnSuccess {
+		err = setFileCompletionNotificationModes(h, windows.FILE_SKIP_COMPLETION_PORT_ON_SUCCESS|windows.FILE_SKIP_SET_EVENT_ON_HANDLE)
+		f.skipSyncIOCP = err == nil
 	}
 	f.readDeadline.channel = make(timeoutChan)
 	f.writeDeadline.channel = make(timeoutChan)
@@ -117,6 +150,9 @@ func MakeOpenFile(h syscall.Handle) (io.ReadWriteCloser, error) {
 	return NewOpenFile(windows.Handle(h))
 }
 
+// NewOpenFile returns a reader and writer for the handle h, which must have been opened with
+// FILE_FLAG_OVERLAPPED, and takes ownership of it. The returned value also implements
+// [io.ReaderAt] and [io.WriterAt], for random access to files without using the file pointer.
 func NewOpenFile(h windows.Handle) (io.ReadWriteCloser, error) {
 	// If we return the result of makeWin32File directly, it can result in an
 	// interface-wrapped nil, rather than a nil interface value.
@@ -124,7 +160,67 @@ func NewOpenFile(h windows.Handle) (io.ReadWriteCloser, error) {
 	if err != nil {
 		return nil, err
 	}
-	return f, nil
+	return &win32OpenFile{f}, nil
+}
+
+// win32OpenFile is a win32File returned by NewOpenFile, which also supports random access.
+// Pipes and sockets embed win32File directly, so they do not expose ReadAt and WriteAt.
+type win32OpenFile struct {
+	*win32File
+}
+
+var (
+	_ io.ReaderAt = (*win32OpenFile)(nil)
+	_ io.WriterAt = (*win32OpenFile)(nil)
+)
+
+// DuplicateHandleToProcess duplicates h into the process with ID pid, and returns the handle's
+// value in that process, where it can be used once the value is sent to it (over a pipe, for
+// instance). The caller needs PROCESS_DUP_HANDLE access to the process.
+//
+// The duplicated handle has the specified access, or the same access as h if access is zero.
+// If inherit is true, the handle is inherited by processes created by the target process.
+// h can be the handle of a file, pipe, or socket opened by this package, as returned by the
+// Fd method of its connections.
+func DuplicateHandleToProcess(h windows.Handle, pid uint32, access uint32, inherit bool) (windows.Handle, error) {
+	process, err := windows.OpenProcess(windows.PROCESS_DUP_HANDLE, false, pid)
+	if err != nil {
+		return 0, os.NewSyscallError("OpenProcess", err)
+	}
+	defer windows.CloseHandle(process) //nolint:errcheck
+
+	var options uint32
+	if access == 0 {
+		options = windows.DUPLICATE_SAME_ACCESS
+	}
+	var dup windows.Handle
+	if err := windows.DuplicateHandle(windows.CurrentProcess(), h, process, &dup, access, inherit, options); err != nil {
+		return 0, os.NewSyscallError("DuplicateHandle", err)
+	}
+	return dup, nil
+}
+
+// SetHandleInheritable sets whether h is inherited by child processes that are created with
+// handle inheritance enabled, such as those started by [os/exec] with handles listed in
+// SysProcAttr.AdditionalInheritedHandles.
+func SetHandleInheritable(h windows.Handle, inherit bool) error {
+	var flags uint32
+	if inherit {
+		flags = windows.HANDLE_FLAG_INHERIT
+	}
+	if err := windows.SetHandleInformation(h, windows.HANDLE_FLAG_INHERIT, flags); err != nil {
+		return os.NewSyscallError("SetHandleInformation", err)
+	}
+	return nil
+}
+
+// IsHandleInheritable reports whether h is inherited by child processes.
+func IsHandleInheritable(h windows.Handle) (bool, error) {
+	var flags uint32
+	if err := getHandleInformation(h, &flags); err != nil {
+		return false, os.NewSyscallError("GetHandleInformation", err)
+	}
+	return flags&windows.HANDLE_FLAG_INHERIT != 0, nil
 }
 
 // closeHandle closes the resources associated with a Win32 handle.
@@ -180,6 +276,10 @@ func ioCompletionProcessor(h windows.Handle) {
 		if op == nil {
 			panic(err)
 		}
+		if op.complete != nil {
+			op.complete(ioResult{bytes, err})
+			continue
+		}
 		op.ch <- ioResult{bytes, err}
 	}
 }
@@ -190,7 +290,14 @@ func ioCompletionProcessor(h windows.Handle) {
 // the operation has actually completed.
 func (f *win32File) asyncIO(c *ioOperation, d *deadlineHandler, bytes uint32, err error) (int, error) {
 	if err != windows.ERROR_IO_PENDING { //nolint:errorlint // err is Errno
-		return int(bytes), err
+		if (err != nil && err != windows.ERROR_MORE_DATA) || f.skipSyncIOCP { //nolint:errorlint // err is Errno
+			return int(bytes), err
+		}
+		// The IO completed synchronously (possibly with a warning, such as ERROR_MORE_DATA), but
+		// its completion is still queued to the completion port, and must be consumed.
+		r := <-c.ch
+		runtime.KeepAlive(c)
+		return int(r.bytes), r.err
 	}
 
 	if f.closing.isSet() {
@@ -235,7 +342,11 @@ func (f *win32File) asyncIO(c *ioOperation, d *deadlineHandler, bytes uint32, er
 }
 
 // Read reads from a file handle.
-func (f *win32File) Read(b []byte) (int, error) {
+func (f *win32File) Read(b []byte) (n int, err error) {
+	if f.ioTracking() {
+		defer func(start time.Time) { f.recordIO(IORead, start, n, err) }(time.Now())
+	}
+
 	c, err := f.prepareIO()
 	if err != nil {
 		return 0, err
@@ -248,7 +359,7 @@ func (f *win32File) Read(b []byte) (int, error) {
 
 	var bytes uint32
 	err = windows.ReadFile(f.handle, b, &bytes, &c.o)
-	n, err := f.asyncIO(c, &f.readDeadline, bytes, err)
+	n, err = f.asyncIO(c, &f.readDeadline, bytes, err)
 	runtime.KeepAlive(b)
 
 	// Handle EOF conditions.
@@ -261,8 +372,145 @@ func (f *win32File) Read(b []byte) (int, error) {
 	}
 }
 
-// Write writes to a file handle.
-func (f *win32File) Write(b []byte) (int, error) {
+// Write writes to a file handle. Writes larger than the pipe's buffer (or 1MB, if it is not a
+// pipe or its buffer size is not fixed) are split into multiple WriteFile calls (except for
+// message-mode pipes, where each Write is a message). If the write deadline
+// passes, or an error occurs, Write returns the number of bytes written so far.
+func (f *win32File) Write(b []byte) (n int, err error) {
+	if f.ioTracking() {
+		defer func(start time.Time) { f.recordIO(IOWrite, start, n, err) }(time.Now())
+	}
+
+	max := f.maxWrite()
+	if f.messageWrites || len(b) <= max {
+		return f.write(b)
+	}
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		m, err := f.write(chunk)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+		b = b[m:]
+	}
+	return n, nil
+}
+
+// maxWrite returns the largest write to issue with a single WriteFile call.
+func (f *win32File) maxWrite() int {
+	if f.writeChunk > 0 {
+		return f.writeChunk
+	}
+	return maxWriteChunk
+}
+
+// write writes b with a single WriteFile call.
+func (f *win32File) write(b []byte) (int, error) {
+	c, err := f.prepareIO()
+	if err != nil {
+		return 0, err
+	}
+	defer f.wg.Done()
+
+	if f.writeDeadline.timedout.isSet() {
+		return 0, ErrTimeout
+	}
+
+	var bytes uint32
+	err = windows.WriteFile(f.handle, b, &bytes, &c.o)
+	n, err := f.asyncIO(c, &f.writeDeadline, bytes, err)
+	runtime.KeepAlive(b)
+	return n, err
+}
+
+// ReadAt reads len(b) bytes from the file starting at byte offset off, using the offset of the
+// OVERLAPPED structure rather than the file pointer, so concurrent ReadAt and WriteAt calls do
+// not race to seek. It implements [io.ReaderAt], and returns io.EOF if the end of the file is
+// reached before b is filled.
+func (f *win32OpenFile) ReadAt(b []byte, off int64) (n int, err error) {
+	if f.ioTracking() {
+		defer func(start time.Time) { f.recordIO(IORead, start, n, err) }(time.Now())
+	}
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	for len(b) > 0 {
+		m, err := f.readAt(b, off)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		b = b[m:]
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// readAt reads into b at off with a single ReadFile call.
+func (f *win32File) readAt(b []byte, off int64) (int, error) {
+	c, err := f.prepareIO()
+	if err != nil {
+		return 0, err
+	}
+	defer f.wg.Done()
+
+	if f.readDeadline.timedout.isSet() {
+		return 0, ErrTimeout
+	}
+
+	c.o.Offset = uint32(off)
+	c.o.OffsetHigh = uint32(off >> 32)
+	var bytes uint32
+	err = windows.ReadFile(f.handle, b, &bytes, &c.o)
+	n, err := f.asyncIO(c, &f.readDeadline, bytes, err)
+	runtime.KeepAlive(b)
+	if err == windows.ERROR_HANDLE_EOF || (err == nil && n == 0) { //nolint:errorlint // err is Errno
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// WriteAt writes b to the file starting at byte offset off, using the offset of the OVERLAPPED
+// structure rather than the file pointer; see [win32OpenFile.ReadAt]. It implements
+// [io.WriterAt]. Writes larger than 1MB are split into multiple WriteFile calls.
+func (f *win32OpenFile) WriteAt(b []byte, off int64) (n int, err error) {
+	if f.ioTracking() {
+		defer func(start time.Time) { f.recordIO(IOWrite, start, n, err) }(time.Now())
+	}
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	max := f.maxWrite()
+	for len(b) > 0 {
+		chunk := b
+		if len(chunk) > max {
+			chunk = chunk[:max]
+		}
+		m, err := f.writeAt(chunk, off)
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+		b = b[m:]
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// writeAt writes b at off with a single WriteFile call.
+func (f *win32File) writeAt(b []byte, off int64) (int, error) {
 	c, err := f.prepareIO()
 	if err != nil {
 		return 0, err
@@ -273,6 +521,8 @@ func (f *win32File) Write(b []byte) (int, error) {
 		return 0, ErrTimeout
 	}
 
+	c.o.Offset = uint32(off)
+	c.o.OffsetHigh = uint32(off >> 32)
 	var bytes uint32
 	err = windows.WriteFile(f.handle, b, &bytes, &c.o)
 	n, err := f.asyncIO(c, &f.writeDeadline, bytes, err)
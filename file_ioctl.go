@@ -0,0 +1,89 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// DeviceIoController is implemented by the files returned by [NewOpenFile], to issue control
+// codes that remain pending until an event occurs, such as FSCTL_REQUEST_OPLOCK, without
+// blocking a goroutine for each of them.
+type DeviceIoController interface {
+	// DeviceIoControl issues the control code with the input and output buffers, which must not
+	// be modified until the operation completes. complete is called with the number of bytes
+	// written to out and the result of the operation when it completes, which may be before
+	// DeviceIoControl returns; it must not block, since it runs on the goroutine processing all
+	// IO completions. The returned function cancels the operation if it is still pending.
+	//
+	// If the file is closed, the operation is canceled and completes with [ErrFileClosed].
+	// complete is not called if an error is returned.
+	DeviceIoControl(code uint32, in, out []byte, complete func(n uint32, err error)) (cancel func(), err error)
+}
+
+var _ DeviceIoController = (*win32File)(nil)
+
+// pendingIO holds the operations issued with a completion function until they complete, since
+// the system writes to them after the issuing goroutine has returned.
+var pendingIO = struct {
+	sync.Mutex
+	ops map[*ioOperation]struct{}
+}{ops: make(map[*ioOperation]struct{})}
+
+func (f *win32File) DeviceIoControl(code uint32, in, out []byte, complete func(n uint32, err error)) (func(), error) {
+	c, err := f.prepareIO()
+	if err != nil {
+		return nil, err
+	}
+	c.complete = func(r ioResult) {
+		pendingIO.Lock()
+		delete(pendingIO.ops, c)
+		pendingIO.Unlock()
+		if r.err == windows.ERROR_OPERATION_ABORTED && f.closing.isSet() { //nolint:errorlint // err is Errno
+			r.err = ErrFileClosed
+		}
+		f.wg.Done()
+		complete(r.bytes, r.err)
+	}
+	pendingIO.Lock()
+	pendingIO.ops[c] = struct{}{}
+	pendingIO.Unlock()
+
+	var n uint32
+	err = windows.DeviceIoControl(f.handle, code, bufferPtr(in), uint32(len(in)), bufferPtr(out), uint32(len(out)), &n, &c.o)
+	switch {
+	case err == windows.ERROR_IO_PENDING: //nolint:errorlint // err is Errno
+		if f.closing.isSet() {
+			_ = cancelIoEx(f.handle, &c.o)
+		}
+	case (err != nil && err != windows.ERROR_MORE_DATA) || f.skipSyncIOCP: //nolint:errorlint // err is Errno
+		// the completion is not queued to the completion port
+		pendingIO.Lock()
+		delete(pendingIO.ops, c)
+		pendingIO.Unlock()
+		f.wg.Done()
+		if err != nil {
+			return nil, err
+		}
+		complete(n, nil)
+	}
+	return func() {
+		pendingIO.Lock()
+		defer pendingIO.Unlock()
+		if _, ok := pendingIO.ops[c]; ok {
+			_ = cancelIoEx(f.handle, &c.o)
+		}
+	}, nil
+}
+
+// bufferPtr returns a pointer to the first byte of b, or nil if it is empty.
+func bufferPtr(b []byte) *byte {
+	if len(b) == 0 {
+		return nil
+	}
+	return (*byte)(unsafe.Pointer(&b[0]))
+}
@@ -0,0 +1,131 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func openOverlappedFile(t *testing.T) io.ReadWriteCloser {
+	t.Helper()
+	p, err := windows.UTF16PtrFromString(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := windows.CreateFile(p,
+		windows.GENERIC_READ|windows.GENERIC_WRITE,
+		0,
+		nil,
+		windows.CREATE_NEW,
+		windows.FILE_FLAG_OVERLAPPED,
+		0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := NewOpenFile(h)
+	if err != nil {
+		windows.Close(h)
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+func TestFileReadWriteAt(t *testing.T) {
+	f := openOverlappedFile(t)
+	ra := f.(io.ReaderAt)
+	wa := f.(io.WriterAt)
+
+	// write blocks concurrently, in reverse order
+	const blockSize = 4096
+	const blocks = 16
+	var wg sync.WaitGroup
+	errs := make(chan error, blocks)
+	for i := blocks - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := bytes.Repeat([]byte{byte(i)}, blockSize)
+			if _, err := wa.WriteAt(b, int64(i*blockSize)); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, blockSize)
+	for i := 0; i < blocks; i++ {
+		if _, err := ra.ReadAt(b, int64(i*blockSize)); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, bytes.Repeat([]byte{byte(i)}, blockSize)) {
+			t.Fatalf("block %d has the wrong contents", i)
+		}
+	}
+
+	// a read past the end of the file returns the bytes read and io.EOF
+	n, err := ra.ReadAt(b, (blocks-1)*blockSize+blockSize/2)
+	if n != blockSize/2 || !errors.Is(err, io.EOF) {
+		t.Fatalf("expected %d bytes and io.EOF, got %d bytes and %v", blockSize/2, n, err)
+	}
+	if _, err := ra.ReadAt(b, -1); err == nil {
+		t.Fatal("read at a negative offset succeeded")
+	}
+}
+
+func TestConnsNotReaderAt(t *testing.T) {
+	// random access is only supported by the files returned by NewOpenFile
+	for _, c := range []interface{}{&win32Pipe{}, &win32MessageBytePipe{}, &HvsockConn{}} {
+		if _, ok := c.(io.ReaderAt); ok {
+			t.Errorf("%T implements io.ReaderAt", c)
+		}
+		if _, ok := c.(io.WriterAt); ok {
+			t.Errorf("%T implements io.WriterAt", c)
+		}
+	}
+}
+
+func TestHandleInheritance(t *testing.T) {
+	f := openOverlappedFile(t)
+	h := windows.Handle(f.(*win32OpenFile).Fd())
+
+	for _, inherit := range []bool{true, false} {
+		if err := SetHandleInheritable(h, inherit); err != nil {
+			t.Fatal(err)
+		}
+		if ok, err := IsHandleInheritable(h); err != nil {
+			t.Fatal(err)
+		} else if ok != inherit {
+			t.Fatalf("expected inheritable %t, got %t", inherit, ok)
+		}
+	}
+
+	// duplicate into this process, so the handle can be checked
+	dup, err := DuplicateHandleToProcess(h, windows.GetCurrentProcessId(), 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(dup) //nolint:errcheck
+	if ok, err := IsHandleInheritable(dup); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatal("expected the duplicated handle to be inheritable")
+	}
+	if typ, err := windows.GetFileType(dup); err != nil {
+		t.Fatal(err)
+	} else if typ != windows.FILE_TYPE_DISK {
+		t.Fatalf("expected the duplicated handle to refer to the file, got type %d", typ)
+	}
+}
@@ -104,3 +104,20 @@ func GetFileID(f *os.File) (*FileIDInfo, error) {
 	runtime.KeepAlive(f)
 	return fileID, nil
 }
+
+// SameFile reports whether a and b refer to the same file, by comparing their
+// (volume serial number, file ID) pairs from [GetFileID].
+//
+// Unlike [os.SameFile], this compares the full 128-bit file ID, which is required to
+// distinguish files on ReFS volumes.
+func SameFile(a, b *os.File) (bool, error) {
+	aID, err := GetFileID(a)
+	if err != nil {
+		return false, err
+	}
+	bID, err := GetFileID(b)
+	if err != nil {
+		return false, err
+	}
+	return *aID == *bID, nil
+}
@@ -5,6 +5,7 @@ package winio
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"unsafe"
 
@@ -183,3 +184,41 @@ func TestFileInfoStructAlignment(t *testing.T) {
 		})
 	}
 }
+
+func TestSameFile(t *testing.T) {
+	tempDir := t.TempDir()
+	p := filepath.Join(tempDir, "a")
+	a, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	a2, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a2.Close()
+
+	b, err := os.Create(filepath.Join(tempDir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	same, err := SameFile(a, a2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !same {
+		t.Fatal("expected handles to the same path to refer to the same file")
+	}
+
+	same, err = SameFile(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same {
+		t.Fatal("expected different files to not be the same")
+	}
+}
@@ -0,0 +1,125 @@
+package winio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// DefaultMaxFrameSize is the default maximum size of a message sent or received by a [FramedConn].
+const DefaultMaxFrameSize = 1 << 20
+
+// frameHeaderSize is the size of the little-endian uint32 length that prefixes each message.
+const frameHeaderSize = 4
+
+// frameCoalesceSize is the largest message that is copied into a single buffer with its
+// header, so that it is sent in one write.
+const frameCoalesceSize = 4096
+
+// ErrFrameTooLarge is returned by [FramedConn] when a message is larger than the maximum
+// frame size.
+var ErrFrameTooLarge = errors.New("frame exceeds the maximum size")
+
+// FramedConn sends and receives discrete messages over a byte stream, such as a byte-mode
+// named pipe, by prefixing each message with its length as a little-endian uint32.
+//
+// Send and Recv may be called concurrently with each other, and each may be called from
+// multiple goroutines.
+type FramedConn struct {
+	conn net.Conn
+	max  uint32
+
+	wmu  sync.Mutex
+	wbuf []byte
+
+	rmu  sync.Mutex
+	rhdr [frameHeaderSize]byte
+}
+
+// NewFramedConn returns a [FramedConn] that sends and receives messages over conn, of
+// at most DefaultMaxFrameSize bytes.
+func NewFramedConn(conn net.Conn) *FramedConn {
+	return &FramedConn{conn: conn, max: DefaultMaxFrameSize}
+}
+
+// SetMaxFrameSize sets the maximum size of the messages that can be sent or received. It
+// must be called before the connection is used.
+func (c *FramedConn) SetMaxFrameSize(n uint32) {
+	c.max = n
+}
+
+// Conn returns the underlying connection.
+func (c *FramedConn) Conn() net.Conn {
+	return c.conn
+}
+
+// Send writes b to the connection as a single message. It returns [ErrFrameTooLarge],
+// without writing anything, if b is larger than the maximum frame size.
+func (c *FramedConn) Send(b []byte) error {
+	if uint64(len(b)) > uint64(c.max) {
+		return ErrFrameTooLarge
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if len(b) <= frameCoalesceSize {
+		c.wbuf = appendFrame(c.wbuf[:0], b)
+		_, err := c.conn.Write(c.wbuf)
+		return err
+	}
+	var hdr [frameHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:], uint32(len(b)))
+	if _, err := c.conn.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Recv reads the next message from the connection.
+//
+// It returns io.EOF if the connection was closed between messages, and io.ErrUnexpectedEOF
+// if it was closed part way through one. If the next message is larger than the maximum
+// frame size, it returns [ErrFrameTooLarge]; the connection can no longer be used, since
+// the message has not been read, and should be closed.
+func (c *FramedConn) Recv() ([]byte, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return readFrame(c.conn, c.rhdr[:], c.max)
+}
+
+// Close closes the underlying connection.
+func (c *FramedConn) Close() error {
+	return c.conn.Close()
+}
+
+// appendFrame appends b, prefixed by its length, to buf.
+func appendFrame(buf, b []byte) []byte {
+	var hdr [frameHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[:], uint32(len(b)))
+	buf = append(buf, hdr[:]...)
+	return append(buf, b...)
+}
+
+// readFrame reads a length-prefixed message of at most maxSize bytes from r, using hdr
+// to read the length.
+func readFrame(r io.Reader, hdr []byte, maxSize uint32) ([]byte, error) {
+	if _, err := io.ReadFull(r, hdr[:frameHeaderSize]); err != nil {
+		return nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr)
+	if n > maxSize {
+		return nil, ErrFrameTooLarge
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
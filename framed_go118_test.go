@@ -0,0 +1,44 @@
+//go:build go1.18
+
+package winio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func FuzzReadFrame(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(appendFrame(nil, []byte("hello")))
+	f.Add(append(appendFrame(nil, []byte("a")), appendFrame(nil, nil)...))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		const maxSize = 1024
+		r := bytes.NewReader(b)
+		var hdr [frameHeaderSize]byte
+		var out []byte
+		for {
+			m, err := readFrame(r, hdr[:], maxSize)
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, ErrFrameTooLarge) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				if errors.Is(err, io.EOF) && r.Len() != 0 {
+					t.Fatal("io.EOF returned with data remaining")
+				}
+				break
+			}
+			if len(m) > maxSize {
+				t.Fatalf("frame of %d bytes exceeds the maximum", len(m))
+			}
+			out = appendFrame(out, m)
+		}
+		// the frames that were read re-encode to a prefix of the input
+		if !bytes.HasPrefix(b, out) {
+			t.Fatal("re-encoded frames do not match the input")
+		}
+	})
+}
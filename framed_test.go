@@ -0,0 +1,74 @@
+package winio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestFramedConnRoundTrip(t *testing.T) {
+	a, b := net.Pipe()
+	ca, cb := NewFramedConn(a), NewFramedConn(b)
+	defer ca.Close()
+	defer cb.Close()
+
+	msgs := [][]byte{
+		[]byte("hello"),
+		{},
+		bytes.Repeat([]byte{'x'}, frameCoalesceSize+1),
+		[]byte("bye"),
+	}
+	go func() {
+		for _, m := range msgs {
+			if err := ca.Send(m); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		ca.Close()
+	}()
+
+	for _, want := range msgs {
+		got, err := cb.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("got %d byte message, expected %d bytes", len(got), len(want))
+		}
+	}
+	if _, err := cb.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestFramedConnMaxSize(t *testing.T) {
+	a, b := net.Pipe()
+	ca, cb := NewFramedConn(a), NewFramedConn(b)
+	defer ca.Close()
+	defer cb.Close()
+	ca.SetMaxFrameSize(8)
+	cb.SetMaxFrameSize(4)
+
+	if err := ca.Send(make([]byte, 9)); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge sending, got %v", err)
+	}
+	go func() { _ = ca.Send(make([]byte, 8)) }()
+	if _, err := cb.Recv(); !errors.Is(err, ErrFrameTooLarge) {
+		t.Fatalf("expected ErrFrameTooLarge receiving, got %v", err)
+	}
+}
+
+func TestFramedConnTruncated(t *testing.T) {
+	for _, b := range [][]byte{
+		{1, 0},
+		{4, 0, 0, 0, 'a', 'b'},
+	} {
+		var hdr [frameHeaderSize]byte
+		if _, err := readFrame(bytes.NewReader(b), hdr[:], DefaultMaxFrameSize); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%x: expected io.ErrUnexpectedEOF, got %v", b, err)
+		}
+	}
+}
@@ -10,17 +10,24 @@ import (
 	"io"
 	"net"
 	"os"
+	"sync"
 	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
 
-	"github.com/Microsoft/go-winio/internal/socket"
 	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/Microsoft/go-winio/pkg/socket"
 )
 
 const afHVSock = 34 // AF_HYPERV
 
+// Hyper-V socket options.
+const (
+	hvProtocolRaw             = 1   // HV_PROTOCOL_RAW, the protocol and socket option level
+	hvsocketContainerPassthru = 0x2 // HVSOCKET_CONTAINER_PASSTHRU
+)
+
 // Well known Service and VM IDs
 // https://docs.microsoft.com/en-us/virtualization/hyper-v-on-windows/user-guide/make-integration-service#vmid-wildcards
 
@@ -86,15 +93,6 @@ func HvsockGUIDParent() guid.GUID { // a42e7cda-d03f-480c-9cc2-a4de20abb878
 	}
 }
 
-// hvsockVsockServiceTemplate is the Service GUID used for the VSOCK protocol.
-func hvsockVsockServiceTemplate() guid.GUID { // 00000000-facb-11e6-bd58-64006a7986d3
-	return guid.GUID{
-		Data2: 0xfacb,
-		Data3: 0x11e6,
-		Data4: [8]uint8{0xbd, 0x58, 0x64, 0x00, 0x6a, 0x79, 0x86, 0xd3},
-	}
-}
-
 // An HvsockAddr is an address for a AF_HYPERV socket.
 type HvsockAddr struct {
 	VMID      guid.GUID
@@ -116,12 +114,89 @@ func (*HvsockAddr) Network() string {
 }
 
 func (addr *HvsockAddr) String() string {
+	if hvsockFriendlyNames.isSet() {
+		return hvsockVMName(addr.VMID) + ":" + hvsockServiceName(addr.ServiceID)
+	}
 	return fmt.Sprintf("%s:%s", &addr.VMID, &addr.ServiceID)
 }
 
+var (
+	hvsockFriendlyNames atomicBool
+
+	hvsockNamesLock sync.RWMutex
+	hvsockNames     = make(map[guid.GUID]string)
+)
+
+// EnableHvsockFriendlyNames controls whether [HvsockAddr.String] (and therefore errors
+// returned by Hyper-V socket connections and listeners) replaces VM and service IDs with
+// readable names, when they are known:
+//   - The well-known VM IDs, such as [HvsockGUIDLoopback], are shown as "wildcard",
+//     "broadcast", "loopback", "silohost", "children", or "parent".
+//   - Service IDs created by [VsockServiceID] are shown as "vsock-<port>".
+//   - IDs registered with [RegisterHvsockName] are shown as the registered name.
+//
+// Friendly names are disabled by default.
+func EnableHvsockFriendlyNames(enable bool) {
+	if enable {
+		hvsockFriendlyNames.setTrue()
+	} else {
+		hvsockFriendlyNames.setFalse()
+	}
+}
+
+// RegisterHvsockName registers a readable name for a VM or service ID, which is used by
+// [HvsockAddr.String] when friendly names are enabled. An empty name removes the registration.
+func RegisterHvsockName(id guid.GUID, name string) {
+	hvsockNamesLock.Lock()
+	defer hvsockNamesLock.Unlock()
+	if name == "" {
+		delete(hvsockNames, id)
+	} else {
+		hvsockNames[id] = name
+	}
+}
+
+func registeredHvsockName(id guid.GUID) (string, bool) {
+	hvsockNamesLock.RLock()
+	defer hvsockNamesLock.RUnlock()
+	name, ok := hvsockNames[id]
+	return name, ok
+}
+
+func hvsockVMName(id guid.GUID) string {
+	switch id {
+	case HvsockGUIDWildcard():
+		return "wildcard"
+	case HvsockGUIDBroadcast():
+		return "broadcast"
+	case HvsockGUIDLoopback():
+		return "loopback"
+	case HvsockGUIDSiloHost():
+		return "silohost"
+	case HvsockGUIDChildren():
+		return "children"
+	case HvsockGUIDParent():
+		return "parent"
+	}
+	if name, ok := registeredHvsockName(id); ok {
+		return name
+	}
+	return id.String()
+}
+
+func hvsockServiceName(id guid.GUID) string {
+	if name, ok := registeredHvsockName(id); ok {
+		return name
+	}
+	if t := guid.VsockServiceTemplate(); id.Data2 == t.Data2 && id.Data3 == t.Data3 && id.Data4 == t.Data4 {
+		return fmt.Sprintf("vsock-%d", id.Data1)
+	}
+	return id.String()
+}
+
 // VsockServiceID returns an hvsock service ID corresponding to the specified AF_VSOCK port.
 func VsockServiceID(port uint32) guid.GUID {
-	g := hvsockVsockServiceTemplate() // make a copy
+	g := guid.VsockServiceTemplate() // make a copy
 	g.Data1 = port
 	return g
 }
@@ -147,7 +222,7 @@ func (r *rawHvsockAddr) Sockaddr() (unsafe.Pointer, int32, error) {
 	return unsafe.Pointer(r), int32(unsafe.Sizeof(rawHvsockAddr{})), nil
 }
 
-// Sockaddr interface allows use with `sockets.Bind()` and `.ConnectEx()`.
+// Sockaddr interface allows use with `socket.Bind()` and `.ConnectEx()`.
 func (r *rawHvsockAddr) FromBytes(b []byte) error {
 	n := int(unsafe.Sizeof(rawHvsockAddr{}))
 
@@ -175,6 +250,9 @@ var _ net.Listener = &HvsockListener{}
 type HvsockConn struct {
 	sock          *win32File
 	local, remote HvsockAddr
+
+	// broken is set after the connection is reset or the remote end closes it.
+	broken atomicBool
 }
 
 var _ net.Conn = &HvsockConn{}
@@ -193,8 +271,22 @@ func newHVSocket() (*win32File, error) {
 	return f, nil
 }
 
+// HvsockListenConfig contains options for listening on a Hyper-V socket address.
+type HvsockListenConfig struct {
+	// ContainerPassthru allows the listener to accept connections from process-isolated
+	// containers (server silos) running on the same machine, which are otherwise not
+	// delivered to listeners outside of the container. It is typically combined with
+	// [HvsockGUIDWildcard] or [HvsockGUIDChildren] as the address's VMID.
+	ContainerPassthru bool
+}
+
 // ListenHvsock listens for connections on the specified hvsock address.
-func ListenHvsock(addr *HvsockAddr) (_ *HvsockListener, err error) {
+func ListenHvsock(addr *HvsockAddr) (*HvsockListener, error) {
+	return (&HvsockListenConfig{}).Listen(addr)
+}
+
+// Listen listens for connections on the specified hvsock address, with the options in lc.
+func (lc *HvsockListenConfig) Listen(addr *HvsockAddr) (_ *HvsockListener, err error) {
 	l := &HvsockListener{addr: *addr}
 
 	var sock *win32File
@@ -208,6 +300,15 @@ func ListenHvsock(addr *HvsockAddr) (_ *HvsockListener, err error) {
 		}
 	}()
 
+	if lc.ContainerPassthru {
+		// the option must be set before the socket is bound
+		v := uint32(1)
+		if err = windows.Setsockopt(sock.handle, hvProtocolRaw, hvsocketContainerPassthru,
+			(*byte)(unsafe.Pointer(&v)), int32(unsafe.Sizeof(v))); err != nil {
+			return nil, l.opErr("listen", os.NewSyscallError("setsockopt", err))
+		}
+	}
+
 	sa := addr.raw()
 	err = socket.Bind(sock.handle, &sa)
 	if err != nil {
@@ -221,7 +322,7 @@ func ListenHvsock(addr *HvsockAddr) (_ *HvsockListener, err error) {
 }
 
 func (l *HvsockListener) opErr(op string, err error) error {
-	return &net.OpError{Op: op, Net: "hvsock", Addr: &l.addr, Err: err}
+	return &net.OpError{Op: op, Net: "hvsock", Addr: &l.addr, Err: wrapSocketErrno(err)}
 }
 
 // Addr returns the listener's network address.
@@ -297,6 +398,21 @@ type HvsockDialer struct {
 	// RetryWait is the time to wait after a connection error to retry
 	RetryWait time.Duration
 
+	// ShouldRetry reports whether a connect that failed with err should be retried, if
+	// Retries allows. err is the [windows.Errno] returned by ConnectEx.
+	//
+	// If nil, connects are retried if they time out, are refused, or the network is
+	// unreachable.
+	ShouldRetry func(err error) bool
+
+	// LocalAddr is the address to bind the socket to before connecting. If set, the peer sees
+	// its ServiceID as the connection's remote service ID, which lets services that only
+	// accept known client service IDs verify the caller. Its VMID is typically
+	// [HvsockGUIDWildcard].
+	//
+	// If nil, the socket is bound to the address being dialed.
+	LocalAddr *HvsockAddr
+
 	rt *time.Timer // redial wait timer
 }
 
@@ -341,7 +457,11 @@ func (d *HvsockDialer) Dial(ctx context.Context, addr *HvsockAddr) (conn *Hvsock
 	}()
 
 	sa := addr.raw()
-	err = socket.Bind(sock.handle, &sa)
+	bindAddr := sa
+	if d.LocalAddr != nil {
+		bindAddr = d.LocalAddr.raw()
+	}
+	err = socket.Bind(sock.handle, &bindAddr)
 	if err != nil {
 		return nil, conn.opErr(op, os.NewSyscallError("bind", err))
 	}
@@ -361,7 +481,7 @@ func (d *HvsockDialer) Dial(ctx context.Context, addr *HvsockAddr) (conn *Hvsock
 			&bytes,
 			(*windows.Overlapped)(unsafe.Pointer(&c.o)))
 		_, err = sock.asyncIO(c, nil, bytes, err)
-		if i < d.Retries && canRedial(err) {
+		if i < d.Retries && d.shouldRetry(err) {
 			if err = d.redialWait(ctx); err == nil {
 				continue
 			}
@@ -428,6 +548,13 @@ func (d *HvsockDialer) redialWait(ctx context.Context) (err error) {
 	return ctx.Err()
 }
 
+func (d *HvsockDialer) shouldRetry(err error) bool {
+	if d.ShouldRetry != nil {
+		return d.ShouldRetry(err)
+	}
+	return canRedial(err)
+}
+
 // assumes error is a plain, unwrapped windows.Errno provided by direct syscall.
 func canRedial(err error) bool {
 	//nolint:errorlint // guaranteed to be an Errno
@@ -445,10 +572,56 @@ func (conn *HvsockConn) opErr(op string, err error) error {
 	if errors.Is(err, ErrFileClosed) {
 		err = socket.ErrSocketClosed
 	}
-	return &net.OpError{Op: op, Net: "hvsock", Source: &conn.local, Addr: &conn.remote, Err: err}
+	return &net.OpError{Op: op, Net: "hvsock", Source: &conn.local, Addr: &conn.remote, Err: wrapSocketErrno(err)}
 }
 
-func (conn *HvsockConn) Read(b []byte) (int, error) {
+// socketErrno is a Winsock error that implements the Timeout and Temporary methods of
+// [net.Error]: windows.Errno only recognizes the POSIX error codes, so [net.OpError]
+// would otherwise report timed out and refused connections as neither.
+type socketErrno struct {
+	eno windows.Errno
+}
+
+var _ net.Error = (*socketErrno)(nil)
+
+func (e *socketErrno) Error() string { return e.eno.Error() }
+func (e *socketErrno) Unwrap() error { return e.eno }
+
+func (e *socketErrno) Timeout() bool {
+	return e.eno == windows.WSAETIMEDOUT || e.eno == windows.ERROR_SEM_TIMEOUT
+}
+
+// Temporary returns true for timeouts and refused connections, which may succeed if retried.
+func (e *socketErrno) Temporary() bool {
+	switch e.eno {
+	case windows.WSAECONNREFUSED, windows.ERROR_CONNECTION_REFUSED, windows.ERROR_CONNECTION_UNAVAIL:
+		return true
+	}
+	return e.Timeout()
+}
+
+// wrapSocketErrno wraps the Errno in err with a [socketErrno], if err is an [os.SyscallError]
+// for a timeout or refused connection.
+func wrapSocketErrno(err error) error {
+	se, ok := err.(*os.SyscallError) //nolint:errorlint // only the errors created in this file
+	if !ok {
+		return err
+	}
+	eno, ok := se.Err.(windows.Errno) //nolint:errorlint // see above
+	if !ok {
+		return err
+	}
+	if e := (&socketErrno{eno: eno}); e.Temporary() {
+		return os.NewSyscallError(se.Syscall, e)
+	}
+	return err
+}
+
+func (conn *HvsockConn) Read(b []byte) (n int, err error) {
+	if conn.sock.ioTracking() {
+		defer func(start time.Time) { conn.sock.recordIO(IORead, start, n, err) }(time.Now())
+	}
+
 	c, err := conn.sock.prepareIO()
 	if err != nil {
 		return 0, conn.opErr("read", err)
@@ -457,21 +630,28 @@ func (conn *HvsockConn) Read(b []byte) (int, error) {
 	buf := windows.WSABuf{Buf: &b[0], Len: uint32(len(b))}
 	var flags, bytes uint32
 	err = windows.WSARecv(conn.sock.handle, &buf, 1, &bytes, &flags, &c.o, nil)
-	n, err := conn.sock.asyncIO(c, &conn.sock.readDeadline, bytes, err)
+	n, err = conn.sock.asyncIO(c, &conn.sock.readDeadline, bytes, err)
 	if err != nil {
+		if isConnReset(err) {
+			conn.broken.setTrue()
+		}
 		var eno windows.Errno
 		if errors.As(err, &eno) {
 			err = os.NewSyscallError("wsarecv", eno)
 		}
 		return 0, conn.opErr("read", err)
 	} else if n == 0 {
+		conn.broken.setTrue()
 		err = io.EOF
 	}
 	return n, err
 }
 
-func (conn *HvsockConn) Write(b []byte) (int, error) {
-	t := 0
+func (conn *HvsockConn) Write(b []byte) (t int, err error) {
+	if conn.sock.ioTracking() {
+		defer func(start time.Time) { conn.sock.recordIO(IOWrite, start, t, err) }(time.Now())
+	}
+
 	for len(b) != 0 {
 		n, err := conn.write(b)
 		if err != nil {
@@ -484,16 +664,23 @@ func (conn *HvsockConn) Write(b []byte) (int, error) {
 }
 
 func (conn *HvsockConn) write(b []byte) (int, error) {
+	return conn.writev([]windows.WSABuf{{Buf: &b[0], Len: uint32(len(b))}})
+}
+
+// writev sends bufs with a single WSASend call, returning the number of bytes sent.
+func (conn *HvsockConn) writev(bufs []windows.WSABuf) (int, error) {
 	c, err := conn.sock.prepareIO()
 	if err != nil {
 		return 0, conn.opErr("write", err)
 	}
 	defer conn.sock.wg.Done()
-	buf := windows.WSABuf{Buf: &b[0], Len: uint32(len(b))}
 	var bytes uint32
-	err = windows.WSASend(conn.sock.handle, &buf, 1, &bytes, 0, &c.o, nil)
+	err = windows.WSASend(conn.sock.handle, &bufs[0], uint32(len(bufs)), &bytes, 0, &c.o, nil)
 	n, err := conn.sock.asyncIO(c, &conn.sock.writeDeadline, bytes, err)
 	if err != nil {
+		if isConnReset(err) {
+			conn.broken.setTrue()
+		}
 		var eno windows.Errno
 		if errors.As(err, &eno) {
 			err = os.NewSyscallError("wsasend", eno)
@@ -503,6 +690,12 @@ func (conn *HvsockConn) write(b []byte) (int, error) {
 	return n, err
 }
 
+// IOStats returns a snapshot of the connection's IO statistics, and false if statistics
+// were not enabled when the connection was created. See [EnableIOStats].
+func (conn *HvsockConn) IOStats() (IOStats, bool) {
+	return conn.sock.IOStats()
+}
+
 // Close closes the socket connection, failing any pending read or write calls.
 func (conn *HvsockConn) Close() error {
 	return conn.sock.Close()
@@ -0,0 +1,164 @@
+//go:build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// ErrInvalidHvsockAddr is returned by [ParseHvsockAddr] if an address is not valid.
+var ErrInvalidHvsockAddr = errors.New("invalid Hyper-V socket address")
+
+// ParseHvsockAddr parses an address of the form "<vmid>:<serviceid>", as returned by
+// [HvsockAddr.String]. Both IDs may be GUIDs or the friendly names shown when
+// [EnableHvsockFriendlyNames] is set: "wildcard", "loopback", and the other well-known VM
+// names, "vsock-<port>" service names, and names registered with [RegisterHvsockName].
+func ParseHvsockAddr(s string) (*HvsockAddr, error) {
+	vm, svc, ok := splitHvsockAddr(s)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidHvsockAddr, s)
+	}
+	vmID, ok := parseHvsockVMID(vm)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid VM ID %q", ErrInvalidHvsockAddr, vm)
+	}
+	serviceID, ok := parseHvsockServiceID(svc)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid service ID %q", ErrInvalidHvsockAddr, svc)
+	}
+	return &HvsockAddr{VMID: vmID, ServiceID: serviceID}, nil
+}
+
+func splitHvsockAddr(s string) (vm, svc string, ok bool) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 || strings.IndexByte(s[i+1:], ':') >= 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+func parseHvsockVMID(s string) (guid.GUID, bool) {
+	switch strings.ToLower(s) {
+	case "wildcard":
+		return HvsockGUIDWildcard(), true
+	case "broadcast":
+		return HvsockGUIDBroadcast(), true
+	case "loopback":
+		return HvsockGUIDLoopback(), true
+	case "silohost":
+		return HvsockGUIDSiloHost(), true
+	case "children":
+		return HvsockGUIDChildren(), true
+	case "parent":
+		return HvsockGUIDParent(), true
+	}
+	return parseHvsockID(s)
+}
+
+func parseHvsockServiceID(s string) (guid.GUID, bool) {
+	if p := strings.TrimPrefix(s, "vsock-"); p != s {
+		if port, err := strconv.ParseUint(p, 10, 32); err == nil {
+			return VsockServiceID(uint32(port)), true
+		}
+	}
+	return parseHvsockID(s)
+}
+
+// parseHvsockID parses a GUID or a name registered with RegisterHvsockName.
+func parseHvsockID(s string) (guid.GUID, bool) {
+	if g, err := guid.FromString(s); err == nil {
+		return g, true
+	}
+	hvsockNamesLock.RLock()
+	defer hvsockNamesLock.RUnlock()
+	for id, name := range hvsockNames {
+		if name == s {
+			return id, true
+		}
+	}
+	return guid.GUID{}, false
+}
+
+// HvsockGRPCDialer returns a function that dials Hyper-V sockets with d, for use with gRPC's
+// WithContextDialer dial option.
+//
+// If addr is not nil, the function dials it, ignoring the address it is called with. Otherwise,
+// the address is parsed with [ParseHvsockAddr], so the gRPC target can be the
+// "passthrough:///" scheme followed by the [HvsockAddr.String] of the address (see
+// [HvsockGRPCTarget]). If d is nil, the default dialer is used.
+func HvsockGRPCDialer(d *HvsockDialer, addr *HvsockAddr) func(context.Context, string) (net.Conn, error) {
+	if d == nil {
+		d = &HvsockDialer{}
+	}
+	return func(ctx context.Context, target string) (net.Conn, error) {
+		a := addr
+		if a == nil {
+			var err error
+			if a, err = ParseHvsockAddr(target); err != nil {
+				return nil, &net.OpError{Op: "dial", Net: "hvsock", Err: err}
+			}
+		}
+		return d.Dial(ctx, a)
+	}
+}
+
+// HvsockGRPCTarget returns the gRPC dial target for addr, which [HvsockGRPCDialer] parses when
+// it is not given an address. The IDs are always formatted as GUIDs, so the target does not
+// depend on [EnableHvsockFriendlyNames].
+func HvsockGRPCTarget(addr *HvsockAddr) string {
+	return "passthrough:///" + (*grpcHvsockAddr)(addr).String()
+}
+
+// grpcHvsockAddr is an HvsockAddr that is always formatted with GUIDs, so that the string can
+// be parsed back, and used as a gRPC peer or listener address.
+type grpcHvsockAddr HvsockAddr
+
+func (*grpcHvsockAddr) Network() string { return "hvsock" }
+
+func (addr *grpcHvsockAddr) String() string {
+	return fmt.Sprintf("%s:%s", &addr.VMID, &addr.ServiceID)
+}
+
+// HvsockGRPCListener wraps l for use with a gRPC server, so that the addresses of the listener
+// and of its connections are formatted as GUIDs, regardless of
+// [EnableHvsockFriendlyNames], and can be parsed with [ParseHvsockAddr]. This keeps the peer
+// addresses seen by gRPC interceptors and logs stable.
+func HvsockGRPCListener(l *HvsockListener) net.Listener {
+	return &grpcHvsockListener{l}
+}
+
+type grpcHvsockListener struct {
+	*HvsockListener
+}
+
+func (l *grpcHvsockListener) Accept() (net.Conn, error) {
+	c, err := l.HvsockListener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &grpcHvsockConn{c.(*HvsockConn)}, nil
+}
+
+func (l *grpcHvsockListener) Addr() net.Addr {
+	return (*grpcHvsockAddr)(&l.addr)
+}
+
+// grpcHvsockConn is an HvsockConn with addresses formatted as GUIDs.
+type grpcHvsockConn struct {
+	*HvsockConn
+}
+
+func (c *grpcHvsockConn) LocalAddr() net.Addr {
+	return (*grpcHvsockAddr)(&c.local)
+}
+
+func (c *grpcHvsockConn) RemoteAddr() net.Addr {
+	return (*grpcHvsockAddr)(&c.remote)
+}
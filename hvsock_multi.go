@@ -0,0 +1,119 @@
+//go:build windows
+
+package winio
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/Microsoft/go-winio/pkg/socket"
+)
+
+// HvsockMultiListener is a [net.Listener] that accepts connections on several Hyper-V socket
+// addresses, such as the same service ID on both [HvsockGUIDChildren] and
+// [HvsockGUIDLoopback].
+type HvsockMultiListener struct {
+	listeners []*HvsockListener
+	acceptCh  chan acceptResult
+	closeCh   chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+var _ net.Listener = (*HvsockMultiListener)(nil)
+
+var errNoAddresses = errors.New("no addresses to listen on")
+
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// ListenHvsockMulti listens for connections on all of addrs, which must not be empty. If
+// listening on any of the addresses fails, the other listeners are closed.
+func ListenHvsockMulti(addrs []HvsockAddr) (_ *HvsockMultiListener, err error) {
+	if len(addrs) == 0 {
+		return nil, &net.OpError{Op: "listen", Net: "hvsock", Err: errNoAddresses}
+	}
+	ml := &HvsockMultiListener{
+		acceptCh: make(chan acceptResult),
+		closeCh:  make(chan struct{}),
+	}
+	defer func() {
+		if err != nil {
+			for _, l := range ml.listeners {
+				l.Close()
+			}
+		}
+	}()
+	for i := range addrs {
+		l, err := ListenHvsock(&addrs[i])
+		if err != nil {
+			return nil, err
+		}
+		ml.listeners = append(ml.listeners, l)
+	}
+
+	ml.wg.Add(len(ml.listeners))
+	for _, l := range ml.listeners {
+		go ml.acceptLoop(l)
+	}
+	return ml, nil
+}
+
+// acceptLoop accepts connections from l, and hands them to Accept, until the listener is closed.
+func (ml *HvsockMultiListener) acceptLoop(l *HvsockListener) {
+	defer ml.wg.Done()
+	for {
+		conn, err := l.Accept()
+		select {
+		case ml.acceptCh <- acceptResult{conn, err}:
+		case <-ml.closeCh:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// Accept waits for the next connection on any of the listener's addresses. Errors from the
+// underlying listeners are returned as they occur, and do not stop the listener.
+func (ml *HvsockMultiListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-ml.acceptCh:
+		return r.conn, r.err
+	case <-ml.closeCh:
+		return nil, &net.OpError{Op: "accept", Net: "hvsock", Addr: ml.Addr(), Err: socket.ErrSocketClosed}
+	}
+}
+
+// Addr returns the address of the first listener.
+func (ml *HvsockMultiListener) Addr() net.Addr {
+	return ml.listeners[0].Addr()
+}
+
+// Addrs returns the addresses of all of the listeners, in the order they were passed to
+// [ListenHvsockMulti].
+func (ml *HvsockMultiListener) Addrs() []net.Addr {
+	addrs := make([]net.Addr, 0, len(ml.listeners))
+	for _, l := range ml.listeners {
+		addrs = append(addrs, l.Addr())
+	}
+	return addrs
+}
+
+// Close closes all of the listeners, causing any pending Accept calls to fail.
+func (ml *HvsockMultiListener) Close() (err error) {
+	ml.closeOnce.Do(func() {
+		close(ml.closeCh)
+		for _, l := range ml.listeners {
+			if cerr := l.Close(); err == nil {
+				err = cerr
+			}
+		}
+		ml.wg.Wait()
+	})
+	return err
+}
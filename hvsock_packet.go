@@ -0,0 +1,303 @@
+//go:build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// hvsockPacketQueueSize is the number of received messages an [HvsockPacketConn] buffers
+// before it stops reading from its peers.
+const hvsockPacketQueueSize = 64
+
+// ErrHvsockPeerNotConnected is returned by [HvsockPacketConn.WriteTo] when there is no
+// connection to the destination address.
+var ErrHvsockPeerNotConnected = errors.New("hvsock peer is not connected")
+
+// hvsockPacket is a message received from a peer of an HvsockPacketConn.
+type hvsockPacket struct {
+	b    []byte
+	addr *HvsockAddr
+}
+
+// HvsockPacketConn provides a datagram-style interface, with per-message boundaries, over
+// Hyper-V sockets. Since Hyper-V sockets only support streams, each message is sent as a
+// length-prefixed frame (as with [FramedConn]) over a stream connection to the peer.
+//
+// A connection created with [DialHvsockPacket] has a single peer. One created with
+// [ListenHvsockPacket] accepts connections in the background, and receives messages from
+// all connected peers; the address returned by ReadFrom can be passed to WriteTo to reply.
+//
+// Delivery is best-effort: messages are lost if the peer's connection fails, and messages
+// larger than the buffer passed to ReadFrom are truncated.
+type HvsockPacketConn struct {
+	l     *HvsockListener
+	local HvsockAddr
+	// the address WriteTo uses if its address is nil, for dialed connections
+	remote *HvsockAddr
+
+	recv   chan hvsockPacket
+	closed chan struct{}
+	once   sync.Once
+	wg     sync.WaitGroup
+
+	mu sync.Mutex
+	// the most recent connection from each remote address
+	peers map[HvsockAddr]*FramedConn
+	// all connections, including those replaced in peers
+	conns map[*FramedConn]struct{}
+	// readDeadline and readDeadlineCh are replaced on every call to SetReadDeadline,
+	// and readDeadlineCh is closed to wake pending ReadFrom calls
+	readDeadline   time.Time
+	readDeadlineCh chan struct{}
+	writeDeadline  time.Time
+}
+
+var _ net.PacketConn = &HvsockPacketConn{}
+
+func newHvsockPacketConn(local HvsockAddr) *HvsockPacketConn {
+	return &HvsockPacketConn{
+		local:          local,
+		recv:           make(chan hvsockPacket, hvsockPacketQueueSize),
+		closed:         make(chan struct{}),
+		peers:          make(map[HvsockAddr]*FramedConn),
+		conns:          make(map[*FramedConn]struct{}),
+		readDeadlineCh: make(chan struct{}),
+	}
+}
+
+// DialHvsockPacket connects to the Hyper-V socket address addr, which must be served by
+// [ListenHvsockPacket], and returns a packet connection with it as the only peer.
+func DialHvsockPacket(ctx context.Context, addr *HvsockAddr) (*HvsockPacketConn, error) {
+	conn, err := Dial(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c := newHvsockPacketConn(conn.local)
+	c.remote = &conn.remote
+	c.addPeer(conn)
+	return c, nil
+}
+
+// ListenHvsockPacket listens for connections on the Hyper-V socket address addr, and returns
+// a packet connection that exchanges messages with every peer that connects to it.
+func ListenHvsockPacket(addr *HvsockAddr) (*HvsockPacketConn, error) {
+	l, err := ListenHvsock(addr)
+	if err != nil {
+		return nil, err
+	}
+	c := newHvsockPacketConn(l.addr)
+	c.l = l
+	c.wg.Add(1)
+	go c.acceptLoop()
+	return c, nil
+}
+
+func (c *HvsockPacketConn) acceptLoop() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			select {
+			case <-c.closed:
+				return
+			default:
+			}
+			continue
+		}
+		c.addPeer(conn.(*HvsockConn))
+	}
+}
+
+// addPeer starts receiving messages from conn. If another connection has the same remote
+// address, messages are still received from it, but WriteTo uses conn.
+func (c *HvsockPacketConn) addPeer(conn *HvsockConn) {
+	fc := NewFramedConn(conn)
+	key := conn.remote
+
+	c.mu.Lock()
+	select {
+	case <-c.closed:
+		c.mu.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	c.peers[key] = fc
+	c.conns[fc] = struct{}{}
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	go c.readLoop(key, fc, &conn.remote)
+}
+
+func (c *HvsockPacketConn) readLoop(key HvsockAddr, fc *FramedConn, addr *HvsockAddr) {
+	defer c.wg.Done()
+	defer func() {
+		c.mu.Lock()
+		if c.peers[key] == fc {
+			delete(c.peers, key)
+		}
+		delete(c.conns, fc)
+		c.mu.Unlock()
+		fc.Close()
+	}()
+	for {
+		b, err := fc.Recv()
+		if err != nil {
+			return
+		}
+		select {
+		case c.recv <- hvsockPacket{b: b, addr: addr}:
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *HvsockPacketConn) opErr(op string, addr net.Addr, err error) error {
+	return &net.OpError{Op: op, Net: "hvsock", Source: &c.local, Addr: addr, Err: err}
+}
+
+// ReadFrom reads the next message from any peer into p, and returns the number of bytes
+// copied and the address of the peer. If the message is larger than p, the remainder is
+// discarded.
+func (c *HvsockPacketConn) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		c.mu.Lock()
+		deadline, deadlineCh := c.readDeadline, c.readDeadlineCh
+		c.mu.Unlock()
+
+		var (
+			t       *time.Timer
+			timeout <-chan time.Time
+		)
+		if !deadline.IsZero() {
+			d := time.Until(deadline)
+			if d <= 0 {
+				return 0, nil, c.opErr("read", nil, ErrTimeout)
+			}
+			t = time.NewTimer(d)
+			timeout = t.C
+		}
+
+		var (
+			pkt hvsockPacket
+			err error
+		)
+		select {
+		case pkt = <-c.recv:
+		case <-c.closed:
+			err = net.ErrClosed
+		case <-timeout:
+			err = ErrTimeout
+		case <-deadlineCh:
+			// the deadline changed
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if err != nil {
+			return 0, nil, c.opErr("read", nil, err)
+		}
+		if pkt.addr != nil {
+			return copy(p, pkt.b), pkt.addr, nil
+		}
+	}
+}
+
+// WriteTo sends p as a single message to the peer at addr, which must be an [HvsockAddr].
+// For connections created with [DialHvsockPacket], addr may be nil.
+//
+// It returns an error wrapping [ErrHvsockPeerNotConnected] if no peer with that address
+// is connected, and [ErrFrameTooLarge] if p is larger than [DefaultMaxFrameSize].
+func (c *HvsockPacketConn) WriteTo(p []byte, addr net.Addr) (int, error) {
+	var ha *HvsockAddr
+	switch a := addr.(type) {
+	case *HvsockAddr:
+		ha = a
+	case nil:
+		ha = c.remote
+	}
+	if ha == nil {
+		return 0, c.opErr("write", addr, ErrHvsockPeerNotConnected)
+	}
+
+	c.mu.Lock()
+	fc, ok := c.peers[*ha]
+	deadline := c.writeDeadline
+	c.mu.Unlock()
+	select {
+	case <-c.closed:
+		return 0, c.opErr("write", ha, net.ErrClosed)
+	default:
+	}
+	if !ok {
+		return 0, c.opErr("write", ha, ErrHvsockPeerNotConnected)
+	}
+
+	if err := fc.Conn().SetWriteDeadline(deadline); err != nil {
+		return 0, err
+	}
+	if err := fc.Send(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close stops listening, if the connection was created with [ListenHvsockPacket], and closes
+// the connections to all peers.
+func (c *HvsockPacketConn) Close() error {
+	var err error
+	c.once.Do(func() {
+		c.mu.Lock()
+		close(c.closed)
+		for fc := range c.conns {
+			fc.Close()
+		}
+		c.mu.Unlock()
+		if c.l != nil {
+			err = c.l.Close()
+		}
+		c.wg.Wait()
+	})
+	return err
+}
+
+// LocalAddr returns the local address of the connection.
+func (c *HvsockPacketConn) LocalAddr() net.Addr {
+	return &c.local
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *HvsockPacketConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for pending and future calls to ReadFrom.
+func (c *HvsockPacketConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	close(c.readDeadlineCh)
+	c.readDeadlineCh = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for future calls to WriteTo.
+func (c *HvsockPacketConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeDeadline = t
+	return nil
+}
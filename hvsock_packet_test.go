@@ -0,0 +1,79 @@
+//go:build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHvSockPacketConn(t *testing.T) {
+	u := newUtil(t)
+	addr := randHvsockAddr()
+	sv, err := ListenHvsockPacket(addr)
+	u.Must(err, "listen")
+	defer sv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	cl, err := DialHvsockPacket(ctx, addr)
+	u.Must(err, "dial")
+	defer cl.Close()
+
+	for _, m := range []string{testStr, "", "another message"} {
+		n, err := cl.WriteTo([]byte(m), nil)
+		u.Must(err, "client write")
+		u.Assert(n == len(m), "short write")
+	}
+
+	u.Must(sv.SetReadDeadline(time.Now().Add(5*time.Second)), "set read deadline")
+	b := make([]byte, 64)
+	var from net.Addr
+	for _, m := range []string{testStr, "", "another message"} {
+		n, a, err := sv.ReadFrom(b)
+		u.Must(err, "server read")
+		u.Assert(string(b[:n]) == m, "got message "+string(b[:n])+", expected "+m)
+		from = a
+	}
+
+	// reply to the client, truncating the message on read
+	_, err = sv.WriteTo([]byte("reply"), from)
+	u.Must(err, "server write")
+	u.Must(cl.SetReadDeadline(time.Now().Add(5*time.Second)), "set read deadline")
+	n, _, err := cl.ReadFrom(b[:2])
+	u.Must(err, "client read")
+	u.Assert(string(b[:n]) == "re", "got truncated message "+string(b[:n]))
+
+	_, err = sv.WriteTo([]byte("reply"), randHvsockAddr())
+	u.Is(err, ErrHvsockPeerNotConnected, "write to unknown peer")
+}
+
+func TestHvSockPacketConnDeadline(t *testing.T) {
+	u := newUtil(t)
+	sv, err := ListenHvsockPacket(randHvsockAddr())
+	u.Must(err, "listen")
+	defer sv.Close()
+
+	u.Must(sv.SetReadDeadline(time.Now().Add(10*time.Millisecond)), "set read deadline")
+	_, _, err = sv.ReadFrom(make([]byte, 1))
+	u.Is(err, ErrTimeout, "read with deadline")
+
+	ch := u.Go(func() error {
+		_, _, err := sv.ReadFrom(make([]byte, 1))
+		if !errors.Is(err, net.ErrClosed) {
+			return fmt.Errorf("got error %v, expected net.ErrClosed", err)
+		}
+		return nil
+	})
+	u.Must(sv.SetReadDeadline(time.Time{}), "clear read deadline")
+	time.Sleep(10 * time.Millisecond)
+	u.Must(sv.Close(), "close")
+	u.WaitErr(ch, time.Second, "read should fail after close")
+
+	_, _, err = sv.ReadFrom(make([]byte, 1))
+	u.Assert(errors.Is(err, net.ErrClosed), "read after close should return net.ErrClosed")
+}
@@ -0,0 +1,232 @@
+//go:build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const (
+	soError = 0x1007 // SO_ERROR
+
+	// default and maximum wait between redials for an [HvsockPool].
+	defaultPoolRedialWait = 10 * time.Millisecond
+	maxPoolRedialWait     = 5 * time.Second
+)
+
+// ErrHvsockPoolClosed is returned when getting a connection from a closed [HvsockPool].
+var ErrHvsockPoolClosed = errors.New("hvsock connection pool has been closed")
+
+// HvsockPool is a pool of connections to a single Hyper-V socket address.
+//
+// Connections are retrieved from the pool with [HvsockPool.Get], and should be returned
+// with [HvsockPool.Put] once the caller is done with them.
+// Connections that have been reset or closed by the remote end are discarded, and new
+// connections are transparently dialed (with an exponential backoff) as needed.
+//
+// An HvsockPool is safe for concurrent use.
+type HvsockPool struct {
+	dialer HvsockDialer
+	addr   HvsockAddr
+	size   int
+
+	mu     sync.Mutex
+	idle   []*HvsockConn
+	closed bool
+}
+
+// DialPool creates a connection pool for the Hyper-V socket at addr.
+//
+// See [HvsockDialer.DialPool] for more information.
+func DialPool(ctx context.Context, addr *HvsockAddr, size int) (*HvsockPool, error) {
+	return (&HvsockDialer{}).DialPool(ctx, addr, size)
+}
+
+// DialPool creates a pool of connections to the Hyper-V socket at addr, which will hold
+// at most size idle connections.
+//
+// An initial connection is dialed (and placed in the pool) before returning, so that an
+// unreachable address is reported immediately.
+//
+// Connections are dialed using (HvsockDialer).Retries and (HvsockDialer).RetryWait.
+// (HvsockDialer).Deadline is only used for the initial connection, since a pool is long-lived;
+// use the context passed to [HvsockPool.Get] to limit subsequent dials.
+func (d *HvsockDialer) DialPool(ctx context.Context, addr *HvsockAddr, size int) (*HvsockPool, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid hvsock pool size %d", size)
+	}
+
+	p := &HvsockPool{
+		dialer: HvsockDialer{
+			Retries:   d.Retries,
+			RetryWait: d.RetryWait,
+		},
+		addr: *addr,
+		size: size,
+	}
+
+	if !d.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, d.Deadline)
+		defer cancel()
+	}
+	conn, err := p.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.idle = append(p.idle, conn)
+	return p, nil
+}
+
+// Addr returns the address the pool's connections are dialed to.
+func (p *HvsockPool) Addr() *HvsockAddr {
+	a := p.addr
+	return &a
+}
+
+// Get returns a healthy connection from the pool, dialing a new one if no idle connections
+// are available.
+//
+// If dialing fails because the remote end is not (yet) reachable, Get retries with an
+// exponential backoff until ctx is cancelled.
+func (p *HvsockPool) Get(ctx context.Context) (*HvsockConn, error) {
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			return nil, ErrHvsockPoolClosed
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		conn := p.idle[n-1]
+		p.idle[n-1] = nil
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if conn.healthy() {
+			return conn, nil
+		}
+		conn.Close()
+	}
+	return p.dial(ctx)
+}
+
+// Put returns conn to the pool. conn is closed instead if it is no longer healthy, the pool
+// is full, or the pool is closed.
+//
+// Any deadlines set on conn are cleared.
+func (p *HvsockPool) Put(conn *HvsockConn) {
+	if conn == nil {
+		return
+	}
+	if !conn.healthy() {
+		conn.Close()
+		return
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle) >= p.size {
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+}
+
+// Idle returns the number of idle connections currently in the pool.
+func (p *HvsockPool) Idle() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.idle)
+}
+
+// Close closes the pool and all idle connections. Connections that are currently in use are
+// closed when they are returned to the pool.
+func (p *HvsockPool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, conn := range idle {
+		conn.Close()
+	}
+	return nil
+}
+
+// dial connects to the pool address, waiting with an exponential backoff between
+// failed attempts until ctx is cancelled.
+func (p *HvsockPool) dial(ctx context.Context) (*HvsockConn, error) {
+	wait := p.dialer.RetryWait
+	if wait <= 0 {
+		wait = defaultPoolRedialWait
+	}
+	var t *time.Timer
+	defer func() {
+		if t != nil {
+			t.Stop()
+		}
+	}()
+	for {
+		// use a copy of the dialer, since the redial timer cannot be shared across
+		// concurrent dials
+		d := p.dialer
+		conn, err := d.Dial(ctx, &p.addr)
+		if err == nil || !canPoolRedial(err) {
+			return conn, err
+		}
+
+		if t == nil {
+			t = time.NewTimer(wait)
+		} else {
+			t.Reset(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, (&HvsockConn{remote: p.addr}).opErr("dial", ctx.Err())
+		case <-t.C:
+		}
+
+		if wait *= 2; wait > maxPoolRedialWait {
+			wait = maxPoolRedialWait
+		}
+	}
+}
+
+// canPoolRedial returns true if err (returned by [HvsockDialer.Dial]) indicates the remote
+// end is not reachable yet, or the connection was reset.
+func canPoolRedial(err error) bool {
+	var eno windows.Errno
+	if !errors.As(err, &eno) {
+		return false
+	}
+	return canRedial(eno) || isConnReset(eno)
+}
+
+// isConnReset returns true if err indicates that the connection was reset or aborted.
+func isConnReset(err error) bool {
+	return errors.Is(err, windows.WSAECONNRESET) ||
+		errors.Is(err, windows.WSAECONNABORTED) ||
+		errors.Is(err, windows.WSAENETRESET)
+}
+
+// healthy checks if the connection is still usable: it must not be closed, have seen a
+// connection reset (or EOF), or have a pending socket error.
+func (conn *HvsockConn) healthy() bool {
+	if conn.IsClosed() || conn.broken.isSet() {
+		return false
+	}
+	v, err := windows.GetsockoptInt(conn.sock.handle, windows.SOL_SOCKET, soError)
+	return err == nil && v == 0
+}
@@ -0,0 +1,140 @@
+//go:build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// acceptAll accepts connections on l until it is closed, and sends them on the returned channel.
+func acceptAll(u testUtil, l *HvsockListener) <-chan net.Conn {
+	ch := make(chan net.Conn, 16)
+	go func() {
+		defer close(ch)
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			ch <- c
+		}
+	}()
+	u.T.Cleanup(func() {
+		// close the listener so the accept loop exits
+		l.Close()
+		for c := range ch {
+			c.Close()
+		}
+	})
+	return ch
+}
+
+func TestHvSockPoolReuse(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+	svCh := acceptAll(u, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := DialPool(ctx, addr, 2)
+	u.Must(err, "dial pool")
+	defer p.Close()
+	u.Assert(p.Idle() == 1, "pool should have an initial connection")
+
+	cl, err := p.Get(ctx)
+	u.Must(err, "pool get")
+	u.Assert(p.Idle() == 0, "pool should be empty")
+	p.Put(cl)
+	u.Assert(p.Idle() == 1, "connection should be returned to pool")
+
+	cl2, err := p.Get(ctx)
+	u.Must(err, "pool get")
+	u.Assert(cl == cl2, "pool should reuse idle connection")
+
+	// dial a second connection while the first is in use
+	cl3, err := p.Get(ctx)
+	u.Must(err, "pool get")
+	u.Assert(cl3 != cl2, "pool should dial a new connection")
+	p.Put(cl2)
+	p.Put(cl3)
+	u.Assert(p.Idle() == 2, "pool should hold two idle connections")
+
+	// drain the accepted connections
+	for i := 0; i < 2; i++ {
+		select {
+		case sv := <-svCh:
+			sv.Close()
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for server connection")
+		}
+	}
+}
+
+func TestHvSockPoolDiscardsBroken(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+	svCh := acceptAll(u, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := DialPool(ctx, addr, 1)
+	u.Must(err, "dial pool")
+	defer p.Close()
+
+	cl, err := p.Get(ctx)
+	u.Must(err, "pool get")
+
+	// close the connection from the server side; the client sees EOF
+	sv := <-svCh
+	u.Must(sv.Close(), "server close")
+	_, err = cl.Read(make([]byte, 1))
+	u.Is(err, io.EOF, "client read after server close")
+
+	p.Put(cl)
+	u.Assert(cl.IsClosed(), "broken connection should be closed")
+	u.Assert(p.Idle() == 0, "broken connection should not be pooled")
+
+	cl2, err := p.Get(ctx)
+	u.Must(err, "pool get")
+	u.Assert(cl2 != cl, "pool should dial a new connection")
+	p.Put(cl2)
+}
+
+func TestHvSockPoolClosed(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+	_ = acceptAll(u, l)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := DialPool(ctx, addr, 1)
+	u.Must(err, "dial pool")
+
+	cl, err := p.Get(ctx)
+	u.Must(err, "pool get")
+	u.Must(p.Close(), "pool close")
+
+	_, err = p.Get(ctx)
+	u.Is(err, ErrHvsockPoolClosed, "get from closed pool")
+
+	p.Put(cl)
+	u.Assert(cl.IsClosed(), "connection returned to a closed pool should be closed")
+}
+
+func TestHvSockPoolRedialBackoff(t *testing.T) {
+	u := newUtil(t)
+	addr := randHvsockAddr()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := DialPool(ctx, addr, 1)
+	if err == nil {
+		t.Fatal("dial pool should not have succeeded")
+	}
+	u.Assert(errors.Is(err, context.DeadlineExceeded), "dial pool should retry until the context expires: "+err.Error())
+}
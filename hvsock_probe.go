@@ -0,0 +1,86 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"golang.org/x/sys/windows"
+)
+
+// ProbeResult is the availability of a Hyper-V socket service, as reported by
+// [HvsockAddr.Probe].
+type ProbeResult int
+
+const (
+	// ProbeUnknown is returned with the error from a probe that failed for another reason.
+	ProbeUnknown ProbeResult = iota
+	// ProbeListening means the service accepted the connection.
+	ProbeListening
+	// ProbeRefused means the VM is reachable, but no service is listening on the address.
+	ProbeRefused
+	// ProbeUnreachable means the VM does not exist or is not running.
+	ProbeUnreachable
+	// ProbeTimedOut means the connection was neither accepted nor refused before ctx's
+	// deadline or the system connect timeout.
+	ProbeTimedOut
+)
+
+func (r ProbeResult) String() string {
+	switch r {
+	case ProbeListening:
+		return "listening"
+	case ProbeRefused:
+		return "refused"
+	case ProbeUnreachable:
+		return "unreachable"
+	case ProbeTimedOut:
+		return "timed out"
+	default:
+		return "unknown"
+	}
+}
+
+// Probe checks whether a service is listening on addr by connecting to it and closing the
+// connection immediately, without sending any data. This lets guest agents check that a host
+// service is available without performing the service's protocol handshake; the service will
+// see a connection that is closed before any data is received.
+//
+// The connection is attempted once. Refused, unreachable, and timed out connections are
+// reported by the result, with a nil error; any other failure, including ctx being cancelled,
+// is returned with [ProbeUnknown].
+func (addr *HvsockAddr) Probe(ctx context.Context) (ProbeResult, error) {
+	conn, err := Dial(ctx, addr)
+	if err == nil {
+		_ = conn.Close()
+		return ProbeListening, nil
+	}
+	if r := probeResult(err); r != ProbeUnknown {
+		return r, nil
+	}
+	return ProbeUnknown, err
+}
+
+// probeResult classifies an error returned by [HvsockDialer.Dial].
+func probeResult(err error) ProbeResult {
+	var ne net.Error
+	switch {
+	case errors.Is(err, windows.WSAECONNREFUSED),
+		errors.Is(err, windows.ERROR_CONNECTION_REFUSED),
+		errors.Is(err, windows.ERROR_CONNECTION_UNAVAIL):
+		return ProbeRefused
+	case errors.Is(err, windows.WSAENETUNREACH),
+		errors.Is(err, windows.WSAEHOSTUNREACH),
+		errors.Is(err, windows.ERROR_NETWORK_UNREACHABLE),
+		errors.Is(err, windows.ERROR_HOST_UNREACHABLE):
+		return ProbeUnreachable
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &ne) && ne.Timeout():
+		return ProbeTimedOut
+	default:
+		return ProbeUnknown
+	}
+}
@@ -0,0 +1,130 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	_ syscall.Conn = &HvsockConn{}
+	_ syscall.Conn = &HvsockListener{}
+)
+
+// SyscallConn returns a raw network connection, which can be used to access the underlying
+// socket handle (eg, to call setsockopt or getsockopt).
+func (conn *HvsockConn) SyscallConn() (syscall.RawConn, error) {
+	if conn.IsClosed() {
+		return nil, conn.opErr("syscallconn", ErrFileClosed)
+	}
+	return &rawSocket{sock: conn.sock, opErr: conn.opErr}, nil
+}
+
+// SyscallConn returns a raw network connection, which can be used to access the underlying
+// socket handle.
+//
+// The returned RawConn only supports calling Control; Read and Write return an error.
+func (l *HvsockListener) SyscallConn() (syscall.RawConn, error) {
+	if l.sock.IsClosed() {
+		return nil, l.opErr("syscallconn", ErrFileClosed)
+	}
+	return &rawSocket{sock: l.sock, opErr: l.opErr, listener: true}, nil
+}
+
+// rawSocket implements [syscall.RawConn] for an overlapped socket.
+//
+// The socket handle is kept valid (ie, Close will block) while the functions passed to
+// Control, Read, and Write are running.
+type rawSocket struct {
+	sock     *win32File
+	opErr    func(op string, err error) error
+	listener bool
+}
+
+var _ syscall.RawConn = &rawSocket{}
+
+// Control invokes f on the underlying socket handle.
+func (c *rawSocket) Control(f func(fd uintptr)) error {
+	if _, err := c.sock.prepareIO(); err != nil {
+		return c.opErr("raw-control", err)
+	}
+	defer c.sock.wg.Done()
+
+	f(uintptr(c.sock.handle))
+	return nil
+}
+
+// Read invokes f on the underlying socket handle. While f returns false, Read waits for the
+// socket to become readable and invokes f again, until the connection is closed or the read
+// deadline is exceeded.
+func (c *rawSocket) Read(f func(fd uintptr) (done bool)) error {
+	if c.listener {
+		return c.opErr("raw-read", windows.WSAEINVAL)
+	}
+	return c.loop("raw-read", f, &c.sock.readDeadline, func(o *ioOperation) error {
+		// use a zero-byte read to be notified when the socket is readable
+		var b [1]byte
+		buf := windows.WSABuf{Buf: &b[0], Len: 0}
+		var flags, bytes uint32
+		err := windows.WSARecv(c.sock.handle, &buf, 1, &bytes, &flags, &o.o, nil)
+		if _, err = c.sock.asyncIO(o, &c.sock.readDeadline, bytes, err); err != nil {
+			var eno windows.Errno
+			if errors.As(err, &eno) {
+				err = os.NewSyscallError("wsarecv", eno)
+			}
+		}
+		return err
+	})
+}
+
+// Write invokes f on the underlying socket handle. While f returns false, Write waits for
+// the socket to become writable and invokes f again, until the connection is closed or the
+// write deadline is exceeded.
+func (c *rawSocket) Write(f func(fd uintptr) (done bool)) error {
+	if c.listener {
+		return c.opErr("raw-write", windows.WSAEINVAL)
+	}
+	return c.loop("raw-write", f, &c.sock.writeDeadline, func(o *ioOperation) error {
+		// a zero-byte send completes once the socket can accept more data
+		var b [1]byte
+		buf := windows.WSABuf{Buf: &b[0], Len: 0}
+		var bytes uint32
+		err := windows.WSASend(c.sock.handle, &buf, 1, &bytes, 0, &o.o, nil)
+		if _, err = c.sock.asyncIO(o, &c.sock.writeDeadline, bytes, err); err != nil {
+			var eno windows.Errno
+			if errors.As(err, &eno) {
+				err = os.NewSyscallError("wsasend", eno)
+			}
+		}
+		return err
+	})
+}
+
+// loop invokes f until it returns true, calling wait to wait for the socket to become ready
+// after each time it returns false.
+func (c *rawSocket) loop(op string, f func(fd uintptr) bool, d *deadlineHandler, wait func(*ioOperation) error) error {
+	for {
+		o, err := c.sock.prepareIO()
+		if err != nil {
+			return c.opErr(op, err)
+		}
+		if f(uintptr(c.sock.handle)) {
+			c.sock.wg.Done()
+			return nil
+		}
+		if d.timedout.isSet() {
+			err = ErrTimeout
+		} else {
+			err = wait(o)
+		}
+		c.sock.wg.Done()
+		if err != nil {
+			return c.opErr(op, err)
+		}
+	}
+}
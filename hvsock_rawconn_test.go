@@ -0,0 +1,82 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestHvSockSyscallConn(t *testing.T) {
+	u := newUtil(t)
+	cl, sv, _ := clientServer(u)
+
+	rc, err := cl.SyscallConn()
+	u.Must(err, "client SyscallConn")
+
+	var soerr int
+	var operr error
+	u.Must(rc.Control(func(fd uintptr) {
+		soerr, operr = windows.GetsockoptInt(windows.Handle(fd), windows.SOL_SOCKET, soError)
+	}), "raw control")
+	u.Must(operr, "getsockopt")
+	u.Assert(soerr == 0, "socket has a pending error")
+
+	ch := u.Go(func() error {
+		time.Sleep(50 * time.Millisecond)
+		_, err := sv.Write([]byte(testStr))
+		return err
+	})
+
+	// Read should wait for the socket to become readable, and call f until it returns true
+	calls := 0
+	u.Must(cl.SetReadDeadline(time.Now().Add(5*time.Second)), "set read deadline")
+	u.Must(rc.Read(func(uintptr) bool {
+		calls++
+		return calls == 3
+	}), "raw read")
+	u.Assert(calls == 3, "raw read did not retry")
+	u.WaitErr(ch, time.Second, "server write")
+
+	calls = 0
+	u.Must(cl.SetWriteDeadline(time.Now().Add(5*time.Second)), "set write deadline")
+	u.Must(rc.Write(func(uintptr) bool {
+		calls++
+		return calls == 2
+	}), "raw write")
+	u.Assert(calls == 2, "raw write did not retry")
+
+	// Read stops at the deadline if f never succeeds
+	u.Must(cl.SetReadDeadline(time.Now().Add(50*time.Millisecond)), "set read deadline")
+	err = rc.Read(func(uintptr) bool { return false })
+	u.Is(err, ErrTimeout, "raw read past deadline")
+	u.Must(cl.SetReadDeadline(time.Time{}), "clear read deadline")
+
+	b := make([]byte, len(testStr))
+	n, err := cl.Read(b)
+	u.Must(err, "client read")
+	u.Assert(string(b[:n]) == testStr, "client read wrong data")
+
+	u.Must(cl.Close(), "client close")
+	err = rc.Control(func(uintptr) {})
+	u.Is(err, ErrFileClosed, "raw control after close")
+}
+
+func TestHvSockListenerSyscallConn(t *testing.T) {
+	u := newUtil(t)
+	l, _ := serverListen(u)
+
+	rc, err := l.SyscallConn()
+	u.Must(err, "listener SyscallConn")
+
+	called := false
+	u.Must(rc.Control(func(uintptr) { called = true }), "raw control")
+	u.Assert(called, "raw control did not call f")
+
+	err = rc.Read(func(uintptr) bool { return true })
+	u.Assert(errors.Is(err, windows.WSAEINVAL), "raw read on listener should fail")
+}
@@ -0,0 +1,110 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ io.ReaderFrom = &HvsockConn{}
+
+// ReadFrom buffer ring: readFromBufCount buffers of readFromBufSize bytes are filled from the
+// source and sent with a single WSASend.
+const (
+	readFromBufSize  = 64 << 10
+	readFromBufCount = 4
+)
+
+// use *[]byte, since putting a []byte in the pool allocates a copy of its slice header
+var readFromBufPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, readFromBufSize)
+		return &b
+	},
+}
+
+// ReadFrom implements [io.ReaderFrom], so that [io.Copy] to the connection sends the data from
+// r without an intermediate copy per write: data is read into a ring of pooled buffers, which
+// are sent together in a single overlapped WSASend. Reads that fill their buffer continue
+// into the next one, while a short read (such as from an interactive source) is sent
+// immediately.
+//
+// It returns the number of bytes sent, and, as io.Copy, a nil error if r returns [io.EOF].
+func (conn *HvsockConn) ReadFrom(r io.Reader) (t int64, err error) {
+	var bufs [readFromBufCount]*[]byte
+	for i := range bufs {
+		bufs[i] = readFromBufPool.Get().(*[]byte)
+	}
+	defer func() {
+		for _, b := range bufs {
+			readFromBufPool.Put(b)
+		}
+	}()
+
+	data := make([][]byte, 0, readFromBufCount)
+	for {
+		data = data[:0]
+		var rerr error
+		for _, b := range bufs {
+			var n int
+			n, rerr = r.Read(*b)
+			if n > 0 {
+				data = append(data, (*b)[:n])
+			}
+			if rerr != nil || n < len(*b) {
+				break
+			}
+		}
+		if len(data) > 0 {
+			n, err := conn.writeBuffers(data)
+			t += int64(n)
+			if err != nil {
+				return t, err
+			}
+		}
+		if errors.Is(rerr, io.EOF) {
+			return t, nil
+		} else if rerr != nil {
+			return t, rerr
+		}
+	}
+}
+
+// writeBuffers sends all of bufs, retrying any part that was not sent by a WSASend call. It
+// returns io.ErrShortWrite if a call sends nothing.
+func (conn *HvsockConn) writeBuffers(bufs [][]byte) (t int, err error) {
+	if conn.sock.ioTracking() {
+		defer func(start time.Time) { conn.sock.recordIO(IOWrite, start, t, err) }(time.Now())
+	}
+
+	wsabufs := make([]windows.WSABuf, 0, len(bufs))
+	for len(bufs) != 0 {
+		wsabufs = wsabufs[:0]
+		for _, b := range bufs {
+			wsabufs = append(wsabufs, windows.WSABuf{Buf: &b[0], Len: uint32(len(b))})
+		}
+		n, err := conn.writev(wsabufs)
+		if err != nil {
+			return t + n, err
+		}
+		if n == 0 {
+			return t, io.ErrShortWrite
+		}
+		t += n
+		// skip the buffers that were sent, and the sent part of the next one
+		for len(bufs) != 0 && n >= len(bufs[0]) {
+			n -= len(bufs[0])
+			bufs = bufs[1:]
+		}
+		if n > 0 {
+			bufs[0] = bufs[0][n:]
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,41 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHvSockReadFrom(t *testing.T) {
+	u := newUtil(t)
+	cl, sv, _ := clientServer(u)
+	defer cl.Close()
+	defer sv.Close()
+
+	// more than fills the buffer ring, and does not end on a buffer boundary
+	b := make([]byte, 3*readFromBufCount*readFromBufSize+123)
+	rand.Read(b) //nolint:gosec // used for testing
+
+	ch := u.Go(func() error {
+		// hide bytes.Reader's WriteTo, so that io.Copy uses ReadFrom
+		n, err := io.Copy(cl, struct{ io.Reader }{bytes.NewReader(b)})
+		if err != nil {
+			return err
+		}
+		if n != int64(len(b)) {
+			return fmt.Errorf("copied %d bytes, expected %d", n, len(b))
+		}
+		return cl.CloseWrite()
+	})
+
+	got, err := io.ReadAll(sv)
+	u.Must(err, "server read")
+	u.WaitErr(ch, 15*time.Second, "client copy")
+	u.Assert(bytes.Equal(got, b), "server received wrong data")
+}
@@ -0,0 +1,106 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+// Registry keys used to detect the Hyper-V socket environment.
+const (
+	// hvsockProviderKey is the service key of the HvSocket driver.
+	hvsockProviderKey = `SYSTEM\CurrentControlSet\Services\hvsocket`
+	// hvsockGuestKey is created by the Hyper-V integration services in a guest.
+	hvsockGuestKey = `SOFTWARE\Microsoft\Virtual Machine\Guest\Parameters`
+	// hvsockHostKey holds the Hyper-V socket services registered on a host.
+	hvsockHostKey = `SOFTWARE\Microsoft\Windows NT\CurrentVersion\Virtualization\GuestCommunicationServices`
+	// hvsockContainerKey has a ContainerType value inside a Windows container.
+	hvsockContainerKey = `SYSTEM\CurrentControlSet\Control`
+)
+
+// HvsockCapabilities describes the support for Hyper-V sockets of the system, as reported by
+// [IsHvsockSupported].
+type HvsockCapabilities struct {
+	// Supported is true if Hyper-V sockets can be created.
+	Supported bool
+	// ProviderInstalled is true if the HvSocket driver is installed, even if sockets cannot be
+	// created (for instance, because the driver is not running).
+	ProviderInstalled bool
+	// InsideVM is true in a Hyper-V guest, where the parent partition can be reached with
+	// [HvsockGUIDParent].
+	InsideVM bool
+	// Host is true if Hyper-V is enabled, so that services can be registered in the
+	// GuestCommunicationServices registry key for VMs to connect to.
+	Host bool
+	// Nested is true in a Hyper-V guest that is itself a Hyper-V host.
+	Nested bool
+	// InSilo is true inside a Windows container (a server silo), where the container host can
+	// be reached with [HvsockGUIDSiloHost].
+	InSilo bool
+}
+
+// IsHvsockSupported detects whether Hyper-V sockets are available, by creating a socket of the
+// AF_HYPERV address family, and where the process runs, from the registry. Clients can use it
+// to choose between named pipes and Hyper-V sockets as a transport.
+//
+// An error is only returned if the detection itself fails; the capabilities detected so far are
+// returned with it.
+func IsHvsockSupported() (*HvsockCapabilities, error) {
+	c := &HvsockCapabilities{}
+
+	fd, err := windows.Socket(afHVSock, windows.SOCK_STREAM, 1)
+	switch {
+	case err == nil:
+		windows.Closesocket(fd)
+		c.Supported = true
+	case errors.Is(err, windows.WSAEAFNOSUPPORT), errors.Is(err, windows.WSAEPROTONOSUPPORT),
+		errors.Is(err, windows.WSAESOCKTNOSUPPORT):
+	default:
+		return c, os.NewSyscallError("socket", err)
+	}
+
+	for _, x := range []struct {
+		key string
+		p   *bool
+	}{
+		{hvsockProviderKey, &c.ProviderInstalled},
+		{hvsockGuestKey, &c.InsideVM},
+		{hvsockHostKey, &c.Host},
+	} {
+		if *x.p, err = registryKeyExists(x.key); err != nil {
+			return c, err
+		}
+	}
+	c.Nested = c.InsideVM && c.Host
+	// sockets cannot be created without the provider
+	c.ProviderInstalled = c.ProviderInstalled || c.Supported
+
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, hvsockContainerKey, registry.QUERY_VALUE)
+	if err != nil {
+		return c, &os.PathError{Op: "RegOpenKeyEx", Path: `HKLM\` + hvsockContainerKey, Err: err}
+	}
+	defer k.Close()
+	if _, _, err := k.GetIntegerValue("ContainerType"); err == nil {
+		c.InSilo = true
+	} else if !errors.Is(err, registry.ErrNotExist) {
+		return c, &os.PathError{Op: "RegQueryValueEx", Path: `HKLM\` + hvsockContainerKey + `\ContainerType`, Err: err}
+	}
+	return c, nil
+}
+
+// registryKeyExists reports whether key exists under HKEY_LOCAL_MACHINE.
+func registryKeyExists(key string) (bool, error) {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, key, registry.QUERY_VALUE)
+	if errors.Is(err, registry.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, &os.PathError{Op: "RegOpenKeyEx", Path: `HKLM\` + key, Err: err}
+	}
+	k.Close()
+	return true, nil
+}
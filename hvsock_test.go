@@ -8,14 +8,16 @@ import (
 	"fmt"
 	"io"
 	"math/rand"
+	"net"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
 	"golang.org/x/sys/windows"
 
-	"github.com/Microsoft/go-winio/internal/socket"
 	"github.com/Microsoft/go-winio/pkg/guid"
+	"github.com/Microsoft/go-winio/pkg/socket"
 )
 
 const testStr = "test"
@@ -96,7 +98,7 @@ func TestHvSockConstants(t *testing.T) {
 		{"children", "90db8b89-0d35-4f79-8ce9-49ea0ac8b7cd", HvsockGUIDChildren()},
 		{"parent", "a42e7cda-d03f-480c-9cc2-a4de20abb878", HvsockGUIDParent()},
 		{"silohost", "36bd0c5c-7276-4223-88ba-7d03b654c568", HvsockGUIDSiloHost()},
-		{"vsock template", "00000000-facb-11e6-bd58-64006a7986d3", hvsockVsockServiceTemplate()},
+		{"vsock template", "00000000-facb-11e6-bd58-64006a7986d3", guid.VsockServiceTemplate()},
 	}
 	for _, tt := range tests {
 		if tt.give.String() != tt.want {
@@ -105,6 +107,42 @@ func TestHvSockConstants(t *testing.T) {
 	}
 }
 
+func TestHvSockFriendlyNames(t *testing.T) {
+	svc, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterHvsockName(svc, "my-service")
+	defer RegisterHvsockName(svc, "")
+
+	tests := []struct {
+		give HvsockAddr
+		want string
+	}{
+		{HvsockAddr{VMID: HvsockGUIDLoopback(), ServiceID: svc}, "loopback:my-service"},
+		{HvsockAddr{VMID: HvsockGUIDParent(), ServiceID: VsockServiceID(1234)}, "parent:vsock-1234"},
+		{HvsockAddr{VMID: vm, ServiceID: HvsockGUIDWildcard()}, vm.String() + ":" + HvsockGUIDWildcard().String()},
+	}
+
+	for _, tt := range tests {
+		if s := tt.give.String(); s != tt.give.VMID.String()+":"+tt.give.ServiceID.String() {
+			t.Errorf("friendly names are enabled by default: %s", s)
+		}
+	}
+
+	EnableHvsockFriendlyNames(true)
+	defer EnableHvsockFriendlyNames(false)
+	for _, tt := range tests {
+		if s := tt.give.String(); s != tt.want {
+			t.Errorf("got %q; want %q", s, tt.want)
+		}
+	}
+}
+
 func TestHvSockListenerAddresses(t *testing.T) {
 	u := newUtil(t)
 	l, addr := serverListen(u)
@@ -522,6 +560,90 @@ func TestHvSockDialNoTimeout(t *testing.T) {
 	u.WaitErr(ch, 2*time.Millisecond, "dial did not time out")
 }
 
+func TestHvSockDialRefusedNetError(t *testing.T) {
+	u := newUtil(t)
+	_, err := Dial(context.Background(), randHvsockAddr())
+	if !errors.Is(err, windows.WSAECONNREFUSED) {
+		t.Fatalf("expected WSAECONNREFUSED, got %v", err)
+	}
+	var ne net.Error
+	u.Assert(errors.As(err, &ne), fmt.Sprintf("%v is not a net.Error", err))
+	u.Assert(!ne.Timeout(), "refused connection is a timeout")
+	u.Assert(ne.Temporary(), "refused connection is not temporary") //nolint:staticcheck // Temporary is deprecated
+}
+
+func TestHvSockProbe(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	r, err := addr.Probe(context.Background())
+	u.Must(err, "probe listening service")
+	u.Assert(r == ProbeListening, fmt.Sprintf("listening service probed as %v", r))
+
+	r, err = randHvsockAddr().Probe(context.Background())
+	u.Must(err, "probe unused address")
+	u.Assert(r == ProbeRefused, fmt.Sprintf("unused address probed as %v", r))
+
+	conn := &HvsockConn{}
+	r = probeResult(conn.opErr("dial", os.NewSyscallError("connectex", windows.WSAETIMEDOUT)))
+	u.Assert(r == ProbeTimedOut, fmt.Sprintf("WSAETIMEDOUT classified as %v", r))
+	r = probeResult(conn.opErr("dial", os.NewSyscallError("connectex", windows.WSAENETUNREACH)))
+	u.Assert(r == ProbeUnreachable, fmt.Sprintf("WSAENETUNREACH classified as %v", r))
+}
+
+func TestHvSockTimeoutNetError(t *testing.T) {
+	u := newUtil(t)
+	conn := &HvsockConn{}
+	err := conn.opErr("dial", os.NewSyscallError("connectex", windows.WSAETIMEDOUT))
+	if !errors.Is(err, windows.WSAETIMEDOUT) {
+		t.Fatalf("expected WSAETIMEDOUT, got %v", err)
+	}
+	var ne net.Error
+	u.Assert(errors.As(err, &ne), fmt.Sprintf("%v is not a net.Error", err))
+	u.Assert(ne.Timeout(), "WSAETIMEDOUT is not a timeout")
+	u.Assert(ne.Temporary(), "WSAETIMEDOUT is not temporary") //nolint:staticcheck // Temporary is deprecated
+}
+
+func TestHvSockDialLocalAddr(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+
+	local := &HvsockAddr{
+		VMID:      HvsockGUIDWildcard(),
+		ServiceID: randHvsockAddr().ServiceID,
+	}
+	ch := u.Go(func() error {
+		conn, err := l.Accept()
+		if err != nil {
+			return fmt.Errorf("listener accept: %w", err)
+		}
+		defer conn.Close()
+		ra := conn.RemoteAddr().(*HvsockAddr)
+		if ra.ServiceID != local.ServiceID {
+			return fmt.Errorf("server remote service ID give: %v; want: %v", ra.ServiceID, local.ServiceID)
+		}
+		return nil
+	})
+
+	d := &HvsockDialer{LocalAddr: local}
+	cl, err := d.Dial(context.Background(), addr)
+	u.Must(err, "could not dial")
+	defer cl.Close()
+
+	cla := cl.LocalAddr().(*HvsockAddr)
+	u.Assert(cla.ServiceID == local.ServiceID, fmt.Sprintf("client local service ID give: %v; want: %v", cla.ServiceID, local.ServiceID))
+	u.WaitErr(ch, time.Second)
+}
+
 func TestHvSockDialDeadline(t *testing.T) {
 	u := newUtil(t)
 	d := &HvsockDialer{}
@@ -556,6 +678,75 @@ func TestHvSockDialContext(t *testing.T) {
 	u.Is(err, context.Canceled, "dial was not canceled")
 }
 
+func TestHvSockDialShouldRetry(t *testing.T) {
+	u := newUtil(t)
+	var calls int
+	d := &HvsockDialer{
+		Retries:   3,
+		RetryWait: time.Millisecond,
+		ShouldRetry: func(err error) bool {
+			calls++
+			return false
+		},
+	}
+	cl, err := d.Dial(context.Background(), randHvsockAddr())
+	if err == nil {
+		cl.Close()
+		t.Fatalf("dial should not have succeeded")
+	}
+	u.Assert(calls == 1, fmt.Sprintf("ShouldRetry called %d times, want 1", calls))
+}
+
+func TestParseHvsockAddr(t *testing.T) {
+	addr := randHvsockAddr()
+	defer EnableHvsockFriendlyNames(false)
+	for _, friendly := range []bool{false, true} {
+		EnableHvsockFriendlyNames(friendly)
+		got, err := ParseHvsockAddr(addr.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *got != *addr {
+			t.Fatalf("parsed %q as %v, want %v", addr.String(), got, addr)
+		}
+	}
+
+	for _, s := range []string{"", "loopback", "loopback:", "bogus:vsock-1", "a:b:c", "parent:vsock-x"} {
+		if _, err := ParseHvsockAddr(s); !errors.Is(err, ErrInvalidHvsockAddr) {
+			t.Errorf("parsing %q: expected %v, got %v", s, ErrInvalidHvsockAddr, err)
+		}
+	}
+}
+
+func TestHvSockGRPCDialer(t *testing.T) {
+	u := newUtil(t)
+	l, addr := serverListen(u)
+	gl := HvsockGRPCListener(l)
+	defer EnableHvsockFriendlyNames(false)
+	EnableHvsockFriendlyNames(true)
+
+	ch := u.Go(func() error {
+		conn, err := gl.Accept()
+		if err != nil {
+			return fmt.Errorf("listener accept: %w", err)
+		}
+		defer conn.Close()
+		if _, err := ParseHvsockAddr(conn.RemoteAddr().String()); err != nil {
+			return err
+		}
+		return nil
+	})
+
+	target := HvsockGRPCTarget(addr)
+	u.Assert(target == fmt.Sprintf("passthrough:///%s:%s", &addr.VMID, &addr.ServiceID), "unexpected target "+target)
+	dial := HvsockGRPCDialer(nil, nil)
+	cl, err := dial(context.Background(), strings.TrimPrefix(target, "passthrough:///"))
+	u.Must(err, "could not dial")
+	defer cl.Close()
+	u.WaitErr(ch, time.Second)
+	u.Assert(gl.Addr().String() == fmt.Sprintf("%s:%s", &addr.VMID, &addr.ServiceID), "unexpected listener address "+gl.Addr().String())
+}
+
 func TestHvSockAcceptClose(t *testing.T) {
 	u := newUtil(t)
 	l, _ := serverListen(u)
@@ -661,3 +852,70 @@ func (u testUtil) Check() {
 func msgJoin(pre []string, s string) string {
 	return strings.Join(append(pre, s), ": ")
 }
+
+func TestHvSockListenMulti(t *testing.T) {
+	u := newUtil(t)
+	addrs := []HvsockAddr{*randHvsockAddr(), *randHvsockAddr()}
+	ml, err := ListenHvsockMulti(addrs)
+	u.Must(err, "could not listen")
+	defer ml.Close()
+	u.Assert(len(ml.Addrs()) == len(addrs), fmt.Sprintf("got %d addresses, wanted %d", len(ml.Addrs()), len(addrs)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	for i := range addrs {
+		ch := u.Go(func() error {
+			conn, err := ml.Accept()
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+		cl, err := Dial(ctx, &addrs[i])
+		u.Must(err, "could not dial "+addrs[i].String())
+		u.WaitErr(ch, time.Second, "accept did not complete")
+		cl.Close()
+	}
+
+	u.Must(ml.Close(), "close")
+	_, err = ml.Accept()
+	if !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected net.ErrClosed, got %v", err)
+	}
+}
+
+func TestHvSockListenContainerPassthru(t *testing.T) {
+	u := newUtil(t)
+	addr := randHvsockAddr()
+	l, err := (&HvsockListenConfig{ContainerPassthru: true}).Listen(addr)
+	u.Must(err, "could not listen with container passthru")
+	defer l.Close()
+
+	ch := u.Go(func() error {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	cl, err := Dial(ctx, addr)
+	u.Must(err, "could not dial "+addr.String())
+	u.WaitErr(ch, time.Second, "accept did not complete")
+	cl.Close()
+}
+
+func TestIsHvsockSupported(t *testing.T) {
+	c, err := IsHvsockSupported()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", *c)
+	if c.Supported && !c.ProviderInstalled {
+		t.Error("sockets are supported, but the provider is not reported as installed")
+	}
+	if c.Nested != (c.InsideVM && c.Host) {
+		t.Error("nested must be reported exactly for guests that are hosts")
+	}
+}
@@ -5,7 +5,7 @@ package fs
 import (
 	"golang.org/x/sys/windows"
 
-	"github.com/Microsoft/go-winio/internal/stringbuffer"
+	"github.com/Microsoft/go-winio/pkg/stringbuffer"
 )
 
 //go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go fs.go
@@ -108,6 +108,22 @@ const (
 	STANDARD_RIGHTS_ALL AccessMask = 0x001F_0000
 )
 
+// MapGenericFileRights maps the generic rights in m to the specific rights for files and
+// directories, as is done when a file is opened or an ACE is checked.
+func MapGenericFileRights(m AccessMask) AccessMask {
+	for _, x := range []struct{ generic, specific AccessMask }{
+		{GENERIC_READ, FILE_GENERIC_READ},
+		{GENERIC_WRITE, FILE_GENERIC_WRITE},
+		{GENERIC_EXECUTE, FILE_GENERIC_EXECUTE},
+		{GENERIC_ALL, FILE_ALL_ACCESS},
+	} {
+		if m&x.generic != 0 {
+			m = m&^x.generic | x.specific
+		}
+	}
+	return m
+}
+
 type FileShareMode uint32
 
 //nolint:revive // SNAKE_CASE is not idiomatic in Go, but aligned with Win32 API.
@@ -201,6 +217,12 @@ const (
 	FILE_OPEN_BY_FILE_ID        NTCreateOptions = 0x0000_2000
 	FILE_OPEN_FOR_BACKUP_INTENT NTCreateOptions = 0x0000_4000
 	FILE_NO_COMPRESSION         NTCreateOptions = 0x0000_8000
+
+	FILE_OPEN_REQUIRING_OPLOCK     NTCreateOptions = 0x0001_0000
+	FILE_RESERVE_OPFILTER          NTCreateOptions = 0x0010_0000
+	FILE_OPEN_REPARSE_POINT        NTCreateOptions = 0x0020_0000
+	FILE_OPEN_NO_RECALL            NTCreateOptions = 0x0040_0000
+	FILE_OPEN_FOR_FREE_SPACE_QUERY NTCreateOptions = 0x0080_0000
 )
 
 type FileSQSFlag = FileFlagOrAttribute
@@ -242,21 +264,9 @@ const (
 //
 // https://learn.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-getfinalpathnamebyhandlew
 func GetFinalPathNameByHandle(h windows.Handle, flags GetFinalPathFlag) (string, error) {
-	b := stringbuffer.NewWString()
-	//TODO: can loop infinitely if Win32 keeps returning the same (or a larger) n?
-	for {
-		n, err := windows.GetFinalPathNameByHandle(h, b.Pointer(), b.Cap(), uint32(flags))
-		if err != nil {
-			return "", err
-		}
-		// If the buffer wasn't large enough, n will be the total size needed (including null terminator).
-		// Resize and try again.
-		if n > b.Cap() {
-			b.ResizeTo(n)
-			continue
-		}
-		// If the buffer is large enough, n will be the size not including the null terminator.
-		// Convert to a Go string and return.
-		return b.String(), nil
-	}
+	return stringbuffer.Fill(func(p *uint16, n uint32) (uint32, error) {
+		// If the buffer isn't large enough, the returned size is the total size needed
+		// (including the null terminator); otherwise, it is the length of the path.
+		return windows.GetFinalPathNameByHandle(h, p, n, uint32(flags))
+	})
 }
@@ -40,3 +40,20 @@ func Test_GetFinalPathNameByHandle(t *testing.T) {
 		t.Fatalf("expected %s, got %s", fullPath, path)
 	}
 }
+
+func Test_MapGenericFileRights(t *testing.T) {
+	for _, tt := range []struct {
+		in, want AccessMask
+	}{
+		{0, 0},
+		{FILE_READ_DATA | DELETE, FILE_READ_DATA | DELETE},
+		{GENERIC_READ, FILE_GENERIC_READ},
+		{GENERIC_READ | GENERIC_WRITE, FILE_GENERIC_READ | FILE_GENERIC_WRITE},
+		{GENERIC_EXECUTE | DELETE, FILE_GENERIC_EXECUTE | DELETE},
+		{GENERIC_ALL, FILE_ALL_ACCESS},
+	} {
+		if got := MapGenericFileRights(tt.in); got != tt.want {
+			t.Errorf("MapGenericFileRights(%#x) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+//go:build windows
+
+package fs
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// NtCreateFileParams specifies how NtCreateFile opens or creates a file.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/winternl/nf-winternl-ntcreatefile
+type NtCreateFileParams struct {
+	// Root is an optional handle to the directory that the path is relative to.
+	// If it is [NullHandle], the path must be an absolute Win32 (DOS) path, and is converted
+	// to an NT path.
+	Root windows.Handle
+
+	Access      AccessMask
+	Share       FileShareMode
+	Disposition NTFileCreationDisposition
+	Options     NTCreateOptions
+
+	// Attributes are the file attributes used if the file is created.
+	Attributes uint32
+
+	// CaseSensitive looks up the path case-sensitively, rather than the default
+	// case-insensitive lookup. This only has an effect in case-sensitive directories.
+	CaseSensitive bool
+
+	// SecurityDescriptor is the optional self-relative security descriptor applied
+	// if the file is created.
+	SecurityDescriptor []byte
+
+	// EA is an optional extended attribute buffer, encoded as a series of
+	// FILE_FULL_EA_INFORMATION structures, which is applied if the file is created.
+	// This allows creating a file with EAs (such as LxFs metadata) atomically.
+	EA []byte
+}
+
+// NtCreateFile opens or creates the file at path, as specified by p.
+//
+// Unlike [CreateFile], path can be relative to an open directory handle, and extended
+// attributes can be set when the file is created. Errors are converted from NTSTATUS
+// values to Win32 errors, so they can be compared against os.ErrNotExist and the like.
+func NtCreateFile(path string, p *NtCreateFileParams) (windows.Handle, error) {
+	var (
+		name *windows.NTUnicodeString
+		err  error
+	)
+	if p.Root == NullHandle {
+		var ntPath windows.NTUnicodeString
+		path16, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			return NullHandle, &os.PathError{Op: "NtCreateFile", Path: path, Err: err}
+		}
+		if err := windows.RtlDosPathNameToNtPathName(path16, &ntPath, nil, nil); err != nil {
+			return NullHandle, &os.PathError{Op: "NtCreateFile", Path: path, Err: ntStatusErr(err)}
+		}
+		defer windows.LocalFree(windows.Handle(unsafe.Pointer(ntPath.Buffer))) //nolint:errcheck
+		name = &ntPath
+	} else if name, err = windows.NewNTUnicodeString(path); err != nil {
+		return NullHandle, &os.PathError{Op: "NtCreateFile", Path: path, Err: err}
+	}
+
+	oa := windows.OBJECT_ATTRIBUTES{
+		RootDirectory: p.Root,
+		ObjectName:    name,
+	}
+	oa.Length = uint32(unsafe.Sizeof(oa))
+	if !p.CaseSensitive {
+		oa.Attributes |= windows.OBJ_CASE_INSENSITIVE
+	}
+	if len(p.SecurityDescriptor) > 0 {
+		oa.SecurityDescriptor = (*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&p.SecurityDescriptor[0]))
+	}
+
+	var ea uintptr
+	if len(p.EA) > 0 {
+		ea = uintptr(unsafe.Pointer(&p.EA[0]))
+	}
+
+	var (
+		h    windows.Handle
+		iosb windows.IO_STATUS_BLOCK
+	)
+	err = windows.NtCreateFile(&h,
+		uint32(p.Access),
+		&oa,
+		&iosb,
+		nil, // allocation size
+		p.Attributes,
+		uint32(p.Share),
+		uint32(p.Disposition),
+		uint32(p.Options),
+		ea,
+		uint32(len(p.EA)),
+	)
+	runtime.KeepAlive(p)
+	runtime.KeepAlive(name)
+	if err != nil {
+		return NullHandle, &os.PathError{Op: "NtCreateFile", Path: path, Err: ntStatusErr(err)}
+	}
+	return h, nil
+}
+
+// ntStatusErr converts an NTSTATUS error into the equivalent Win32 error.
+func ntStatusErr(err error) error {
+	if st, ok := err.(windows.NTStatus); ok { //nolint:errorlint // err is NTStatus
+		return st.Errno()
+	}
+	return err
+}
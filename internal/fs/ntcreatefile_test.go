@@ -0,0 +1,92 @@
+//go:build windows
+
+package fs
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+// encodeEA encodes a single FILE_FULL_EA_INFORMATION structure.
+func encodeEA(name string, value []byte) []byte {
+	b := make([]byte, 8, 8+len(name)+1+len(value))
+	b[5] = uint8(len(name))
+	binary.LittleEndian.PutUint16(b[6:], uint16(len(value)))
+	b = append(b, name...)
+	b = append(b, 0)
+	return append(b, value...)
+}
+
+func TestNtCreateFileRelative(t *testing.T) {
+	d := t.TempDir()
+
+	root, err := CreateFile(d,
+		FILE_LIST_DIRECTORY|FILE_TRAVERSE|SYNCHRONIZE,
+		FILE_SHARE_READ|FILE_SHARE_WRITE|FILE_SHARE_DELETE,
+		nil,
+		OPEN_EXISTING,
+		FILE_FLAG_BACKUP_SEMANTICS,
+		NullHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(root) //nolint:errcheck
+
+	h, err := NtCreateFile("child.txt", &NtCreateFileParams{
+		Root:        root,
+		Access:      GENERIC_READ | GENERIC_WRITE | SYNCHRONIZE,
+		Disposition: FILE_CREATE,
+		Options:     FILE_NON_DIRECTORY_FILE | FILE_SYNCHRONOUS_IO_NONALERT,
+		Attributes:  windows.FILE_ATTRIBUTE_NORMAL,
+		EA:          encodeEA("TEST", []byte("value")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows.CloseHandle(h) //nolint:errcheck
+
+	if _, err := os.Stat(filepath.Join(d, "child.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NtCreateFile("child.txt", &NtCreateFileParams{
+		Root:        root,
+		Access:      GENERIC_READ | SYNCHRONIZE,
+		Disposition: FILE_CREATE,
+		Options:     FILE_NON_DIRECTORY_FILE,
+	})
+	if !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected %v, got %v", os.ErrExist, err)
+	}
+}
+
+func TestNtCreateFileAbsolute(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NtCreateFile(p, &NtCreateFileParams{
+		Access:      GENERIC_READ | SYNCHRONIZE,
+		Share:       FILE_SHARE_READ,
+		Disposition: FILE_OPEN,
+		Options:     FILE_NON_DIRECTORY_FILE | FILE_SYNCHRONOUS_IO_NONALERT,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows.CloseHandle(h) //nolint:errcheck
+
+	_, err = NtCreateFile(p+".missing", &NtCreateFileParams{
+		Access:      GENERIC_READ | SYNCHRONIZE,
+		Disposition: FILE_OPEN,
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
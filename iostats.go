@@ -0,0 +1,168 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// IOOp is the kind of IO operation reported in an [IOSpan].
+type IOOp int
+
+const (
+	IORead IOOp = iota
+	IOWrite
+)
+
+func (op IOOp) String() string {
+	if op == IORead {
+		return "read"
+	}
+	return "write"
+}
+
+// IOStats is a snapshot of the IO statistics of a pipe or Hyper-V socket connection.
+//
+// Blocked durations are the total time spent in Read or Write calls, including time spent
+// waiting for pending IO to complete.
+type IOStats struct {
+	ReadBytes    uint64
+	ReadOps      uint64
+	ReadErrors   uint64
+	ReadBlocked  time.Duration
+	WriteBytes   uint64
+	WriteOps     uint64
+	WriteErrors  uint64
+	WriteBlocked time.Duration
+}
+
+// IOStatsReporter is implemented by the pipes and Hyper-V socket connections returned by
+// this package.
+type IOStatsReporter interface {
+	// IOStats returns a snapshot of the connection's IO statistics, and false if statistics
+	// were not enabled when the connection was created.
+	IOStats() (IOStats, bool)
+}
+
+var ioStatsEnabled atomicBool
+
+// EnableIOStats enables or disables collecting IO statistics for pipes and Hyper-V socket
+// connections created afterwards. Statistics are disabled by default.
+//
+// Use [IOStatsReporter] to retrieve the statistics for a connection.
+func EnableIOStats(enable bool) {
+	if enable {
+		ioStatsEnabled.setTrue()
+	} else {
+		ioStatsEnabled.setFalse()
+	}
+}
+
+// ioStats holds the counters for a win32File. It is allocated separately so that the
+// 64-bit counters are aligned on 32-bit platforms.
+type ioStats struct {
+	ops     [2]uint64
+	bytes   [2]uint64
+	errors  [2]uint64
+	blocked [2]int64
+}
+
+func (s *ioStats) record(op IOOp, n int, d time.Duration, err error) {
+	atomic.AddUint64(&s.ops[op], 1)
+	atomic.AddUint64(&s.bytes[op], uint64(n))
+	atomic.AddInt64(&s.blocked[op], int64(d))
+	if err != nil {
+		atomic.AddUint64(&s.errors[op], 1)
+	}
+}
+
+func (s *ioStats) snapshot() IOStats {
+	return IOStats{
+		ReadBytes:    atomic.LoadUint64(&s.bytes[IORead]),
+		ReadOps:      atomic.LoadUint64(&s.ops[IORead]),
+		ReadErrors:   atomic.LoadUint64(&s.errors[IORead]),
+		ReadBlocked:  time.Duration(atomic.LoadInt64(&s.blocked[IORead])),
+		WriteBytes:   atomic.LoadUint64(&s.bytes[IOWrite]),
+		WriteOps:     atomic.LoadUint64(&s.ops[IOWrite]),
+		WriteErrors:  atomic.LoadUint64(&s.errors[IOWrite]),
+		WriteBlocked: time.Duration(atomic.LoadInt64(&s.blocked[IOWrite])),
+	}
+}
+
+// IOSpan describes a completed Read or Write call on a pipe or Hyper-V socket connection.
+type IOSpan struct {
+	Op     IOOp
+	Handle windows.Handle
+	Start  time.Time
+	// Duration is the time spent in the call, including waiting for pending IO.
+	Duration time.Duration
+	Bytes    int
+	// Err is the error returned by the call, if any. io.EOF is not reported.
+	Err error
+}
+
+// IOTracer receives a span for every Read and Write call on pipes and Hyper-V socket
+// connections. The NewIOTracer function of the github.com/Microsoft/go-winio/pkg/etw package
+// returns a tracer that writes the spans as ETW events.
+//
+// TraceIO is called synchronously at the end of each call, and must not block.
+type IOTracer interface {
+	TraceIO(span IOSpan)
+}
+
+// ioTracerHolder allows storing tracers of different types in an atomic.Value.
+type ioTracerHolder struct{ t IOTracer }
+
+var ioTracer atomic.Value // ioTracerHolder
+
+// SetIOTracer sets the tracer for all pipe and Hyper-V socket IO, replacing any previous
+// tracer. A nil tracer disables tracing.
+func SetIOTracer(t IOTracer) {
+	ioTracer.Store(ioTracerHolder{t})
+}
+
+func getIOTracer() IOTracer {
+	h, _ := ioTracer.Load().(ioTracerHolder)
+	return h.t
+}
+
+// ioTracking returns true if IO on f is being counted or traced, in which case the caller
+// should time the operation and call f.recordIO.
+func (f *win32File) ioTracking() bool {
+	return f.stats != nil || getIOTracer() != nil
+}
+
+// recordIO updates f's statistics and reports a span to the IO tracer, if any.
+func (f *win32File) recordIO(op IOOp, start time.Time, n int, err error) {
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	d := time.Since(start)
+	if f.stats != nil {
+		f.stats.record(op, n, d, err)
+	}
+	if t := getIOTracer(); t != nil {
+		t.TraceIO(IOSpan{
+			Op:       op,
+			Handle:   f.handle,
+			Start:    start,
+			Duration: d,
+			Bytes:    n,
+			Err:      err,
+		})
+	}
+}
+
+// IOStats returns a snapshot of the IO statistics for f.
+func (f *win32File) IOStats() (IOStats, bool) {
+	if f.stats == nil {
+		return IOStats{}, false
+	}
+	return f.stats.snapshot(), true
+}
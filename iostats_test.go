@@ -0,0 +1,107 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"io"
+	"sync"
+	"testing"
+)
+
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []IOSpan
+}
+
+func (r *recordingTracer) TraceIO(s IOSpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func TestIOStats(t *testing.T) {
+	EnableIOStats(true)
+	defer EnableIOStats(false)
+
+	client, server, err := getConnection(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(server, b); err != nil {
+		t.Fatal(err)
+	}
+
+	cs, ok := client.(IOStatsReporter).IOStats()
+	if !ok {
+		t.Fatal("client stats not enabled")
+	}
+	if cs.WriteOps != 1 || cs.WriteBytes != 5 || cs.WriteErrors != 0 || cs.ReadOps != 0 {
+		t.Fatalf("unexpected client stats %+v", cs)
+	}
+	ss, ok := server.(IOStatsReporter).IOStats()
+	if !ok {
+		t.Fatal("server stats not enabled")
+	}
+	if ss.ReadOps == 0 || ss.ReadBytes != 5 || ss.WriteOps != 0 {
+		t.Fatalf("unexpected server stats %+v", ss)
+	}
+}
+
+func TestIOStatsDisabled(t *testing.T) {
+	client, server, err := getConnection(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	if _, ok := client.(IOStatsReporter).IOStats(); ok {
+		t.Fatal("stats enabled by default")
+	}
+}
+
+func TestIOTracer(t *testing.T) {
+	r := &recordingTracer{}
+	SetIOTracer(r)
+	defer SetIOTracer(nil)
+
+	client, server, err := getConnection(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	defer server.Close()
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 5)
+	if _, err := io.ReadFull(server, b); err != nil {
+		t.Fatal(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var read, written int
+	for _, s := range r.spans {
+		if s.Err != nil {
+			t.Errorf("unexpected error in span %+v", s)
+		}
+		if s.Op == IORead {
+			read += s.Bytes
+		} else {
+			written += s.Bytes
+		}
+	}
+	if read != 5 || written != 5 {
+		t.Fatalf("traced %d bytes read and %d bytes written, want 5 each", read, written)
+	}
+}
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"golang.org/x/sys/windows"
+)
+
+// NTFS object ID control codes
+// https://learn.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_get_object_id
+const (
+	fsctlSetObjectID    = 0x00090098 // FSCTL_SET_OBJECT_ID
+	fsctlGetObjectID    = 0x0009009c // FSCTL_GET_OBJECT_ID
+	fsctlDeleteObjectID = 0x000900a0 // FSCTL_DELETE_OBJECT_ID
+)
+
+var errInvalidObjectID = errors.New("invalid object ID buffer")
+
+// FileObjectID is the NTFS object ID of a file, with the extended information used by the
+// distributed link tracking service. It has the layout of FILE_OBJECTID_BUFFER, which is
+// also the contents of the [BackupObjectId] backup stream.
+type FileObjectID struct {
+	ObjectID      guid.GUID // unique on the file's volume
+	BirthVolumeID guid.GUID // the volume the file was created on
+	BirthObjectID guid.GUID // the object ID the file was created with
+	DomainID      guid.GUID // reserved, and zero
+}
+
+// GetFileObjectID returns the object ID of a file. It returns an error satisfying
+// errors.Is(err, os.ErrNotExist) if the file does not have an object ID.
+func GetFileObjectID(f *os.File) (*FileObjectID, error) {
+	id := &FileObjectID{}
+	var n uint32
+	if err := windows.DeviceIoControl(
+		windows.Handle(f.Fd()),
+		fsctlGetObjectID,
+		nil,
+		0,
+		(*byte)(unsafe.Pointer(id)),
+		uint32(unsafe.Sizeof(*id)),
+		&n,
+		nil,
+	); err != nil {
+		return nil, &os.PathError{Op: "FSCTL_GET_OBJECT_ID", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return id, nil
+}
+
+// SetFileObjectID sets the object ID of a file, which must not already have one. The object
+// ID must be unique on the volume. This requires [SeRestorePrivilege] to be enabled (see
+// [RunWithPrivilege]).
+func SetFileObjectID(f *os.File, id *FileObjectID) error {
+	idCopy := *id
+	var n uint32
+	if err := windows.DeviceIoControl(
+		windows.Handle(f.Fd()),
+		fsctlSetObjectID,
+		(*byte)(unsafe.Pointer(&idCopy)),
+		uint32(unsafe.Sizeof(idCopy)),
+		nil,
+		0,
+		&n,
+		nil,
+	); err != nil {
+		return &os.PathError{Op: "FSCTL_SET_OBJECT_ID", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// DeleteFileObjectID removes the object ID of a file, which must have been opened for
+// writing. Links to the file that are tracked by its object ID are broken.
+func DeleteFileObjectID(f *os.File) error {
+	var n uint32
+	if err := windows.DeviceIoControl(
+		windows.Handle(f.Fd()),
+		fsctlDeleteObjectID,
+		nil,
+		0,
+		nil,
+		0,
+		&n,
+		nil,
+	); err != nil {
+		return &os.PathError{Op: "FSCTL_DELETE_OBJECT_ID", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// DecodeFileObjectID decodes the contents of a [BackupObjectId] backup stream.
+func DecodeFileObjectID(b []byte) (*FileObjectID, error) {
+	id := &FileObjectID{}
+	if len(b) != binary.Size(id) {
+		return nil, errInvalidObjectID
+	}
+	if err := binary.Read(bytes.NewReader(b), binary.LittleEndian, id); err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// EncodeFileObjectID encodes id as the contents of a [BackupObjectId] backup stream.
+func EncodeFileObjectID(id *FileObjectID) []byte {
+	var buf bytes.Buffer
+	_ = binary.Write(&buf, binary.LittleEndian, id)
+	return buf.Bytes()
+}
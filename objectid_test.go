@@ -0,0 +1,89 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func newObjectID(t *testing.T) *FileObjectID {
+	t.Helper()
+	var id FileObjectID
+	for _, g := range []*guid.GUID{&id.ObjectID, &id.BirthVolumeID, &id.BirthObjectID} {
+		var err error
+		if *g, err = guid.NewV4(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &id
+}
+
+func TestEncodeFileObjectID(t *testing.T) {
+	id := newObjectID(t)
+	b := EncodeFileObjectID(id)
+	if len(b) != 64 {
+		t.Fatalf("expected 64 bytes, got %d", len(b))
+	}
+	id2, err := DecodeFileObjectID(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *id2 != *id {
+		t.Fatalf("got %+v, want %+v", id2, id)
+	}
+	if _, err := DecodeFileObjectID(b[:63]); err == nil {
+		t.Fatal("expected error decoding short buffer")
+	}
+}
+
+func TestGetFileObjectIDNotExist(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := GetFileObjectID(f); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error, got %v", err)
+	}
+}
+
+func TestSetFileObjectID(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	id := newObjectID(t)
+	err = RunWithPrivilege(SeRestorePrivilege, func() error {
+		return SetFileObjectID(f, id)
+	})
+	var perr *PrivilegeError
+	if errors.As(err, &perr) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := GetFileObjectID(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *id {
+		t.Fatalf("got %+v, want %+v", got, id)
+	}
+
+	if err := DeleteFileObjectID(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetFileObjectID(f); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not exist error after delete, got %v", err)
+	}
+}
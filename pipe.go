@@ -11,6 +11,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 	"unsafe"
 
@@ -28,6 +29,7 @@ import (
 //sys rtlNtStatusToDosError(status ntStatus) (winerr error) = ntdll.RtlNtStatusToDosErrorNoTeb
 //sys rtlDosPathNameToNtPathName(name *uint16, ntName *unicodeString, filePart uintptr, reserved uintptr) (status ntStatus) = ntdll.RtlDosPathNameToNtPathName_U
 //sys rtlDefaultNpAcl(dacl *uintptr) (status ntStatus) = ntdll.RtlDefaultNpAcl
+//sys waitNamedPipe(name string, timeout uint32) (err error) = WaitNamedPipeW
 
 type PipeConn interface {
 	net.Conn
@@ -35,6 +37,32 @@ type PipeConn interface {
 	Flush() error
 }
 
+// PipeListener is implemented by the listeners returned by [ListenPipe].
+type PipeListener interface {
+	net.Listener
+	// TryAccept returns the next connection if a client has already connected, and
+	// [ErrNoPendingConnection] otherwise, without blocking.
+	TryAccept() (net.Conn, error)
+	// SetDeadline sets the deadline for Accept: once it passes, pending and future calls to
+	// Accept return [ErrTimeout] until a new deadline is set. A zero value disables the
+	// deadline. The listener is not closed, and keeps waiting for clients in the background.
+	SetDeadline(t time.Time) error
+	// Conns returns the accepted connections that have not been closed or disconnected.
+	// It returns nil unless [PipeConfig.TrackConnections] is set.
+	Conns() []PipeConn
+	// Broadcast writes b to every connection returned by Conns, concurrently. Connections
+	// that fail to be written to are closed, as are connections that have not been written
+	// to once ctx is done, such as those whose clients have stopped reading.
+	Broadcast(ctx context.Context, b []byte) error
+	// Resize changes the number of pipe instances kept for clients to connect to (see
+	// [PipeConfig.QueueSize]). Shrinking the queue closes instances that no client has
+	// connected to, but keeps connected clients until they are accepted.
+	Resize(n int) error
+	// QueueStats returns the state of the accept queue, and counters of accepted clients and
+	// of the times the queue was saturated.
+	QueueStats() PipeQueueStats
+}
+
 // type aliases for mkwinsyscall code
 type (
 	ntAccessMask              = fs.AccessMask
@@ -63,7 +91,22 @@ type objectAttributes struct {
 	ObjectName         *unicodeString
 	Attributes         uintptr
 	SecurityDescriptor *securityDescriptor
-	SecurityQoS        uintptr
+	SecurityQoS        *securityQoS
+}
+
+//	typedef struct _SECURITY_QUALITY_OF_SERVICE {
+//	  DWORD                          Length;
+//	  SECURITY_IMPERSONATION_LEVEL   ImpersonationLevel;
+//	  SECURITY_CONTEXT_TRACKING_MODE ContextTrackingMode;
+//	  BOOLEAN                        EffectiveOnly;
+//	} SECURITY_QUALITY_OF_SERVICE;
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/winnt/ns-winnt-security_quality_of_service
+type securityQoS struct {
+	Length              uint32
+	ImpersonationLevel  fs.SecurityImpersonationLevel
+	ContextTrackingMode uint8
+	EffectiveOnly       uint8
 }
 
 type unicodeString struct {
@@ -104,14 +147,21 @@ func (status ntStatus) Err() error {
 
 var (
 	// ErrPipeListenerClosed is returned for pipe operations on listeners that have been closed.
+	// See also [ErrListenerClosed].
 	ErrPipeListenerClosed = net.ErrClosed
 
+	// ErrNoPendingConnection is returned by [PipeListener.TryAccept] when no client is
+	// waiting to be accepted.
+	ErrNoPendingConnection = errors.New("no pending pipe connection")
+
 	errPipeWriteClosed = errors.New("pipe has been closed for write")
 )
 
 type win32Pipe struct {
 	*win32File
 	path string
+
+	untrack func() // removes the pipe from its listener's connection registry, if set
 }
 
 var _ PipeConn = (*win32Pipe)(nil)
@@ -120,6 +170,13 @@ type win32MessageBytePipe struct {
 	win32Pipe
 	writeClosed bool
 	readEOF     bool
+
+	// disconnectOnCloseWrite makes CloseWrite disconnect the pipe (see
+	// [PipeConfig.CompatNonWinioClients]) rather than write a zero-byte message.
+	disconnectOnCloseWrite bool
+
+	readModeOnce sync.Once // sets the message read mode, for ReadMessage
+	readModeErr  error
 }
 
 type pipeAddress string
@@ -140,7 +197,19 @@ func (f *win32Pipe) SetDeadline(t time.Time) error {
 }
 
 func (f *win32Pipe) Disconnect() error {
-	return disconnectNamedPipe(f.win32File.handle)
+	err := disconnectNamedPipe(f.win32File.handle)
+	if f.untrack != nil {
+		f.untrack()
+	}
+	return err
+}
+
+func (f *win32Pipe) Close() error {
+	err := f.win32File.Close()
+	if f.untrack != nil {
+		f.untrack()
+	}
+	return err
 }
 
 // CloseWrite closes the write side of a message pipe in byte mode.
@@ -148,6 +217,13 @@ func (f *win32MessageBytePipe) CloseWrite() error {
 	if f.writeClosed {
 		return errPipeWriteClosed
 	}
+	if f.disconnectOnCloseWrite {
+		if err := f.flushAndDisconnect(); err != nil {
+			return err
+		}
+		f.writeClosed = true
+		return nil
+	}
 	err := f.win32File.Flush()
 	if err != nil {
 		return err
@@ -160,6 +236,53 @@ func (f *win32MessageBytePipe) CloseWrite() error {
 	return nil
 }
 
+// win32CompatPipe is the server end of a byte-mode pipe accepted by a listener with
+// [PipeConfig.CompatNonWinioClients] set, which supports CloseWrite by disconnecting the pipe.
+type win32CompatPipe struct {
+	win32Pipe
+	writeClosed bool
+}
+
+// CloseWrite waits for the client to read the data written to the pipe, and then disconnects
+// it, so the client reads EOF. The pipe cannot be read from afterwards.
+func (f *win32CompatPipe) CloseWrite() error {
+	if f.writeClosed {
+		return errPipeWriteClosed
+	}
+	if err := f.flushAndDisconnect(); err != nil {
+		return err
+	}
+	f.writeClosed = true
+	return nil
+}
+
+// Write writes bytes to the pipe, failing once CloseWrite has been called.
+func (f *win32CompatPipe) Write(b []byte) (int, error) {
+	if f.writeClosed {
+		return 0, errPipeWriteClosed
+	}
+	return f.win32File.Write(b)
+}
+
+// Read reads bytes from the pipe, returning io.EOF once the pipe has been disconnected.
+func (f *win32CompatPipe) Read(b []byte) (int, error) {
+	n, err := f.win32File.Read(b)
+	if err == windows.ERROR_PIPE_NOT_CONNECTED { //nolint:errorlint // err is Errno
+		err = io.EOF
+	}
+	return n, err
+}
+
+// flushAndDisconnect waits until the client has read all the data written to the pipe (with
+// FlushFileBuffers), and then disconnects the pipe, which non-go-winio clients (such as those
+// built on libuv) read as the end of the stream.
+func (f *win32Pipe) flushAndDisconnect() error {
+	if err := f.win32File.Flush(); err != nil {
+		return err
+	}
+	return f.Disconnect()
+}
+
 // Write writes bytes to a message pipe in byte mode. Zero-byte writes are ignored, since
 // they are used to implement CloseWrite().
 func (f *win32MessageBytePipe) Write(b []byte) (int, error) {
@@ -179,6 +302,10 @@ func (f *win32MessageBytePipe) Read(b []byte) (int, error) {
 		return 0, io.EOF
 	}
 	n, err := f.win32File.Read(b)
+	if err == windows.ERROR_PIPE_NOT_CONNECTED && f.disconnectOnCloseWrite { //nolint:errorlint // err is Errno
+		// the pipe was disconnected by CloseWrite
+		err = io.EOF
+	}
 	if err == io.EOF { //nolint:errorlint
 		// If this was the result of a zero-byte read, then
 		// it is possible that the read was due to a zero-size
@@ -203,30 +330,64 @@ func (s pipeAddress) String() string {
 	return string(s)
 }
 
-// tryDialPipe attempts to dial the pipe at `path` until `ctx` cancellation or timeout.
-func tryDialPipe(ctx context.Context, path *string, access fs.AccessMask, impLevel PipeImpLevel) (windows.Handle, error) {
-	for {
+// tryDialPipe attempts to dial the pipe at `path` until `ctx` cancellation or timeout,
+// retrying while the pipe is busy according to the retry policy `rp`.
+func tryDialPipe(ctx context.Context, path *string, access fs.AccessMask, impLevel PipeImpLevel, rp *RetryPolicy) (windows.Handle, error) {
+	var t *time.Timer
+	defer func() {
+		if t != nil {
+			t.Stop()
+		}
+	}()
+	backoff := rp.initial()
+	for attempts := 1; ; attempts++ {
 		select {
 		case <-ctx.Done():
-			return windows.Handle(0), ctx.Err()
+			return windows.Handle(0), classifyPipeError(ctx.Err())
 		default:
-			h, err := fs.CreateFile(*path,
-				access,
-				0,   // mode
-				nil, // security attributes
-				fs.OPEN_EXISTING,
-				fs.FILE_FLAG_OVERLAPPED|fs.SECURITY_SQOS_PRESENT|fs.FileSQSFlag(impLevel),
-				0, // template file handle
-			)
-			if err == nil {
-				return h, nil
+		}
+		h, err := fs.CreateFile(*path,
+			access,
+			0,   // mode
+			nil, // security attributes
+			fs.OPEN_EXISTING,
+			fs.FILE_FLAG_OVERLAPPED|fs.SECURITY_SQOS_PRESENT|fs.FileSQSFlag(impLevel),
+			0, // template file handle
+		)
+		if err == nil {
+			return h, nil
+		}
+		if err != windows.ERROR_PIPE_BUSY || rp.exhausted(attempts) { //nolint:errorlint // err is Errno
+			return h, &os.PathError{Err: classifyPipeError(err), Op: "open", Path: *path}
+		}
+
+		wait := rp.jitter(backoff)
+		backoff = rp.next(backoff)
+		if rp.UseWaitNamedPipe {
+			// WaitNamedPipe returns as soon as an instance is available, or fails after the
+			// timeout; either way, try to open the pipe again. It cannot be cancelled, so do
+			// not wait past the context's deadline.
+			if dl, ok := ctx.Deadline(); ok {
+				if rem := time.Until(dl); rem < wait {
+					wait = rem
+				}
 			}
-			if err != windows.ERROR_PIPE_BUSY { //nolint:errorlint // err is Errno
-				return h, &os.PathError{Err: err, Op: "open", Path: *path}
+			ms := uint32(wait.Milliseconds())
+			if ms == 0 {
+				ms = 1
 			}
-			// Wait 10 msec and try again. This is a rather simplistic
-			// view, as we always try each 10 milliseconds.
-			time.Sleep(10 * time.Millisecond)
+			_ = waitNamedPipe(*path, ms)
+			continue
+		}
+		if t == nil {
+			t = time.NewTimer(wait)
+		} else {
+			t.Reset(wait)
+		}
+		select {
+		case <-ctx.Done():
+			return windows.Handle(0), classifyPipeError(ctx.Err())
+		case <-t.C:
 		}
 	}
 }
@@ -245,15 +406,18 @@ func DialPipe(path string, timeout *time.Duration) (net.Conn, error) {
 	defer cancel()
 	conn, err := DialPipeContext(ctx, path)
 	if errors.Is(err, context.DeadlineExceeded) {
-		return nil, ErrTimeout
+		return nil, classifyPipeError(ErrTimeout)
 	}
 	return conn, err
 }
 
 // DialPipeContext attempts to connect to a named pipe by `path` until `ctx`
 // cancellation or timeout.
-func DialPipeContext(ctx context.Context, path string) (net.Conn, error) {
-	return DialPipeAccess(ctx, path, uint32(fs.GENERIC_READ|fs.GENERIC_WRITE))
+//
+// By default, dialing is retried every 10 milliseconds while the pipe is busy;
+// use [WithRetryPolicy] to configure this.
+func DialPipeContext(ctx context.Context, path string, opts ...DialOption) (net.Conn, error) {
+	return DialPipeAccess(ctx, path, uint32(fs.GENERIC_READ|fs.GENERIC_WRITE), opts...)
 }
 
 // PipeImpLevel is an enumeration of impersonation levels that may be set
@@ -269,23 +433,24 @@ const (
 
 // DialPipeAccess attempts to connect to a named pipe by `path` with `access` until `ctx`
 // cancellation or timeout.
-func DialPipeAccess(ctx context.Context, path string, access uint32) (net.Conn, error) {
-	return DialPipeAccessImpLevel(ctx, path, access, PipeImpLevelAnonymous)
+func DialPipeAccess(ctx context.Context, path string, access uint32, opts ...DialOption) (net.Conn, error) {
+	return DialPipeAccessImpLevel(ctx, path, access, PipeImpLevelAnonymous, opts...)
 }
 
 // DialPipeAccessImpLevel attempts to connect to a named pipe by `path` with
 // `access` at `impLevel` until `ctx` cancellation or timeout. The other
 // DialPipe* implementations use PipeImpLevelAnonymous.
-func DialPipeAccessImpLevel(ctx context.Context, path string, access uint32, impLevel PipeImpLevel) (net.Conn, error) {
+func DialPipeAccessImpLevel(ctx context.Context, path string, access uint32, impLevel PipeImpLevel, opts ...DialOption) (net.Conn, error) {
+	c := newDialConfig(opts)
 	var err error
 	var h windows.Handle
-	h, err = tryDialPipe(ctx, &path, fs.AccessMask(access), impLevel)
+	h, err = tryDialPipe(ctx, &path, fs.AccessMask(access), impLevel, &c.retry)
 	if err != nil {
 		return nil, err
 	}
 
-	var flags uint32
-	err = getNamedPipeInfo(h, &flags, nil, nil, nil)
+	var flags, inSize uint32
+	err = getNamedPipeInfo(h, &flags, nil, &inSize, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -295,10 +460,13 @@ func DialPipeAccessImpLevel(ctx context.Context, path string, access uint32, imp
 		windows.Close(h)
 		return nil, err
 	}
+	// the client's writes fill the server's input buffer
+	f.writeChunk = int(inSize)
 
 	// If the pipe is in message mode, return a message byte pipe, which
 	// supports CloseWrite().
 	if flags&windows.PIPE_TYPE_MESSAGE != 0 {
+		f.messageWrites = true
 		return &win32MessageBytePipe{
 			win32Pipe: win32Pipe{win32File: f, path: path},
 		}, nil
@@ -306,6 +474,11 @@ func DialPipeAccessImpLevel(ctx context.Context, path string, access uint32, imp
 	return &win32Pipe{win32File: f, path: path}, nil
 }
 
+type acceptRequest struct {
+	ch   chan acceptResponse
+	wait bool
+}
+
 type acceptResponse struct {
 	f   *win32File
 	err error
@@ -315,9 +488,16 @@ type win32PipeListener struct {
 	firstHandle windows.Handle
 	path        string
 	config      PipeConfig
-	acceptCh    chan (chan acceptResponse)
+	acceptCh    chan acceptRequest
+	tryAcceptCh chan acceptRequest // served even while an Accept call is waiting
 	closeCh     chan int
 	doneCh      chan int
+	deadline    deadlineHandler
+	resizeCh    chan resizeRequest
+	statsCh     chan chan PipeQueueStats
+
+	connsLock sync.Mutex
+	conns     map[*win32Pipe]PipeConn
 }
 
 func makeServerPipeHandle(path string, sd []byte, c *PipeConfig, first bool) (windows.Handle, error) {
@@ -330,16 +510,27 @@ func makeServerPipeHandle(path string, sd []byte, c *PipeConfig, first bool) (wi
 	oa.Length = unsafe.Sizeof(oa)
 
 	var ntPath unicodeString
-	if err := rtlDosPathNameToNtPathName(&path16[0],
-		&ntPath,
-		0,
-		0,
-	).Err(); err != nil {
-		return 0, &os.PathError{Op: "open", Path: path, Err: err}
+	if isNTPipePath(path) {
+		ntPath = unicodeString{
+			Length:        uint16((len(path16) - 1) * 2),
+			MaximumLength: uint16(len(path16) * 2),
+			Buffer:        uintptr(unsafe.Pointer(&path16[0])),
+		}
+	} else {
+		if err := rtlDosPathNameToNtPathName(&path16[0],
+			&ntPath,
+			0,
+			0,
+		).Err(); err != nil {
+			return 0, &os.PathError{Op: "open", Path: path, Err: err}
+		}
+		defer windows.LocalFree(windows.Handle(ntPath.Buffer)) //nolint:errcheck
 	}
-	defer windows.LocalFree(windows.Handle(ntPath.Buffer)) //nolint:errcheck
 	oa.ObjectName = &ntPath
 	oa.Attributes = windows.OBJ_CASE_INSENSITIVE
+	if c.SecurityQoS != nil {
+		oa.SecurityQoS = c.SecurityQoS.raw()
+	}
 
 	// The security descriptor is only needed for the first pipe.
 	if first {
@@ -406,10 +597,11 @@ func makeServerPipeHandle(path string, sd []byte, c *PipeConfig, first bool) (wi
 		uint32(c.OutputBufferSize),
 		&timeout).Err()
 	if err != nil {
-		return 0, &os.PathError{Op: "open", Path: path, Err: err}
+		return 0, &os.PathError{Op: "open", Path: path, Err: classifyPipeError(err)}
 	}
 
 	runtime.KeepAlive(ntPath)
+	runtime.KeepAlive(path16)
 	return h, nil
 }
 
@@ -426,59 +618,70 @@ func (l *win32PipeListener) makeServerPipe() (*win32File, error) {
 	return f, nil
 }
 
-func (l *win32PipeListener) makeConnectedServerPipe() (*win32File, error) {
-	p, err := l.makeServerPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	// Wait for the client to connect.
-	ch := make(chan error)
-	go func(p *win32File) {
-		ch <- connectPipe(p)
-	}(p)
-
-	select {
-	case err = <-ch:
-		if err != nil {
-			p.Close()
-			p = nil
+func (l *win32PipeListener) listenerRoutine() {
+	q := newAcceptQueue(l)
+	var (
+		waiter  *acceptRequest // an Accept call waiting for a client
+		timeout timeoutChan    // the deadline for waiter
+	)
+	serve := func(req acceptRequest) {
+		if waiter != nil {
+			// Any connection that is ready has already been returned to the waiting Accept
+			// call, which gets the next one too.
+			req.ch <- acceptResponse{nil, ErrNoPendingConnection}
+			return
 		}
-	case <-l.closeCh:
-		// Abort the connect request by closing the handle.
-		p.Close()
-		p = nil
-		err = <-ch
-		if err == nil || err == ErrFileClosed { //nolint:errorlint // err is Errno
-			err = ErrPipeListenerClosed
+		q.started = true
+		err := q.fill()
+		if pc := q.next(); pc != nil {
+			req.ch <- acceptResponse{pc.p, pc.err}
+		} else if err != nil && len(q.waiting) == 0 {
+			req.ch <- acceptResponse{nil, err}
+		} else if !req.wait {
+			req.ch <- acceptResponse{nil, ErrNoPendingConnection}
+		} else {
+			l.deadline.channelLock.RLock()
+			timeout = l.deadline.channel
+			l.deadline.channelLock.RUnlock()
+			waiter = &req
 		}
 	}
-	return p, err
-}
-
-func (l *win32PipeListener) listenerRoutine() {
 	closed := false
 	for !closed {
+		acceptCh := l.acceptCh
+		if waiter != nil {
+			// serve Accept calls one at a time
+			acceptCh = nil
+		}
 		select {
 		case <-l.closeCh:
 			closed = true
-		case responseCh := <-l.acceptCh:
-			var (
-				p   *win32File
-				err error
-			)
-			for {
-				p, err = l.makeConnectedServerPipe()
-				// If the connection was immediately closed by the client, try
-				// again.
-				if err != windows.ERROR_NO_DATA { //nolint:errorlint // err is Errno
-					break
+		case req := <-acceptCh:
+			serve(req)
+		case req := <-l.tryAcceptCh:
+			serve(req)
+		case pc := <-q.results:
+			q.completed(pc)
+			if waiter != nil {
+				if pc := q.next(); pc != nil {
+					waiter.ch <- acceptResponse{pc.p, pc.err}
+					waiter, timeout = nil, nil
 				}
 			}
-			responseCh <- acceptResponse{p, err}
-			closed = err == ErrPipeListenerClosed //nolint:errorlint // err is Errno
+		case <-timeout:
+			// Leave the pending connections in place for the next call.
+			waiter.ch <- acceptResponse{nil, ErrTimeout}
+			waiter, timeout = nil, nil
+		case req := <-l.resizeCh:
+			req.ch <- q.resize(req.size)
+		case ch := <-l.statsCh:
+			ch <- q.stats()
 		}
 	}
+	if waiter != nil {
+		waiter.ch <- acceptResponse{nil, ErrPipeListenerClosed}
+	}
+	q.close()
 	windows.Close(l.firstHandle)
 	l.firstHandle = 0
 	// Notify Close() and Accept() callers that the handle has been closed.
@@ -503,10 +706,132 @@ type PipeConfig struct {
 
 	// OutputBufferSize specifies the size of the output buffer, in bytes.
 	OutputBufferSize int32
+
+	// ExpectedMessageSize is the typical size, in bytes, of the messages written to the pipe.
+	// If it is set, and InputBufferSize and OutputBufferSize are both zero, the buffers are
+	// sized with [RecommendedPipeBufferSize] instead of using the system's default sizes,
+	// which perform poorly for large (64KB or more) messages.
+	ExpectedMessageSize int32
+
+	// FirstInstance makes [ListenPipe] report a failure to create the pipe because its name is
+	// already in use as [ErrPipeNameInUse], rather than as [ErrAccessDenied].
+	//
+	// ListenPipe always creates the pipe as its first instance (like
+	// FILE_FLAG_FIRST_PIPE_INSTANCE), and keeps that instance until the listener is closed, so
+	// another process cannot create instances of the pipe that would receive the listener's
+	// clients. Services that want to detect (and alert on) attempts to squat their pipe names
+	// should set FirstInstance, and treat ErrPipeNameInUse as a fatal error.
+	FirstInstance bool
+
+	// WriteBuffering coalesces writes to accepted connections in a buffer (of OutputBufferSize
+	// bytes, or 4096 if unset), reducing the number of syscalls made by protocols that send
+	// many small messages.
+	//
+	// Buffered data is written to the pipe as soon as the buffer is full, or when the
+	// connection is read from, flushed, or closed (including via CloseWrite). It is not written
+	// on a timer, so a server that writes a response and then waits for something other than
+	// the client's next request must call Flush for the client to receive it.
+	//
+	// WriteBuffering is ignored if MessageMode is set, since buffering would merge and split
+	// the messages written to the pipe.
+	WriteBuffering bool
+
+	// CompatNonWinioClients makes CloseWrite on accepted connections compatible with clients
+	// that do not use go-winio, such as Node.js (libuv) and dockerode: rather than writing a
+	// zero-byte message (which only go-winio reads as EOF, and only in message mode), CloseWrite
+	// waits for the client to read the pending data with FlushFileBuffers, and then calls
+	// DisconnectNamedPipe. Accepted byte-mode connections then support CloseWrite too.
+	//
+	// The connection cannot be read from after CloseWrite, since it is disconnected; reads
+	// return io.EOF, as they do when the client closes its end of the pipe (ERROR_BROKEN_PIPE).
+	CompatNonWinioClients bool
+
+	// TrackConnections keeps a registry of the accepted connections, until they are closed
+	// or disconnected, for use with [PipeListener.Conns] and [PipeListener.Broadcast].
+	TrackConnections bool
+
+	// OnConnect is called with each accepted connection, before it is returned by Accept or
+	// TryAccept. It is only used if TrackConnections is set.
+	OnConnect func(PipeConn)
+
+	// OnDisconnect is called once for each accepted connection, when it is closed or
+	// disconnected (including by a failed [PipeListener.Broadcast]). It is only used if
+	// TrackConnections is set.
+	OnDisconnect func(PipeConn)
+
+	// QueueSize is the number of pipe instances the listener keeps for clients to connect to,
+	// once Accept or TryAccept is first called. Clients that connect while the server is busy
+	// are queued in these instances until accepted; once all of them are connected, further
+	// clients fail with ERROR_PIPE_BUSY (and retry, see [RetryPolicy]) until one is accepted.
+	// It can be changed with [PipeListener.Resize]. If zero, there is a single instance.
+	QueueSize int
+
+	// SecurityQoS sets the security quality of service of the pipe's instances, which limits
+	// the client security context available to the server when impersonating the client (see
+	// [PipeClientImpersonator.RunAsClient]). If nil, the system defaults are used.
+	SecurityQoS *PipeSecurityQoS
+}
+
+// PipeSecurityQoS is the security quality of service of a named pipe server.
+type PipeSecurityQoS struct {
+	// ImpersonationLevel is the highest impersonation level of the client's token while
+	// impersonating. The default, [PipeImpLevelAnonymous], does not expose the client's
+	// identity.
+	ImpersonationLevel PipeImpLevel
+
+	// DynamicTracking uses the client's security context as it is when the server impersonates
+	// the client, rather than a snapshot of it taken when the client connected.
+	DynamicTracking bool
+
+	// EffectiveOnly only exposes the enabled privileges and groups of the client's token: they
+	// cannot be enabled while impersonating.
+	EffectiveOnly bool
+}
+
+func (q *PipeSecurityQoS) raw() *securityQoS {
+	r := &securityQoS{
+		// PipeImpLevel values are CreateFile SECURITY_* flags, which hold the level in the
+		// third byte
+		ImpersonationLevel: fs.SecurityImpersonationLevel(q.ImpersonationLevel >> 16),
+	}
+	r.Length = uint32(unsafe.Sizeof(*r))
+	if q.DynamicTracking {
+		r.ContextTrackingMode = 1 // SECURITY_DYNAMIC_TRACKING
+	}
+	if q.EffectiveOnly {
+		r.EffectiveOnly = 1
+	}
+	return r
+}
+
+// Bounds of the buffer sizes returned by [RecommendedPipeBufferSize].
+const (
+	minRecommendedPipeBufferSize = 4 << 10
+	maxRecommendedPipeBufferSize = 1 << 20
+)
+
+// RecommendedPipeBufferSize returns the input and output buffer size to use for a pipe that
+// mostly carries messages of messageSize bytes: large enough for a whole message to be written
+// without waiting for the reader, rounded up to a multiple of the page size, and clamped to
+// between 4KB and 1MB (buffers are charged against the process's nonpaged pool quota).
+// It returns 0, which selects the system's defaults, if messageSize is not positive.
+func RecommendedPipeBufferSize(messageSize int) int32 {
+	if messageSize <= 0 {
+		return 0
+	}
+	if messageSize >= maxRecommendedPipeBufferSize {
+		return maxRecommendedPipeBufferSize
+	}
+	size := (messageSize + minRecommendedPipeBufferSize - 1) &^ (minRecommendedPipeBufferSize - 1)
+	return int32(size)
 }
 
 // ListenPipe creates a listener on a Windows named pipe path, e.g. \\.\pipe\mypipe.
 // The pipe must not already exist.
+//
+// path may also be an NT object path, such as \Device\NamedPipe\mypipe (see [PipeNTPath]).
+//
+// The returned listener implements [PipeListener].
 func ListenPipe(path string, c *PipeConfig) (net.Listener, error) {
 	var (
 		sd  []byte
@@ -515,6 +840,12 @@ func ListenPipe(path string, c *PipeConfig) (net.Listener, error) {
 	if c == nil {
 		c = &PipeConfig{}
 	}
+	if c.InputBufferSize == 0 && c.OutputBufferSize == 0 && c.ExpectedMessageSize > 0 {
+		tuned := *c
+		tuned.InputBufferSize = RecommendedPipeBufferSize(int(c.ExpectedMessageSize))
+		tuned.OutputBufferSize = tuned.InputBufferSize
+		c = &tuned
+	}
 	if c.SecurityDescriptor != "" {
 		sd, err = SddlToSecurityDescriptor(c.SecurityDescriptor)
 		if err != nil {
@@ -523,20 +854,45 @@ func ListenPipe(path string, c *PipeConfig) (net.Listener, error) {
 	}
 	h, err := makeServerPipeHandle(path, sd, c, true)
 	if err != nil {
+		if c.FirstInstance && errors.Is(err, windows.ERROR_ACCESS_DENIED) && pipeExists(path) {
+			return nil, &os.PathError{Op: "open", Path: path, Err: &pipeError{kind: ErrPipeNameInUse, err: windows.ERROR_ACCESS_DENIED}}
+		}
 		return nil, err
 	}
 	l := &win32PipeListener{
 		firstHandle: h,
 		path:        path,
 		config:      *c,
-		acceptCh:    make(chan (chan acceptResponse)),
+		acceptCh:    make(chan acceptRequest),
+		tryAcceptCh: make(chan acceptRequest),
 		closeCh:     make(chan int),
 		doneCh:      make(chan int),
+		resizeCh:    make(chan resizeRequest),
+		statsCh:     make(chan chan PipeQueueStats),
 	}
+	l.deadline.channel = make(timeoutChan)
 	go l.listenerRoutine()
 	return l, nil
 }
 
+// pipeExists returns true if there is a pipe called path, without connecting to it.
+func pipeExists(path string) bool {
+	if isNTPipePath(path) {
+		path = `\\?\GLOBALROOT` + path
+	}
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return false
+	}
+	var fd windows.Win32finddata
+	h, err := windows.FindFirstFile(path16, &fd)
+	if err != nil {
+		return false
+	}
+	windows.FindClose(h) //nolint:errcheck
+	return true
+}
+
 func connectPipe(p *win32File) error {
 	c, err := p.prepareIO()
 	if err != nil {
@@ -553,25 +909,72 @@ func connectPipe(p *win32File) error {
 }
 
 func (l *win32PipeListener) Accept() (net.Conn, error) {
+	return l.accept(true)
+}
+
+// TryAccept returns the next connection to the listener if a client has already connected,
+// and [ErrNoPendingConnection] otherwise, without blocking. While another goroutine is waiting
+// in Accept, TryAccept always returns ErrNoPendingConnection, as the next client goes to Accept.
+//
+// The listener waits for clients in the background between calls, so a client that starts
+// connecting after TryAccept returns is returned by a subsequent call to TryAccept or Accept.
+func (l *win32PipeListener) TryAccept() (net.Conn, error) {
+	return l.accept(false)
+}
+
+func (l *win32PipeListener) accept(wait bool) (net.Conn, error) {
 	ch := make(chan acceptResponse)
+	reqCh := l.acceptCh
+	if !wait {
+		reqCh = l.tryAcceptCh
+	}
 	select {
-	case l.acceptCh <- ch:
+	case reqCh <- acceptRequest{ch: ch, wait: wait}:
 		response := <-ch
 		err := response.err
 		if err != nil {
 			return nil, err
 		}
+		var outSize uint32
+		if getNamedPipeInfo(response.f.handle, nil, &outSize, nil, nil) == nil {
+			response.f.writeChunk = int(outSize)
+		}
+		var (
+			conn PipeConn
+			p    *win32Pipe
+		)
 		if l.config.MessageMode {
-			return &win32MessageBytePipe{
-				win32Pipe: win32Pipe{win32File: response.f, path: l.path},
-			}, nil
+			response.f.messageWrites = true
+			mp := &win32MessageBytePipe{
+				win32Pipe:              win32Pipe{win32File: response.f, path: l.path},
+				disconnectOnCloseWrite: l.config.CompatNonWinioClients,
+			}
+			conn, p = mp, &mp.win32Pipe
+		} else if l.config.CompatNonWinioClients {
+			cp := &win32CompatPipe{win32Pipe: win32Pipe{win32File: response.f, path: l.path}}
+			conn, p = cp, &cp.win32Pipe
+		} else {
+			p = &win32Pipe{win32File: response.f, path: l.path}
+			conn = p
+		}
+		if l.config.WriteBuffering && !l.config.MessageMode {
+			conn = newBufferedPipe(conn, int(l.config.OutputBufferSize))
 		}
-		return &win32Pipe{win32File: response.f, path: l.path}, nil
+		if l.config.TrackConnections {
+			l.track(p, conn)
+		}
+		return conn, nil
 	case <-l.doneCh:
 		return nil, ErrPipeListenerClosed
 	}
 }
 
+// SetDeadline sets the deadline for Accept. Once it passes, Accept returns [ErrTimeout],
+// which satisfies net.Error with Timeout() true, without closing the listener.
+func (l *win32PipeListener) SetDeadline(t time.Time) error {
+	return l.deadline.set(t)
+}
+
 func (l *win32PipeListener) Close() error {
 	select {
 	case l.closeCh <- 1:
@@ -0,0 +1,86 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/internal/fs"
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// anonymousPipeBufferSize is the input and output buffer size of pipes created by
+// AnonymousPipe, which matches the default of CreatePipe.
+const anonymousPipeBufferSize = 4096
+
+// AnonymousPipe returns the two ends of a new, connected, bidirectional pipe: the server end,
+// which is kept by this process, and the client end, which is meant to be passed to a child
+// process.
+//
+// As with .NET's anonymous pipes (and CreatePipe), the pipe is a named pipe with a unique name
+// that only accepts one client, which is opened immediately, so that no other process can
+// connect to it. The server end uses overlapped IO, so, unlike the pipes returned by
+// [os.Pipe], it supports deadlines and concurrent reads and writes, and closing the client
+// end unblocks its pending reads with [io.EOF].
+//
+// The client end is opened for synchronous IO, as child processes generally expect of their
+// standard handles, and is returned as an [os.File]. It is not inheritable. To pass it to a
+// child process, set it as the Stdin, Stdout, or Stderr of an [os/exec.Cmd], which makes an
+// inheritable copy of it for the child; or make it inheritable with [SetHandleInheritable],
+// list it in the command's SysProcAttr.AdditionalInheritedHandles, and pass its value (from
+// Fd) to the child. The client end should be closed once the child has started, so that the
+// server end reads [io.EOF] when the child exits.
+func AnonymousPipe() (server net.Conn, client *os.File, err error) {
+	id, err := guid.NewV4()
+	if err != nil {
+		return nil, nil, err
+	}
+	path := fmt.Sprintf(`%swinio-anonymous-%d-%s`, pipePrefix, os.Getpid(), id)
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sh, err := windows.CreateNamedPipe(path16,
+		windows.PIPE_ACCESS_DUPLEX|windows.FILE_FLAG_FIRST_PIPE_INSTANCE|windows.FILE_FLAG_OVERLAPPED,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, // max instances
+		anonymousPipeBufferSize,
+		anonymousPipeBufferSize,
+		0,   // default timeout
+		nil, // security attributes
+	)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "CreateNamedPipe", Path: path, Err: err}
+	}
+	sf, err := makeWin32File(sh)
+	if err != nil {
+		windows.Close(sh)
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			sf.Close()
+		}
+	}()
+
+	// The pipe is connected once the client is opened, so ConnectNamedPipe is not needed. If
+	// another process connected first, the open fails with ERROR_PIPE_BUSY.
+	ch, err := fs.CreateFile(path,
+		fs.GENERIC_READ|fs.GENERIC_WRITE,
+		0,   // mode
+		nil, // security attributes
+		fs.OPEN_EXISTING,
+		fs.SECURITY_SQOS_PRESENT|fs.SECURITY_ANONYMOUS,
+		0, // template file handle
+	)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return &win32Pipe{win32File: sf, path: path}, os.NewFile(uintptr(ch), path), nil
+}
@@ -0,0 +1,141 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bufio"
+)
+
+// defaultPipeWriteBufferSize is the size of the write buffer used if PipeConfig.WriteBuffering
+// is set and PipeConfig.OutputBufferSize is not.
+const defaultPipeWriteBufferSize = 4096
+
+// bufferedPipe coalesces small writes to a pipe in a buffer, which is written to the pipe
+// as soon as it is full, or when the connection is flushed, read from, or closed. Data is
+// never written on a timer, so it stays in the buffer until one of these happens.
+//
+// Writes to the pipe are serialized by writeLock, a one-slot semaphore rather than a mutex, so
+// that Close and Disconnect can skip writing the buffer instead of waiting behind a Write
+// blocked on a peer that is not reading.
+type bufferedPipe struct {
+	PipeConn
+	writeLock chan struct{}
+	closed    atomicBool
+	w         *bufio.Writer
+}
+
+var _ PipeConn = (*bufferedPipe)(nil)
+
+// bufferedCloseWritePipe is a bufferedPipe wrapping a pipe that supports CloseWrite.
+type bufferedCloseWritePipe struct {
+	bufferedPipe
+	cw interface{ CloseWrite() error }
+}
+
+// newBufferedPipe wraps p so that writes are buffered, using a buffer of size bytes. p must
+// not be a message-mode pipe, since buffering merges and splits the messages written to it.
+func newBufferedPipe(p PipeConn, size int) PipeConn {
+	if size <= 0 {
+		size = defaultPipeWriteBufferSize
+	}
+	bp := bufferedPipe{
+		PipeConn:  p,
+		writeLock: make(chan struct{}, 1),
+		w:         bufio.NewWriterSize(p, size),
+	}
+	if cw, ok := p.(interface{ CloseWrite() error }); ok {
+		return &bufferedCloseWritePipe{bufferedPipe: bp, cw: cw}
+	}
+	return &bp
+}
+
+func (p *bufferedPipe) lock()   { p.writeLock <- struct{}{} }
+func (p *bufferedPipe) unlock() { <-p.writeLock }
+
+// tryLock acquires the write lock if no other write holds it.
+func (p *bufferedPipe) tryLock() bool {
+	select {
+	case p.writeLock <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Write writes b into the buffer, writing the buffer to the pipe whenever it fills up. Data
+// larger than the buffer is written to the pipe directly.
+func (p *bufferedPipe) Write(b []byte) (int, error) {
+	p.lock()
+	defer p.unlock()
+	if p.closed.isSet() {
+		return 0, ErrFileClosed
+	}
+	n, err := p.w.Write(b)
+	if err == nil && p.w.Available() == 0 {
+		// bufio only writes a full buffer once more data is written
+		err = p.w.Flush()
+	}
+	return n, err
+}
+
+// Read first writes any buffered data to the pipe, so that request/response protocols
+// do not deadlock, and then reads from the pipe.
+func (p *bufferedPipe) Read(b []byte) (int, error) {
+	if err := p.flushBuffer(); err != nil {
+		return 0, err
+	}
+	return p.PipeConn.Read(b)
+}
+
+// Flush writes any buffered data to the pipe, and then waits for the client to read
+// all the data written to the pipe.
+func (p *bufferedPipe) Flush() error {
+	if err := p.flushBuffer(); err != nil {
+		return err
+	}
+	return p.PipeConn.Flush()
+}
+
+// Disconnect writes any buffered data to the pipe before disconnecting it, unless a Write is
+// in progress, as with Close.
+//
+// Since disconnecting discards any unread data, callers should call Flush first.
+func (p *bufferedPipe) Disconnect() error {
+	p.tryFlushBuffer()
+	return p.PipeConn.Disconnect()
+}
+
+// Close writes any buffered data to the pipe and then closes it.
+//
+// Close does not wait for a Write in progress, which may be blocked on a client that is not
+// reading: it closes the pipe straight away, failing that Write and discarding the buffered
+// data. Writing the buffered data is subject to the write deadline, so set one to bound how
+// long Close can block if the client is not reading.
+func (p *bufferedPipe) Close() error {
+	p.closed.setTrue()
+	p.tryFlushBuffer()
+	return p.PipeConn.Close()
+}
+
+func (p *bufferedPipe) flushBuffer() error {
+	p.lock()
+	defer p.unlock()
+	return p.w.Flush()
+}
+
+// tryFlushBuffer writes any buffered data to the pipe, unless a write is in progress.
+func (p *bufferedPipe) tryFlushBuffer() {
+	if p.tryLock() {
+		_ = p.w.Flush()
+		p.unlock()
+	}
+}
+
+// CloseWrite writes any buffered data to the pipe and then closes the write side of the pipe.
+func (p *bufferedCloseWritePipe) CloseWrite() error {
+	if err := p.flushBuffer(); err != nil {
+		return err
+	}
+	return p.cw.CloseWrite()
+}
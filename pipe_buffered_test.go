@@ -0,0 +1,208 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestWriteBufferingMessageMode(t *testing.T) {
+	c := &PipeConfig{MessageMode: true, WriteBuffering: true}
+	l, err := ListenPipe(testPipeName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer s.Close()
+		// buffering would merge the messages, so it is not used in message mode
+		mc, ok := s.(MessageConn)
+		if !ok {
+			t.Errorf("accepted connection has type %T", s)
+			ch <- nil
+			return
+		}
+		for _, msg := range []string{"hello", "world"} {
+			if err := mc.WriteMessage([]byte(msg)); err != nil {
+				ch <- err
+				return
+			}
+		}
+		ch <- mc.CloseWrite()
+	}()
+
+	client, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	for _, want := range []string{"hello", "world"} {
+		b, err := client.(MessageConn).ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Fatalf("expected message %q, got %q", want, b)
+		}
+	}
+	if _, err := client.(MessageConn).ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteBufferingCloseWithBlockedWrite(t *testing.T) {
+	c := &PipeConfig{WriteBuffering: true, OutputBufferSize: 16}
+	l, err := ListenPipe(testPipeName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	client, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the client never reads, so the write blocks once the pipe's buffer is full
+	ch := make(chan error, 1)
+	go func() {
+		_, err := s.Write(make([]byte, 1<<20))
+		ch <- err
+	}()
+	select {
+	case err := <-ch:
+		t.Fatalf("write to a pipe that is not being read completed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- s.Close() }()
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked behind a pending write")
+	}
+	select {
+	case err := <-ch:
+		if err == nil {
+			t.Fatal("expected the pending write to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending write was not unblocked by Close")
+	}
+	if _, err := s.Write([]byte("x")); !errors.Is(err, ErrFileClosed) {
+		t.Fatalf("expected ErrFileClosed writing after Close, got %v", err)
+	}
+}
+
+func TestWriteBufferingFlushesOnRead(t *testing.T) {
+	c := &PipeConfig{WriteBuffering: true}
+	l, err := ListenPipe(testPipeName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		// echo lines back; the buffered response must be written before the next read blocks
+		r := bufio.NewReader(s)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if _, err := s.Write([]byte(line)); err != nil {
+				return
+			}
+		}
+	}()
+
+	client, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	r := bufio.NewReader(client)
+
+	for _, msg := range []string{"hello\n", "world\n"} {
+		if _, err := client.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != msg {
+			t.Fatalf("expected %q, got %q", msg, got)
+		}
+	}
+}
+
+func TestWriteBufferingFlushesWhenFull(t *testing.T) {
+	const size = 16
+	c := &PipeConfig{WriteBuffering: true, OutputBufferSize: size}
+	l, err := ListenPipe(testPipeName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	release := make(chan struct{})
+	defer close(release)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer s.Close()
+		// fill the buffer exactly, and wait for the client without reading or flushing
+		_, _ = s.Write(bytes.Repeat([]byte{'a'}, size))
+		<-release
+	}()
+
+	client, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(client, b); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,93 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"fmt"
+)
+
+// track adds conn, whose underlying pipe is p, to the listener's connection registry.
+func (l *win32PipeListener) track(p *win32Pipe, conn PipeConn) {
+	l.connsLock.Lock()
+	if l.conns == nil {
+		l.conns = make(map[*win32Pipe]PipeConn)
+	}
+	l.conns[p] = conn
+	l.connsLock.Unlock()
+
+	p.untrack = func() {
+		l.connsLock.Lock()
+		_, ok := l.conns[p]
+		delete(l.conns, p)
+		l.connsLock.Unlock()
+		if ok && l.config.OnDisconnect != nil {
+			l.config.OnDisconnect(conn)
+		}
+	}
+	if l.config.OnConnect != nil {
+		l.config.OnConnect(conn)
+	}
+}
+
+func (l *win32PipeListener) Conns() []PipeConn {
+	l.connsLock.Lock()
+	defer l.connsLock.Unlock()
+	if len(l.conns) == 0 {
+		return nil
+	}
+	conns := make([]PipeConn, 0, len(l.conns))
+	for _, c := range l.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+func (l *win32PipeListener) Broadcast(ctx context.Context, b []byte) error {
+	conns := l.Conns()
+	errs := make([]error, len(conns))
+	done := make([]chan struct{}, len(conns))
+	for i, c := range conns {
+		done[i] = make(chan struct{})
+		go func(i int, c PipeConn) {
+			defer close(done[i])
+			_, err := c.Write(b)
+			if bp, ok := c.(interface{ flushBuffer() error }); ok && err == nil {
+				// send the message now, rather than waiting for the write buffer to fill
+				err = bp.flushBuffer()
+			}
+			if err != nil {
+				errs[i] = err
+				c.Close()
+			}
+		}(i, c)
+	}
+	for i, c := range conns {
+		select {
+		case <-done[i]:
+		case <-ctx.Done():
+			// closing the connection fails the pending write
+			c.Close()
+			<-done[i]
+			errs[i] = ctx.Err()
+		}
+	}
+
+	var (
+		failed   int
+		firstErr error
+	)
+	for _, err := range errs {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+		}
+	}
+	if firstErr != nil {
+		return fmt.Errorf("broadcast to %d of %d connections failed: %w", failed, len(conns), firstErr)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeConnsBroadcast(t *testing.T) {
+	var (
+		mu                      sync.Mutex
+		connected, disconnected int
+	)
+	l, err := ListenPipe(testPipeName, &PipeConfig{
+		TrackConnections: true,
+		OnConnect: func(PipeConn) {
+			mu.Lock()
+			connected++
+			mu.Unlock()
+		},
+		OnDisconnect: func(PipeConn) {
+			mu.Lock()
+			disconnected++
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	const n = 3
+	var clients, servers []net.Conn
+	for i := 0; i < n; i++ {
+		ch := make(chan net.Conn)
+		go func() {
+			s, err := l.Accept()
+			if err != nil {
+				t.Error(err)
+			}
+			ch <- s
+		}()
+		c, err := DialPipe(testPipeName, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		clients = append(clients, c)
+		s := <-ch
+		if s == nil {
+			t.FailNow()
+		}
+		defer s.Close()
+		servers = append(servers, s)
+	}
+
+	if got := len(pl.Conns()); got != n {
+		t.Fatalf("got %d connections, want %d", got, n)
+	}
+	if err := pl.Broadcast(context.Background(), []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range clients {
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(c, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Fatalf("got %q", b)
+		}
+	}
+
+	servers[0].Close()
+	servers[0].Close()
+	if got := len(pl.Conns()); got != n-1 {
+		t.Fatalf("got %d connections after close, want %d", got, n-1)
+	}
+
+	// a client going away is detected by the next broadcast
+	clients[1].Close()
+	if err := pl.Broadcast(context.Background(), []byte("bye")); err == nil {
+		t.Fatal("expected broadcast to a closed client to fail")
+	}
+	if got := len(pl.Conns()); got != n-2 {
+		t.Fatalf("got %d connections after failed broadcast, want %d", got, n-2)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if connected != n || disconnected != 2 {
+		t.Fatalf("got %d connects and %d disconnects, want %d and 2", connected, disconnected, n)
+	}
+}
+
+func TestPipeConnsBroadcastTimeout(t *testing.T) {
+	l, err := ListenPipe(testPipeName, &PipeConfig{TrackConnections: true, OutputBufferSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	ch := make(chan net.Conn)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- s
+	}()
+	// the client never reads, so the broadcast cannot complete
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := <-ch
+	if s == nil {
+		t.FailNow()
+	}
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() { errCh <- pl.Broadcast(ctx, make([]byte, 1<<20)) }()
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("broadcast blocked on a client that is not reading")
+	}
+	if got := len(pl.Conns()); got != 0 {
+		t.Fatalf("got %d connections after a timed out broadcast, want 0", got)
+	}
+}
+
+func TestPipeConnsUntracked(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if conns := l.(PipeListener).Conns(); conns != nil {
+		t.Fatalf("got connections without tracking: %v", conns)
+	}
+}
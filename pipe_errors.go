@@ -0,0 +1,82 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// Classified pipe errors.
+//
+// Errors returned by the pipe functions wrap these when the failure can be classified, so
+// callers can use errors.Is instead of comparing against raw Win32 error codes. The
+// returned errors also continue to match the underlying error: for example, an error that
+// matches [ErrPipeBusy] also matches windows.ERROR_PIPE_BUSY.
+var (
+	// ErrPipeBusy is returned when dialing a pipe whose instances are all busy, after any
+	// retries allowed by the dial's [RetryPolicy] are exhausted.
+	ErrPipeBusy = errors.New("all pipe instances are busy")
+
+	// ErrAccessDenied is returned when the caller is not allowed to open or create a pipe,
+	// including when creating a pipe whose name is already in use.
+	ErrAccessDenied = errors.New("access to the pipe is denied")
+
+	// ErrPipeNameInUse is returned by [ListenPipe], when [PipeConfig.FirstInstance] is set,
+	// if a pipe with the same name already exists, possibly created by another process to
+	// intercept the service's clients. It also matches windows.ERROR_ACCESS_DENIED, but not
+	// [ErrAccessDenied].
+	ErrPipeNameInUse = errors.New("pipe name is already in use")
+
+	// ErrListenerClosed is returned for operations on pipe listeners that have been closed.
+	// It is the same error as [ErrPipeListenerClosed].
+	ErrListenerClosed = ErrPipeListenerClosed
+
+	// ErrDialTimeout is returned when dialing a pipe does not complete before the timeout
+	// passed to [DialPipe], or the deadline of the dial's context. It also matches
+	// [ErrTimeout] (for DialPipe) or context.DeadlineExceeded (for the context-based
+	// dial functions).
+	ErrDialTimeout = errors.New("timed out dialing pipe")
+
+	// ErrPipeDisconnected is returned by [PipePinger.Ping] when the other end of the pipe has
+	// been closed or disconnected.
+	ErrPipeDisconnected = errors.New("pipe has been disconnected")
+)
+
+// pipeError is a pipe error that has been classified as one of the sentinel errors above.
+// It matches both the sentinel and the underlying error.
+type pipeError struct {
+	kind error
+	err  error
+}
+
+func (e *pipeError) Error() string { return e.err.Error() }
+func (e *pipeError) Unwrap() error { return e.err }
+
+func (e *pipeError) Is(target error) bool {
+	return target == e.kind //nolint:errorlint // comparing sentinel values
+}
+
+func (e *pipeError) Timeout() bool   { return e.kind == ErrDialTimeout }             //nolint:errorlint
+func (e *pipeError) Temporary() bool { return e.Timeout() || e.kind == ErrPipeBusy } //nolint:errorlint
+
+// classifyPipeError wraps err with the sentinel error describing it, if there is one.
+func classifyPipeError(err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, windows.ERROR_PIPE_BUSY):
+		kind = ErrPipeBusy
+	case errors.Is(err, windows.ERROR_ACCESS_DENIED):
+		kind = ErrAccessDenied
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, ErrTimeout):
+		kind = ErrDialTimeout
+	case isPipeDisconnected(err):
+		kind = ErrPipeDisconnected
+	default:
+		return err
+	}
+	return &pipeError{kind: kind, err: err}
+}
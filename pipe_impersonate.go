@@ -0,0 +1,58 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"os"
+	"runtime"
+)
+
+//sys impersonateNamedPipeClient(pipe windows.Handle) (err error) = advapi32.ImpersonateNamedPipeClient
+
+// PipeClientImpersonator is implemented by the pipe connections returned by this package.
+type PipeClientImpersonator interface {
+	// RunAsClient calls fn while impersonating the client end of the pipe, so that files and
+	// other securable objects opened by fn are accessed with the client's identity.
+	//
+	// The calling goroutine is locked to its OS thread for the duration of the call, and the
+	// thread reverts to the process identity when fn returns. Goroutines started by fn do not run
+	// as the client.
+	//
+	// Only the server end of a pipe can impersonate its client, and only after data has been
+	// read from the pipe; until then, RunAsClient fails with windows.ERROR_CANNOT_IMPERSONATE.
+	// The access fn has depends on the impersonation level the client dialed with: the default
+	// level, [PipeImpLevelAnonymous], does not allow opening files as the client (see
+	// [DialPipeAccessImpLevel]).
+	RunAsClient(fn func() error) error
+}
+
+var (
+	_ PipeClientImpersonator = (*win32Pipe)(nil)
+	_ PipeClientImpersonator = (*bufferedPipe)(nil)
+)
+
+// RunAsClient implements [PipeClientImpersonator.RunAsClient].
+func (f *win32Pipe) RunAsClient(fn func() error) error {
+	if f.IsClosed() {
+		return ErrFileClosed
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := impersonateNamedPipeClient(f.handle); err != nil {
+		return &os.PathError{Op: "ImpersonateNamedPipeClient", Path: f.path, Err: err}
+	}
+	defer func() {
+		// the thread cannot be reused if it is still impersonating the client
+		if err := revertToSelf(); err != nil {
+			panic(err)
+		}
+	}()
+	return fn()
+}
+
+// RunAsClient implements [PipeClientImpersonator.RunAsClient] for the underlying pipe.
+func (p *bufferedPipe) RunAsClient(fn func() error) error {
+	return p.PipeConn.(PipeClientImpersonator).RunAsClient(fn)
+}
@@ -0,0 +1,80 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// PipeUnlimitedInstances is the [PipeInfo.MaxInstances] value of a pipe that has no limit on the
+// number of instances that can be created.
+const PipeUnlimitedInstances = windows.PIPE_UNLIMITED_INSTANCES
+
+// PipeInfoReporter is implemented by the pipe connections returned by this package.
+type PipeInfoReporter interface {
+	// Info returns information about the pipe instance.
+	Info() (PipeInfo, error)
+}
+
+var (
+	_ PipeInfoReporter = (*win32Pipe)(nil)
+	_ PipeInfoReporter = (*bufferedPipe)(nil)
+)
+
+// PipeInfo describes a named pipe instance, as returned by [PipeInfoReporter.Info].
+type PipeInfo struct {
+	// ServerEnd is true if the connection is the server end of the pipe.
+	ServerEnd bool
+
+	// MessageType is true if the pipe was created in message mode, ie, data is written as a
+	// stream of messages.
+	MessageType bool
+
+	// MessageReadMode is true if data is read from the pipe as a stream of messages, rather
+	// than a stream of bytes.
+	MessageReadMode bool
+
+	// OutputBufferSize and InputBufferSize are the sizes of the pipe's outgoing and incoming
+	// buffers, in bytes. A size of zero means that buffers are allocated as needed.
+	OutputBufferSize uint32
+	InputBufferSize  uint32
+
+	// MaxInstances is the maximum number of pipe instances that can be created, or
+	// [PipeUnlimitedInstances].
+	MaxInstances uint32
+
+	// CurrentInstances is the number of pipe instances that currently exist.
+	CurrentInstances uint32
+}
+
+// Info returns information about the pipe instance, by querying GetNamedPipeInfo and
+// GetNamedPipeHandleState.
+func (f *win32Pipe) Info() (PipeInfo, error) {
+	var info PipeInfo
+	if f.IsClosed() {
+		return info, ErrFileClosed
+	}
+
+	var flags uint32
+	if err := getNamedPipeInfo(f.handle, &flags, &info.OutputBufferSize, &info.InputBufferSize, &info.MaxInstances); err != nil {
+		return PipeInfo{}, &os.PathError{Op: "GetNamedPipeInfo", Path: f.path, Err: err}
+	}
+	info.ServerEnd = flags&windows.PIPE_SERVER_END != 0
+	info.MessageType = flags&windows.PIPE_TYPE_MESSAGE != 0
+
+	// the collection count and timeout can only be queried on remote client handles
+	var state uint32
+	if err := getNamedPipeHandleState(f.handle, &state, &info.CurrentInstances, nil, nil, nil, 0); err != nil {
+		return PipeInfo{}, &os.PathError{Op: "GetNamedPipeHandleState", Path: f.path, Err: err}
+	}
+	info.MessageReadMode = state&windows.PIPE_READMODE_MESSAGE != 0
+	return info, nil
+}
+
+// Info returns information about the underlying pipe instance.
+func (p *bufferedPipe) Info() (PipeInfo, error) {
+	return p.PipeConn.(PipeInfoReporter).Info()
+}
@@ -0,0 +1,73 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPipeInfo(t *testing.T) {
+	c := &PipeConfig{
+		MessageMode:      true,
+		InputBufferSize:  1024,
+		OutputBufferSize: 2048,
+	}
+	l, err := ListenPipe(testPipeName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan PipeInfo, 1)
+	go func() {
+		defer close(ch)
+		s, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer s.Close()
+		info, err := s.(PipeInfoReporter).Info()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ch <- info
+	}()
+
+	client, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	info, ok := <-ch
+	if !ok {
+		t.FailNow()
+	}
+	if !info.ServerEnd || !info.MessageType || !info.MessageReadMode {
+		t.Fatalf("unexpected server pipe info %+v", info)
+	}
+	// the buffer sizes are advisory, and may be rounded by the system
+	if info.InputBufferSize == 0 || info.OutputBufferSize == 0 {
+		t.Fatalf("unexpected buffer sizes %+v", info)
+	}
+	if info.MaxInstances != PipeUnlimitedInstances || info.CurrentInstances == 0 {
+		t.Fatalf("unexpected instances %+v", info)
+	}
+
+	info, err = client.(PipeInfoReporter).Info()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ServerEnd || !info.MessageType {
+		t.Fatalf("unexpected client pipe info %+v", info)
+	}
+
+	client.Close()
+	if _, err := client.(PipeInfoReporter).Info(); !errors.Is(err, ErrFileClosed) {
+		t.Fatalf("expected %v, got %v", ErrFileClosed, err)
+	}
+}
@@ -0,0 +1,87 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// initialMessageBufferSize is the size of the buffer that ReadMessage starts reading a message
+// into; it is doubled for as long as the message is larger.
+const initialMessageBufferSize = 4096
+
+// ErrEmptyMessage is returned by [MessageConn.WriteMessage] for an empty message, since
+// zero-byte messages are used to implement CloseWrite.
+var ErrEmptyMessage = errors.New("cannot write an empty pipe message")
+
+// MessageConn is implemented by connections to message-type pipes (see
+// [PipeConfig.MessageMode]).
+//
+// Read and Write present the pipe as a byte stream; ReadMessage and WriteMessage preserve the
+// boundaries of the messages written by the other end of the pipe. Mixing the two is allowed,
+// but a message partially consumed by Read is returned by ReadMessage starting at the first
+// unread byte.
+type MessageConn interface {
+	PipeConn
+	// ReadMessage reads the next message from the pipe, however large, switching the pipe to
+	// the message read mode if necessary. It returns io.EOF once the other end of the pipe has
+	// called CloseWrite (that is, written a zero-byte message) or closed the pipe.
+	ReadMessage() ([]byte, error)
+	// WriteMessage writes b as a single message. It returns [ErrEmptyMessage] if b is empty.
+	WriteMessage(b []byte) error
+	// CloseWrite writes a zero-byte message, which the other end of the pipe reads as io.EOF.
+	CloseWrite() error
+}
+
+var _ MessageConn = (*win32MessageBytePipe)(nil)
+
+func (f *win32MessageBytePipe) ReadMessage() ([]byte, error) {
+	if f.readEOF {
+		return nil, io.EOF
+	}
+	f.readModeOnce.Do(func() {
+		mode := uint32(windows.PIPE_READMODE_MESSAGE)
+		if err := windows.SetNamedPipeHandleState(f.handle, &mode, nil, nil); err != nil {
+			f.readModeErr = &os.PathError{Op: "SetNamedPipeHandleState", Path: f.path, Err: err}
+		}
+	})
+	if f.readModeErr != nil {
+		return nil, f.readModeErr
+	}
+
+	b := make([]byte, initialMessageBufferSize)
+	var n int
+	for {
+		m, err := f.win32File.Read(b[n:])
+		n += m
+		if err == windows.ERROR_MORE_DATA { //nolint:errorlint // err is Errno
+			// the buffer is full, and the message has more bytes
+			b = append(b, make([]byte, len(b))...)
+			continue
+		}
+		if err == io.EOF && n == 0 { //nolint:errorlint
+			// a zero-byte message, see win32MessageBytePipe.Read
+			f.readEOF = true
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+
+func (f *win32MessageBytePipe) WriteMessage(b []byte) error {
+	if f.writeClosed {
+		return errPipeWriteClosed
+	}
+	if len(b) == 0 {
+		return ErrEmptyMessage
+	}
+	_, err := f.win32File.Write(b)
+	return err
+}
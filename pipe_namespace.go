@@ -0,0 +1,142 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"net"
+	"os"
+	"runtime"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+//sys ntOpenSymbolicLinkObject(handle *windows.Handle, access uint32, oa *objectAttributes) (status ntStatus) = ntdll.NtOpenSymbolicLinkObject
+//sys ntQuerySymbolicLinkObject(handle windows.Handle, target *unicodeString, length *uint32) (status ntStatus) = ntdll.NtQuerySymbolicLinkObject
+
+const (
+	// pipePrefix is the Win32 prefix of local named pipe paths.
+	pipePrefix = `\\.\pipe\`
+
+	// pipeDosDevice is the DOS device symbolic link that pipePrefix resolves through.
+	pipeDosDevice = `\??\pipe`
+
+	// ntDevicePrefix is the prefix of NT object paths accepted by ListenPipe.
+	ntDevicePrefix = `\Device\`
+
+	symbolicLinkQuery = 0x1 // SYMBOLIC_LINK_QUERY
+)
+
+// PipeNamespace is a prefix for pipe names, such as `\\.\pipe\containers\<id>\`, which
+// groups the pipes created by a component and keeps them from conflicting with other pipes.
+//
+// The named pipe file system has no directories: a namespace only scopes pipe names, and
+// does not restrict access to the pipes in it. Use [PipeConfig.SecurityDescriptor] for that.
+type PipeNamespace string
+
+// SiloPipeNamespace returns the namespace for pipes belonging to the container (server silo)
+// with the specified ID, `\\.\pipe\containers\<id>\`.
+func SiloPipeNamespace(id string) PipeNamespace {
+	return PipeNamespace(pipePrefix + `containers\` + id + `\`)
+}
+
+// UniquePipeNamespace returns a new namespace with a random name, `\\.\pipe\<guid>\`, so that
+// its pipes cannot conflict with those of any other namespace. As with any namespace, the name
+// does not restrict access: other processes can list, open, or create pipes in it, subject to
+// the pipes' security descriptors.
+func UniquePipeNamespace() (PipeNamespace, error) {
+	g, err := guid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	return PipeNamespace(pipePrefix + g.String() + `\`), nil
+}
+
+// Path returns the path of the pipe called name in the namespace.
+func (ns PipeNamespace) Path(name string) string {
+	s := string(ns)
+	if !strings.HasSuffix(s, `\`) {
+		s += `\`
+	}
+	return s + strings.TrimPrefix(name, `\`)
+}
+
+// Listen creates a listener on the pipe called name in the namespace. See [ListenPipe].
+func (ns PipeNamespace) Listen(name string, c *PipeConfig) (net.Listener, error) {
+	return ListenPipe(ns.Path(name), c)
+}
+
+// Dial connects to the pipe called name in the namespace. See [DialPipeContext].
+func (ns PipeNamespace) Dial(ctx context.Context, name string, opts ...DialOption) (net.Conn, error) {
+	return DialPipeContext(ctx, ns.Path(name), opts...)
+}
+
+// PipeNTPath returns the NT object path of the local named pipe at path, such as
+// `\Device\NamedPipe\mypipe` for `\\.\pipe\mypipe`.
+//
+// The `\\.\pipe\` prefix is resolved through the calling process's DOS device namespace,
+// so inside a server silo the silo's named pipe device is returned. The NT path can be
+// passed to [ListenPipe], and dialed by prefixing it with `\\?\GLOBALROOT`.
+func PipeNTPath(path string) (string, error) {
+	if len(path) < len(pipePrefix) || !strings.EqualFold(path[:len(pipePrefix)], pipePrefix) {
+		return "", &os.PathError{Op: "resolve", Path: path, Err: windows.ERROR_BAD_PATHNAME}
+	}
+	dev, err := resolveSymbolicLink(pipeDosDevice)
+	if err != nil {
+		return "", &os.PathError{Op: "resolve", Path: path, Err: err}
+	}
+	return strings.TrimSuffix(dev, `\`) + `\` + path[len(pipePrefix):], nil
+}
+
+// resolveSymbolicLink returns the target of the object manager symbolic link at path.
+func resolveSymbolicLink(path string) (string, error) {
+	path16, err := windows.UTF16FromString(path)
+	if err != nil {
+		return "", err
+	}
+	name := unicodeString{
+		Length:        uint16((len(path16) - 1) * 2),
+		MaximumLength: uint16(len(path16) * 2),
+		Buffer:        uintptr(unsafe.Pointer(&path16[0])),
+	}
+	var oa objectAttributes
+	oa.Length = unsafe.Sizeof(oa)
+	oa.ObjectName = &name
+	oa.Attributes = windows.OBJ_CASE_INSENSITIVE
+
+	var h windows.Handle
+	err = ntOpenSymbolicLinkObject(&h, symbolicLinkQuery, &oa).Err()
+	runtime.KeepAlive(path16)
+	if err != nil {
+		return "", err
+	}
+	defer windows.Close(h) //nolint:errcheck
+
+	buf := make([]uint16, windows.MAX_PATH)
+	for {
+		target := unicodeString{
+			MaximumLength: uint16(len(buf) * 2),
+			Buffer:        uintptr(unsafe.Pointer(&buf[0])),
+		}
+		var n uint32
+		err = ntQuerySymbolicLinkObject(h, &target, &n).Err()
+		runtime.KeepAlive(buf)
+		if err == windows.ERROR_INSUFFICIENT_BUFFER && int(n) > len(buf)*2 && n <= 0xffff { //nolint:errorlint // err is Errno
+			buf = make([]uint16, (n+1)/2)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+		return windows.UTF16ToString(buf[:target.Length/2]), nil
+	}
+}
+
+// isNTPipePath returns true if path is an NT object path, rather than a Win32 path.
+func isNTPipePath(path string) bool {
+	return len(path) >= len(ntDevicePrefix) && strings.EqualFold(path[:len(ntDevicePrefix)], ntDevicePrefix)
+}
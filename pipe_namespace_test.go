@@ -0,0 +1,99 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSiloPipeNamespace(t *testing.T) {
+	ns := SiloPipeNamespace("abc")
+	for _, name := range []string{"docker", `\docker`} {
+		if p := ns.Path(name); p != `\\.\pipe\containers\abc\docker` {
+			t.Fatalf("unexpected path for %q: %s", name, p)
+		}
+	}
+}
+
+func TestUniquePipeNamespace(t *testing.T) {
+	ns, err := UniquePipeNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ns2, err := UniquePipeNamespace()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ns == ns2 {
+		t.Fatalf("unique namespaces are equal: %s", ns)
+	}
+
+	l, err := ns.Listen("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the same name in another namespace does not conflict
+	l2, err := ns2.Listen("test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := ns.Dial(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestPipeNTPath(t *testing.T) {
+	p, err := PipeNTPath(testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(strings.ToLower(p), `\device\namedpipe\`) || !strings.HasSuffix(p, `\winiotestpipe`) {
+		t.Fatalf("unexpected NT path %s", p)
+	}
+
+	if _, err := PipeNTPath(`C:\foo`); err == nil {
+		t.Fatal("expected error for a non-pipe path")
+	}
+}
+
+func TestListenPipeNTPath(t *testing.T) {
+	p, err := PipeNTPath(testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := ListenPipe(p, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
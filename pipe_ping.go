@@ -0,0 +1,59 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+//sys peekNamedPipe(pipe windows.Handle, buf *byte, bufSize uint32, bytesRead *uint32, bytesAvail *uint32, bytesLeftThisMessage *uint32) (err error) = PeekNamedPipe
+
+// PipePinger is implemented by the pipe connections returned by this package.
+type PipePinger interface {
+	// Ping checks that the other end of the pipe is still connected, without reading or
+	// writing data. It returns an error matching [ErrPipeDisconnected] if it is not.
+	Ping() error
+}
+
+var (
+	_ PipePinger = (*win32Pipe)(nil)
+	_ PipePinger = (*bufferedPipe)(nil)
+)
+
+// Ping checks that the other end of the pipe is still connected, without reading or writing
+// any data, by peeking at the pipe with PeekNamedPipe. It returns an error matching
+// [ErrPipeDisconnected] if the other end has closed or disconnected the pipe, and
+// [ErrFileClosed] if the connection itself is closed.
+//
+// Ping does not block, so connection pools can use it to validate idle connections before
+// reusing them. It cannot detect a peer that is connected but no longer responding.
+func (f *win32Pipe) Ping() error {
+	if f.IsClosed() {
+		return ErrFileClosed
+	}
+	var avail uint32
+	err := peekNamedPipe(f.handle, nil, 0, nil, &avail, nil)
+	runtime.KeepAlive(f)
+	if err != nil {
+		return &os.PathError{Op: "PeekNamedPipe", Path: f.path, Err: classifyPipeError(err)}
+	}
+	return nil
+}
+
+// Ping implements [PipePinger.Ping] for the underlying pipe. Buffered data is not written.
+func (p *bufferedPipe) Ping() error {
+	return p.PipeConn.(PipePinger).Ping()
+}
+
+// isPipeDisconnected reports whether err is a Win32 error returned for IO on a pipe whose
+// other end has been closed or disconnected.
+func isPipeDisconnected(err error) bool {
+	return errors.Is(err, windows.ERROR_BROKEN_PIPE) ||
+		errors.Is(err, windows.ERROR_PIPE_NOT_CONNECTED) ||
+		errors.Is(err, windows.ERROR_NO_DATA)
+}
@@ -0,0 +1,191 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+var errInvalidQueueSize = errors.New("pipe listener queue size must be positive")
+
+// PipeQueueStats describes the accept queue of a pipe listener; see [PipeListener.QueueStats].
+type PipeQueueStats struct {
+	// Size is the maximum number of pipe instances, as set by [PipeConfig.QueueSize] or
+	// [PipeListener.Resize].
+	Size int
+	// Waiting is the number of instances waiting for a client to connect.
+	Waiting int
+	// Connected is the number of clients that have connected, but have not been accepted.
+	Connected int
+	// Accepted is the number of clients accepted by the listener.
+	Accepted uint64
+	// Saturated is the number of times a client connected to the last waiting instance,
+	// after which clients fail to connect with ERROR_PIPE_BUSY until one is accepted. A
+	// growing count means the server is not accepting clients fast enough for the queue size.
+	Saturated uint64
+}
+
+// pendingConnect is a server pipe instance waiting for a client to connect.
+type pendingConnect struct {
+	p       *win32File
+	err     error // the result of connectPipe
+	retired bool  // the instance was closed by a resize
+}
+
+type resizeRequest struct {
+	size int
+	ch   chan error
+}
+
+// acceptQueue holds the server pipe instances that clients can connect to. It is only used by
+// the listener routine.
+type acceptQueue struct {
+	l         *win32PipeListener
+	size      int
+	started   bool                         // instances are only created once Accept is called
+	waiting   map[*pendingConnect]struct{} // instances waiting for a client
+	connected []*pendingConnect            // completed connects, in order, not yet accepted
+	retiring  int                          // closed instances whose connect has not returned
+	results   chan *pendingConnect         // receives each instance once its connect returns
+	accepted  uint64
+	saturated uint64
+}
+
+func newAcceptQueue(l *win32PipeListener) *acceptQueue {
+	size := l.config.QueueSize
+	if size < 1 {
+		size = 1
+	}
+	return &acceptQueue{
+		l:       l,
+		size:    size,
+		waiting: make(map[*pendingConnect]struct{}),
+		results: make(chan *pendingConnect),
+	}
+}
+
+// fill creates instances until the queue is full.
+func (q *acceptQueue) fill() error {
+	for q.started && len(q.waiting)+len(q.connected) < q.size {
+		p, err := q.l.makeServerPipe()
+		if err != nil {
+			return err
+		}
+		pc := &pendingConnect{p: p}
+		q.waiting[pc] = struct{}{}
+		go func() {
+			pc.err = connectPipe(p)
+			q.results <- pc
+		}()
+	}
+	return nil
+}
+
+// completed records that the connect of pc returned.
+func (q *acceptQueue) completed(pc *pendingConnect) {
+	if pc.retired {
+		q.retiring--
+		return
+	}
+	delete(q.waiting, pc)
+	if pc.err == windows.ERROR_NO_DATA { //nolint:errorlint // err is Errno
+		// The connection was immediately closed by the client, so wait for another.
+		pc.p.Close()
+		_ = q.fill()
+		return
+	}
+	q.connected = append(q.connected, pc)
+	if pc.err == nil && len(q.waiting) == 0 {
+		q.saturated++
+	}
+}
+
+// next removes and returns the first completed connect, if any, and replaces its instance.
+// If the connect failed, the instance is closed.
+func (q *acceptQueue) next() *pendingConnect {
+	if len(q.connected) == 0 {
+		return nil
+	}
+	pc := q.connected[0]
+	q.connected[0] = nil
+	q.connected = q.connected[1:]
+	if pc.err != nil {
+		pc.p.Close()
+		pc.p = nil
+	} else {
+		q.accepted++
+	}
+	// failures are returned by the next Accept call, if no instance is left
+	_ = q.fill()
+	return pc
+}
+
+// resize changes the size of the queue, creating or closing waiting instances as needed.
+func (q *acceptQueue) resize(n int) error {
+	q.size = n
+	for pc := range q.waiting {
+		if len(q.waiting)+len(q.connected) <= n {
+			break
+		}
+		delete(q.waiting, pc)
+		pc.retired = true
+		q.retiring++
+		pc.p.Close()
+	}
+	return q.fill()
+}
+
+func (q *acceptQueue) stats() PipeQueueStats {
+	return PipeQueueStats{
+		Size:      q.size,
+		Waiting:   len(q.waiting),
+		Connected: len(q.connected),
+		Accepted:  q.accepted,
+		Saturated: q.saturated,
+	}
+}
+
+// close closes all instances, and waits for their connects to return.
+func (q *acceptQueue) close() {
+	for pc := range q.waiting {
+		pc.p.Close()
+		q.retiring++
+	}
+	q.waiting = nil
+	for _, pc := range q.connected {
+		pc.p.Close()
+	}
+	q.connected = nil
+	for ; q.retiring > 0; q.retiring-- {
+		<-q.results
+	}
+}
+
+// Resize changes the number of pipe instances kept for clients to connect to.
+func (l *win32PipeListener) Resize(n int) error {
+	if n < 1 {
+		return errInvalidQueueSize
+	}
+	ch := make(chan error)
+	select {
+	case l.resizeCh <- resizeRequest{size: n, ch: ch}:
+		return <-ch
+	case <-l.doneCh:
+		return ErrPipeListenerClosed
+	}
+}
+
+// QueueStats returns the state of the accept queue. It returns zero stats once the listener is
+// closed.
+func (l *win32PipeListener) QueueStats() PipeQueueStats {
+	ch := make(chan PipeQueueStats)
+	select {
+	case l.statsCh <- ch:
+		return <-ch
+	case <-l.doneCh:
+		return PipeQueueStats{}
+	}
+}
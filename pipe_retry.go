@@ -0,0 +1,135 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"math/rand"
+	"time"
+)
+
+// defaultPipeRetryBackoff is the wait between dial attempts used by the zero value [RetryPolicy],
+// and matches the historic behavior of DialPipe.
+const defaultPipeRetryBackoff = 10 * time.Millisecond
+
+// maxPipeRetryBackoff caps the wait between dial attempts if [RetryPolicy.MaxBackoff] is unset,
+// so that the wait does not grow without bound (and overflow time.Duration).
+const maxPipeRetryBackoff = time.Minute
+
+// RetryPolicy controls how dialing a named pipe is retried while all instances of the
+// pipe are busy (ie, the server has not yet created a new pipe instance for the next client).
+//
+// The zero value retries every 10 milliseconds until the dial context is cancelled.
+type RetryPolicy struct {
+	// InitialBackoff is the time to wait after the first failed attempt.
+	// If zero, 10 milliseconds is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the time to wait between attempts. If zero, the wait is capped at one
+	// minute.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the wait is increased after each failed attempt.
+	// Values less than 1 are treated as 1, which keeps the wait constant.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to ±Jitter (as a fraction of the wait), to avoid many
+	// clients retrying in lockstep. It is clamped to the range [0, 1].
+	Jitter float64
+
+	// MaxAttempts is the maximum number of attempts to open the pipe. If zero, dialing is
+	// retried until the dial context is cancelled.
+	MaxAttempts int
+
+	// UseWaitNamedPipe waits for a pipe instance to become available with the WaitNamedPipe
+	// Win32 API, instead of sleeping, so that the next attempt is made as soon as possible.
+	// The wait is still bounded by the backoff and by the dial context's deadline, but blocks
+	// an OS thread, and is not interrupted if the context is cancelled.
+	UseWaitNamedPipe bool
+}
+
+// ExponentialRetryPolicy returns a [RetryPolicy] that starts at 10 milliseconds and doubles
+// the wait (with 20% jitter) up to one second.
+func ExponentialRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialBackoff: defaultPipeRetryBackoff,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+	}
+}
+
+// DialOption configures dialing a named pipe.
+type DialOption func(*dialConfig)
+
+type dialConfig struct {
+	retry RetryPolicy
+}
+
+// WithRetryPolicy sets the policy used to retry dialing a busy named pipe.
+func WithRetryPolicy(p RetryPolicy) DialOption {
+	return func(c *dialConfig) {
+		c.retry = p
+	}
+}
+
+func newDialConfig(opts []DialOption) *dialConfig {
+	c := &dialConfig{}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// initial returns the first backoff to wait.
+func (p *RetryPolicy) initial() time.Duration {
+	if p.InitialBackoff <= 0 {
+		return defaultPipeRetryBackoff
+	}
+	return p.capped(p.InitialBackoff)
+}
+
+// next returns the backoff to use after waiting for d.
+func (p *RetryPolicy) next(d time.Duration) time.Duration {
+	if p.Multiplier <= 1 {
+		return d
+	}
+	// compare before converting, as converting an out of range float64 overflows
+	if f := float64(d) * p.Multiplier; f < float64(p.maxBackoff()) {
+		return time.Duration(f)
+	}
+	return p.maxBackoff()
+}
+
+func (p *RetryPolicy) capped(d time.Duration) time.Duration {
+	if limit := p.maxBackoff(); d > limit || d < 0 {
+		return limit
+	}
+	return d
+}
+
+// maxBackoff returns the longest wait between attempts.
+func (p *RetryPolicy) maxBackoff() time.Duration {
+	if p.MaxBackoff > 0 {
+		return p.MaxBackoff
+	}
+	return maxPipeRetryBackoff
+}
+
+// jitter randomizes d by the policy's jitter factor.
+func (p *RetryPolicy) jitter(d time.Duration) time.Duration {
+	j := p.Jitter
+	if j <= 0 {
+		return d
+	}
+	if j > 1 {
+		j = 1
+	}
+	delta := j * float64(d)
+	return d + time.Duration(delta*(2*rand.Float64()-1)) //nolint:gosec // jitter does not need to be cryptographically secure
+}
+
+// exhausted returns true if no more attempts should be made after the specified number.
+func (p *RetryPolicy) exhausted(attempts int) bool {
+	return p.MaxAttempts > 0 && attempts >= p.MaxAttempts
+}
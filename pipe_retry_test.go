@@ -0,0 +1,137 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	var zero RetryPolicy
+	if d := zero.initial(); d != defaultPipeRetryBackoff {
+		t.Fatalf("zero policy initial backoff is %v, want %v", d, defaultPipeRetryBackoff)
+	}
+	if d := zero.next(zero.initial()); d != defaultPipeRetryBackoff {
+		t.Fatalf("zero policy next backoff is %v, want %v", d, defaultPipeRetryBackoff)
+	}
+	if zero.exhausted(1000) {
+		t.Fatal("zero policy should retry indefinitely")
+	}
+
+	p := RetryPolicy{
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     35 * time.Millisecond,
+		Multiplier:     2,
+		MaxAttempts:    3,
+	}
+	var got []time.Duration
+	for d, i := p.initial(), 0; i < 4; d, i = p.next(d), i+1 {
+		got = append(got, d)
+	}
+	want := []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 35 * time.Millisecond, 35 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("backoff %d is %v, want %v", i, got[i], want[i])
+		}
+	}
+	if p.exhausted(2) || !p.exhausted(3) {
+		t.Fatal("policy should be exhausted after 3 attempts")
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.jitter(100 * time.Millisecond); d < 50*time.Millisecond || d > 150*time.Millisecond {
+			t.Fatalf("jittered backoff %v out of range", d)
+		}
+	}
+}
+
+func TestRetryPolicyBackoffCapped(t *testing.T) {
+	// without MaxBackoff, the backoff stops growing rather than overflowing
+	p := RetryPolicy{Multiplier: 10}
+	d := p.initial()
+	for i := 0; i < 100; i++ {
+		d = p.next(d)
+		if d <= 0 || d > maxPipeRetryBackoff {
+			t.Fatalf("backoff %d is %v, want at most %v", i, d, maxPipeRetryBackoff)
+		}
+	}
+	if d != maxPipeRetryBackoff {
+		t.Fatalf("backoff is %v, want %v", d, maxPipeRetryBackoff)
+	}
+}
+
+func TestDialPipeWaitNamedPipeDeadline(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the listener is not accepting, so the pipe is busy, and each wait is far longer than
+	// the dial deadline
+	p := RetryPolicy{InitialBackoff: time.Minute, UseWaitNamedPipe: true}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := DialPipeContext(ctx, testPipeName, WithRetryPolicy(p)); err == nil {
+		t.Fatal("expected dialing a busy pipe to fail")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("dial took %v, past the context deadline", d)
+	}
+}
+
+func TestDialPipeRetryMaxAttempts(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the listener is not accepting, so the pipe is busy
+	for name, p := range map[string]RetryPolicy{
+		"sleep":         {MaxAttempts: 3},
+		"WaitNamedPipe": {MaxAttempts: 3, UseWaitNamedPipe: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := DialPipeContext(ctx, testPipeName, WithRetryPolicy(p))
+			if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+				t.Fatalf("expected ERROR_PIPE_BUSY, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDialPipeRetryPolicyConnects(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	go func() {
+		// start accepting after the client starts dialing
+		time.Sleep(50 * time.Millisecond)
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialPipeContext(ctx, testPipeName, WithRetryPolicy(ExponentialRetryPolicy()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
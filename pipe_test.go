@@ -8,14 +8,18 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
+	"os"
 	"sync"
 	"testing"
 	"time"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/internal/fs"
 )
 
 var testPipeName = `\\.\pipe\winiotestpipe`
@@ -40,6 +44,12 @@ func TestDialListenerTimesOut(t *testing.T) {
 	if !errors.Is(err, ErrTimeout) {
 		t.Fatalf("expected ErrTimeout, got %v", err)
 	}
+	if !errors.Is(err, ErrDialTimeout) {
+		t.Fatalf("expected ErrDialTimeout, got %v", err)
+	}
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
 }
 
 func TestDialContextListenerTimesOut(t *testing.T) {
@@ -55,6 +65,9 @@ func TestDialContextListenerTimesOut(t *testing.T) {
 	if !errors.Is(err, context.DeadlineExceeded) {
 		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
 	}
+	if !errors.Is(err, ErrDialTimeout) {
+		t.Fatalf("expected ErrDialTimeout, got %v", err)
+	}
 }
 
 func TestDialListenerGetsCancelled(t *testing.T) {
@@ -90,6 +103,47 @@ func TestDialAccessDeniedWithRestrictedSD(t *testing.T) {
 	if !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
 		t.Fatalf("expected ERROR_ACCESS_DENIED, got %v", err)
 	}
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestListenPipeNameInUse(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, err = ListenPipe(testPipeName, &PipeConfig{FirstInstance: true})
+	if !errors.Is(err, ErrPipeNameInUse) {
+		t.Fatalf("expected ErrPipeNameInUse, got %v", err)
+	}
+	if !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		t.Fatalf("expected ERROR_ACCESS_DENIED, got %v", err)
+	}
+
+	_, err = ListenPipe(testPipeName, nil)
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("expected ErrAccessDenied, got %v", err)
+	}
+}
+
+func TestDialBusyPipe(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The listener has no instance waiting for a connection until Accept is called.
+	_, err = DialPipeContext(context.Background(), testPipeName, WithRetryPolicy(RetryPolicy{MaxAttempts: 1}))
+	if !errors.Is(err, ErrPipeBusy) {
+		t.Fatalf("expected ErrPipeBusy, got %v", err)
+	}
+	if !errors.Is(err, windows.ERROR_PIPE_BUSY) {
+		t.Fatalf("expected ERROR_PIPE_BUSY, got %v", err)
+	}
 }
 
 func getConnection(cfg *PipeConfig) (client net.Conn, server net.Conn, err error) {
@@ -623,6 +677,123 @@ func TestMessageReadMode(t *testing.T) {
 	}
 }
 
+func TestMessageConn(t *testing.T) {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	l, err := ListenPipe(testPipeName, &PipeConfig{MessageMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the large message is read in several pieces, but returned whole
+	msgs := [][]byte{
+		[]byte("hello"),
+		bytes.Repeat([]byte("x"), 3*initialMessageBufferSize+1),
+		[]byte("world"),
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer s.Close()
+		mc := s.(MessageConn)
+		for _, m := range msgs {
+			if err := mc.WriteMessage(m); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if err := mc.WriteMessage(nil); !errors.Is(err, ErrEmptyMessage) {
+			t.Errorf("expected %v, got %v", ErrEmptyMessage, err)
+		}
+		if err := mc.CloseWrite(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	mc := c.(MessageConn)
+	for _, want := range msgs {
+		m, err := mc.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(m, want) {
+			t.Fatalf("expected a %d byte message, got %d bytes", len(want), len(m))
+		}
+	}
+	if _, err := mc.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestCompatNonWinioClientsCloseWrite(t *testing.T) {
+	for _, messageMode := range []bool{false, true} {
+		t.Run(fmt.Sprintf("MessageMode=%v", messageMode), func(t *testing.T) {
+			l, err := ListenPipe(testPipeName, &PipeConfig{MessageMode: messageMode, CompatNonWinioClients: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			const data = "hello world"
+			serverDone := make(chan struct{})
+			go func() {
+				defer close(serverDone)
+				s, err := l.Accept()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer s.Close()
+				if _, err := s.Write([]byte(data)); err != nil {
+					t.Error(err)
+					return
+				}
+				// CloseWrite waits for the client to read the data
+				if err := s.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := s.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+					t.Errorf("expected %v reading after CloseWrite, got %v", io.EOF, err)
+				}
+				if _, err := s.Write([]byte(data)); err == nil {
+					t.Error("write after CloseWrite should fail")
+				}
+			}()
+
+			c, err := DialPipe(testPipeName, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			b := make([]byte, len(data))
+			if _, err := io.ReadFull(c, b); err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != data {
+				t.Fatalf("read %q, expected %q", b, data)
+			}
+			if _, err := c.Read(b); err == nil {
+				t.Fatal("read after the server's CloseWrite should fail")
+			}
+			<-serverDone
+		})
+	}
+}
+
 func TestListenConnectRace(t *testing.T) {
 	for i := 0; i < 50 && !t.Failed(); i++ {
 		var wg sync.WaitGroup
@@ -643,3 +814,545 @@ func TestListenConnectRace(t *testing.T) {
 		wg.Wait()
 	}
 }
+
+func TestTryAccept(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	if _, err := pl.TryAccept(); !errors.Is(err, ErrNoPendingConnection) {
+		t.Fatalf("expected %v, got %v", ErrNoPendingConnection, err)
+	}
+
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var s net.Conn
+	for i := 0; i < 100; i++ {
+		s, err = pl.TryAccept()
+		if !errors.Is(err, ErrNoPendingConnection) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if _, err := c.Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(s, b); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := pl.TryAccept(); !errors.Is(err, ErrNoPendingConnection) {
+		t.Fatalf("expected %v, got %v", ErrNoPendingConnection, err)
+	}
+}
+
+func TestTryAcceptWhileAccepting(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	accepted := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err == nil {
+			s.Close()
+		}
+		accepted <- err
+	}()
+	// give Accept time to start waiting
+	time.Sleep(100 * time.Millisecond)
+
+	tried := make(chan error, 1)
+	go func() {
+		_, err := pl.TryAccept()
+		tried <- err
+	}()
+	select {
+	case err := <-tried:
+		if !errors.Is(err, ErrNoPendingConnection) {
+			t.Fatalf("expected %v, got %v", ErrNoPendingConnection, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("TryAccept blocked behind a waiting Accept")
+	}
+
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := <-accepted; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTryAcceptAfterCloseFails(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.(PipeListener).TryAccept(); !errors.Is(err, ErrNoPendingConnection) {
+		t.Fatalf("expected %v, got %v", ErrNoPendingConnection, err)
+	}
+	l.Close()
+	if _, err := l.(PipeListener).TryAccept(); !errors.Is(err, ErrPipeListenerClosed) {
+		t.Fatalf("expected %v, got %v", ErrPipeListenerClosed, err)
+	}
+}
+
+func TestAcceptDeadline(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	if err := pl.SetDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = pl.Accept()
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	// the deadline stays in effect until it is reset
+	if _, err := pl.Accept(); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected %v, got %v", ErrTimeout, err)
+	}
+
+	if err := pl.SetDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := pl.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
+
+// BenchmarkPipeSmallMessages measures writing and then reading small messages, which
+// both complete synchronously, with and without skipping the completion port on success.
+func TestPipeQueueResize(t *testing.T) {
+	l, err := ListenPipe(testPipeName, &PipeConfig{QueueSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pl := l.(PipeListener)
+
+	if _, err := pl.TryAccept(); !errors.Is(err, ErrNoPendingConnection) {
+		t.Fatalf("expected %v, got %v", ErrNoPendingConnection, err)
+	}
+	if st := pl.QueueStats(); st.Size != 2 || st.Waiting != 2 {
+		t.Fatalf("unexpected queue stats %+v", st)
+	}
+
+	// both clients connect before either is accepted
+	for i := 0; i < 2; i++ {
+		c, err := DialPipe(testPipeName, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	for i := 0; i < 100 && pl.QueueStats().Connected < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if st := pl.QueueStats(); st.Connected != 2 || st.Waiting != 0 || st.Saturated != 1 {
+		t.Fatalf("unexpected queue stats %+v", st)
+	}
+
+	if err := pl.Resize(1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		s, err := pl.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer s.Close()
+	}
+	if st := pl.QueueStats(); st.Size != 1 || st.Waiting != 1 || st.Connected != 0 || st.Accepted != 2 {
+		t.Fatalf("unexpected queue stats %+v", st)
+	}
+
+	if err := pl.Resize(3); err != nil {
+		t.Fatal(err)
+	}
+	if st := pl.QueueStats(); st.Waiting != 3 {
+		t.Fatalf("unexpected queue stats %+v", st)
+	}
+	if err := pl.Resize(0); err == nil {
+		t.Fatal("expected error resizing to 0")
+	}
+
+	l.Close()
+	if err := pl.Resize(1); !errors.Is(err, ErrPipeListenerClosed) {
+		t.Fatalf("expected %v, got %v", ErrPipeListenerClosed, err)
+	}
+}
+
+func TestAnonymousPipe(t *testing.T) {
+	s, c, err := AnonymousPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	defer c.Close()
+
+	if inherit, err := IsHandleInheritable(windows.Handle(c.Fd())); err != nil {
+		t.Fatal(err)
+	} else if inherit {
+		t.Fatal("client end is inheritable")
+	}
+
+	for _, tc := range []struct {
+		w io.Writer
+		r io.Reader
+	}{{s, c}, {c, s}} {
+		if _, err := tc.w.Write([]byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(tc.r, b); err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "hello" {
+			t.Fatalf("read %q", b)
+		}
+	}
+
+	if err := s.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(make([]byte, 1)); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected %v, got %v", ErrTimeout, err)
+	}
+	if err := s.SetReadDeadline(time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Close()
+	if _, err := s.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+}
+
+func TestPipePing(t *testing.T) {
+	c, s, err := getConnection(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err := c.Write([]byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	for _, conn := range []net.Conn{c, s} {
+		if err := conn.(PipePinger).Ping(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// pinging does not consume the data
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(s, b); err != nil {
+		t.Fatal(err)
+	}
+
+	s.Close()
+	if err := c.(PipePinger).Ping(); !errors.Is(err, ErrPipeDisconnected) {
+		t.Fatalf("expected %v, got %v", ErrPipeDisconnected, err)
+	}
+	if err := s.(PipePinger).Ping(); !errors.Is(err, ErrFileClosed) {
+		t.Fatalf("expected %v, got %v", ErrFileClosed, err)
+	}
+}
+
+func BenchmarkPipeSmallMessages(b *testing.B) {
+	for _, bm := range []struct {
+		name string
+		skip bool
+	}{
+		{"SkipCompletionPort", true},
+		{"CompletionPort", false},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			defer func(v bool) { skipCompletionPortOnSuccess = v }(skipCompletionPortOnSuccess)
+			skipCompletionPortOnSuccess = bm.skip
+
+			client, server, err := getConnection(nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer client.Close()
+			defer server.Close()
+
+			msg := make([]byte, 16)
+			buf := make([]byte, len(msg))
+			b.SetBytes(int64(len(msg)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := client.Write(msg); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.ReadFull(server, buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunAsClient(t *testing.T) {
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan net.Conn)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- c
+	}()
+	c, err := DialPipeAccessImpLevel(context.Background(), testPipeName,
+		uint32(windows.GENERIC_READ|windows.GENERIC_WRITE), PipeImpLevelImpersonation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := <-ch
+	if s == nil {
+		t.FailNow()
+	}
+	defer s.Close()
+
+	// impersonation requires reading from the pipe first
+	if _, err := c.Write([]byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Read(make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.(PipeClientImpersonator).RunAsClient(func() error {
+		var token windows.Token
+		if err := windows.OpenThreadToken(windows.CurrentThread(), windows.TOKEN_QUERY, true, &token); err != nil {
+			return err
+		}
+		return token.Close()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenPipeSecurityQoS(t *testing.T) {
+	qos := &PipeSecurityQoS{
+		ImpersonationLevel: PipeImpLevelIdentification,
+		DynamicTracking:    true,
+		EffectiveOnly:      true,
+	}
+	raw := qos.raw()
+	if raw.ImpersonationLevel != fs.SecurityIdentification || raw.ContextTrackingMode != 1 || raw.EffectiveOnly != 1 {
+		t.Fatalf("unexpected SECURITY_QUALITY_OF_SERVICE %+v", *raw)
+	}
+
+	l, err := ListenPipe(testPipeName, &PipeConfig{SecurityQoS: qos})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err == nil {
+			s.Close()
+		}
+		ch <- err
+	}()
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecommendedPipeBufferSize(t *testing.T) {
+	for _, tc := range []struct {
+		messageSize int
+		want        int32
+	}{
+		{0, 0},
+		{-1, 0},
+		{1, 4 << 10},
+		{4 << 10, 4 << 10},
+		{64<<10 + 1, 68 << 10},
+		{1 << 20, 1 << 20},
+		{16 << 20, 1 << 20},
+	} {
+		if got := RecommendedPipeBufferSize(tc.messageSize); got != tc.want {
+			t.Errorf("RecommendedPipeBufferSize(%d) = %d, want %d", tc.messageSize, got, tc.want)
+		}
+	}
+}
+
+// BenchmarkPipeBufferSizes measures streaming messages of various sizes through pipes with the
+// system's default buffer sizes, and with buffers sized by ExpectedMessageSize.
+func BenchmarkPipeBufferSizes(b *testing.B) {
+	for _, size := range []int{4 << 10, 64 << 10, 1 << 20} {
+		for _, bm := range []struct {
+			name string
+			cfg  PipeConfig
+		}{
+			{"Default", PipeConfig{}},
+			{"Tuned", PipeConfig{ExpectedMessageSize: int32(size)}},
+		} {
+			b.Run(fmt.Sprintf("%dKB/%s", size>>10, bm.name), func(b *testing.B) {
+				cfg := bm.cfg
+				client, server, err := getConnection(&cfg)
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer client.Close()
+				defer server.Close()
+
+				msg := make([]byte, size)
+				b.SetBytes(int64(size))
+				b.ResetTimer()
+				done := make(chan error, 1)
+				go func() {
+					_, err := io.CopyN(io.Discard, server, int64(b.N)*int64(size))
+					done <- err
+				}()
+				for i := 0; i < b.N; i++ {
+					if _, err := client.Write(msg); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if err := <-done; err != nil {
+					b.Fatal(err)
+				}
+			})
+		}
+	}
+}
+
+func TestLargeWritePartialTimeout(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		c     *PipeConfig
+		chunk int
+	}{
+		{"default buffer", nil, maxWriteChunk},
+		// writes are split at the size of the server's input buffer
+		{"input buffer", &PipeConfig{InputBufferSize: 64 << 10}, 64 << 10},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := ListenPipe(testPipeName, tt.c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer l.Close()
+
+			serverDone := make(chan struct{})
+			go func() {
+				defer close(serverDone)
+				s, err := l.Accept()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer s.Close()
+				// read the first chunk, then stop reading so the rest of the write times out
+				if _, err := io.ReadFull(s, make([]byte, tt.chunk)); err != nil {
+					t.Error(err)
+				}
+				time.Sleep(time.Second)
+			}()
+
+			client, err := DialPipe(testPipeName, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			_ = client.SetWriteDeadline(time.Now().Add(500 * time.Millisecond))
+			b := make([]byte, 4*tt.chunk)
+			n, err := client.Write(b)
+			if !errors.Is(err, ErrTimeout) {
+				t.Fatalf("expected ErrTimeout, got %v", err)
+			}
+			if n < tt.chunk || n >= len(b) {
+				t.Fatalf("expected a partial write of at least %d bytes, got %d", tt.chunk, n)
+			}
+			<-serverDone
+		})
+	}
+}
+
+func TestListenPipeUnique(t *testing.T) {
+	l, path, err := ListenPipeUnique("winio-unique-", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if want := `\\.\pipe\winio-unique-`; len(path) <= len(want) || path[:len(want)] != want {
+		t.Fatalf("unexpected path %s", path)
+	}
+
+	l2, path2, err := ListenPipeUnique(`\\.\pipe\winio-unique-`, &PipeConfig{MessageMode: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	if path2 == path {
+		t.Fatalf("expected different paths, got %s twice", path)
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+		ch <- err
+	}()
+	c, err := DialPipe(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// maxUniquePipeAttempts is the number of names tried by ListenPipeUnique before giving up.
+const maxUniquePipeAttempts = 10
+
+// ListenPipeUnique creates a listener on a new pipe whose name is prefix followed by a random
+// GUID, and returns the listener and the pipe's path, for clients to dial. If prefix is not a
+// pipe path (`\\.\pipe\...` or an NT object path), it is a name in `\\.\pipe\`; for example,
+// "myservice-" gives paths such as `\\.\pipe\myservice-2c6a7f8e-...`.
+//
+// If a pipe with the generated name already exists, another name is tried. c is used as with
+// [ListenPipe], except that [PipeConfig.FirstInstance] is always set, so that existing pipes
+// are detected. The returned listener implements [PipeListener].
+func ListenPipeUnique(prefix string, c *PipeConfig) (net.Listener, string, error) {
+	if !strings.HasPrefix(prefix, `\\`) && !isNTPipePath(prefix) {
+		prefix = pipePrefix + strings.TrimPrefix(prefix, `\`)
+	}
+	config := PipeConfig{}
+	if c != nil {
+		config = *c
+	}
+	config.FirstInstance = true
+
+	for i := 0; ; i++ {
+		g, err := guid.NewV4()
+		if err != nil {
+			return nil, "", err
+		}
+		path := prefix + g.String()
+		l, err := ListenPipe(path, &config)
+		if errors.Is(err, ErrPipeNameInUse) && i < maxUniquePipeAttempts-1 {
+			continue
+		} else if err != nil {
+			return nil, "", err
+		}
+		return l, path, nil
+	}
+}
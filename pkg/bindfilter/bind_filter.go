@@ -46,6 +46,9 @@ const (
 // second source will superscede the first source that was mounted.
 // This function disables this behavior and sets the BINDFLT_FLAG_NO_MULTIPLE_TARGETS flag
 // on the mount.
+//
+// Errors from the bind filter are returned as a [*BindError]; use [Supported] to check that
+// the bind filter is available beforehand.
 func ApplyFileBinding(root, source string, readOnly bool) error {
 	// The parent directory needs to exist for the bind to work. MkdirAll stats and
 	// returns nil if the directory exists internally so we should be fine to mkdirall
@@ -74,7 +77,7 @@ func ApplyFileBinding(root, source string, readOnly bool) error {
 		nil,
 		0,
 	); err != nil {
-		return fmt.Errorf("failed to bind target %q to root %q: %w", source, root, err)
+		return &BindError{Op: "BfSetupFilter", Root: root, Source: source, Err: err}
 	}
 	return nil
 }
@@ -82,7 +85,7 @@ func ApplyFileBinding(root, source string, readOnly bool) error {
 // RemoveFileBinding removes a mount from the root path.
 func RemoveFileBinding(root string) error {
 	if err := bfRemoveMapping(0, root); err != nil {
-		return fmt.Errorf("removing file binding: %w", err)
+		return &BindError{Op: "BfRemoveMapping", Root: root, Err: err}
 	}
 	return nil
 }
@@ -105,7 +108,7 @@ func GetBindMappings(volumePath string) ([]BindMapping, error) {
 	buf := make([]byte, outBuffSize)
 
 	if err := bfGetMappings(flags, 0, rootPtr, nil, &outBuffSize, &buf[0]); err != nil {
-		return nil, err
+		return nil, &BindError{Op: "BfGetMappings", Root: volumePath, Err: err}
 	}
 
 	if outBuffSize < 12 {
@@ -113,7 +113,7 @@ func TestApplyFileBindingReadOnly(t *testing.T) {
 
 func TestEnsureOnlyOneTargetCanBeMounted(t *testing.T) {
 	requireElevated(t)
-	requireBuild(t, RS5+1) // support added after RS5
+	requireSupported(t)
 
 	source := t.TempDir()
 	secondarySource := t.TempDir()
@@ -159,7 +159,7 @@ func checkSourceIsMountedOnDestination(src, dst string) (bool, error) {
 
 func TestGetBindMappings(t *testing.T) {
 	requireElevated(t)
-	requireBuild(t, RS5+1) // support added after RS5
+	requireSupported(t)
 
 	// GetBindMappings will expand short paths like ADMINI~1 and PROGRA~1 to their
 	// full names. In order to properly match the names later, we expand them here.
@@ -236,7 +236,7 @@ func TestRemoveFileBinding(t *testing.T) {
 
 func TestGetBindMappingsSymlinks(t *testing.T) {
 	requireElevated(t)
-	requireBuild(t, RS5+1) // support added after RS5
+	requireSupported(t)
 
 	srcShort := t.TempDir()
 	sourceNested := filepath.Join(srcShort, "source")
@@ -288,15 +288,38 @@ func requireElevated(tb testing.TB) {
 	}
 }
 
-const RS5 = 17763
+func requireSupported(tb testing.TB) {
+	tb.Helper()
+	if ok, reason := Supported(); !ok {
+		tb.Skip(reason)
+	}
+}
 
-//todo: also check that `bindfltapi.dll` exists
+func TestSupported(t *testing.T) {
+	ok, reason := Supported()
+	if ok != (reason == nil) {
+		t.Fatalf("Supported returned %t with reason %v", ok, reason)
+	}
+	if !ok && !errors.Is(reason, ErrNotSupported) {
+		t.Fatalf("expected reason %v to be ErrNotSupported", reason)
+	}
+}
 
-// require current build to be >= build
-func requireBuild(tb testing.TB, build uint32) {
-	tb.Helper()
-	_, _, b := windows.RtlGetNtVersionNumbers()
-	if b < build {
-		tb.Skipf("requires build %d+; current build is %d", build, b)
+func TestBindErrorNotSupported(t *testing.T) {
+	for _, tc := range []struct {
+		err  error
+		want bool
+	}{
+		{&windows.DLLError{Err: windows.ERROR_MOD_NOT_FOUND, ObjName: "bindfltapi.dll"}, true},
+		{windows.ERROR_NOT_SUPPORTED, true},
+		{windows.ERROR_ACCESS_DENIED, false},
+	} {
+		err := &BindError{Op: "BfSetupFilter", Root: `C:\root`, Source: `C:\source`, Err: tc.err}
+		if got := errors.Is(err, ErrNotSupported); got != tc.want {
+			t.Errorf("errors.Is(%v, ErrNotSupported) = %t, want %t", err, got, tc.want)
+		}
+		if !errors.Is(err, tc.err) {
+			t.Errorf("%v does not wrap %v", err, tc.err)
+		}
 	}
 }
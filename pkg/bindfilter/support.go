@@ -0,0 +1,75 @@
+//go:build windows
+// +build windows
+
+package bindfilter
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// minBuild is the first build whose bind filter supports BINDFLT_FLAG_NO_MULTIPLE_TARGETS and
+// BfGetMappings: both were added after Windows Server 2019 (RS5, build 17763).
+const minBuild = 17763 + 1
+
+// ErrNotSupported is returned by [Supported] as the reason the bind filter cannot be used, and
+// matches (with [errors.Is]) the errors returned when the bind filter or bindfltapi.dll is not
+// available.
+var ErrNotSupported = errors.New("bind filter is not supported")
+
+var (
+	supportedOnce   sync.Once
+	supportedReason error
+)
+
+// Supported returns whether the bind filter can be used on this system, and if not, the reason
+// why, which satisfies errors.Is(reason, ErrNotSupported).
+//
+// The bind filter requires bindfltapi.dll, and a build of Windows newer than RS5 (Windows
+// Server 2019), on which mappings cannot be restricted to a single target or listed.
+func Supported() (bool, error) {
+	supportedOnce.Do(func() {
+		if err := procBfSetupFilter.Find(); err != nil {
+			supportedReason = fmt.Errorf("%w: %v", ErrNotSupported, err) //nolint:errorlint // only wrap the sentinel
+			return
+		}
+		if _, _, b := windows.RtlGetNtVersionNumbers(); b < minBuild {
+			supportedReason = fmt.Errorf("%w: requires build %d or later; current build is %d",
+				ErrNotSupported, minBuild, b)
+		}
+	})
+	return supportedReason == nil, supportedReason
+}
+
+// BindError records an error from the bind filter, and the operation and paths that caused it.
+//
+// It matches [ErrNotSupported] (with [errors.Is]) if bindfltapi.dll or the function called is
+// not available, or the bind filter does not support the operation.
+type BindError struct {
+	Op     string // the bindfltapi.dll function called, such as "BfSetupFilter"
+	Root   string // the mount point or volume
+	Source string // the bound target, if any
+	Err    error
+}
+
+func (e *BindError) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s %q to %q: %v", e.Op, e.Source, e.Root, e.Err)
+	}
+	return fmt.Sprintf("%s %q: %v", e.Op, e.Root, e.Err)
+}
+
+func (e *BindError) Unwrap() error { return e.Err }
+
+func (e *BindError) Is(target error) bool {
+	if target != ErrNotSupported { //nolint:errorlint // comparing to the sentinel
+		return false
+	}
+	var dllErr *windows.DLLError
+	return errors.As(e.Err, &dllErr) ||
+		errors.Is(e.Err, windows.ERROR_NOT_SUPPORTED) ||
+		errors.Is(e.Err, windows.ERROR_INVALID_FUNCTION)
+}
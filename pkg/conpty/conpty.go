@@ -0,0 +1,253 @@
+//go:build windows
+
+// Package conpty provides support for hosting Windows pseudo consoles (ConPTY).
+//
+// A pseudo console translates the console API calls made by a process into a
+// stream of UTF-8 text and virtual terminal sequences, allowing interactive
+// console applications (such as shells) to be hosted over arbitrary transports,
+// like named pipes or Hyper-V sockets.
+//
+// https://learn.microsoft.com/en-us/windows/console/creating-a-pseudoconsole-session
+package conpty
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE is the process thread attribute used to
+// associate a pseudo console with a new process.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/processthreadsapi/nf-processthreadsapi-updateprocthreadattribute
+//
+//nolint:revive // SNAKE_CASE is not idiomatic in Go, but aligned with Win32 API.
+const PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE = 0x20016
+
+// Flags for [New].
+const (
+	// InheritCursor causes the pseudo console to inherit the cursor position
+	// of the parent console.
+	InheritCursor uint32 = 0x1 // PSEUDOCONSOLE_INHERIT_CURSOR
+)
+
+var (
+	// ErrClosed is returned when an operation is performed on a closed pseudo console.
+	ErrClosed = errors.New("pseudo console has already been closed")
+	// ErrInvalidSize is returned when the requested pseudo console dimensions are not positive.
+	ErrInvalidSize = errors.New("pseudo console dimensions must be positive")
+)
+
+// ConPTY is a Windows pseudo console.
+//
+// Reading from a ConPTY returns the output of the attached process(es), and
+// writing to it sends input to the attached process(es).
+// Both are backed by overlapped named pipes, so reads and writes do not block
+// system threads.
+type ConPTY struct {
+	mu  sync.RWMutex
+	hpc windows.Handle
+
+	// in is used to write to the pseudo console's input.
+	in io.WriteCloser
+	// out is used to read from the pseudo console's output.
+	out io.ReadCloser
+}
+
+var _ io.ReadWriteCloser = &ConPTY{}
+
+// New creates a pseudo console with the specified width and height (in characters).
+//
+// flags can be 0 or [InheritCursor].
+func New(width, height int16, flags uint32) (_ *ConPTY, err error) {
+	size, err := packCoord(width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// The pseudo console reads input from inR and writes output to outW.
+	// We keep the opposite (overlapped) ends of both pipes.
+	inR, inW, err := newPipe(false)
+	if err != nil {
+		return nil, fmt.Errorf("create pseudo console input pipe: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			inW.Close()
+		}
+	}()
+	// ConPTY duplicates the handles it is passed, so close our copies.
+	defer windows.Close(inR) //nolint:errcheck
+
+	outW, outR, err := newPipe(true)
+	if err != nil {
+		return nil, fmt.Errorf("create pseudo console output pipe: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			outR.Close()
+		}
+	}()
+	defer windows.Close(outW) //nolint:errcheck
+
+	var hpc windows.Handle
+	if err := createPseudoConsole(size, inR, outW, flags, &hpc); err != nil {
+		return nil, os.NewSyscallError("CreatePseudoConsole", err)
+	}
+
+	return &ConPTY{
+		hpc: hpc,
+		in:  inW,
+		out: outR,
+	}, nil
+}
+
+// Handle returns the underlying pseudo console handle (HPCON).
+//
+// The handle is owned by the ConPTY, and is invalid after Close is called.
+func (c *ConPTY) Handle() windows.Handle {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hpc
+}
+
+// Resize changes the dimensions of the pseudo console.
+func (c *ConPTY) Resize(width, height int16) error {
+	size, err := packCoord(width, height)
+	if err != nil {
+		return err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.hpc == 0 {
+		return ErrClosed
+	}
+	if err := resizePseudoConsole(c.hpc, size); err != nil {
+		return os.NewSyscallError("ResizePseudoConsole", err)
+	}
+	return nil
+}
+
+// UpdateProcThreadAttribute adds the pseudo console to the attribute list, so
+// that a process created with the list will be attached to the pseudo console.
+//
+// The process must be created with the [windows.EXTENDED_STARTUPINFO_PRESENT] flag,
+// and should not inherit the standard handles of the current process.
+func (c *ConPTY) UpdateProcThreadAttribute(attrList *windows.ProcThreadAttributeListContainer) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.hpc == 0 {
+		return ErrClosed
+	}
+	// The attribute value is the HPCON itself, not a pointer to it.
+	if err := attrList.Update(
+		PROC_THREAD_ATTRIBUTE_PSEUDOCONSOLE,
+		unsafe.Pointer(c.hpc), //nolint:govet // HPCON is passed by value
+		unsafe.Sizeof(c.hpc),
+	); err != nil {
+		return fmt.Errorf("update process thread attribute list with pseudo console: %w", err)
+	}
+	return nil
+}
+
+// Read reads output from the pseudo console.
+func (c *ConPTY) Read(b []byte) (int, error) {
+	return c.out.Read(b)
+}
+
+// Write writes input to the pseudo console.
+func (c *ConPTY) Write(b []byte) (int, error) {
+	return c.in.Write(b)
+}
+
+// Close closes the pseudo console and its pipes.
+//
+// Any processes attached to the pseudo console will be terminated, and pending
+// reads and writes will fail.
+func (c *ConPTY) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.hpc == 0 {
+		return nil
+	}
+
+	// Close the pipes first: on older versions of Windows, ClosePseudoConsole can
+	// block until the output pipe is drained.
+	c.in.Close()
+	c.out.Close()
+	// ClosePseudoConsole does not return a value. It is only called after
+	// CreatePseudoConsole succeeded, so the procedure is available.
+	closePseudoConsole(c.hpc)
+	c.hpc = 0
+	return nil
+}
+
+// packCoord packs width and height into a COORD, which is passed by value.
+func packCoord(width, height int16) (uint32, error) {
+	if width <= 0 || height <= 0 {
+		return 0, fmt.Errorf("%dx%d: %w", width, height, ErrInvalidSize)
+	}
+	return uint32(uint16(width)) | uint32(uint16(height))<<16, nil
+}
+
+// newPipe creates a uni-directional named pipe, with an overlapped server end wrapped
+// for asynchronous IO and a synchronous client end handle, suitable for passing to ConPTY.
+//
+// If inbound is true, data flows from the client to the (read-only) server; otherwise, it
+// flows from the (write-only) server to the client.
+func newPipe(inbound bool) (client windows.Handle, server io.ReadWriteCloser, err error) {
+	g, err := guid.NewV4()
+	if err != nil {
+		return 0, nil, err
+	}
+	name, err := windows.UTF16PtrFromString(fmt.Sprintf(`\\.\pipe\conpty-%d-%s`, os.Getpid(), g))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	openMode := uint32(windows.PIPE_ACCESS_OUTBOUND)
+	clientAccess := uint32(windows.GENERIC_READ)
+	if inbound {
+		openMode = windows.PIPE_ACCESS_INBOUND
+		clientAccess = windows.GENERIC_WRITE
+	}
+
+	const bufferSize = 4096
+	sh, err := windows.CreateNamedPipe(name,
+		openMode|windows.FILE_FLAG_OVERLAPPED|windows.FILE_FLAG_FIRST_PIPE_INSTANCE,
+		windows.PIPE_TYPE_BYTE|windows.PIPE_READMODE_BYTE|windows.PIPE_WAIT|windows.PIPE_REJECT_REMOTE_CLIENTS,
+		1, // max instances
+		bufferSize,
+		bufferSize,
+		0,   // default timeout
+		nil, // security attributes
+	)
+	if err != nil {
+		return 0, nil, os.NewSyscallError("CreateNamedPipe", err)
+	}
+
+	// Since the pipe server was just created, the client connects immediately and there
+	// is no need to call ConnectNamedPipe.
+	ch, err := windows.CreateFile(name, clientAccess, 0, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		windows.Close(sh)
+		return 0, nil, os.NewSyscallError("CreateFile", err)
+	}
+
+	server, err = winio.NewOpenFile(sh)
+	if err != nil {
+		windows.Close(sh)
+		windows.Close(ch)
+		return 0, nil, err
+	}
+	return ch, server, nil
+}
@@ -0,0 +1,126 @@
+//go:build windows
+
+package conpty
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+func startProcess(t *testing.T, c *ConPTY, cmdLine string) windows.Handle {
+	t.Helper()
+
+	attrList, err := windows.NewProcThreadAttributeList(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer attrList.Delete()
+	if err := c.UpdateProcThreadAttribute(attrList); err != nil {
+		t.Fatal(err)
+	}
+
+	si := &windows.StartupInfoEx{ProcThreadAttributeList: attrList.List()}
+	si.Cb = uint32(unsafe.Sizeof(*si))
+	pi := &windows.ProcessInformation{}
+	cmd, err := windows.UTF16PtrFromString(cmdLine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := windows.CreateProcess(nil, cmd, nil, nil, false,
+		windows.EXTENDED_STARTUPINFO_PRESENT|windows.CREATE_UNICODE_ENVIRONMENT,
+		nil, nil, &si.StartupInfo, pi); err != nil {
+		t.Fatal(err)
+	}
+	windows.Close(pi.Thread)
+	t.Cleanup(func() { windows.Close(pi.Process) })
+	return pi.Process
+}
+
+func TestConPTYEcho(t *testing.T) {
+	c, err := New(80, 25, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	p := startProcess(t, c, `cmd.exe /c echo hello conpty`)
+
+	ch := make(chan error)
+	go func() {
+		var out bytes.Buffer
+		b := make([]byte, 512)
+		for !bytes.Contains(out.Bytes(), []byte("hello conpty")) {
+			n, err := c.Read(b)
+			if err != nil {
+				ch <- err
+				return
+			}
+			out.Write(b[:n])
+		}
+		ch <- nil
+	}()
+
+	select {
+	case err := <-ch:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for process output")
+	}
+
+	if _, err := windows.WaitForSingleObject(p, 10*1000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConPTYResize(t *testing.T) {
+	c, err := New(80, 25, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Resize(120, 40); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Resize(0, 40); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("expected %v, got %v", ErrInvalidSize, err)
+	}
+}
+
+func TestConPTYClose(t *testing.T) {
+	c, err := New(80, 25, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// closing twice is a no-op
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Resize(80, 25); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected %v, got %v", ErrClosed, err)
+	}
+	if _, err := c.Write([]byte("hello")); err == nil {
+		t.Fatal("expected write to closed pseudo console to fail")
+	}
+	if _, err := c.Read(make([]byte, 1)); err == nil || errors.Is(err, io.EOF) {
+		t.Fatalf("expected read from closed pseudo console to fail, got %v", err)
+	}
+}
+
+func TestNewInvalidSize(t *testing.T) {
+	if _, err := New(-1, 25, 0); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("expected %v, got %v", ErrInvalidSize, err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package conpty
+
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go syscall.go
+
+//sys createPseudoConsole(size uint32, hInput windows.Handle, hOutput windows.Handle, flags uint32, hpc *windows.Handle) (hr error) = kernel32.CreatePseudoConsole?
+//sys resizePseudoConsole(hpc windows.Handle, size uint32) (hr error) = kernel32.ResizePseudoConsole?
+//sys closePseudoConsole(hpc windows.Handle) = kernel32.ClosePseudoConsole
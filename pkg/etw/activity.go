@@ -0,0 +1,78 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"context"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"golang.org/x/sys/windows"
+)
+
+// eventActivityCtrlCreateID is EVENT_ACTIVITY_CTRL_CREATE_ID.
+const eventActivityCtrlCreateID = 3
+
+type activityKey struct{}
+
+// activity is the activity ID stored in a context, along with the ID of the activity
+// that was current when it was started.
+type activity struct {
+	id      guid.GUID
+	related guid.GUID
+}
+
+// ContextWithActivityID returns a child of ctx that carries the activity ID id. If ctx
+// already carries an activity ID, it becomes the related (parent) activity ID of id.
+//
+// Go does not have goroutine-local storage, and the thread activity ID used by other ETW
+// providers is not preserved across goroutine scheduling, so the activity ID is carried in
+// the context instead. Use [WithContextActivityID] to write events with it.
+func ContextWithActivityID(ctx context.Context, id guid.GUID) context.Context {
+	a := activity{id: id}
+	if parent, ok := ctx.Value(activityKey{}).(activity); ok {
+		a.related = parent.id
+	}
+	return context.WithValue(ctx, activityKey{}, a)
+}
+
+// NewActivityID creates a new activity ID, which is unique on the system, with
+// EventActivityIdControl.
+func NewActivityID() (guid.GUID, error) {
+	var id guid.GUID
+	if err := eventActivityIDControl(eventActivityCtrlCreateID, (*windows.GUID)(&id)); err != nil {
+		return guid.GUID{}, err
+	}
+	return id, nil
+}
+
+// WithActivity starts a new activity: it returns a child of ctx that carries a new activity
+// ID (see [NewActivityID]), with the activity ID carried by ctx, if any, as its related
+// activity ID. Events written with [Provider.WriteEventContext] are stamped with the activity.
+func WithActivity(ctx context.Context) context.Context {
+	id, err := NewActivityID()
+	if err != nil {
+		if id, err = guid.NewV4(); err != nil {
+			return ctx
+		}
+	}
+	return ContextWithActivityID(ctx, id)
+}
+
+// ActivityIDFromContext returns the activity ID carried by ctx, if any.
+func ActivityIDFromContext(ctx context.Context) (guid.GUID, bool) {
+	a, ok := ctx.Value(activityKey{}).(activity)
+	return a.id, ok
+}
+
+// WithContextActivityID specifies that the event is written with the activity ID carried
+// by ctx, and its related activity ID, if any. If ctx does not carry an activity ID, the
+// event's activity IDs are not changed.
+func WithContextActivityID(ctx context.Context) EventOpt {
+	return func(options *eventOptions) {
+		if a, ok := ctx.Value(activityKey{}).(activity); ok {
+			options.activityID = a.id
+			options.relatedActivityID = a.related
+		}
+	}
+}
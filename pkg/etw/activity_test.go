@@ -0,0 +1,73 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func TestContextActivityID(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := ActivityIDFromContext(ctx); ok {
+		t.Fatal("background context has an activity ID")
+	}
+	var options eventOptions
+	WithContextActivityID(ctx)(&options)
+	if options.activityID != (guid.GUID{}) || options.relatedActivityID != (guid.GUID{}) {
+		t.Fatal("activity IDs set from a context without one")
+	}
+
+	parent, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx = ContextWithActivityID(ctx, parent)
+	ctx = ContextWithActivityID(ctx, child)
+
+	if id, ok := ActivityIDFromContext(ctx); !ok || id != child {
+		t.Fatalf("got activity ID %v, expected %v", id, child)
+	}
+	WithContextActivityID(ctx)(&options)
+	if options.activityID != child {
+		t.Fatalf("got activity ID %v, expected %v", options.activityID, child)
+	}
+	if options.relatedActivityID != parent {
+		t.Fatalf("got related activity ID %v, expected %v", options.relatedActivityID, parent)
+	}
+}
+
+func TestWithActivity(t *testing.T) {
+	ctx := WithActivity(context.Background())
+	parent, ok := ActivityIDFromContext(ctx)
+	if !ok || parent == (guid.GUID{}) {
+		t.Fatal("WithActivity did not start an activity")
+	}
+	ctx = WithActivity(ctx)
+	child, _ := ActivityIDFromContext(ctx)
+	if child == parent {
+		t.Fatal("WithActivity reused the parent activity ID")
+	}
+
+	var options eventOptions
+	WithContextActivityID(ctx)(&options)
+	if options.activityID != child || options.relatedActivityID != parent {
+		t.Fatalf("got activity IDs %v, %v, expected %v, %v", options.activityID, options.relatedActivityID, child, parent)
+	}
+
+	p, err := NewProvider("TestWithActivity", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	if err := p.WriteEventContext(ctx, "event", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import "sync"
+
+// maxPooledEventSize is the largest event metadata or data buffer that is returned to the pool,
+// so that a single large event does not pin its buffers in memory indefinitely.
+const maxPooledEventSize = 64 * 1024
+
+// eventBuffers holds the buffers used to build up a single event. The buffers (and the data
+// descriptors passed to EventWriteTransfer) are reused across WriteEvent calls to avoid
+// re-allocating them for every event.
+type eventBuffers struct {
+	em          eventMetadata
+	ed          eventData
+	descriptors []eventDataDescriptor
+}
+
+var eventBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &eventBuffers{
+			// provider, event metadata, and event data
+			descriptors: make([]eventDataDescriptor, 0, 3),
+		}
+	},
+}
+
+// getEventBuffers returns empty event buffers from the pool.
+// They must be returned with [eventBuffers.release] once the event is written.
+func getEventBuffers() *eventBuffers {
+	return eventBufferPool.Get().(*eventBuffers)
+}
+
+func (b *eventBuffers) release() {
+	if b.em.buffer.Cap() > maxPooledEventSize || b.ed.buffer.Cap() > maxPooledEventSize {
+		return
+	}
+	b.reset()
+	eventBufferPool.Put(b)
+}
+
+// reset empties the buffers, so that they can be used for another event.
+func (b *eventBuffers) reset() {
+	b.em.reset()
+	b.ed.buffer.Reset()
+	b.descriptors = b.descriptors[:0]
+}
+
+// writeEvent writes the data for an event with the specified name, tags, and fields, and
+// returns its metadata. If cache is not nil, the metadata is taken from it when the event was
+// already written with the same fields, and is otherwise added to it.
+func (b *eventBuffers) writeEvent(cache *metadataCache, name string, tags uint32, fieldOpts []FieldOpt) []byte {
+	b.em.beginEvent(name, tags, cache.get(name, tags))
+	for _, opt := range fieldOpts {
+		opt(&b.em, &b.ed)
+	}
+	metadata, cached := b.em.finishEvent()
+	if !cached {
+		cache.put(name, tags, b.em.fields, metadata)
+	}
+	return metadata
+}
@@ -0,0 +1,118 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func testFields() []FieldOpt {
+	return WithFields(
+		StringField("message", "hello"),
+		IntField("count", 42),
+		Uint64Field("id", 1<<40),
+		Time("time", time.Unix(0, 0)),
+		StringArray("tags", []string{"a", "b"}),
+	)
+}
+
+func TestEventBuffersReuse(t *testing.T) {
+	var em eventMetadata
+	var ed eventData
+	em.writeEventHeader("event", 0)
+	for _, opt := range testFields() {
+		opt(&em, &ed)
+	}
+
+	for i := 0; i < 3; i++ {
+		b := getEventBuffers()
+		if b.em.buffer.Len() != 0 || b.ed.buffer.Len() != 0 || len(b.descriptors) != 0 {
+			t.Fatal("pooled event buffers are not empty")
+		}
+		if metadata := b.writeEvent(nil, "event", 0, testFields()); !bytes.Equal(metadata, em.toBytes()) {
+			t.Fatalf("event metadata mismatch:\n%x\n%x", metadata, em.toBytes())
+		}
+		if !bytes.Equal(b.ed.toBytes(), ed.toBytes()) {
+			t.Fatalf("event data mismatch:\n%x\n%x", b.ed.toBytes(), ed.toBytes())
+		}
+		b.release()
+	}
+}
+
+func TestEventMetadataCache(t *testing.T) {
+	// the metadata built without a cache
+	want := func(name string, tags uint32, fields []FieldOpt) []byte {
+		b := getEventBuffers()
+		defer b.release()
+		return append([]byte(nil), b.writeEvent(nil, name, tags, fields)...)
+	}
+
+	var c metadataCache
+	for _, tt := range []struct {
+		name   string
+		event  string
+		tags   uint32
+		fields []FieldOpt
+		cached bool
+	}{
+		{"first", "event", 0, testFields(), false},
+		{"same fields", "event", 0, testFields(), true},
+		{"other values", "event", 0, WithFields(
+			StringField("message", "bye"),
+			IntField("count", 7),
+			Uint64Field("id", 1),
+			Time("time", time.Unix(1, 0)),
+			StringArray("tags", nil),
+		), true},
+		{"other name", "event2", 0, testFields(), false},
+		{"other tags", "event", 1, testFields(), false},
+		{"fewer fields", "event", 0, testFields()[:2], false},
+		{"more fields", "event", 0, append(testFields(), BoolField("ok", true)), false},
+		{"other field type", "event", 0, WithFields(StringField("message", "hello"), Int32Field("count", 42)), false},
+		{"other field name", "event", 0, WithFields(StringField("message", "hello"), Int32Field("n", 42)), false},
+		{"no fields", "event", 0, nil, false},
+		{"no fields again", "event", 0, nil, true},
+		{"struct", "event", 0, WithFields(Struct("s", IntField("a", 1))), false},
+		{"struct fields", "event", 0, WithFields(Struct("s", IntField("a", 1), IntField("b", 2))), false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := c.get(tt.event, tt.tags)
+			b := getEventBuffers()
+			defer b.release()
+			metadata := b.writeEvent(&c, tt.event, tt.tags, tt.fields)
+			if w := want(tt.event, tt.tags, tt.fields); !bytes.Equal(metadata, w) {
+				t.Fatalf("event metadata mismatch:\n%x\n%x", metadata, w)
+			}
+			if cached := cm != nil && b.em.buffer.Len() == 0; cached != tt.cached {
+				t.Fatalf("got cached %t, expected %t", cached, tt.cached)
+			}
+			if got := c.get(tt.event, tt.tags); got == nil || !bytes.Equal(got.metadata, metadata) {
+				t.Fatal("event metadata was not cached")
+			}
+		})
+	}
+}
+
+func BenchmarkWriteEventBuffers(b *testing.B) {
+	fields := testFields()
+	for _, bb := range []struct {
+		name  string
+		cache *metadataCache
+	}{
+		{"NoCache", nil},
+		{"Cache", &metadataCache{}},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				eb := getEventBuffers()
+				_ = eb.writeEvent(bb.cache, "event", 0, fields)
+				_ = eb.ed.toBytes()
+				eb.release()
+			}
+		})
+	}
+}
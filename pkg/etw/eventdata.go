@@ -14,6 +14,8 @@ import (
 // needs to be paired with EventMetadata which describes the event.
 type eventData struct {
 	buffer bytes.Buffer
+	// scratch is used to encode integers without the allocations of binary.Write.
+	scratch [8]byte
 }
 
 // toBytes returns the raw binary data containing the event data. The returned
@@ -36,17 +38,17 @@ func (ed *eventData) writeInt8(value int8) {
 
 // writeInt16 appends a int16 to the buffer.
 func (ed *eventData) writeInt16(value int16) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	ed.writeUint16(uint16(value))
 }
 
 // writeInt32 appends a int32 to the buffer.
 func (ed *eventData) writeInt32(value int32) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	ed.writeUint32(uint32(value))
 }
 
 // writeInt64 appends a int64 to the buffer.
 func (ed *eventData) writeInt64(value int64) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	ed.writeUint64(uint64(value))
 }
 
 // writeUint8 appends a uint8 to the buffer.
@@ -56,20 +58,24 @@ func (ed *eventData) writeUint8(value uint8) {
 
 // writeUint16 appends a uint16 to the buffer.
 func (ed *eventData) writeUint16(value uint16) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	binary.LittleEndian.PutUint16(ed.scratch[:], value)
+	_, _ = ed.buffer.Write(ed.scratch[:2])
 }
 
 // writeUint32 appends a uint32 to the buffer.
 func (ed *eventData) writeUint32(value uint32) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	binary.LittleEndian.PutUint32(ed.scratch[:], value)
+	_, _ = ed.buffer.Write(ed.scratch[:4])
 }
 
 // writeUint64 appends a uint64 to the buffer.
 func (ed *eventData) writeUint64(value uint64) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	binary.LittleEndian.PutUint64(ed.scratch[:], value)
+	_, _ = ed.buffer.Write(ed.scratch[:8])
 }
 
 // writeFiletime appends a FILETIME to the buffer.
 func (ed *eventData) writeFiletime(value windows.Filetime) {
-	_ = binary.Write(&ed.buffer, binary.LittleEndian, value)
+	ed.writeUint32(value.LowDateTime)
+	ed.writeUint32(value.HighDateTime)
 }
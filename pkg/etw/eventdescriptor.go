@@ -11,6 +11,19 @@ const (
 	// not required to be used for TraceLogging, but will prevent decoding
 	// issues for these events on older operating systems.
 	ChannelTraceLogging Channel = 11
+
+	// ChannelAdmin is the conventional channel for events targeting administrators,
+	// which indicate problems with a well-defined solution.
+	ChannelAdmin Channel = 16
+	// ChannelOperational is the conventional channel for events used to analyze and
+	// diagnose problems or occurrences.
+	ChannelOperational Channel = 17
+	// ChannelAnalytic is the conventional channel for high-volume events describing
+	// program operation.
+	ChannelAnalytic Channel = 18
+	// ChannelDebug is the conventional channel for events used by developers for
+	// debugging.
+	ChannelDebug Channel = 19
 )
 
 // Level represents the ETW logging level. There are several predefined levels
@@ -85,6 +85,28 @@ const (
 // event. It needs to be paired with EventData which describes the event.
 type eventMetadata struct {
 	buffer bytes.Buffer
+
+	// When an event is started with beginEvent, its fields are recorded, so that its
+	// metadata can be cached. While they match the fields of the cached metadata, nothing
+	// is written to the buffer.
+	recording bool
+	name      string
+	tags      uint32
+	fields    []fieldSchema
+	cached    *cachedMetadata
+
+	// If not outTypeDefault, the output type of the next field written, in place of its own.
+	// It is set by StructFields for fields with an output type in their tag.
+	outTypeOverride outType
+}
+
+// fieldSchema describes a field of an event, as written to the event metadata.
+type fieldSchema struct {
+	name    string
+	inType  inType
+	outType outType
+	tags    uint32
+	arrSize uint16
 }
 
 // toBytes returns the raw binary data containing the event metadata. Before being
@@ -101,30 +123,97 @@ func (em *eventMetadata) toBytes() []byte {
 // writeEventHeader writes the metadata for the start of an event to the buffer.
 // This specifies the event name and tags.
 func (em *eventMetadata) writeEventHeader(name string, tags uint32) {
-	_ = binary.Write(&em.buffer, binary.LittleEndian, uint16(0)) // Length placeholder
+	em.buffer.Write([]byte{0, 0}) // Length placeholder
 	em.writeTags(tags)
 	em.buffer.WriteString(name)
 	em.buffer.WriteByte(0) // Null terminator for name
 }
 
+// beginEvent starts the metadata for an event, like writeEventHeader. If cached is not
+// nil, the metadata is only written once the event's fields differ from the cached ones;
+// finishEvent returns the cached metadata if they do not.
+func (em *eventMetadata) beginEvent(name string, tags uint32, cached *cachedMetadata) {
+	em.recording = true
+	em.name = name
+	em.tags = tags
+	em.cached = cached
+	if cached == nil {
+		em.writeEventHeader(name, tags)
+	}
+}
+
+// finishEvent returns the metadata of an event started with beginEvent, and whether it is
+// the cached metadata passed to beginEvent.
+func (em *eventMetadata) finishEvent() ([]byte, bool) {
+	if em.cached != nil {
+		if len(em.fields) == len(em.cached.fields) {
+			return em.cached.metadata, true
+		}
+		em.flush()
+	}
+	return em.toBytes(), false
+}
+
+// flush writes the event header and the fields recorded so far, once they no longer match
+// the cached metadata.
+func (em *eventMetadata) flush() {
+	em.cached = nil
+	em.writeEventHeader(em.name, em.tags)
+	for i := range em.fields {
+		em.writeFieldSchema(&em.fields[i])
+	}
+}
+
+// reset empties the metadata, so that it can be used for another event.
+func (em *eventMetadata) reset() {
+	em.buffer.Reset()
+	em.recording = false
+	em.name = ""
+	em.fields = em.fields[:0]
+	em.cached = nil
+	em.outTypeOverride = outTypeDefault
+}
+
 func (em *eventMetadata) writeFieldInner(name string, inType inType, outType outType, tags uint32, arrSize uint16) {
-	em.buffer.WriteString(name)
+	if em.outTypeOverride != outTypeDefault {
+		// the out type of a struct is its field count, so it cannot be overridden
+		if inType&^(inTypeArray|inTypeCountedArray) != inTypeStruct {
+			outType = em.outTypeOverride
+		}
+		em.outTypeOverride = outTypeDefault
+	}
+	f := fieldSchema{name: name, inType: inType, outType: outType, tags: tags, arrSize: arrSize}
+	if em.cached != nil {
+		if n := len(em.fields); n < len(em.cached.fields) && em.cached.fields[n] == f {
+			em.fields = append(em.fields, f)
+			return
+		}
+		em.flush()
+	}
+	if em.recording {
+		em.fields = append(em.fields, f)
+	}
+	em.writeFieldSchema(&f)
+}
+
+func (em *eventMetadata) writeFieldSchema(f *fieldSchema) {
+	em.buffer.WriteString(f.name)
 	em.buffer.WriteByte(0) // Null terminator for name
 
-	if outType == outTypeDefault && tags == 0 {
-		em.buffer.WriteByte(byte(inType))
+	if f.outType == outTypeDefault && f.tags == 0 {
+		em.buffer.WriteByte(byte(f.inType))
 	} else {
-		em.buffer.WriteByte(byte(inType | 128))
-		if tags == 0 {
-			em.buffer.WriteByte(byte(outType))
+		em.buffer.WriteByte(byte(f.inType | 128))
+		if f.tags == 0 {
+			em.buffer.WriteByte(byte(f.outType))
 		} else {
-			em.buffer.WriteByte(byte(outType | 128))
-			em.writeTags(tags)
+			em.buffer.WriteByte(byte(f.outType | 128))
+			em.writeTags(f.tags)
 		}
 	}
 
-	if arrSize != 0 {
-		_ = binary.Write(&em.buffer, binary.LittleEndian, arrSize)
+	if f.arrSize != 0 {
+		em.buffer.Write([]byte{byte(f.arrSize), byte(f.arrSize >> 8)})
 	}
 }
 
@@ -12,6 +12,7 @@ type eventOptions struct {
 	activityID        guid.GUID
 	relatedActivityID guid.GUID
 	tags              uint32
+	writeFlags        WriteFlag
 }
 
 // EventOpt defines the option function type that can be passed to
@@ -19,6 +20,18 @@ type eventOptions struct {
 // keyword.
 type EventOpt func(options *eventOptions)
 
+// WriteFlag is a flag that modifies how an event is written, passed to EventWriteEx.
+type WriteFlag uint32
+
+const (
+	// WriteFlagNoFaulting writes the event without faulting in pageable memory, which
+	// allows writing events while holding locks that page faults would deadlock on.
+	WriteFlagNoFaulting WriteFlag = 0x1
+	// WriteFlagInPrivate marks the event as containing private data, so that it is not
+	// delivered to sessions that exclude events from private (InPrivate) activity.
+	WriteFlagInPrivate WriteFlag = 0x2
+)
+
 // WithEventOpts returns the variadic arguments as a single slice.
 func WithEventOpts(opts ...EventOpt) []EventOpt {
 	return opts
@@ -39,7 +52,10 @@ func WithKeyword(keyword uint64) EventOpt {
 	}
 }
 
-// WithChannel specifies the channel of the event to be written.
+// WithChannel specifies the channel of the event to be written. Events written to
+// [ChannelAdmin], [ChannelOperational], [ChannelAnalytic], or [ChannelDebug] only appear in
+// the corresponding Event Viewer logs if the provider's channels have been registered on
+// the machine (for example, with a manifest installed by wevtutil).
 func WithChannel(channel Channel) EventOpt {
 	return func(options *eventOptions) {
 		options.descriptor.channel = channel
@@ -74,3 +90,11 @@ func WithRelatedActivityID(activityID guid.GUID) EventOpt {
 		options.relatedActivityID = activityID
 	}
 }
+
+// WithWriteFlags specifies flags that modify how the event is written. Multiple uses of
+// this option are OR'd together.
+func WithWriteFlags(flags WriteFlag) EventOpt {
+	return func(options *eventOptions) {
+		options.writeFlags |= flags
+	}
+}
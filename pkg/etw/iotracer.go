@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import "github.com/Microsoft/go-winio"
+
+// NewIOTracer returns a [winio.IOTracer] that writes an "IO" event to p for each span, at the
+// verbose level. Events are only constructed while p is enabled at that level. Register it
+// with [winio.SetIOTracer].
+func NewIOTracer(p *Provider) winio.IOTracer {
+	return ioTracer{p}
+}
+
+type ioTracer struct{ p *Provider }
+
+func (t ioTracer) TraceIO(s winio.IOSpan) {
+	if !t.p.IsEnabledForLevel(LevelVerbose) {
+		return
+	}
+	fields := []FieldOpt{
+		StringField("Op", s.Op.String()),
+		UintptrField("Handle", uintptr(s.Handle)),
+		Time("Start", s.Start),
+		Int64Field("DurationNs", int64(s.Duration)),
+		IntField("Bytes", s.Bytes),
+	}
+	if s.Err != nil {
+		fields = append(fields, StringField("Error", s.Err.Error()))
+	}
+	_ = t.p.WriteEvent("IO", WithEventOpts(WithLevel(LevelVerbose)), fields)
+}
@@ -0,0 +1,25 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+)
+
+func TestIOTracer(t *testing.T) {
+	p, err := NewProvider("TestIOTracer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// the provider is not enabled by any session, so the spans are dropped
+	tr := NewIOTracer(p)
+	tr.TraceIO(winio.IOSpan{Op: winio.IORead, Start: time.Now(), Bytes: 5})
+	tr.TraceIO(winio.IOSpan{Op: winio.IOWrite, Start: time.Now(), Err: errors.New("failed")})
+}
@@ -0,0 +1,62 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import "sync"
+
+// maxCachedEvents is the number of events whose metadata is cached by a provider, so that
+// events with generated names do not grow the cache indefinitely.
+const maxCachedEvents = 1024
+
+// metadataCache caches the metadata of the events written by a provider, keyed by the event
+// name and tags, and the schema of its fields, so that the metadata of frequently written
+// events is not rebuilt every time. Only the last schema written with a name and tags is
+// cached.
+type metadataCache struct {
+	mu     sync.RWMutex
+	events map[metadataKey]*cachedMetadata
+}
+
+type metadataKey struct {
+	name string
+	tags uint32
+}
+
+// cachedMetadata is the metadata of an event with the given fields. It must not be modified.
+type cachedMetadata struct {
+	fields   []fieldSchema
+	metadata []byte
+}
+
+// get returns the metadata cached for the event, or nil. A nil cache is always empty.
+func (c *metadataCache) get(name string, tags uint32) *cachedMetadata {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.events[metadataKey{name: name, tags: tags}]
+}
+
+// put caches the metadata of the event with the specified fields, replacing the metadata cached
+// for it with other fields, if any. Nothing is cached in a nil cache.
+func (c *metadataCache) put(name string, tags uint32, fields []fieldSchema, metadata []byte) {
+	if c == nil {
+		return
+	}
+	cm := &cachedMetadata{
+		fields:   append([]fieldSchema(nil), fields...),
+		metadata: append([]byte(nil), metadata...),
+	}
+	k := metadataKey{name: name, tags: tags}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.events[k]; !ok && len(c.events) >= maxCachedEvents {
+		return
+	}
+	if c.events == nil {
+		c.events = make(map[metadataKey]*cachedMetadata)
+	}
+	c.events[k] = cm
+}
@@ -5,8 +5,6 @@
 package etw
 
 import (
-	"bytes"
-	"encoding/binary"
 	"unsafe"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
@@ -39,27 +37,15 @@ func NewProviderWithOptions(name string, options ...ProviderOpt) (provider *Prov
 	}(provider)
 	provider.ID = opts.id
 	provider.callback = opts.callback
+	if opts.recordSchema {
+		provider.schema = newSchemaRecorder(name, opts.id)
+	}
 
 	if err := eventRegister((*windows.GUID)(&provider.ID), globalProviderCallback, uintptr(provider.index), &provider.handle); err != nil {
 		return nil, err
 	}
 
-	trait := &bytes.Buffer{}
-	if opts.group != (guid.GUID{}) {
-		_ = binary.Write(trait, binary.LittleEndian, uint16(0)) // Write empty size for buffer (update later)
-		_ = binary.Write(trait, binary.LittleEndian, uint8(1))  // EtwProviderTraitTypeGroup
-		traitArray := opts.group.ToWindowsArray()               // Append group guid
-		trait.Write(traitArray[:])
-		binary.LittleEndian.PutUint16(trait.Bytes(), uint16(trait.Len())) // Update size
-	}
-
-	metadata := &bytes.Buffer{}
-	_ = binary.Write(metadata, binary.LittleEndian, uint16(0)) // Write empty size for buffer (to update later)
-	metadata.WriteString(name)
-	metadata.WriteByte(0)                                                   // Null terminator for name
-	_, _ = trait.WriteTo(metadata)                                          // Add traits if applicable
-	binary.LittleEndian.PutUint16(metadata.Bytes(), uint16(metadata.Len())) // Update the size at the beginning of the buffer
-	provider.metadata = metadata.Bytes()
+	provider.metadata = providerMetadata(name, &opts)
 
 	if err := eventSetInformation(
 		provider.handle,
@@ -4,10 +4,12 @@
 package etw
 
 import (
-	"crypto/sha1" //nolint:gosec // not used for secure application
+	"bytes"
+	"context"
 	"encoding/binary"
-	"strings"
-	"unicode/utf16"
+	"os"
+	"sync"
+	"unsafe"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 	"golang.org/x/sys/windows"
@@ -26,6 +28,13 @@ type Provider struct {
 	level      Level
 	keywordAny uint64
 	keywordAll uint64
+
+	schema        *schemaRecorder // set by WithSchemaRecording
+	metadataCache metadataCache
+
+	updatesLock sync.Mutex
+	updates     chan ProviderUpdate // created by Updates
+	closed      bool
 }
 
 // String returns the `provider`.ID as a string.
@@ -63,8 +72,27 @@ const (
 	eventInfoClassProviderUseDescriptorType
 )
 
+// Provider trait types, from ETW_PROVIDER_TRAIT_TYPE.
+const (
+	providerTraitTypeGroup = 1 // EtwProviderTraitTypeGroup
+)
+
+// eventFilterTypePID is EVENT_FILTER_TYPE_PID: the filter data is an array of process IDs, and
+// the provider is only enabled in those processes.
+const eventFilterTypePID = 0x80000004
+
+// eventFilterDescriptor is EVENT_FILTER_DESCRIPTOR, which is passed to the enable callback.
+type eventFilterDescriptor struct {
+	ptr  uint64
+	size uint32
+	typ  uint32
+}
+
 // EnableCallback is the form of the callback function that receives provider
-// enable/disable notifications from ETW.
+// enable/disable notifications from ETW. It is not called when a session enables the
+// provider with a process ID filter that does not include the current process, although the
+// provider's state is still updated, since ETW combines the level and keywords of all the
+// sessions enabling the provider.
 type EnableCallback func(guid.GUID, ProviderState, Level, uint64, uint64, uintptr)
 
 func providerCallback(
@@ -89,47 +117,120 @@ func providerCallback(
 		provider.keywordAll = matchAllKeyword
 	}
 
-	if provider.callback != nil {
+	if provider.callback != nil && !(state == ProviderStateEnable && filterExcludesProcess(filterData)) {
 		provider.callback(sourceID, state, level, matchAnyKeyword, matchAllKeyword, filterData)
 	}
+	if state != ProviderStateCaptureState {
+		provider.notify(ProviderUpdate{
+			Enabled:         provider.enabled,
+			Level:           provider.level,
+			MatchAnyKeyword: provider.keywordAny,
+			MatchAllKeyword: provider.keywordAll,
+		})
+	}
 }
 
-// providerIDFromName generates a provider ID based on the provider name. It
-// uses the same algorithm as used by .NET's EventSource class, which is based
-// on RFC 4122. More information on the algorithm can be found here:
-// https://blogs.msdn.microsoft.com/dcook/2015/09/08/etw-provider-names-and-guids/
+// filterExcludesProcess reports whether filterData, the EVENT_FILTER_DESCRIPTOR passed to the
+// enable callback, is a process ID filter that does not include the current process.
+func filterExcludesProcess(filterData uintptr) bool {
+	if filterData == 0 {
+		return false
+	}
+	// convert through a pointer to filterData, since it is not a pointer into Go memory
+	fd := *(**eventFilterDescriptor)(unsafe.Pointer(&filterData))
+	if fd.typ != eventFilterTypePID || fd.ptr == 0 {
+		return false
+	}
+	p := uintptr(fd.ptr)
+	pids := unsafe.Slice(*(**uint32)(unsafe.Pointer(&p)), fd.size/4)
+	pid := uint32(os.Getpid())
+	for _, id := range pids {
+		if id == pid {
+			return false
+		}
+	}
+	return true
+}
+
+// providerMetadata returns the provider's registration metadata: the size of the metadata, the
+// null-terminated provider name, and then the provider traits, each of which is its size, type,
+// and data.
+func providerMetadata(name string, opts *providerOpts) []byte {
+	metadata := &bytes.Buffer{}
+	_ = binary.Write(metadata, binary.LittleEndian, uint16(0)) // Write empty size for buffer (to update later)
+	metadata.WriteString(name)
+	metadata.WriteByte(0) // Null terminator for name
+	if opts.group != (guid.GUID{}) {
+		g := opts.group.ToWindowsArray()
+		writeProviderTrait(metadata, providerTraitTypeGroup, g[:])
+	}
+	binary.LittleEndian.PutUint16(metadata.Bytes(), uint16(metadata.Len())) // Update the size at the beginning of the buffer
+	return metadata.Bytes()
+}
+
+func writeProviderTrait(b *bytes.Buffer, typ uint8, data []byte) {
+	_ = binary.Write(b, binary.LittleEndian, uint16(3+len(data))) // size, including the size and type
+	b.WriteByte(typ)
+	b.Write(data)
+}
+
+// ProviderUpdate describes the provider's enablement after a session enables or disables
+// it, as delivered by [Provider.Updates].
+type ProviderUpdate struct {
+	// Enabled is true if any session has the provider enabled.
+	Enabled bool
+	// Level, MatchAnyKeyword, and MatchAllKeyword are the level and keywords of the most
+	// recent enablement, which are used by [Provider.IsEnabledForLevelAndKeywords].
+	Level           Level
+	MatchAnyKeyword uint64
+	MatchAllKeyword uint64
+}
+
+// Updates returns a channel that receives a [ProviderUpdate] whenever a session enables or
+// disables the provider, so that the application can adjust what it logs. Every call returns
+// the same channel, which is closed when the provider is closed.
 //
-// The algorithm is roughly the RFC 4122 algorithm for a V5 UUID, but differs in
-// the following ways:
-//   - The input name is first upper-cased, UTF16-encoded, and converted to
-//     big-endian.
-//   - No variant is set on the result UUID.
-//   - The result UUID is treated as being in little-endian format, rather than
-//     big-endian.
-func providerIDFromName(name string) guid.GUID {
-	buffer := sha1.New() //nolint:gosec // not used for secure application
-	namespace := guid.GUID{
-		Data1: 0x482C2DB2,
-		Data2: 0xC390,
-		Data3: 0x47C8,
-		Data4: [8]byte{0x87, 0xF8, 0x1A, 0x15, 0xBF, 0xC1, 0x30, 0xFB},
+// The channel holds only the most recent update: if the previous update has not been received
+// when a new one arrives, it is replaced, so a slow receiver always observes the current
+// state. Updates that happen before the first call to Updates are not delivered.
+func (provider *Provider) Updates() <-chan ProviderUpdate {
+	provider.updatesLock.Lock()
+	defer provider.updatesLock.Unlock()
+	if provider.updates == nil {
+		provider.updates = make(chan ProviderUpdate, 1)
+		if provider.closed {
+			close(provider.updates)
+		}
 	}
-	namespaceBytes := namespace.ToArray()
-	buffer.Write(namespaceBytes[:])
-	_ = binary.Write(buffer, binary.BigEndian, utf16.Encode([]rune(strings.ToUpper(name))))
+	return provider.updates
+}
 
-	sum := buffer.Sum(nil)
-	sum[7] = (sum[7] & 0xf) | 0x50
+// notify sends u on the updates channel, replacing any update that has not been received.
+// It never blocks, since it is called from ETW's enable callback.
+func (provider *Provider) notify(u ProviderUpdate) {
+	provider.updatesLock.Lock()
+	defer provider.updatesLock.Unlock()
+	if provider.updates == nil || provider.closed {
+		return
+	}
+	select {
+	case <-provider.updates:
+	default:
+	}
+	provider.updates <- u
+}
 
-	a := [16]byte{}
-	copy(a[:], sum)
-	return guid.FromWindowsArray(a)
+// providerIDFromName generates a provider ID based on the provider name, using
+// the same algorithm as .NET's EventSource class.
+func providerIDFromName(name string) guid.GUID {
+	return guid.FromNameInNamespace(guid.EventSourceNamespace(), name)
 }
 
 type providerOpts struct {
-	callback EnableCallback
-	id       guid.GUID
-	group    guid.GUID
+	callback     EnableCallback
+	id           guid.GUID
+	group        guid.GUID
+	recordSchema bool
 }
 
 // ProviderOpt allows the caller to specify provider options to
@@ -150,7 +251,8 @@ func WithID(id guid.GUID) ProviderOpt {
 	}
 }
 
-// WithGroup is used to provide a provider group option to NewProviderWithOptions.
+// WithGroup is used to provide a provider group option to NewProviderWithOptions. The group ID
+// is written to the provider's traits, so that sessions enabling the group enable the provider.
 func WithGroup(group guid.GUID) ProviderOpt {
 	return func(opts *providerOpts) {
 		opts.group = group
@@ -178,7 +280,15 @@ func (provider *Provider) Close() error {
 	}
 
 	providers.removeProvider(provider)
-	return eventUnregister(provider.handle)
+	err := eventUnregister(provider.handle)
+
+	provider.updatesLock.Lock()
+	if !provider.closed && provider.updates != nil {
+		close(provider.updates)
+	}
+	provider.closed = true
+	provider.updatesLock.Unlock()
+	return err
 }
 
 // IsEnabled calls IsEnabledForLevelAndKeywords with LevelAlways and all
@@ -228,9 +338,46 @@ func (provider *Provider) WriteEvent(name string, eventOpts []EventOpt, fieldOpt
 		return nil
 	}
 
+	b := getEventBuffers()
+	defer b.release()
+	return provider.writeEvent(b, name, eventOpts, fieldOpts)
+}
+
+// Event is an event to be written by [Provider.WriteEvents], with the same name, EventOpt, and
+// FieldOpt values that would be passed to [Provider.WriteEvent].
+type Event struct {
+	Name      string
+	EventOpts []EventOpt
+	FieldOpts []FieldOpt
+}
+
+// WriteEvents writes several events from the provider, in order, as with WriteEvent. It is
+// intended for bursts of events, such as when flushing buffered logs: the events are built in
+// the same buffers, and none of them are built if the provider is not enabled by any session.
+// ETW has no batch API, so each event is still written with its own system call.
+//
+// All of the events are written, even if writing one of them fails; the first error is
+// returned.
+func (provider *Provider) WriteEvents(events []*Event) error {
+	if provider == nil || !provider.enabled {
+		return nil
+	}
+
+	b := getEventBuffers()
+	defer b.release()
+	var firstErr error
+	for _, e := range events {
+		b.reset()
+		if err := provider.writeEvent(b, e.Name, e.EventOpts, e.FieldOpts); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// writeEvent builds the event in b, which must be empty, and writes it.
+func (provider *Provider) writeEvent(b *eventBuffers, name string, eventOpts []EventOpt, fieldOpts []FieldOpt) error {
 	options := eventOptions{descriptor: newEventDescriptor()}
-	em := &eventMetadata{}
-	ed := &eventData{}
 
 	// We need to evaluate the EventOpts first since they might change tags, and
 	// we write out the tags before evaluating FieldOpts.
@@ -242,29 +389,38 @@ func (provider *Provider) WriteEvent(name string, eventOpts []EventOpt, fieldOpt
 		return nil
 	}
 
-	em.writeEventHeader(name, options.tags)
-
-	for _, opt := range fieldOpts {
-		opt(em, ed)
+	metadata := b.writeEvent(&provider.metadataCache, name, options.tags, fieldOpts)
+	if provider.schema != nil {
+		provider.schema.record(options.descriptor, metadata)
 	}
 
 	// Don't pass a data blob if there is no event data. There will always be
 	// event metadata (e.g. for the name) so we don't need to do this check for
 	// the metadata.
 	dataBlobs := [][]byte{}
-	if len(ed.toBytes()) > 0 {
-		dataBlobs = [][]byte{ed.toBytes()}
+	if len(b.ed.toBytes()) > 0 {
+		dataBlobs = [][]byte{b.ed.toBytes()}
 	}
 
 	return provider.writeEventRaw(
 		options.descriptor,
 		options.activityID,
 		options.relatedActivityID,
-		[][]byte{em.toBytes()},
+		options.writeFlags,
+		[][]byte{metadata},
 		dataBlobs,
+		b.descriptors,
 	)
 }
 
+// WriteEventContext is like WriteEvent, but writes the event with the activity ID and related
+// activity ID carried by ctx (see [WithActivity]), unless eventOpts specifies them.
+func (provider *Provider) WriteEventContext(ctx context.Context, name string, eventOpts []EventOpt, fieldOpts []FieldOpt) error {
+	opts := make([]EventOpt, 0, len(eventOpts)+1)
+	opts = append(opts, WithContextActivityID(ctx))
+	return provider.WriteEvent(name, append(opts, eventOpts...), fieldOpts)
+}
+
 // writeEventRaw writes a single ETW event from the provider. This function is
 // less abstracted than WriteEvent, and presents a fairly direct interface to
 // the event writing functionality. It expects a series of event metadata and
@@ -272,14 +428,24 @@ func (provider *Provider) WriteEvent(name string, eventOpts []EventOpt, fieldOpt
 // schema. The functions on EventMetadata and EventData can help with creating
 // these blobs. The blobs of each type are effectively concatenated together by
 // the ETW infrastructure.
+//
+// If writeFlags is not zero, the event is written with EventWriteEx instead of
+// EventWriteTransfer.
+//
+// dataDescriptors is an optional, empty slice whose backing array is reused to
+// pass the blobs to ETW.
 func (provider *Provider) writeEventRaw(
 	descriptor *eventDescriptor,
 	activityID guid.GUID,
 	relatedActivityID guid.GUID,
+	writeFlags WriteFlag,
 	metadataBlobs [][]byte,
-	dataBlobs [][]byte) error {
+	dataBlobs [][]byte,
+	dataDescriptors []eventDataDescriptor) error {
 	dataDescriptorCount := uint32(1 + len(metadataBlobs) + len(dataBlobs))
-	dataDescriptors := make([]eventDataDescriptor, 0, dataDescriptorCount)
+	if uint32(cap(dataDescriptors)) < dataDescriptorCount {
+		dataDescriptors = make([]eventDataDescriptor, 0, dataDescriptorCount)
+	}
 
 	dataDescriptors = append(dataDescriptors,
 		newEventDataDescriptor(eventDataDescriptorTypeProviderMetadata, provider.metadata))
@@ -292,6 +458,16 @@ func (provider *Provider) writeEventRaw(
 			newEventDataDescriptor(eventDataDescriptorTypeUserData, blob))
 	}
 
+	if writeFlags != 0 {
+		return eventWriteEx(provider.handle,
+			descriptor,
+			0, // filter
+			uint32(writeFlags),
+			(*windows.GUID)(&activityID),
+			(*windows.GUID)(&relatedActivityID),
+			dataDescriptorCount,
+			&dataDescriptors[0])
+	}
 	return eventWriteTransfer(provider.handle,
 		descriptor,
 		(*windows.GUID)(&activityID),
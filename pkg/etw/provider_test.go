@@ -4,7 +4,11 @@
 package etw
 
 import (
+	"bytes"
+	"os"
+	"runtime"
 	"testing"
+	"unsafe"
 
 	"github.com/Microsoft/go-winio/pkg/guid"
 )
@@ -37,3 +41,144 @@ func Test_ProviderIDFromName(t *testing.T) {
 		}
 	}
 }
+
+func Test_WriteEventWithFlags(t *testing.T) {
+	p, err := NewProvider("TestWriteEventWithFlags", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	options := eventOptions{descriptor: newEventDescriptor()}
+	for _, opt := range []EventOpt{
+		WithChannel(ChannelOperational),
+		WithWriteFlags(WriteFlagNoFaulting),
+		WithWriteFlags(WriteFlagInPrivate),
+	} {
+		opt(&options)
+	}
+	if options.writeFlags != WriteFlagNoFaulting|WriteFlagInPrivate {
+		t.Fatalf("got write flags %#x", options.writeFlags)
+	}
+
+	// Write the event directly, since the provider is not enabled by any session.
+	b := getEventBuffers()
+	defer b.release()
+	metadata := b.writeEvent(nil, "TestEvent", options.tags, nil)
+	if err := p.writeEventRaw(
+		options.descriptor,
+		options.activityID,
+		options.relatedActivityID,
+		options.writeFlags,
+		[][]byte{metadata},
+		nil,
+		b.descriptors,
+	); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ProviderUpdates(t *testing.T) {
+	p, err := NewProvider("TestProviderUpdates", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := p.Updates()
+
+	// simulate session (de)registration through the provider's enable callback
+	providerCallback(p.ID, ProviderStateEnable, LevelVerbose, 0x3, 0, 0, uintptr(p.index))
+	want := ProviderUpdate{Enabled: true, Level: LevelVerbose, MatchAnyKeyword: 0x3}
+	if u := <-ch; u != want {
+		t.Fatalf("got update %+v, expected %+v", u, want)
+	}
+
+	// only the most recent update is kept
+	providerCallback(p.ID, ProviderStateEnable, LevelInfo, 0x1, 0, 0, uintptr(p.index))
+	providerCallback(p.ID, ProviderStateDisable, 0, 0, 0, 0, uintptr(p.index))
+	if u := <-ch; u.Enabled {
+		t.Fatalf("got update %+v, expected the provider to be disabled", u)
+	}
+	select {
+	case u := <-ch:
+		t.Fatalf("unexpected update %+v", u)
+	default:
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-ch; ok {
+		t.Fatal("updates channel was not closed")
+	}
+}
+
+func Test_ProviderMetadata(t *testing.T) {
+	group := mustGUIDFromString(t, "12341234-abcd-abcd-abcd-123412341234")
+	b := providerMetadata("p", &providerOpts{group: group})
+
+	g := group.ToWindowsArray()
+	want := append([]byte{23, 0, 'p', 0, 19, 0, providerTraitTypeGroup}, g[:]...)
+	if !bytes.Equal(b, want) {
+		t.Fatalf("got metadata %x, expected %x", b, want)
+	}
+
+	if b := providerMetadata("p", &providerOpts{}); !bytes.Equal(b, []byte{4, 0, 'p', 0}) {
+		t.Fatalf("got metadata without traits %x", b)
+	}
+}
+
+func Test_FilterExcludesProcess(t *testing.T) {
+	pids := []uint32{uint32(os.Getpid()) + 1, uint32(os.Getpid())}
+	fd := eventFilterDescriptor{
+		ptr:  uint64(uintptr(unsafe.Pointer(&pids[0]))),
+		size: uint32(4 * len(pids)),
+		typ:  eventFilterTypePID,
+	}
+	if filterExcludesProcess(uintptr(unsafe.Pointer(&fd))) {
+		t.Fatal("filter including the current process excludes it")
+	}
+	fd.size = 4
+	if !filterExcludesProcess(uintptr(unsafe.Pointer(&fd))) {
+		t.Fatal("filter not including the current process does not exclude it")
+	}
+	runtime.KeepAlive(pids)
+	if filterExcludesProcess(0) {
+		t.Fatal("no filter excludes the current process")
+	}
+}
+
+func Test_WriteEvents(t *testing.T) {
+	p, err := NewProviderWithOptions("TestWriteEvents", WithSchemaRecording())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	events := []*Event{
+		{Name: "First", FieldOpts: WithFields(StringField("message", "hello"))},
+		{Name: "Second", EventOpts: WithEventOpts(WithLevel(LevelVerbose)), FieldOpts: WithFields(IntField("count", 1))},
+		{Name: "Empty"},
+	}
+	// nothing is written while the provider is disabled
+	if err := p.WriteEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Schema(); len(s.Events) != 0 {
+		t.Fatalf("events were written by a disabled provider: %+v", s.Events)
+	}
+
+	// enable the provider as a session would, up to the informational level
+	p.enabled = true
+	p.level = LevelInfo
+	p.keywordAny = ^uint64(0)
+	if err := p.WriteEvents(events); err != nil {
+		t.Fatal(err)
+	}
+	s := p.Schema()
+	if len(s.Events) != 2 || s.Events[0].Name != "First" || s.Events[1].Name != "Empty" {
+		t.Fatalf("unexpected events written: %+v", s.Events)
+	}
+	if len(s.Events[0].Fields) != 1 || s.Events[0].Fields[0].Name != "message" {
+		t.Fatalf("unexpected fields for event First: %+v", s.Events[0].Fields)
+	}
+}
@@ -0,0 +1,330 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// ProviderSchema describes the events of a provider, for documentation and for consumers
+// configuring trace processing. It is encoded as JSON with [encoding/json], or [ProviderSchema.JSON].
+//
+// A schema is recorded by a provider created with [WithSchemaRecording], or built with
+// [NewProviderSchema] and [ProviderSchema.AddEvent] from the same options used to write the events.
+type ProviderSchema struct {
+	Name   string         `json:"name"`
+	ID     guid.GUID      `json:"id"`
+	Events []*EventSchema `json:"events"`
+}
+
+// EventSchema describes an event, and the fields written by its [FieldOpt] values.
+type EventSchema struct {
+	Name    string        `json:"name"`
+	Level   string        `json:"level"`
+	Opcode  string        `json:"opcode"`
+	Channel Channel       `json:"channel"`
+	Keyword uint64        `json:"keyword,omitempty"`
+	Tags    uint32        `json:"tags,omitempty"`
+	Fields  []FieldSchema `json:"fields,omitempty"`
+}
+
+// FieldSchema describes a field of an event.
+type FieldSchema struct {
+	Name string `json:"name"`
+	// Type is the TraceLogging input type of the field, such as "int32" or "ansistring".
+	Type string `json:"type"`
+	// Format is the TraceLogging output type, which tells decoders how to format the field,
+	// such as "hex" or "json", named as in `etw` struct tags (see [StructFields]). It is
+	// empty for the default format of the type.
+	Format string `json:"format,omitempty"`
+	// Array is set if the field is a variable-length array of Type.
+	Array bool `json:"array,omitempty"`
+	// Count is the number of elements of a fixed-length array.
+	Count  uint16        `json:"count,omitempty"`
+	Tags   uint32        `json:"tags,omitempty"`
+	Fields []FieldSchema `json:"fields,omitempty"` // the fields of a struct
+}
+
+// NewProviderSchema returns an empty schema for the provider name. If id is the zero GUID, the
+// provider ID is generated from the name, as done by [NewProvider].
+func NewProviderSchema(name string, id guid.GUID) *ProviderSchema {
+	if id == (guid.GUID{}) {
+		id = providerIDFromName(name)
+	}
+	return &ProviderSchema{Name: name, ID: id}
+}
+
+// AddEvent adds the event written with name, eventOpts, and fieldOpts (as passed to
+// [Provider.WriteEvent]) to the schema, replacing any event with the same name. The field
+// values are ignored.
+func (s *ProviderSchema) AddEvent(name string, eventOpts []EventOpt, fieldOpts []FieldOpt) error {
+	options := eventOptions{descriptor: newEventDescriptor()}
+	for _, opt := range eventOpts {
+		opt(&options)
+	}
+	b := getEventBuffers()
+	defer b.release()
+	metadata := b.writeEvent(nil, name, options.tags, fieldOpts)
+
+	e, err := describeEvent(options.descriptor, metadata)
+	if err != nil {
+		return err
+	}
+	s.addEvent(e)
+	return nil
+}
+
+func (s *ProviderSchema) addEvent(e *EventSchema) {
+	for i, x := range s.Events {
+		if x.Name == e.Name {
+			s.Events[i] = e
+			return
+		}
+	}
+	s.Events = append(s.Events, e)
+}
+
+// JSON returns the indented JSON encoding of the schema.
+func (s *ProviderSchema) JSON() ([]byte, error) {
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaRecorder records the schema of the events written by a provider.
+type schemaRecorder struct {
+	mu     sync.Mutex
+	schema ProviderSchema
+	seen   map[recordedEvent]struct{}
+}
+
+// recordedEvent identifies an event written with a given descriptor and metadata.
+type recordedEvent struct {
+	descriptor eventDescriptor
+	metadata   string
+}
+
+func newSchemaRecorder(name string, id guid.GUID) *schemaRecorder {
+	return &schemaRecorder{
+		schema: ProviderSchema{Name: name, ID: id},
+		seen:   make(map[recordedEvent]struct{}),
+	}
+}
+
+// record adds the event to the schema, unless it has already been recorded.
+func (r *schemaRecorder) record(descriptor *eventDescriptor, metadata []byte) {
+	k := recordedEvent{descriptor: *descriptor, metadata: string(metadata)}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[k]; ok {
+		return
+	}
+	e, err := describeEvent(descriptor, metadata)
+	if err != nil {
+		return
+	}
+	r.seen[k] = struct{}{}
+	r.schema.addEvent(e)
+}
+
+// WithSchemaRecording is used to provide an option to NewProviderWithOptions that records the
+// schema of the events written by the provider, as returned by [Provider.Schema]. Events are
+// only recorded when they are written, which requires the provider to be enabled.
+func WithSchemaRecording() ProviderOpt {
+	return func(opts *providerOpts) {
+		opts.recordSchema = true
+	}
+}
+
+// Schema returns the schema of the events written by the provider so far, or nil if the
+// provider was not created with [WithSchemaRecording].
+func (provider *Provider) Schema() *ProviderSchema {
+	if provider == nil || provider.schema == nil {
+		return nil
+	}
+	r := provider.schema
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.schema
+	s.Events = append([]*EventSchema(nil), s.Events...)
+	return &s
+}
+
+var errInvalidEventMetadata = errors.New("invalid event metadata")
+
+// describeEvent decodes TraceLogging event metadata, as written by eventMetadata.
+func describeEvent(descriptor *eventDescriptor, metadata []byte) (*EventSchema, error) {
+	if len(metadata) < 2 {
+		return nil, errInvalidEventMetadata
+	}
+	r := bytes.NewReader(metadata[2:]) // skip the length
+	tags, err := readTags(r)
+	if err != nil {
+		return nil, err
+	}
+	name, err := readName(r)
+	if err != nil {
+		return nil, err
+	}
+	e := &EventSchema{
+		Name:    name,
+		Level:   descriptor.level.String(),
+		Opcode:  descriptor.opcode.String(),
+		Channel: descriptor.channel,
+		Keyword: descriptor.keyword,
+		Tags:    tags,
+	}
+	for r.Len() > 0 {
+		f, err := readField(r)
+		if err != nil {
+			return nil, err
+		}
+		e.Fields = append(e.Fields, f)
+	}
+	return e, nil
+}
+
+func readField(r *bytes.Reader) (f FieldSchema, err error) {
+	if f.Name, err = readName(r); err != nil {
+		return f, err
+	}
+	in, err := r.ReadByte()
+	if err != nil {
+		return f, errInvalidEventMetadata
+	}
+	var out outType
+	if in&128 != 0 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return f, errInvalidEventMetadata
+		}
+		out = outType(b &^ 128)
+		if b&128 != 0 {
+			if f.Tags, err = readTags(r); err != nil {
+				return f, err
+			}
+		}
+	}
+	typ := inType(in) &^ (128 | inTypeArray | inTypeCountedArray)
+	switch inType(in) & (inTypeArray | inTypeCountedArray) {
+	case inTypeArray:
+		f.Array = true
+	case inTypeCountedArray:
+		var count uint16
+		if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+			return f, errInvalidEventMetadata
+		}
+		f.Count = count
+	}
+
+	f.Type = inTypeName(typ)
+	if typ == inTypeStruct {
+		// the output type of a struct is its field count
+		for i := 0; i < int(out); i++ {
+			sf, err := readField(r)
+			if err != nil {
+				return f, err
+			}
+			f.Fields = append(f.Fields, sf)
+		}
+	} else if out != outTypeDefault {
+		f.Format = outTypeName(out)
+	}
+	return f, nil
+}
+
+func readName(r *bytes.Reader) (string, error) {
+	var b []byte
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return "", errInvalidEventMetadata
+		}
+		if c == 0 {
+			return string(b), nil
+		}
+		b = append(b, c)
+	}
+}
+
+// readTags reads tags written by [eventMetadata.writeTags].
+func readTags(r *bytes.Reader) (uint32, error) {
+	var tags uint32
+	for shift := 21; ; shift -= 7 {
+		c, err := r.ReadByte()
+		if err != nil || shift < 0 {
+			return 0, errInvalidEventMetadata
+		}
+		tags |= uint32(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return tags, nil
+		}
+	}
+}
+
+var inTypeNames = [...]string{
+	inTypeNull:               "null",
+	inTypeUnicodeString:      "unicodestring",
+	inTypeANSIString:         "ansistring",
+	inTypeInt8:               "int8",
+	inTypeUint8:              "uint8",
+	inTypeInt16:              "int16",
+	inTypeUint16:             "uint16",
+	inTypeInt32:              "int32",
+	inTypeUint32:             "uint32",
+	inTypeInt64:              "int64",
+	inTypeUint64:             "uint64",
+	inTypeFloat:              "float",
+	inTypeDouble:             "double",
+	inTypeBool32:             "bool32",
+	inTypeBinary:             "binary",
+	inTypeGUID:               "guid",
+	inTypePointerUnsupported: "pointer",
+	inTypeFileTime:           "filetime",
+	inTypeSystemTime:         "systemtime",
+	inTypeSID:                "sid",
+	inTypeHexInt32:           "hexint32",
+	inTypeHexInt64:           "hexint64",
+	inTypeCountedString:      "countedstring",
+	inTypeCountedANSIString:  "countedansistring",
+	inTypeStruct:             "struct",
+	inTypeCountedBinary:      "countedbinary",
+}
+
+func inTypeName(t inType) string {
+	if int(t) < len(inTypeNames) {
+		return inTypeNames[t]
+	}
+	return fmt.Sprintf("intype(%d)", t)
+}
+
+// otherOutTypeNames names the output types that cannot be used in `etw` struct tags (see
+// outTypeNames), for schemas.
+var otherOutTypeNames = map[outType]string{
+	outTypeSocketAddress:     "socketaddress",
+	outTypeFileTime:          "filetime",
+	outTypeSigned:            "signed",
+	outTypeUnsigned:          "unsigned",
+	outTypePKCS7WithTypeInfo: "pkcs7withtypeinfo",
+	outTypeCodePointer:       "codepointer",
+	outTypeDateTimeUTC:       "datetimeutc",
+}
+
+// outTypeName returns the name of an output type, which is the same as in `etw` struct tags.
+func outTypeName(t outType) string {
+	for s, ot := range outTypeNames {
+		if ot == t {
+			return s
+		}
+	}
+	if s, ok := otherOutTypeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("outtype(%d)", t)
+}
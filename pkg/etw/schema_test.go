@@ -0,0 +1,87 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func Test_ProviderSchema(t *testing.T) {
+	s := NewProviderSchema("Test.Provider", guid.GUID{})
+	if s.ID != providerIDFromName("Test.Provider") {
+		t.Fatalf("unexpected provider ID %v", s.ID)
+	}
+
+	err := s.AddEvent("Request",
+		WithEventOpts(WithLevel(LevelWarning), WithOpcode(OpcodeStart), WithKeyword(0x10), WithTags(0x1234567)),
+		WithFields(
+			StringField("path", "x"),
+			Uint32Array("sizes", nil),
+			JSONStringField("body", "{}"),
+			Struct("peer",
+				Int64Field("pid", 0),
+				BoolField("local", true),
+			),
+		))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &EventSchema{
+		Name:    "Request",
+		Level:   "Warning",
+		Opcode:  "Start",
+		Channel: ChannelTraceLogging,
+		Keyword: 0x10,
+		Tags:    0x1234567,
+		Fields: []FieldSchema{
+			{Name: "path", Type: "ansistring", Format: "utf8"},
+			{Name: "sizes", Type: "uint32", Array: true},
+			{Name: "body", Type: "ansistring", Format: "json"},
+			{Name: "peer", Type: "struct", Fields: []FieldSchema{
+				{Name: "pid", Type: "int64"},
+				{Name: "local", Type: "uint8", Format: "bool"},
+			}},
+		},
+	}
+	if len(s.Events) != 1 || !reflect.DeepEqual(s.Events[0], want) {
+		t.Fatalf("got schema %+v, want %+v", s.Events, want)
+	}
+
+	// adding an event with the same name replaces it
+	if err := s.AddEvent("Request", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Events) != 1 || len(s.Events[0].Fields) != 0 {
+		t.Fatalf("event was not replaced: %+v", s.Events)
+	}
+
+	b, err := s.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ProviderSchema
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, s) {
+		t.Fatalf("JSON round trip: got %+v, want %+v", got, s)
+	}
+}
+
+func Test_SchemaRecorder(t *testing.T) {
+	r := newSchemaRecorder("Test.Provider", providerIDFromName("Test.Provider"))
+	for i := 0; i < 2; i++ {
+		b := getEventBuffers()
+		d := newEventDescriptor()
+		r.record(d, b.writeEvent(nil, "Event", 0, WithFields(IntField("n", i))))
+		b.release()
+	}
+	if len(r.seen) != 1 || len(r.schema.Events) != 1 {
+		t.Fatalf("expected one recorded event, got %+v", r.schema.Events)
+	}
+}
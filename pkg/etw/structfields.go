@@ -0,0 +1,191 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// outTypeNames maps the output type names that can be used in `etw` struct tags to
+// their outType values.
+var outTypeNames = map[string]outType{
+	"default":    outTypeDefault,
+	"noprint":    outTypeNoPrint,
+	"string":     outTypeString,
+	"bool":       outTypeBoolean,
+	"hex":        outTypeHex,
+	"pid":        outTypePID,
+	"tid":        outTypeTID,
+	"port":       outTypePort,
+	"ipv4":       outTypeIPv4,
+	"ipv6":       outTypeIPv6,
+	"xml":        outTypeXML,
+	"json":       outTypeJSON,
+	"win32error": outTypeWin32Error,
+	"ntstatus":   outTypeNTStatus,
+	"hresult":    outTypeHResult,
+	"utf8":       outTypeUTF8,
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// StructFields adds the exported fields of the struct v (or the struct that v points to)
+// to the event, as if each were added with [SmartField]. Fields that are structs are
+// added as nested structs, with their fields added the same way.
+//
+// The field names and output types can be customized with `etw` struct tags, in the form
+// `etw:"name,outtype"`:
+//
+//   - name is the name of the field in the event. If it is empty, the Go field name is used.
+//     If it is "-", the field is omitted.
+//   - outtype is an optional hint for how consumers should format the field's value, and
+//     is one of: default, noprint, string, bool, hex, pid, tid, port, ipv4, ipv6, xml, json,
+//     win32error, ntstatus, hresult, or utf8. Unknown output types are ignored.
+//
+// Embedded structs without a tag name have their fields added directly, as with
+// encoding/json. Nil pointer fields are omitted, and other pointers are dereferenced.
+//
+// If v is not a struct or a pointer to a struct, it is added as a single field named "value".
+func StructFields(v interface{}) FieldOpt {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct || rv.Type() == timeType {
+		return SmartField("value", v)
+	}
+	if !rv.CanAddr() {
+		// make a copy, so that fields can be addressed in structFieldOpts
+		p := reflect.New(rv.Type()).Elem()
+		p.Set(rv)
+		rv = p
+	}
+	opts := structFieldOpts(rv)
+	return func(em *eventMetadata, ed *eventData) {
+		for _, opt := range opts {
+			opt(em, ed)
+		}
+	}
+}
+
+// structFieldOpts returns a FieldOpt for each field of the struct rv that is added to the event.
+// rv must be addressable.
+func structFieldOpts(rv reflect.Value) []FieldOpt {
+	t := rv.Type()
+	opts := make([]FieldOpt, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			// unexported
+			continue
+		}
+		name, out, named := sf.Name, "", false
+		if tag, ok := sf.Tag.Lookup("etw"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name, named = parts[0], true
+			}
+			if len(parts) > 1 {
+				out = parts[1]
+			}
+		}
+
+		fv := rv.Field(i)
+		for fv.Kind() == reflect.Ptr {
+			if fv.IsNil() {
+				break
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr {
+			// nil pointer
+			continue
+		}
+
+		isStruct := fv.Kind() == reflect.Struct && fv.Type() != timeType
+		if sf.Anonymous && isStruct && !named {
+			opts = append(opts, structFieldOpts(fv)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			// unexported embedded non-struct
+			continue
+		}
+		if isStruct {
+			opts = append(opts, Struct(name, structFieldOpts(fv)...))
+			continue
+		}
+
+		v, ok := fieldValue(fv)
+		if !ok {
+			continue
+		}
+		opt := SmartField(name, v)
+		if ot, ok := outTypeNames[strings.ToLower(out)]; ok {
+			opt = withOutType(opt, ot)
+		}
+		opts = append(opts, opt)
+	}
+	return opts
+}
+
+// fieldValue returns the value of the struct field fv.
+//
+// A value reached through an unexported field cannot be converted to an interface, so if
+// it is a boolean, number, or string it is read with the typed accessors instead, and
+// otherwise it is not supported.
+func fieldValue(fv reflect.Value) (interface{}, bool) {
+	if fv.CanInterface() {
+		return fv.Interface(), true
+	}
+	switch fv.Kind() {
+	case reflect.Bool:
+		return fv.Bool(), true
+	case reflect.Int:
+		return int(fv.Int()), true
+	case reflect.Int8:
+		return int8(fv.Int()), true
+	case reflect.Int16:
+		return int16(fv.Int()), true
+	case reflect.Int32:
+		return int32(fv.Int()), true
+	case reflect.Int64:
+		return int64(fv.Int()), true //nolint:unconvert // make look consistent
+	case reflect.Uint:
+		return uint(fv.Uint()), true
+	case reflect.Uint8:
+		return uint8(fv.Uint()), true
+	case reflect.Uint16:
+		return uint16(fv.Uint()), true
+	case reflect.Uint32:
+		return uint32(fv.Uint()), true
+	case reflect.Uint64:
+		return uint64(fv.Uint()), true //nolint:unconvert // make look consistent
+	case reflect.Uintptr:
+		return uintptr(fv.Uint()), true
+	case reflect.Float32:
+		return float32(fv.Float()), true
+	case reflect.Float64:
+		return float64(fv.Float()), true //nolint:unconvert // make look consistent
+	case reflect.String:
+		return fv.String(), true
+	default:
+		return nil, false
+	}
+}
+
+// withOutType returns a FieldOpt that adds the same field as opt, but with the metadata
+// specifying the output type out.
+func withOutType(opt FieldOpt, out outType) FieldOpt {
+	return func(em *eventMetadata, ed *eventData) {
+		em.outTypeOverride = out
+		opt(em, ed)
+		em.outTypeOverride = outTypeDefault
+	}
+}
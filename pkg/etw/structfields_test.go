@@ -0,0 +1,134 @@
+//go:build windows
+// +build windows
+
+package etw
+
+import (
+	"bytes"
+	"testing"
+)
+
+type testBase struct {
+	ID   uint32
+	Tags []string
+}
+
+type testInner struct {
+	Path string
+}
+
+type testEvent struct {
+	testBase
+	Name    string
+	Code    uint32 `etw:"code,hex"`
+	Status  int32  `etw:",hresult"`
+	Ignored int    `etw:"-"`
+	Inner   testInner
+	Opt     *int
+	private int
+}
+
+func writeFields(opts ...FieldOpt) (*eventMetadata, *eventData) {
+	var em eventMetadata
+	var ed eventData
+	em.writeEventHeader("event", 0)
+	for _, opt := range opts {
+		opt(&em, &ed)
+	}
+	return &em, &ed
+}
+
+func TestStructFields(t *testing.T) {
+	n := 7
+	v := testEvent{
+		testBase: testBase{ID: 1, Tags: []string{"a"}},
+		Name:     "name",
+		Code:     0x80,
+		Status:   -1,
+		Ignored:  2,
+		Inner:    testInner{Path: `C:\`},
+		Opt:      &n,
+		private:  3,
+	}
+	em, ed := writeFields(StructFields(&v))
+
+	wantEM, wantED := writeFields(
+		Uint32Field("ID", 1),
+		StringArray("Tags", []string{"a"}),
+		StringField("Name", "name"),
+		func(em *eventMetadata, ed *eventData) {
+			em.writeField("code", inTypeUint32, outTypeHex, 0)
+			ed.writeUint32(0x80)
+		},
+		func(em *eventMetadata, ed *eventData) {
+			em.writeField("Status", inTypeInt32, outTypeHResult, 0)
+			ed.writeInt32(-1)
+		},
+		Struct("Inner", StringField("Path", `C:\`)),
+		IntField("Opt", 7),
+	)
+
+	if !bytes.Equal(em.toBytes(), wantEM.toBytes()) {
+		t.Fatalf("event metadata mismatch:\n%x\n%x", em.toBytes(), wantEM.toBytes())
+	}
+	if !bytes.Equal(ed.toBytes(), wantED.toBytes()) {
+		t.Fatalf("event data mismatch:\n%x\n%x", ed.toBytes(), wantED.toBytes())
+	}
+
+	// a nil pointer field is omitted
+	v.Opt = nil
+	em, _ = writeFields(StructFields(v))
+	wantEM, _ = writeFields(
+		Uint32Field("ID", 1),
+		StringArray("Tags", []string{"a"}),
+		StringField("Name", "name"),
+		func(em *eventMetadata, ed *eventData) { em.writeField("code", inTypeUint32, outTypeHex, 0) },
+		func(em *eventMetadata, ed *eventData) { em.writeField("Status", inTypeInt32, outTypeHResult, 0) },
+		Struct("Inner", StringField("Path", `C:\`)),
+	)
+	if !bytes.Equal(em.toBytes(), wantEM.toBytes()) {
+		t.Fatalf("event metadata mismatch:\n%x\n%x", em.toBytes(), wantEM.toBytes())
+	}
+}
+
+func TestStructFieldsCachedOutType(t *testing.T) {
+	type hexEvent struct {
+		Code uint32 `etw:"code,hex"`
+	}
+	type plainEvent struct {
+		Code uint32 `etw:"code"`
+	}
+	hexEM, _ := writeFields(func(em *eventMetadata, ed *eventData) {
+		em.writeField("code", inTypeUint32, outTypeHex, 0)
+	})
+	plainEM, _ := writeFields(Uint32Field("code", 0))
+
+	var c metadataCache
+	for _, tt := range []struct {
+		name string
+		v    interface{}
+		want []byte
+	}{
+		{"miss", hexEvent{Code: 1}, hexEM.toBytes()},
+		{"hit", hexEvent{Code: 2}, hexEM.toBytes()},
+		{"other out type", plainEvent{Code: 3}, plainEM.toBytes()},
+		{"hex again", hexEvent{Code: 4}, hexEM.toBytes()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			b := getEventBuffers()
+			defer b.release()
+			metadata := b.writeEvent(&c, "event", 0, []FieldOpt{StructFields(tt.v)})
+			if !bytes.Equal(metadata, tt.want) {
+				t.Fatalf("event metadata mismatch:\n%x\n%x", metadata, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructFieldsNotStruct(t *testing.T) {
+	em, ed := writeFields(StructFields(42))
+	wantEM, wantED := writeFields(IntField("value", 42))
+	if !bytes.Equal(em.toBytes(), wantEM.toBytes()) || !bytes.Equal(ed.toBytes(), wantED.toBytes()) {
+		t.Fatal("non-struct value was not added as a single field")
+	}
+}
@@ -39,6 +39,29 @@ func eventWriteTransfer(
 		dataDescriptors)
 }
 
+func eventWriteEx(
+	providerHandle providerHandle,
+	descriptor *eventDescriptor,
+	filter uint64,
+	flags uint32,
+	activityID *windows.GUID,
+	relatedActivityID *windows.GUID,
+	dataDescriptorCount uint32,
+	dataDescriptors *eventDataDescriptor) (win32err error) {
+
+	return eventWriteEx_32(
+		low(providerHandle),
+		high(providerHandle),
+		descriptor,
+		uint32(filter),
+		uint32(filter>>32),
+		flags,
+		activityID,
+		relatedActivityID,
+		dataDescriptorCount,
+		dataDescriptors)
+}
+
 func eventSetInformation(
 	providerHandle providerHandle,
 	class eventInfoClass,
@@ -29,6 +29,26 @@ func eventWriteTransfer(
 		dataDescriptors)
 }
 
+func eventWriteEx(
+	providerHandle providerHandle,
+	descriptor *eventDescriptor,
+	filter uint64,
+	flags uint32,
+	activityID *windows.GUID,
+	relatedActivityID *windows.GUID,
+	dataDescriptorCount uint32,
+	dataDescriptors *eventDataDescriptor) (win32err error) {
+	return eventWriteEx_64(
+		providerHandle,
+		descriptor,
+		filter,
+		flags,
+		activityID,
+		relatedActivityID,
+		dataDescriptorCount,
+		dataDescriptors)
+}
+
 func eventSetInformation(
 	providerHandle providerHandle,
 	class eventInfoClass,
@@ -42,12 +42,22 @@ func errnoErr(e syscall.Errno) error {
 var (
 	modadvapi32 = windows.NewLazySystemDLL("advapi32.dll")
 
-	procEventRegister       = modadvapi32.NewProc("EventRegister")
-	procEventSetInformation = modadvapi32.NewProc("EventSetInformation")
-	procEventUnregister     = modadvapi32.NewProc("EventUnregister")
-	procEventWriteTransfer  = modadvapi32.NewProc("EventWriteTransfer")
+	procEventActivityIdControl = modadvapi32.NewProc("EventActivityIdControl")
+	procEventRegister          = modadvapi32.NewProc("EventRegister")
+	procEventSetInformation    = modadvapi32.NewProc("EventSetInformation")
+	procEventUnregister        = modadvapi32.NewProc("EventUnregister")
+	procEventWriteEx           = modadvapi32.NewProc("EventWriteEx")
+	procEventWriteTransfer     = modadvapi32.NewProc("EventWriteTransfer")
 )
 
+func eventActivityIDControl(controlCode uint32, activityID *windows.GUID) (win32err error) {
+	r0, _, _ := syscall.Syscall(procEventActivityIdControl.Addr(), 2, uintptr(controlCode), uintptr(unsafe.Pointer(activityID)), 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
 func eventRegister(providerId *windows.GUID, callback uintptr, callbackContext uintptr, providerHandle *providerHandle) (win32err error) {
 	r0, _, _ := syscall.Syscall6(procEventRegister.Addr(), 4, uintptr(unsafe.Pointer(providerId)), uintptr(callback), uintptr(callbackContext), uintptr(unsafe.Pointer(providerHandle)), 0, 0)
 	if r0 != 0 {
@@ -88,6 +98,22 @@ func eventUnregister_32(providerHandle_low uint32, providerHandle_high uint32) (
 	return
 }
 
+func eventWriteEx_64(providerHandle providerHandle, descriptor *eventDescriptor, filter uint64, flags uint32, activityID *windows.GUID, relatedActivityID *windows.GUID, dataDescriptorCount uint32, dataDescriptors *eventDataDescriptor) (win32err error) {
+	r0, _, _ := syscall.Syscall9(procEventWriteEx.Addr(), 8, uintptr(providerHandle), uintptr(unsafe.Pointer(descriptor)), uintptr(filter), uintptr(flags), uintptr(unsafe.Pointer(activityID)), uintptr(unsafe.Pointer(relatedActivityID)), uintptr(dataDescriptorCount), uintptr(unsafe.Pointer(dataDescriptors)), 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func eventWriteEx_32(providerHandle_low uint32, providerHandle_high uint32, descriptor *eventDescriptor, filter_low uint32, filter_high uint32, flags uint32, activityID *windows.GUID, relatedActivityID *windows.GUID, dataDescriptorCount uint32, dataDescriptors *eventDataDescriptor) (win32err error) {
+	r0, _, _ := syscall.Syscall12(procEventWriteEx.Addr(), 10, uintptr(providerHandle_low), uintptr(providerHandle_high), uintptr(unsafe.Pointer(descriptor)), uintptr(filter_low), uintptr(filter_high), uintptr(flags), uintptr(unsafe.Pointer(activityID)), uintptr(unsafe.Pointer(relatedActivityID)), uintptr(dataDescriptorCount), uintptr(unsafe.Pointer(dataDescriptors)), 0, 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
 func eventWriteTransfer_64(providerHandle providerHandle, descriptor *eventDescriptor, activityID *windows.GUID, relatedActivityID *windows.GUID, dataDescriptorCount uint32, dataDescriptors *eventDataDescriptor) (win32err error) {
 	r0, _, _ := syscall.Syscall6(procEventWriteTransfer.Addr(), 6, uintptr(providerHandle), uintptr(unsafe.Pointer(descriptor)), uintptr(unsafe.Pointer(activityID)), uintptr(unsafe.Pointer(relatedActivityID)), uintptr(dataDescriptorCount), uintptr(unsafe.Pointer(dataDescriptors)))
 	if r0 != 0 {
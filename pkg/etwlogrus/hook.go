@@ -5,7 +5,10 @@ package etwlogrus
 
 import (
 	"errors"
+	"reflect"
 	"sort"
+	"sync"
+	"sync/atomic"
 
 	"github.com/sirupsen/logrus"
 
@@ -22,12 +25,32 @@ type HookOpt func(*Hook) error
 
 // Hook is a Logrus hook which logs received events to ETW.
 type Hook struct {
+	// number of events dropped because the queue was full; first for 64-bit alignment
+	dropped uint64
+
 	provider      *etw.Provider
 	closeProvider bool
+	// selects the provider to log an entry to, instead of provider
+	getProvider func(*logrus.Entry) *etw.Provider
 	// allows setting the entry name
 	getName func(*logrus.Entry) string
 	// returns additional options to add to the event
 	getEventsOpts func(*logrus.Entry) []etw.EventOpt
+
+	// if queueSize is set, events are queued and written by a background goroutine
+	queueSize int
+	queue     chan *queuedEvent
+	queueMu   sync.RWMutex // held for writing while closing queue
+	closed    bool
+	done      chan struct{}
+}
+
+// queuedEvent is an event waiting to be written by a non-blocking hook.
+type queuedEvent struct {
+	provider *etw.Provider
+	name     string
+	opts     []etw.EventOpt
+	fields   []etw.FieldOpt
 }
 
 // NewHook registers a new ETW provider and returns a hook to log from it.
@@ -56,7 +79,15 @@ func NewHookFromOpts(opts ...HookOpt) (*Hook, error) {
 			return nil, err
 		}
 	}
-	return h, h.validate()
+	if err := h.validate(); err != nil {
+		return h, err
+	}
+	if h.queueSize > 0 {
+		h.queue = make(chan *queuedEvent, h.queueSize)
+		h.done = make(chan struct{})
+		go h.writeQueued()
+	}
+	return h, nil
 }
 
 func defaultHook() *Hook {
@@ -93,7 +124,8 @@ func (h *Hook) Fire(e *logrus.Entry) error {
 	// easiest when using a consistent set of levels across ETW providers, so we
 	// map the Logrus levels to ETW levels.
 	level := logrusToETWLevelMap[e.Level]
-	if !h.provider.IsEnabledForLevel(level) {
+	provider := h.providerFor(e)
+	if !provider.IsEnabledForLevel(level) {
 		return nil
 	}
 
@@ -104,10 +136,13 @@ func (h *Hook) Fire(e *logrus.Entry) error {
 		}
 	}
 
-	// extra room for two more options in addition to log level to avoid repeated reallocations
-	// if the user also provides options
-	opts := make([]etw.EventOpt, 0, 3)
+	// extra room for two more options in addition to log level and activity ID to avoid
+	// repeated reallocations if the user also provides options
+	opts := make([]etw.EventOpt, 0, 4)
 	opts = append(opts, etw.WithLevel(level))
+	if e.Context != nil {
+		opts = append(opts, etw.WithContextActivityID(e.Context))
+	}
 	if h.getEventsOpts != nil {
 		opts = append(opts, h.getEventsOpts(e)...)
 	}
@@ -131,10 +166,15 @@ func (h *Hook) Fire(e *logrus.Entry) error {
 	fields = append(fields, etw.StringField("Message", e.Message))
 	fields = append(fields, etw.Time("Time", e.Time))
 	for _, k := range names {
-		fields = append(fields, etw.SmartField(k, e.Data[k]))
+		fields = append(fields, etw.SmartField(k, h.fieldValue(e.Data[k])))
 	}
 	if hasError {
-		fields = append(fields, etw.SmartField(logrus.ErrorKey, e.Data[logrus.ErrorKey]))
+		fields = append(fields, etw.SmartField(logrus.ErrorKey, h.fieldValue(e.Data[logrus.ErrorKey])))
+	}
+
+	if h.queueSize > 0 {
+		h.enqueue(&queuedEvent{provider: provider, name: name, opts: opts, fields: fields})
+		return nil
 	}
 
 	// Firing an ETW event is essentially best effort, as the event write can
@@ -142,15 +182,105 @@ func (h *Hook) Fire(e *logrus.Entry) error {
 	// as a session listening for the event having no available space in its
 	// buffers). Therefore, we don't return the error from WriteEvent, as it is
 	// just noise in many cases.
-	_ = h.provider.WriteEvent(name, opts, fields)
+	_ = provider.WriteEvent(name, opts, fields)
 
 	return nil
 }
 
+// fieldValue returns the value v of an entry field to pass to [etw.SmartField].
+//
+// Field options only read slices when the event is written, so if the event is queued the
+// slices in v are copied, in case the caller modifies them after logging the entry.
+func (h *Hook) fieldValue(v interface{}) interface{} {
+	if h.queueSize <= 0 || v == nil {
+		return v
+	}
+	return copyValue(reflect.ValueOf(v)).Interface()
+}
+
+// copyValue returns a copy of rv that shares no slices with it. Only the slices that
+// [etw.SmartField] reads are copied: slices and the exported fields of structs.
+func copyValue(rv reflect.Value) reflect.Value {
+	switch rv.Kind() {
+	case reflect.Slice:
+		if rv.IsNil() {
+			return rv
+		}
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(c, rv)
+		return c
+	case reflect.Struct:
+		c := reflect.New(rv.Type()).Elem()
+		c.Set(rv)
+		for i := 0; i < c.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(copyValue(f))
+			}
+		}
+		return c
+	default:
+		// other kinds are formatted when the field is created
+		return rv
+	}
+}
+
+// enqueue queues ev to be written by the background goroutine, or drops it if the queue is
+// full or the hook is closed.
+func (h *Hook) enqueue(ev *queuedEvent) {
+	h.queueMu.RLock()
+	defer h.queueMu.RUnlock()
+	if !h.closed {
+		select {
+		case h.queue <- ev:
+			return
+		default:
+		}
+	}
+	atomic.AddUint64(&h.dropped, 1)
+}
+
+// writeQueued writes the queued events until the queue is closed.
+func (h *Hook) writeQueued() {
+	defer close(h.done)
+	for ev := range h.queue {
+		// as in Fire, errors writing the event are ignored
+		_ = ev.provider.WriteEvent(ev.name, ev.opts, ev.fields)
+	}
+}
+
+// Dropped returns the number of entries that a hook created with [WithNonBlocking] has
+// dropped, because its queue was full or the hook was closed.
+func (h *Hook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// providerFor returns the provider to log e to.
+func (h *Hook) providerFor(e *logrus.Entry) *etw.Provider {
+	if h.getProvider != nil {
+		if p := h.getProvider(e); p != nil {
+			return p
+		}
+	}
+	return h.provider
+}
+
 // Close cleans up the hook and closes the ETW provider. If the provder was
 // registered by etwlogrus, it will be closed as part of `Close`. If the
-// provider was passed in, it will not be closed.
+// provider was passed in, it will not be closed. Providers selected with
+// [WithGetProvider] or [WithFieldProviders] are never closed.
+//
+// For a hook created with [WithNonBlocking], Close waits for the queued events
+// to be written first.
 func (h *Hook) Close() error {
+	if h.queue != nil {
+		h.queueMu.Lock()
+		if !h.closed {
+			h.closed = true
+			close(h.queue)
+		}
+		h.queueMu.Unlock()
+		<-h.done
+	}
 	if h.closeProvider {
 		return h.provider.Close()
 	}
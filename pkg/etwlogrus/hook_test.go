@@ -4,9 +4,13 @@
 package etwlogrus
 
 import (
+	"context"
 	"testing"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/Microsoft/go-winio/pkg/etw"
+	"github.com/Microsoft/go-winio/pkg/guid"
 )
 
 func fireEvent(name string, value interface{}) {
@@ -98,3 +102,102 @@ func TestFieldLogging(t *testing.T) {
 	// Unexported fields, and fields in embedded structs, should not log.
 	fireEvent("Struct", struct3{struct2{-1, -2}, 1, "2s", "-3s", struct1{3.4, -4, []uint{5, 6, 7}}, 8})
 }
+
+func TestFieldProviders(t *testing.T) {
+	storage, err := etw.NewProvider("HookTestStorage", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer storage.Close()
+
+	h, err := NewHook("HookTest", WithFieldProviders("subsystem", map[string]*etw.Provider{
+		"storage": storage,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	l := logrus.New()
+	for _, tc := range []struct {
+		data logrus.Fields
+		want *etw.Provider
+	}{
+		{logrus.Fields{"subsystem": "storage"}, storage},
+		{logrus.Fields{"subsystem": "network"}, h.provider},
+		{logrus.Fields{}, h.provider},
+	} {
+		if p := h.providerFor(l.WithFields(tc.data)); p != tc.want {
+			t.Errorf("fields %v: got provider %p, expected %p", tc.data, p, tc.want)
+		}
+	}
+
+	// entries with an activity ID in their context are logged without error
+	id, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := l.WithContext(etw.ContextWithActivityID(context.Background(), id)).WithField("subsystem", "storage")
+	e.Message = "activity"
+	if err := h.Fire(e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNonBlocking(t *testing.T) {
+	h, err := NewHook("HookTest", WithNonBlocking(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logrus.New()
+	l.AddHook(h)
+	for i := 0; i < 100; i++ {
+		l.WithField("i", i).Info("NonBlocking")
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// queued entries are written before Close returns, and entries logged afterward are
+	// dropped
+	dropped := h.Dropped()
+	h.enqueue(&queuedEvent{})
+	if h.Dropped() != dropped+1 {
+		t.Fatalf("expected entry logged after Close to be dropped")
+	}
+}
+
+func TestNonBlockingCopiesFields(t *testing.T) {
+	type fields struct {
+		Names []string
+	}
+	h := &Hook{queueSize: 1}
+
+	b := []byte{1, 2}
+	v := fields{Names: []string{"a"}}
+	cb := h.fieldValue(b).([]byte)
+	cv := h.fieldValue(v).(fields)
+	b[0] = 3
+	v.Names[0] = "b"
+	if cb[0] != 1 || cv.Names[0] != "a" {
+		t.Fatal("field values were not copied when the fields were created")
+	}
+}
+
+func TestNonBlockingQueueFull(t *testing.T) {
+	// without a goroutine writing the events, the queue fills up
+	h := &Hook{queueSize: 2, queue: make(chan *queuedEvent, 2)}
+	for i := 0; i < 5; i++ {
+		h.enqueue(&queuedEvent{})
+	}
+	if d := h.Dropped(); d != 3 {
+		t.Fatalf("expected 3 dropped entries, got %d", d)
+	}
+}
+
+func TestNonBlockingInvalidQueueSize(t *testing.T) {
+	if _, err := NewHook("HookTest", WithNonBlocking(0)); err == nil {
+		t.Fatal("expected error for queue size 0")
+	}
+}
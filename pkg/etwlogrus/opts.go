@@ -3,6 +3,8 @@
 package etwlogrus
 
 import (
+	"fmt"
+
 	"github.com/sirupsen/logrus"
 
 	"github.com/Microsoft/go-winio/pkg/etw"
@@ -51,3 +53,48 @@ func WithEventOpts(f func(*logrus.Entry) []etw.EventOpt) HookOpt {
 		return nil
 	}
 }
+
+// WithNonBlocking makes the hook write events to ETW from a background goroutine, so that
+// goroutines logging entries are not stalled by slow ETW sessions. Up to queueSize events
+// are queued; entries logged while the queue is full are dropped, and counted by
+// [Hook.Dropped].
+func WithNonBlocking(queueSize int) HookOpt {
+	return func(h *Hook) error {
+		if queueSize <= 0 {
+			return fmt.Errorf("invalid queue size %d", queueSize)
+		}
+		h.queueSize = queueSize
+		return nil
+	}
+}
+
+// WithGetProvider logs each entry to the provider returned by f, allowing entries to be
+// split across several providers (such as one per subsystem). If f returns nil, the
+// hook's provider is used.
+//
+// The providers returned by f are not closed when the hook is closed.
+func WithGetProvider(f func(*logrus.Entry) *etw.Provider) HookOpt {
+	return func(h *Hook) error {
+		h.getProvider = f
+		return nil
+	}
+}
+
+// WithFieldProviders logs entries to the provider in providers keyed by the value of the
+// entry's field, formatted with fmt.Sprint. Entries without the field, or with a value not
+// in providers, are logged to the hook's provider.
+//
+// The providers are not closed when the hook is closed.
+func WithFieldProviders(field string, providers map[string]*etw.Provider) HookOpt {
+	return WithGetProvider(func(e *logrus.Entry) *etw.Provider {
+		v, ok := e.Data[field]
+		if !ok {
+			return nil
+		}
+		s, ok := v.(string)
+		if !ok {
+			s = fmt.Sprint(v)
+		}
+		return providers[s]
+	})
+}
@@ -0,0 +1,19 @@
+module github.com/Microsoft/go-winio/pkg/etwotel
+
+go 1.17
+
+require (
+	github.com/Microsoft/go-winio v0.0.0-00010101000000-000000000000
+	go.opentelemetry.io/otel v1.10.0
+	go.opentelemetry.io/otel/sdk v1.10.0
+	go.opentelemetry.io/otel/trace v1.10.0
+)
+
+require (
+	github.com/go-logr/logr v1.2.3 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	golang.org/x/sys v0.10.0 // indirect
+)
+
+// The package is developed alongside go-winio, and uses it from this repository.
+replace github.com/Microsoft/go-winio => ../..
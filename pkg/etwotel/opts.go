@@ -0,0 +1,54 @@
+//go:build windows
+// +build windows
+
+package etwotel
+
+import (
+	"github.com/Microsoft/go-winio/pkg/etw"
+)
+
+// ProcessorOpt is an option to change the behavior of the ETW span processor.
+type ProcessorOpt func(*SpanProcessor) error
+
+// WithNewETWProvider registers a new ETW provider and sets the processor to write to it.
+// The provider will be closed when the processor is shut down.
+func WithNewETWProvider(n string) ProcessorOpt {
+	return func(p *SpanProcessor) error {
+		provider, err := etw.NewProvider(n, nil)
+		if err != nil {
+			return err
+		}
+
+		p.provider = provider
+		p.closeProvider = true
+		return nil
+	}
+}
+
+// WithExistingETWProvider configures the processor to use an existing ETW provider.
+// The provider will not be closed when the processor is shut down.
+func WithExistingETWProvider(provider *etw.Provider) ProcessorOpt {
+	return func(p *SpanProcessor) error {
+		p.provider = provider
+		p.closeProvider = false
+		return nil
+	}
+}
+
+// WithLevel sets the level of the events, which is etw.LevelInfo by default. The stop events
+// of spans whose status is an error are written at etw.LevelError, if that is more severe.
+func WithLevel(level etw.Level) ProcessorOpt {
+	return func(p *SpanProcessor) error {
+		p.level = level
+		return nil
+	}
+}
+
+// WithKeyword sets the keyword of the events, so that sessions can enable the span events of
+// a provider separately from its other events.
+func WithKeyword(keyword uint64) ProcessorOpt {
+	return func(p *SpanProcessor) error {
+		p.keyword = keyword
+		return nil
+	}
+}
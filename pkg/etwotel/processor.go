@@ -0,0 +1,221 @@
+//go:build windows
+// +build windows
+
+// Package etwotel writes OpenTelemetry spans to ETW, so that services instrumented with
+// OpenTelemetry can be traced with the Windows Performance Recorder and analyzed in the
+// Windows Performance Analyzer.
+package etwotel
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Microsoft/go-winio/pkg/etw"
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// ErrNoProvider is returned when a processor is created without a provider being configured.
+var ErrNoProvider = errors.New("no ETW registered provider")
+
+// SpanProcessor is an OpenTelemetry span processor that writes an ETW start event when a span
+// starts, and a stop event when it ends, preceded by an event for each of the span's events.
+// All the events of a span have its [ActivityID], and the start event has its parent's as
+// the related activity ID, so that tools can group the events into activities and rebuild
+// the trace.
+//
+// Register it with sdktrace.WithSpanProcessor. The events are written synchronously, and only
+// if an ETW session is listening to the provider.
+type SpanProcessor struct {
+	provider      *etw.Provider
+	closeProvider bool
+	level         etw.Level
+	keyword       uint64
+	shutdown      int32
+}
+
+var _ sdktrace.SpanProcessor = &SpanProcessor{}
+
+// NewSpanProcessor registers a new ETW provider and returns a processor that writes spans to
+// it. The provider is closed when the processor is shut down.
+func NewSpanProcessor(providerName string, opts ...ProcessorOpt) (*SpanProcessor, error) {
+	opts = append(opts, WithNewETWProvider(providerName))
+
+	return NewSpanProcessorFromOpts(opts...)
+}
+
+// NewSpanProcessorFromProvider returns a processor that writes spans to an existing ETW
+// provider. The provider is not closed when the processor is shut down.
+func NewSpanProcessorFromProvider(provider *etw.Provider, opts ...ProcessorOpt) (*SpanProcessor, error) {
+	opts = append(opts, WithExistingETWProvider(provider))
+
+	return NewSpanProcessorFromOpts(opts...)
+}
+
+// NewSpanProcessorFromOpts creates a new processor with the provided options.
+// An error is returned if the processor does not have a valid provider.
+func NewSpanProcessorFromOpts(opts ...ProcessorOpt) (*SpanProcessor, error) {
+	p := &SpanProcessor{level: etw.LevelInfo}
+
+	for _, o := range opts {
+		if err := o(p); err != nil {
+			return nil, err
+		}
+	}
+	if p.provider == nil {
+		return nil, ErrNoProvider
+	}
+	return p, nil
+}
+
+// ActivityID returns the ETW activity ID of the span identified by sc. Its first eight bytes
+// are those of the trace ID, and the last eight are the span ID, so that the spans of a trace
+// sort together.
+func ActivityID(sc trace.SpanContext) guid.GUID {
+	var b [16]byte
+	tid, sid := sc.TraceID(), sc.SpanID()
+	copy(b[:8], tid[:8])
+	copy(b[8:], sid[:])
+	return guid.FromArray(b)
+}
+
+// OnStart writes the start event of s.
+func (p *SpanProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if !p.enabled(p.level) {
+		return
+	}
+
+	sc := s.SpanContext()
+	opts := []etw.EventOpt{
+		etw.WithLevel(p.level),
+		etw.WithKeyword(p.keyword),
+		etw.WithOpcode(etw.OpcodeStart),
+		etw.WithActivityID(ActivityID(sc)),
+	}
+	parent := s.Parent()
+	if parent.IsValid() {
+		opts = append(opts, etw.WithRelatedActivityID(ActivityID(parent)))
+	}
+
+	attrs := s.Attributes()
+	fields := make([]etw.FieldOpt, 0, len(attrs)+4)
+	fields = append(fields,
+		etw.StringField("TraceID", sc.TraceID().String()),
+		etw.StringField("SpanID", sc.SpanID().String()),
+		etw.StringField("ParentSpanID", parentSpanID(parent)),
+		etw.StringField("Kind", s.SpanKind().String()),
+	)
+	fields = appendAttributes(fields, attrs)
+
+	// As with other ETW writers, failing to write the event is not reported: the write can fail
+	// for reasons outside of the writer's control, such as a session being out of buffers.
+	_ = p.provider.WriteEvent(s.Name(), opts, fields)
+}
+
+// OnEnd writes the events of s, and its stop event.
+func (p *SpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	level := p.level
+	status := s.Status()
+	if status.Code == codes.Error && level > etw.LevelError {
+		level = etw.LevelError
+	}
+	// level is at least as severe as p.level, so it is enabled if any event is
+	if !p.enabled(level) {
+		return
+	}
+
+	sc := s.SpanContext()
+	activityID := ActivityID(sc)
+	events := make([]*etw.Event, 0, len(s.Events())+1)
+	if p.enabled(p.level) {
+		for _, e := range s.Events() {
+			fields := make([]etw.FieldOpt, 0, len(e.Attributes)+3)
+			fields = append(fields,
+				etw.StringField("TraceID", sc.TraceID().String()),
+				etw.StringField("SpanID", sc.SpanID().String()),
+				etw.Time("Time", e.Time),
+			)
+			events = append(events, &etw.Event{
+				Name: e.Name,
+				EventOpts: []etw.EventOpt{
+					etw.WithLevel(p.level),
+					etw.WithKeyword(p.keyword),
+					etw.WithOpcode(etw.OpcodeInfo),
+					etw.WithActivityID(activityID),
+				},
+				FieldOpts: appendAttributes(fields, e.Attributes),
+			})
+		}
+	}
+
+	attrs := s.Attributes()
+	fields := make([]etw.FieldOpt, 0, len(attrs)+5)
+	fields = append(fields,
+		etw.StringField("TraceID", sc.TraceID().String()),
+		etw.StringField("SpanID", sc.SpanID().String()),
+		etw.StringField("StatusCode", status.Code.String()),
+		etw.StringField("StatusDescription", status.Description),
+		etw.Int64Field("DurationNs", s.EndTime().Sub(s.StartTime()).Nanoseconds()),
+	)
+	events = append(events, &etw.Event{
+		Name: s.Name(),
+		EventOpts: []etw.EventOpt{
+			etw.WithLevel(level),
+			etw.WithKeyword(p.keyword),
+			etw.WithOpcode(etw.OpcodeStop),
+			etw.WithActivityID(activityID),
+		},
+		FieldOpts: appendAttributes(fields, attrs),
+	})
+
+	// errors are ignored, as in OnStart
+	_ = p.provider.WriteEvents(events)
+}
+
+// Shutdown stops the processor from writing events, and closes the provider if it was
+// registered by the processor.
+func (p *SpanProcessor) Shutdown(context.Context) error {
+	if !atomic.CompareAndSwapInt32(&p.shutdown, 0, 1) {
+		return nil
+	}
+	if p.closeProvider {
+		return p.provider.Close()
+	}
+	return nil
+}
+
+// ForceFlush does nothing, as the events are written synchronously.
+func (*SpanProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+// enabled reports whether events should be written at level.
+func (p *SpanProcessor) enabled(level etw.Level) bool {
+	return atomic.LoadInt32(&p.shutdown) == 0 && p.provider.IsEnabledForLevelAndKeywords(level, p.keyword)
+}
+
+func parentSpanID(parent trace.SpanContext) string {
+	if !parent.IsValid() {
+		return ""
+	}
+	return parent.SpanID().String()
+}
+
+// appendAttributes appends a field for each attribute to fields. The attributes are sorted
+// by key, so that the fields are consistent between instances of an event, which WPA needs
+// to line them up.
+func appendAttributes(fields []etw.FieldOpt, attrs []attribute.KeyValue) []etw.FieldOpt {
+	sorted := make([]attribute.KeyValue, len(attrs))
+	copy(sorted, attrs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	for _, kv := range sorted {
+		fields = append(fields, etw.SmartField(string(kv.Key), kv.Value.AsInterface()))
+	}
+	return fields
+}
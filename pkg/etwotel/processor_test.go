@@ -0,0 +1,60 @@
+//go:build windows
+// +build windows
+
+package etwotel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Microsoft/go-winio/pkg/etw"
+)
+
+func TestActivityID(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10},
+		SpanID:  trace.SpanID{0xa1, 0xa2, 0xa3, 0xa4, 0xa5, 0xa6, 0xa7, 0xa8},
+	})
+	if s, want := ActivityID(sc).String(), "01020304-0506-0708-a1a2-a3a4a5a6a7a8"; s != want {
+		t.Fatalf("expected %s, got %s", want, s)
+	}
+}
+
+func TestNoProvider(t *testing.T) {
+	if _, err := NewSpanProcessorFromOpts(WithLevel(etw.LevelVerbose)); !errors.Is(err, ErrNoProvider) {
+		t.Fatalf("expected ErrNoProvider, got %v", err)
+	}
+}
+
+// As for etwlogrus, the events cannot be validated programmatically: this checks that writing
+// them does not fail, and allows them to be checked manually with a tool such as WPA.
+func TestSpanProcessor(t *testing.T) {
+	p, err := NewSpanProcessor("SpanProcessorTest", WithKeyword(0x10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(p))
+	tracer := tp.Tracer("etwotel")
+
+	ctx, parent := tracer.Start(context.Background(), "Parent",
+		trace.WithAttributes(attribute.String("b", "value"), attribute.Int64("a", 1)))
+	_, child := tracer.Start(ctx, "Child", trace.WithSpanKind(trace.SpanKindClient))
+	child.AddEvent("Event", trace.WithAttributes(attribute.BoolSlice("c", []bool{true, false})))
+	child.SetStatus(codes.Error, "failed")
+	child.End()
+	parent.End()
+
+	if err := tp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the processor is already shut down
+	if err := p.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
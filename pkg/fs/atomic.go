@@ -0,0 +1,230 @@
+//go:build windows
+
+package fs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// preservedAttributes are the attributes of a replaced file that [WriteFileAtomic] copies to
+// the new file.
+const preservedAttributes = windows.FILE_ATTRIBUTE_READONLY | windows.FILE_ATTRIBUTE_HIDDEN |
+	windows.FILE_ATTRIBUTE_SYSTEM | windows.FILE_ATTRIBUTE_ARCHIVE | windows.FILE_ATTRIBUTE_NOT_CONTENT_INDEXED
+
+// fileRenameInfo is FILE_RENAME_INFO, with the Flags member of the union; FileName is variable
+// length.
+type fileRenameInfo struct {
+	Flags          uint32
+	RootDirectory  windows.Handle
+	FileNameLength uint32
+	FileName       [1]uint16
+}
+
+// WriteFileOptions are the options for [WriteFileAtomic].
+type WriteFileOptions struct {
+	// SecurityDescriptor, if not nil, is applied to the file: its owner, group, and DACL, if
+	// present. Setting an owner other than the caller requires the restore privilege.
+	//
+	// If nil, the DACL of the file being replaced is preserved, and a new file inherits its
+	// security from its directory.
+	SecurityDescriptor *windows.SECURITY_DESCRIPTOR
+
+	// Attributes, if not zero, are the FILE_ATTRIBUTE_* values to set on the file. Otherwise,
+	// the read-only, hidden, system, archive, and not content indexed attributes of the file
+	// being replaced are preserved.
+	Attributes uint32
+}
+
+// WriteFileAtomic writes the contents of r to the file path, replacing it if it exists, so that
+// readers of path see either the old or the new contents, but never a partial file, even if
+// the system crashes.
+//
+// The contents are written to a temporary file in the same directory, which is flushed to disk,
+// given the security descriptor and attributes specified by opts (or those of the replaced
+// file), and then renamed over path. The rename uses POSIX semantics where the file system
+// supports them, so it succeeds even if path is open by another process, which continues to
+// see the old contents. If any step fails, the temporary file is removed and path is unchanged.
+func WriteFileAtomic(path string, r io.Reader, opts *WriteFileOptions) (err error) {
+	if opts == nil {
+		opts = &WriteFileOptions{}
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := createTemp(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := setSecurity(f, path, opts.SecurityDescriptor); err != nil {
+		return err
+	}
+	if err := setAttributes(f, path, opts.Attributes); err != nil {
+		return err
+	}
+	return renameOver(f, path)
+}
+
+// createTemp creates a uniquely named temporary file next to path, opened with the access needed
+// to set its security and rename it.
+func createTemp(path string) (*os.File, error) {
+	const access = windows.GENERIC_READ | windows.GENERIC_WRITE | windows.DELETE |
+		windows.READ_CONTROL | windows.WRITE_DAC | windows.WRITE_OWNER
+	dir, base := filepath.Split(path)
+	for i := 0; ; i++ {
+		var b [8]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, "."+base+"."+hex.EncodeToString(b[:])+".tmp")
+		name16, err := windows.UTF16PtrFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		h, err := windows.CreateFile(name16, access, 0, nil, windows.CREATE_NEW, windows.FILE_ATTRIBUTE_NORMAL, 0)
+		if errors.Is(err, windows.ERROR_FILE_EXISTS) && i < 10 {
+			continue
+		} else if err != nil {
+			return nil, &os.PathError{Op: "CreateFile", Path: name, Err: err}
+		}
+		return os.NewFile(uintptr(h), name), nil
+	}
+}
+
+// setSecurity applies sd to f, or, if sd is nil, the DACL of the file at path, if it exists.
+func setSecurity(f *os.File, path string, sd *windows.SECURITY_DESCRIPTOR) error {
+	var si windows.SECURITY_INFORMATION
+	if sd == nil {
+		var err error
+		sd, err = windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+		if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+			return nil
+		} else if err != nil {
+			return &os.PathError{Op: "GetNamedSecurityInfo", Path: path, Err: err}
+		}
+	} else {
+		if owner, _, err := sd.Owner(); err == nil && owner != nil {
+			si |= windows.OWNER_SECURITY_INFORMATION
+		}
+		if group, _, err := sd.Group(); err == nil && group != nil {
+			si |= windows.GROUP_SECURITY_INFORMATION
+		}
+	}
+
+	owner, _, _ := sd.Owner()
+	group, _, _ := sd.Group()
+	dacl, _, err := sd.DACL()
+	if err != nil && !errors.Is(err, windows.ERROR_OBJECT_NOT_FOUND) {
+		return err
+	}
+	if err == nil {
+		si |= windows.DACL_SECURITY_INFORMATION
+		// preserve whether the DACL inherits from the directory
+		if control, _, err := sd.Control(); err == nil && control&windows.SE_DACL_PROTECTED != 0 {
+			si |= windows.PROTECTED_DACL_SECURITY_INFORMATION
+		} else {
+			si |= windows.UNPROTECTED_DACL_SECURITY_INFORMATION
+		}
+	}
+	if si == 0 {
+		return nil
+	}
+	if err := windows.SetSecurityInfo(windows.Handle(f.Fd()), windows.SE_FILE_OBJECT, si, owner, group, dacl, nil); err != nil {
+		return &os.PathError{Op: "SetSecurityInfo", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// setAttributes sets attrs on f, or, if attrs is zero, the preserved attributes of the file at
+// path, if it exists.
+func setAttributes(f *os.File, path string, attrs uint32) error {
+	if attrs == 0 {
+		path16, err := windows.UTF16PtrFromString(path)
+		if err != nil {
+			return err
+		}
+		a, err := windows.GetFileAttributes(path16)
+		if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+			return nil
+		} else if err != nil {
+			return &os.PathError{Op: "GetFileAttributes", Path: path, Err: err}
+		}
+		if attrs = a & preservedAttributes; attrs == 0 {
+			return nil
+		}
+	}
+	// FILE_BASIC_INFO, with the times left unchanged
+	var bi struct {
+		CreationTime, LastAccessTime, LastWriteTime, ChangeTime int64
+		FileAttributes                                          uint32
+		_                                                       uint32
+	}
+	bi.FileAttributes = attrs
+	if err := windows.SetFileInformationByHandle(windows.Handle(f.Fd()), windows.FileBasicInfo,
+		(*byte)(unsafe.Pointer(&bi)), uint32(unsafe.Sizeof(bi))); err != nil {
+		return &os.PathError{Op: "SetFileInformationByHandle", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// renameOver renames f to path, replacing it. It uses POSIX semantics, ignoring the read-only
+// attribute of path, if the system and file system support them.
+func renameOver(f *os.File, path string) error {
+	name, err := windows.UTF16FromString(path)
+	if err != nil {
+		return err
+	}
+	name = name[:len(name)-1] // FileNameLength excludes the terminator
+	var info fileRenameInfo
+	size := unsafe.Offsetof(info.FileName) + uintptr(len(name))*2
+	// allocate as uint64s, for the alignment of RootDirectory
+	buf := make([]uint64, (size+7)/8)
+	ri := (*fileRenameInfo)(unsafe.Pointer(&buf[0]))
+	ri.FileNameLength = uint32(len(name) * 2)
+	copy(unsafe.Slice(&ri.FileName[0], len(name)), name)
+
+	rename := func(class uint32) error {
+		return windows.SetFileInformationByHandle(windows.Handle(f.Fd()), class,
+			(*byte)(unsafe.Pointer(ri)), uint32(size))
+	}
+	ri.Flags = windows.FILE_RENAME_REPLACE_IF_EXISTS | windows.FILE_RENAME_POSIX_SEMANTICS |
+		windows.FILE_RENAME_IGNORE_READONLY_ATTRIBUTE
+	err = rename(windows.FileRenameInfoEx)
+	if errors.Is(err, windows.ERROR_INVALID_PARAMETER) || errors.Is(err, windows.ERROR_NOT_SUPPORTED) ||
+		errors.Is(err, windows.ERROR_INVALID_FUNCTION) {
+		// FileRenameInfoEx is not supported before Windows 10 1607, or by some file systems;
+		// use FileRenameInfo, where the flags are the ReplaceIfExists BOOLEAN.
+		ri.Flags = 1
+		err = rename(windows.FileRenameInfo)
+	}
+	runtime.KeepAlive(f)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: f.Name(), New: path, Err: err}
+	}
+	return nil
+}
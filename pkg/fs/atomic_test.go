@@ -0,0 +1,91 @@
+//go:build windows
+
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+
+	if err := WriteFileAtomic(path, strings.NewReader("old"), nil); err != nil {
+		t.Fatal(err)
+	}
+	path16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := windows.SetFileAttributes(path16, windows.FILE_ATTRIBUTE_HIDDEN|windows.FILE_ATTRIBUTE_READONLY); err != nil {
+		t.Fatal(err)
+	}
+	defer windows.SetFileAttributes(path16, windows.FILE_ATTRIBUTE_NORMAL) //nolint:errcheck
+
+	// the file can be replaced while it is open, if deletes are shared
+	h, err := windows.CreateFile(path16, windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	if err := WriteFileAtomic(path, strings.NewReader("new"), nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "new" {
+		t.Fatalf("got contents %q, want %q", b, "new")
+	}
+	attrs, err := windows.GetFileAttributes(path16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint32(windows.FILE_ATTRIBUTE_HIDDEN | windows.FILE_ATTRIBUTE_READONLY); attrs&want != want {
+		t.Fatalf("attributes %#x were not preserved", attrs)
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("unexpected files in %s: %v", dir, entries)
+	}
+}
+
+func TestWriteFileAtomicSecurityDescriptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	sd, err := windows.SecurityDescriptorFromString("D:P(A;;FA;;;SY)(A;;FA;;;OW)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &WriteFileOptions{SecurityDescriptor: sd, Attributes: windows.FILE_ATTRIBUTE_ARCHIVE}
+	if err := WriteFileAtomic(path, strings.NewReader("data"), opts); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := windows.GetNamedSecurityInfo(path, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control, _, err := got.Control()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if control&windows.SE_DACL_PROTECTED == 0 {
+		t.Fatalf("DACL of %s is not protected: %s", path, got)
+	}
+	if s := got.String(); !strings.Contains(s, ";;;SY)") {
+		t.Fatalf("DACL of %s was not applied: %s", path, s)
+	}
+}
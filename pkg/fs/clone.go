@@ -0,0 +1,169 @@
+//go:build windows
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go ./clone.go
+
+//sys copyFile2(existingFileName string, newFileName string, extendedParameters *byte) (hr error) = kernel32.CopyFile2
+
+// ReFS integrity control codes
+// https://learn.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_get_integrity_information
+const (
+	fsctlGetIntegrityInformation = 0x0009027c // FSCTL_GET_INTEGRITY_INFORMATION
+	fsctlSetIntegrityInformation = 0x0009c280 // FSCTL_SET_INTEGRITY_INFORMATION
+)
+
+// maxCloneChunk is the largest range cloned by one FSCTL_DUPLICATE_EXTENTS_TO_FILE call, which
+// must be less than 4GB and a multiple of the cluster size.
+const maxCloneChunk = 1 << 31
+
+// getIntegrityInformationBuffer is FSCTL_GET_INTEGRITY_INFORMATION_BUFFER.
+type getIntegrityInformationBuffer struct {
+	ChecksumAlgorithm        uint16
+	Reserved                 uint16
+	Flags                    uint32
+	ChecksumChunkSizeInBytes uint32
+	ClusterSizeInBytes       uint32
+}
+
+// setIntegrityInformationBuffer is FSCTL_SET_INTEGRITY_INFORMATION_BUFFER.
+type setIntegrityInformationBuffer struct {
+	ChecksumAlgorithm uint16
+	Reserved          uint16
+	Flags             uint32
+}
+
+// duplicateExtentsData is DUPLICATE_EXTENTS_DATA. The handle is padded, since the offsets are
+// 8-byte aligned even on 32-bit platforms.
+type duplicateExtentsData struct {
+	FileHandle       windows.Handle
+	_                [8 - unsafe.Sizeof(windows.Handle(0))]byte
+	SourceFileOffset int64
+	TargetFileOffset int64
+	ByteCount        int64
+}
+
+// errCloneNotSupported is returned by cloneFile when the files cannot be block cloned, and
+// should be copied instead.
+var errCloneNotSupported = errors.New("block cloning not supported")
+
+// CloneFile copies the file src to dst, creating or truncating dst.
+//
+// On file systems that support block cloning, such as ReFS (including Dev Drives), the data
+// is cloned with FSCTL_DUPLICATE_EXTENTS_TO_FILE: the files share the underlying clusters until
+// either is modified, so the copy takes constant time regardless of the file's size. Otherwise,
+// including when src and dst are on different volumes, the file is copied with CopyFile2, which
+// also copies its attributes and alternate data streams.
+//
+// https://learn.microsoft.com/en-us/windows/win32/fileio/block-cloning
+func CloneFile(src, dst string) error {
+	err := cloneFile(src, dst)
+	if !errors.Is(err, errCloneNotSupported) {
+		return err
+	}
+	if err := copyFile2(src, dst, nil); err != nil {
+		return &os.LinkError{Op: "CopyFile2", Old: src, New: dst, Err: err}
+	}
+	return nil
+}
+
+func cloneFile(src, dst string) error {
+	s, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	fi, err := s.Stat()
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return &os.PathError{Op: "clone", Path: src, Err: windows.ERROR_INVALID_PARAMETER}
+	}
+	// Checking the source's integrity information also checks that its file system supports
+	// block cloning, before dst is created.
+	var integrity getIntegrityInformationBuffer
+	if err := fsctl(s, fsctlGetIntegrityInformation, nil, 0,
+		(*byte)(unsafe.Pointer(&integrity)), uint32(unsafe.Sizeof(integrity))); err != nil {
+		return cloneError("FSCTL_GET_INTEGRITY_INFORMATION", src, err)
+	}
+
+	d, err := os.OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	// the files must have the same sparseness and integrity settings to share clusters
+	if attrs := fi.Sys().(*syscall.Win32FileAttributeData).FileAttributes; attrs&windows.FILE_ATTRIBUTE_SPARSE_FILE != 0 {
+		if err := fsctl(d, windows.FSCTL_SET_SPARSE, nil, 0, nil, 0); err != nil {
+			return cloneError("FSCTL_SET_SPARSE", dst, err)
+		}
+	}
+	set := setIntegrityInformationBuffer{ChecksumAlgorithm: integrity.ChecksumAlgorithm, Flags: integrity.Flags}
+	if err := fsctl(d, fsctlSetIntegrityInformation,
+		(*byte)(unsafe.Pointer(&set)), uint32(unsafe.Sizeof(set)), nil, 0); err != nil {
+		return cloneError("FSCTL_SET_INTEGRITY_INFORMATION", dst, err)
+	}
+	size := fi.Size()
+	if err := d.Truncate(size); err != nil {
+		return err
+	}
+
+	// The cloned range must end on a cluster boundary, which may be beyond the end of the
+	// file, but not beyond the end of the file's last cluster.
+	cluster := int64(integrity.ClusterSizeInBytes)
+	if cluster == 0 {
+		return errCloneNotSupported
+	}
+	end := (size + cluster - 1) / cluster * cluster
+	for off := int64(0); off < end; off += maxCloneChunk {
+		n := end - off
+		if n > maxCloneChunk {
+			n = maxCloneChunk
+		}
+		data := duplicateExtentsData{
+			FileHandle:       windows.Handle(s.Fd()),
+			SourceFileOffset: off,
+			TargetFileOffset: off,
+			ByteCount:        n,
+		}
+		if err := fsctl(d, windows.FSCTL_DUPLICATE_EXTENTS_TO_FILE,
+			(*byte)(unsafe.Pointer(&data)), uint32(unsafe.Sizeof(data)), nil, 0); err != nil {
+			if off != 0 {
+				// dst has been partly cloned, so it cannot be copied instead
+				return &os.PathError{Op: "FSCTL_DUPLICATE_EXTENTS_TO_FILE", Path: dst, Err: err}
+			}
+			return cloneError("FSCTL_DUPLICATE_EXTENTS_TO_FILE", dst, err)
+		}
+	}
+	return nil
+}
+
+// cloneError returns errCloneNotSupported if err indicates that the file system or the
+// combination of files does not support block cloning, and an *os.PathError otherwise.
+func cloneError(op, path string, err error) error {
+	switch {
+	case errors.Is(err, windows.ERROR_INVALID_FUNCTION),
+		errors.Is(err, windows.ERROR_NOT_SUPPORTED),
+		errors.Is(err, windows.ERROR_NOT_SAME_DEVICE),
+		errors.Is(err, windows.ERROR_INVALID_PARAMETER):
+		return errCloneNotSupported
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
+
+func fsctl(f *os.File, code uint32, in *byte, inSize uint32, out *byte, outSize uint32) error {
+	var n uint32
+	return windows.DeviceIoControl(windows.Handle(f.Fd()), code, in, inSize, out, outSize, &n, nil)
+}
@@ -0,0 +1,45 @@
+//go:build windows
+
+package fs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	// not a multiple of the cluster size
+	b := bytes.Repeat([]byte("clone"), 100001)
+	if err := os.WriteFile(src, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// dst is truncated
+	if err := os.WriteFile(dst, bytes.Repeat([]byte("x"), 2*len(b)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CloneFile(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, b) {
+		t.Fatalf("cloned file has %d bytes, expected %d", len(got), len(b))
+	}
+}
+
+func TestCloneFileMissingSource(t *testing.T) {
+	dir := t.TempDir()
+	err := CloneFile(filepath.Join(dir, "missing"), filepath.Join(dir, "dst"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
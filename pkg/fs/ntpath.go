@@ -0,0 +1,228 @@
+//go:build windows
+
+package fs
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/pkg/stringbuffer"
+)
+
+// Path prefixes used by the conversion functions below.
+const (
+	extendedPrefix    = `\\?\`
+	extendedUNCPrefix = `\\?\UNC\`
+	ntPrefix          = `\??\`
+	ntUNCPrefix       = `\??\UNC\`
+	mupDevice         = `\Device\Mup`
+	globalRootPrefix  = `\\?\GLOBALROOT`
+)
+
+// ErrNotDevicePath is returned by [FromDevicePath] when the path is not an NT object path.
+var ErrNotDevicePath = errors.New("not an NT device path")
+
+// ToNTPath converts a Win32 path into the equivalent NT object manager path, which can be
+// passed to NT APIs such as NtCreateFile: for example, `C:\dir` becomes `\??\C:\dir`, and
+// `\\server\share` becomes `\??\UNC\server\share`. Relative paths are resolved against the
+// current directory.
+//
+// The conversion is done by RtlDosPathNameToNtPathName_U, the same function Win32 APIs use.
+func ToNTPath(path string) (string, error) {
+	p16, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", &os.PathError{Op: "RtlDosPathNameToNtPathName", Path: path, Err: err}
+	}
+	var nt windows.NTUnicodeString
+	if err := windows.RtlDosPathNameToNtPathName(p16, &nt, nil, nil); err != nil {
+		return "", &os.PathError{Op: "RtlDosPathNameToNtPathName", Path: path, Err: ntStatusErr(err)}
+	}
+	defer windows.LocalFree(windows.Handle(uintptr(unsafe.Pointer(nt.Buffer)))) //nolint:errcheck
+	return nt.String(), nil
+}
+
+// ToDevicePath converts a Win32 path into an NT path rooted at the device that contains it:
+// for example, `C:\dir` becomes `\Device\HarddiskVolume3\dir`, and `\\server\share` becomes
+// `\Device\Mup\server\share`.
+//
+// Unlike the paths returned by [ToNTPath], device paths do not depend on the caller's drive
+// letter mappings, so they can be compared with the paths reported by drivers.
+func ToDevicePath(path string) (string, error) {
+	nt, err := ToNTPath(path)
+	if err != nil {
+		return "", err
+	}
+	// \??\<device>\rest: resolve the symbolic link for the device in the DOS devices directory
+	rest := nt[len(ntPrefix):]
+	name := rest
+	if i := strings.IndexByte(rest, '\\'); i >= 0 {
+		name, rest = rest[:i], rest[i:]
+	} else {
+		rest = ""
+	}
+	target, err := queryDosDevice(name)
+	if err != nil {
+		return "", &os.PathError{Op: "QueryDosDevice", Path: path, Err: err}
+	}
+	return target + rest, nil
+}
+
+// FromDevicePath converts an NT path, such as one returned by [ToDevicePath] or [ToNTPath],
+// into a Win32 path:
+//   - Paths on a volume with a drive letter are converted to use that drive letter (`C:\dir`).
+//   - Paths on network shares are converted to UNC paths (`\\server\share`).
+//   - `\??\` paths are converted to the equivalent `\\?\` path, unless they are one of the
+//     above.
+//   - Other device paths are converted to `\\?\GLOBALROOT\Device\...` paths, which Win32
+//     APIs can open, but which are not understood by most path manipulation functions.
+//
+// It returns [ErrNotDevicePath] if path is not an NT path.
+func FromDevicePath(path string) (string, error) {
+	switch {
+	case hasPrefixFold(path, ntUNCPrefix):
+		return `\\` + path[len(ntUNCPrefix):], nil
+	case hasPrefixFold(path, ntPrefix):
+		p := path[len(ntPrefix):]
+		if len(p) >= 2 && p[1] == ':' {
+			return p, nil
+		}
+		return extendedPrefix + p, nil
+	case hasDevicePrefix(path, mupDevice):
+		return `\` + path[len(mupDevice):], nil
+	case !strings.HasPrefix(path, `\`) || strings.HasPrefix(path, `\\`):
+		return "", &os.PathError{Op: "FromDevicePath", Path: path, Err: ErrNotDevicePath}
+	}
+
+	drives, err := logicalDrives()
+	if err != nil {
+		return "", err
+	}
+	for _, d := range drives {
+		target, err := queryDosDevice(d)
+		if err != nil {
+			continue
+		}
+		if hasDevicePrefix(path, target) {
+			return d + path[len(target):], nil
+		}
+	}
+	return globalRootPrefix + path, nil
+}
+
+// ToExtendedPath converts a Win32 path into an absolute extended-length path, which begins
+// with `\\?\` and is not subject to the MAX_PATH limit or further normalization: for example,
+// `C:\dir` becomes `\\?\C:\dir`, and `\\server\share` becomes `\\?\UNC\server\share`. Paths
+// that already begin with `\\?\` or `\\.\` are returned unchanged.
+func ToExtendedPath(path string) (string, error) {
+	if strings.HasPrefix(path, extendedPrefix) || strings.HasPrefix(path, `\\.\`) {
+		return path, nil
+	}
+	p, err := windows.FullPath(path)
+	if err != nil {
+		return "", &os.PathError{Op: "GetFullPathName", Path: path, Err: err}
+	}
+	if strings.HasPrefix(p, `\\`) {
+		return extendedUNCPrefix + p[2:], nil
+	}
+	return extendedPrefix + p, nil
+}
+
+// FromExtendedPath converts an extended-length path into a regular Win32 path, if there is an
+// equivalent one: `\\?\C:\dir` becomes `C:\dir`, and `\\?\UNC\server\share` becomes
+// `\\server\share`. Other paths, including volume GUID paths, are returned unchanged.
+//
+// Note that the regular path may refer to a different file than the extended-length path, if
+// the path contains components that are normalized away, such as trailing dots or spaces.
+func FromExtendedPath(path string) string {
+	switch {
+	case hasPrefixFold(path, extendedUNCPrefix):
+		return `\\` + path[len(extendedUNCPrefix):]
+	case strings.HasPrefix(path, extendedPrefix):
+		p := path[len(extendedPrefix):]
+		if len(p) >= 2 && p[1] == ':' {
+			return p
+		}
+	}
+	return path
+}
+
+// ToVolumeGUIDPath converts a path on a local volume into a path rooted at the volume's GUID
+// path: for example, `C:\dir` becomes `\\?\Volume{8a25748f-cf34-4ac6-9ee2-c89400e886db}\dir`.
+// Volume GUID paths do not change if the volume is mounted at a different location.
+//
+// The path must exist up to its volume mount point. Network paths do not have volume GUID
+// paths, and fail.
+func ToVolumeGUIDPath(path string) (string, error) {
+	p, err := windows.FullPath(path)
+	if err != nil {
+		return "", &os.PathError{Op: "GetFullPathName", Path: path, Err: err}
+	}
+	p16, err := windows.UTF16PtrFromString(p)
+	if err != nil {
+		return "", &os.PathError{Op: "GetVolumePathName", Path: path, Err: err}
+	}
+	mp := make([]uint16, len(p)+2)
+	if err := windows.GetVolumePathName(p16, &mp[0], uint32(len(mp))); err != nil {
+		return "", &os.PathError{Op: "GetVolumePathName", Path: path, Err: err}
+	}
+	mountPoint := windows.UTF16ToString(mp)
+
+	vol := make([]uint16, windows.MAX_PATH)
+	if err := windows.GetVolumeNameForVolumeMountPoint(&mp[0], &vol[0], uint32(len(vol))); err != nil {
+		return "", &os.PathError{Op: "GetVolumeNameForVolumeMountPoint", Path: path, Err: err}
+	}
+	// both the mount point and volume name end in a backslash
+	return windows.UTF16ToString(vol) + p[len(mountPoint):], nil
+}
+
+// queryDosDevice returns the target of the DOS device name, such as `C:` or `UNC`.
+func queryDosDevice(name string) (string, error) {
+	n16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return "", err
+	}
+	// the result is a list of targets; the first (up to the null terminator) is the current one
+	return stringbuffer.Fill(func(p *uint16, n uint32) (uint32, error) {
+		m, err := windows.QueryDosDevice(n16, p, n)
+		if errors.Is(err, windows.ERROR_INSUFFICIENT_BUFFER) {
+			return n + 1, nil
+		}
+		return m, err
+	})
+}
+
+// logicalDrives returns the drive letters in use, such as `C:`.
+func logicalDrives() ([]string, error) {
+	mask, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil, os.NewSyscallError("GetLogicalDrives", err)
+	}
+	var drives []string
+	for i := 0; i < 26; i++ {
+		if mask&(1<<i) != 0 {
+			drives = append(drives, string(rune('A'+i))+":")
+		}
+	}
+	return drives, nil
+}
+
+// hasDevicePrefix returns true if path is device, or is within device.
+func hasDevicePrefix(path, device string) bool {
+	return hasPrefixFold(path, device) && (len(path) == len(device) || path[len(device)] == '\\')
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// ntStatusErr converts an NTSTATUS error into the equivalent Win32 error.
+func ntStatusErr(err error) error {
+	if st, ok := err.(windows.NTStatus); ok { //nolint:errorlint // err is NTStatus
+		return st.Errno()
+	}
+	return err
+}
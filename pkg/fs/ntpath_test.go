@@ -0,0 +1,122 @@
+//go:build windows
+
+package fs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestToNTPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{`C:\dir\file.txt`, `\??\C:\dir\file.txt`},
+		{`C:/dir/./sub/../file.txt`, `\??\C:\dir\file.txt`},
+		{`\\server\share\file.txt`, `\??\UNC\server\share\file.txt`},
+		{`\\?\C:\dir.`, `\??\C:\dir.`},
+	} {
+		got, err := ToNTPath(tc.path)
+		if err != nil {
+			t.Fatalf("ToNTPath(%q): %v", tc.path, err)
+		}
+		if got != tc.want {
+			t.Errorf("ToNTPath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestFromDevicePath(t *testing.T) {
+	for _, tc := range []struct {
+		path, want string
+	}{
+		{`\??\C:\dir`, `C:\dir`},
+		{`\??\UNC\server\share\file.txt`, `\\server\share\file.txt`},
+		{`\??\Volume{8a25748f-cf34-4ac6-9ee2-c89400e886db}\dir`, `\\?\Volume{8a25748f-cf34-4ac6-9ee2-c89400e886db}\dir`},
+		{`\Device\Mup\server\share`, `\\server\share`},
+		{`\Device\NoSuchDevice\dir`, `\\?\GLOBALROOT\Device\NoSuchDevice\dir`},
+	} {
+		got, err := FromDevicePath(tc.path)
+		if err != nil {
+			t.Fatalf("FromDevicePath(%q): %v", tc.path, err)
+		}
+		if got != tc.want {
+			t.Errorf("FromDevicePath(%q) = %q, want %q", tc.path, got, tc.want)
+		}
+	}
+
+	for _, p := range []string{`C:\dir`, `\\server\share`, `dir`} {
+		if _, err := FromDevicePath(p); !errors.Is(err, ErrNotDevicePath) {
+			t.Errorf("FromDevicePath(%q): expected ErrNotDevicePath, got %v", p, err)
+		}
+	}
+}
+
+func TestDevicePathRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	dev, err := ToDevicePath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(dev, `\Device\`) {
+		t.Fatalf("ToDevicePath(%q) = %q, expected a device path", dir, dev)
+	}
+	got, err := FromDevicePath(dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.EqualFold(got, dir) {
+		t.Fatalf("FromDevicePath(%q) = %q, want %q", dev, got, dir)
+	}
+}
+
+func TestExtendedPath(t *testing.T) {
+	for _, tc := range []struct {
+		path, extended string
+	}{
+		{`C:\dir\file.txt`, `\\?\C:\dir\file.txt`},
+		{`C:\dir\..\file.txt`, `\\?\C:\file.txt`},
+		{`\\server\share\file.txt`, `\\?\UNC\server\share\file.txt`},
+	} {
+		got, err := ToExtendedPath(tc.path)
+		if err != nil {
+			t.Fatalf("ToExtendedPath(%q): %v", tc.path, err)
+		}
+		if got != tc.extended {
+			t.Errorf("ToExtendedPath(%q) = %q, want %q", tc.path, got, tc.extended)
+		}
+		if back := FromExtendedPath(got); mustToExtendedPath(t, back) != got {
+			t.Errorf("FromExtendedPath(%q) = %q does not round trip", got, back)
+		}
+	}
+
+	const vol = `\\?\Volume{8a25748f-cf34-4ac6-9ee2-c89400e886db}\dir`
+	if got := FromExtendedPath(vol); got != vol {
+		t.Errorf("FromExtendedPath(%q) = %q, want it unchanged", vol, got)
+	}
+}
+
+func mustToExtendedPath(t *testing.T, p string) string {
+	t.Helper()
+
+	e, err := ToExtendedPath(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestToVolumeGUIDPath(t *testing.T) {
+	dir := t.TempDir()
+	p, err := ToVolumeGUIDPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, `\\?\Volume{`) {
+		t.Fatalf("ToVolumeGUIDPath(%q) = %q, expected a volume GUID path", dir, p)
+	}
+	if !strings.HasSuffix(strings.ToLower(p), strings.ToLower(dir[len(`C:\`):])) {
+		t.Fatalf("ToVolumeGUIDPath(%q) = %q, expected it to end with the path on the volume", dir, p)
+	}
+}
@@ -0,0 +1,51 @@
+package guid
+
+import (
+	"crypto/subtle"
+	"sort"
+)
+
+// Equal returns true if a and b are the same GUID. The comparison runs in constant time, so
+// it does not leak which bytes differ.
+func Equal(a, b GUID) bool {
+	ab, bb := a.ToArray(), b.ToArray()
+	return subtle.ConstantTimeCompare(ab[:], bb[:]) == 1
+}
+
+// Compare returns an integer comparing a and b in the order of their big-endian encodings,
+// which is also the lexical order of their string forms. The result is 0 if a == b, -1 if
+// a < b, and +1 if a > b.
+//
+// The comparison runs in constant time: all bytes are compared, regardless of where the
+// first difference is.
+func Compare(a, b GUID) int {
+	ab, bb := a.ToArray(), b.ToArray()
+	// r is the result from the first differing byte; done is 1 once it has been found
+	r, done := 0, 0
+	for i := range ab {
+		x, y := int(ab[i]), int(bb[i])
+		// -1, 0, or +1, without branching on the byte values
+		c := ((x - y) >> 8) - ((y - x) >> 8)
+		r |= c & ^(-done)
+		done |= c & 1
+	}
+	return r
+}
+
+// Less returns true if a sorts before b. See [Compare].
+func Less(a, b GUID) bool {
+	return Compare(a, b) < 0
+}
+
+// Slice attaches the methods of sort.Interface to []GUID, sorting in increasing order
+// (see [Compare]).
+type Slice []GUID
+
+func (x Slice) Len() int           { return len(x) }
+func (x Slice) Less(i, j int) bool { return Less(x[i], x[j]) }
+func (x Slice) Swap(i, j int)      { x[i], x[j] = x[j], x[i] }
+
+// Sort sorts a slice of GUIDs in increasing order (see [Compare]).
+func Sort(x []GUID) {
+	sort.Sort(Slice(x))
+}
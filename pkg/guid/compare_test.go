@@ -0,0 +1,56 @@
+package guid
+
+import (
+	"sort"
+	"testing"
+)
+
+func Test_Compare(t *testing.T) {
+	gs := []GUID{
+		mustFromString(t, "00000000-0000-0000-0000-000000000000"),
+		mustFromString(t, "00000000-0000-0000-0000-000000000001"),
+		mustFromString(t, "00000001-0000-0000-0000-000000000000"),
+		mustFromString(t, "0000ff00-0000-0000-0000-000000000000"),
+		mustFromString(t, "73c39589-192e-4c64-9acf-6c5d0aa18528"),
+		mustFromString(t, "73c39589-192e-4c64-9acf-6c5d0aa18529"),
+		mustFromString(t, "ffffffff-ffff-ffff-ffff-ffffffffffff"),
+	}
+	for i, a := range gs {
+		for j, b := range gs {
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := Compare(a, b); got != want {
+				t.Errorf("Compare(%v, %v) = %d; want %d", a, b, got, want)
+			}
+			if got := Equal(a, b); got != (i == j) {
+				t.Errorf("Equal(%v, %v) = %t", a, b, got)
+			}
+			if got := Less(a, b); got != (i < j) {
+				t.Errorf("Less(%v, %v) = %t", a, b, got)
+			}
+		}
+	}
+}
+
+func Test_Sort(t *testing.T) {
+	gs := make([]GUID, 100)
+	ss := make([]string, len(gs))
+	for i := range gs {
+		gs[i] = mustNewV4(t)
+		ss[i] = gs[i].String()
+	}
+	Sort(gs)
+	sort.Strings(ss)
+	if !sort.IsSorted(Slice(gs)) {
+		t.Fatal("GUIDs are not sorted")
+	}
+	for i := range gs {
+		if gs[i].String() != ss[i] {
+			t.Fatalf("GUID %d is %v; want %s", i, gs[i], ss[i])
+		}
+	}
+}
@@ -284,3 +284,28 @@ func Test_UnmarshalJSON_Nested(t *testing.T) {
 		t.Fatalf("GUIDs not equal: %v, %v", t1.G, t2.G)
 	}
 }
+
+func Test_FromNameInNamespace(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		g    string
+	}{
+		{"wincni", "c822b598-f4cc-5a72-7933-ce2a816d033f"},
+		{"Moby", "6996f090-c5de-5082-a81e-5841acc3a635"},
+		{"moby", "6996f090-c5de-5082-a81e-5841acc3a635"},
+	} {
+		g := FromNameInNamespace(EventSourceNamespace(), tc.name)
+		if want := mustFromString(t, tc.g); g != want {
+			t.Fatalf("Incorrect GUID for %q.\nExpected: %s\nActual: %s", tc.name, want, g)
+		}
+	}
+}
+
+func Test_VsockServiceTemplate(t *testing.T) {
+	if s := VsockServiceTemplate().String(); s != "00000000-facb-11e6-bd58-64006a7986d3" {
+		t.Fatalf("unexpected VSock service template %s", s)
+	}
+	if s := EventSourceNamespace().String(); s != "482c2db2-c390-47c8-87f8-1a15bfc130fb" {
+		t.Fatalf("unexpected EventSource namespace %s", s)
+	}
+}
@@ -0,0 +1,61 @@
+package guid
+
+import (
+	"crypto/sha1" //nolint:gosec // not used for secure application
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Namespaces are returned by functions, rather than declared as variables, so that they
+// cannot be modified.
+
+// VsockServiceTemplate returns the hvsock service ID template for the AF_VSOCK protocol,
+// 00000000-facb-11e6-bd58-64006a7986d3. The service ID for an AF_VSOCK port is the template
+// with Data1 set to the port.
+func VsockServiceTemplate() GUID {
+	return GUID{
+		Data2: 0xfacb,
+		Data3: 0x11e6,
+		Data4: [8]byte{0xbd, 0x58, 0x64, 0x00, 0x6a, 0x79, 0x86, 0xd3},
+	}
+}
+
+// EventSourceNamespace returns the namespace used by .NET's EventSource class to derive ETW
+// provider IDs from provider names, 482c2db2-c390-47c8-87f8-1a15bfc130fb.
+//
+// See [FromNameInNamespace].
+func EventSourceNamespace() GUID {
+	return GUID{
+		Data1: 0x482c2db2,
+		Data2: 0xc390,
+		Data3: 0x47c8,
+		Data4: [8]byte{0x87, 0xf8, 0x1a, 0x15, 0xbf, 0xc1, 0x30, 0xfb},
+	}
+}
+
+// FromNameInNamespace derives a GUID from name and the namespace ns, using the algorithm of
+// .NET's EventSource class. With [EventSourceNamespace], it returns the ETW provider ID for
+// the provider name. More information on the algorithm can be found here:
+// https://blogs.msdn.microsoft.com/dcook/2015/09/08/etw-provider-names-and-guids/
+//
+// The algorithm is roughly the RFC 4122 algorithm for a V5 UUID (see [NewV5]), but differs in
+// the following ways:
+//   - The input name is first upper-cased, UTF16-encoded, and converted to
+//     big-endian.
+//   - No variant is set on the result UUID.
+//   - The result UUID is treated as being in little-endian format, rather than
+//     big-endian.
+func FromNameInNamespace(ns GUID, name string) GUID {
+	buffer := sha1.New() //nolint:gosec // not used for secure application
+	namespaceBytes := ns.ToArray()
+	buffer.Write(namespaceBytes[:])
+	_ = binary.Write(buffer, binary.BigEndian, utf16.Encode([]rune(strings.ToUpper(name))))
+
+	sum := buffer.Sum(nil)
+	sum[7] = (sum[7] & 0xf) | 0x50
+
+	a := [16]byte{}
+	copy(a[:], sum)
+	return FromWindowsArray(a)
+}
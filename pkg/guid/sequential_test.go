@@ -0,0 +1,51 @@
+package guid
+
+import (
+	"testing"
+	"time"
+)
+
+func mustNewSequential(t *testing.T) GUID {
+	t.Helper()
+
+	g, err := NewSequential()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func Test_SequentialHasCorrectVersionAndVariant(t *testing.T) {
+	g := mustNewSequential(t)
+	if g.Version() != 7 {
+		t.Fatalf("Version is not 7: %s", g)
+	}
+	if g.Variant() != VariantRFC4122 {
+		t.Fatalf("Variant is not RFC4122: %s", g)
+	}
+}
+
+func Test_SequentialTimestamp(t *testing.T) {
+	before := time.Now().Add(-time.Millisecond)
+	b := mustNewSequential(t).ToArray()
+	ms := int64(b[0])<<40 | int64(b[1])<<32 | int64(b[2])<<24 | int64(b[3])<<16 | int64(b[4])<<8 | int64(b[5])
+	ts := time.Unix(0, ms*int64(time.Millisecond))
+	if ts.Before(before) || ts.After(time.Now().Add(time.Second)) {
+		t.Fatalf("timestamp %v is not the current time", ts)
+	}
+}
+
+func Test_SequentialIsOrdered(t *testing.T) {
+	// generate enough GUIDs to exhaust the counter for a millisecond
+	prev := mustNewSequential(t)
+	for i := 0; i < 10000; i++ {
+		g := mustNewSequential(t)
+		if !Less(prev, g) {
+			t.Fatalf("GUIDs are not in order: %s, %s", prev, g)
+		}
+		if prev.String() >= g.String() {
+			t.Fatalf("GUID strings are not in order: %s, %s", prev, g)
+		}
+		prev = g
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package hvsocktest
+
+import "github.com/Microsoft/go-winio"
+
+// FromHvsockAddr returns the emulated address equivalent to addr.
+func FromHvsockAddr(addr *winio.HvsockAddr) *Addr {
+	return &Addr{VMID: addr.VMID, ServiceID: addr.ServiceID}
+}
+
+// HvsockAddr returns the winio.HvsockAddr equivalent to addr.
+func (addr *Addr) HvsockAddr() *winio.HvsockAddr {
+	return &winio.HvsockAddr{VMID: addr.VMID, ServiceID: addr.ServiceID}
+}
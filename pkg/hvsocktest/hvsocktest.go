@@ -0,0 +1,328 @@
+// Package hvsocktest provides an in-memory emulation of Hyper-V sockets (hvsock), for
+// testing code that listens on or dials hvsock addresses without a Hyper-V host or guest.
+//
+// Connections are created with [net.Pipe], and therefore are synchronous and unbuffered,
+// but support half-closing (via CloseRead and CloseWrite) and deadlines, and report
+// hvsock addresses as their local and remote addresses.
+package hvsocktest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+// acceptBacklog is the number of connections that can be pending on a listener,
+// and matches the backlog used by winio.ListenHvsock.
+const acceptBacklog = 16
+
+var (
+	// ErrConnectionRefused is returned when dialing an address that has no listener.
+	ErrConnectionRefused = errors.New("connection refused")
+
+	// ErrAddressInUse is returned when listening on an address that already has a listener.
+	ErrAddressInUse = errors.New("address already in use")
+)
+
+// Addr is the address of an emulated hvsock endpoint.
+// It mirrors the winio.HvsockAddr type, which is only available on Windows.
+type Addr struct {
+	VMID      guid.GUID
+	ServiceID guid.GUID
+}
+
+var _ net.Addr = &Addr{}
+
+// Network returns the address's network name, "hvsock".
+func (*Addr) Network() string {
+	return "hvsock"
+}
+
+func (addr *Addr) String() string {
+	return fmt.Sprintf("%s:%s", &addr.VMID, &addr.ServiceID)
+}
+
+// Network is an in-memory hvsock network, connecting dialers to listeners by address.
+//
+// The zero value is not valid; use [NewNetwork].
+type Network struct {
+	vmID guid.GUID
+
+	mu        sync.Mutex
+	listeners map[Addr]*Listener
+}
+
+// NewNetwork returns an empty network.
+//
+// vmID is the VM ID used for the local address of dialed connections, analogous to the
+// partition the dialer is running in.
+func NewNetwork(vmID guid.GUID) *Network {
+	return &Network{
+		vmID:      vmID,
+		listeners: make(map[Addr]*Listener),
+	}
+}
+
+// Listen listens for connections on addr.
+//
+// As with hvsock, a listener with a wildcard (all zero) VM ID accepts connections
+// dialed to any VM ID with the same service ID.
+func (n *Network) Listen(addr *Addr) (*Listener, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.listeners[*addr]; ok {
+		return nil, n.opErr("listen", nil, addr, ErrAddressInUse)
+	}
+	l := &Listener{
+		n:      n,
+		addr:   *addr,
+		ch:     make(chan *Conn, acceptBacklog),
+		closed: make(chan struct{}),
+	}
+	n.listeners[*addr] = l
+	return l, nil
+}
+
+// Dial connects to the listener at addr.
+//
+// It returns an error wrapping [ErrConnectionRefused] if there is no listener for addr,
+// and blocks until ctx is done if the listener's backlog is full.
+func (n *Network) Dial(ctx context.Context, addr *Addr) (*Conn, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, n.opErr("dial", nil, addr, err)
+	}
+
+	local := &Addr{VMID: n.vmID}
+	var err error
+	if local.ServiceID, err = guid.NewV4(); err != nil {
+		return nil, n.opErr("dial", nil, addr, err)
+	}
+
+	l := n.lookup(addr)
+	if l == nil {
+		return nil, n.opErr("dial", local, addr, ErrConnectionRefused)
+	}
+
+	// the accepting end reports the listener's address as its own
+	client, server := newConnPair(local, addr, &l.addr)
+	select {
+	case l.ch <- server:
+		return client, nil
+	case <-l.closed:
+		err = ErrConnectionRefused
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	client.Close()
+	server.Close()
+	return nil, n.opErr("dial", local, addr, err)
+}
+
+// lookup returns the listener for addr, falling back to a listener on the wildcard VM ID.
+func (n *Network) lookup(addr *Addr) *Listener {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if l, ok := n.listeners[*addr]; ok {
+		return l
+	}
+	return n.listeners[Addr{ServiceID: addr.ServiceID}]
+}
+
+func (n *Network) remove(l *Listener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.listeners[l.addr] == l {
+		delete(n.listeners, l.addr)
+	}
+}
+
+func (*Network) opErr(op string, local, remote *Addr, err error) error {
+	oe := &net.OpError{Op: op, Net: "hvsock", Err: err}
+	if local != nil {
+		oe.Source = local
+	}
+	if remote != nil {
+		oe.Addr = remote
+	}
+	return oe
+}
+
+// Listener is an emulated hvsock listener.
+type Listener struct {
+	n    *Network
+	addr Addr
+	ch   chan *Conn
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+var _ net.Listener = &Listener{}
+
+// Accept waits for the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	return l.AcceptHvsock()
+}
+
+// AcceptHvsock waits for the next connection to the listener, and returns it as a [*Conn].
+func (l *Listener) AcceptHvsock() (*Conn, error) {
+	// prefer reporting the listener as closed over returning pending connections
+	select {
+	case <-l.closed:
+		return nil, l.opErr("accept", net.ErrClosed)
+	default:
+	}
+
+	select {
+	case c := <-l.ch:
+		return c, nil
+	case <-l.closed:
+		return nil, l.opErr("accept", net.ErrClosed)
+	}
+}
+
+// Close stops listening. Pending connections that have not been accepted are closed.
+func (l *Listener) Close() error {
+	err := l.opErr("close", net.ErrClosed)
+	l.once.Do(func() {
+		err = nil
+		l.n.remove(l)
+		close(l.closed)
+		for {
+			select {
+			case c := <-l.ch:
+				c.Close()
+			default:
+				return
+			}
+		}
+	})
+	return err
+}
+
+// Addr returns the listener's network address.
+func (l *Listener) Addr() net.Addr {
+	return &l.addr
+}
+
+func (l *Listener) opErr(op string, err error) error {
+	return &net.OpError{Op: op, Net: "hvsock", Addr: &l.addr, Err: err}
+}
+
+// Conn is one end of an emulated hvsock connection.
+//
+// Data written to the connection is sent over a separate [net.Pipe] for each direction,
+// so that each direction can be shut down independently.
+type Conn struct {
+	local, remote Addr
+
+	r net.Conn // read end of the pipe carrying data from the peer
+	w net.Conn // write end of the pipe carrying data to the peer
+}
+
+var _ net.Conn = &Conn{}
+
+// newConnPair returns a connected pair of connections. The dialing end is connected from
+// local to remote, and the accepting end reports accepted as its local address.
+func newConnPair(local, remote, accepted *Addr) (*Conn, *Conn) {
+	toServerR, toServerW := net.Pipe()
+	toClientR, toClientW := net.Pipe()
+	client := &Conn{local: *local, remote: *remote, r: toClientR, w: toServerW}
+	server := &Conn{local: *accepted, remote: *local, r: toServerR, w: toClientW}
+	return client, server
+}
+
+func (conn *Conn) opErr(op string, err error) error {
+	// translate from "pipe closed" to the error a closed socket returns
+	if errors.Is(err, io.ErrClosedPipe) {
+		err = net.ErrClosed
+	}
+	return &net.OpError{Op: op, Net: "hvsock", Source: &conn.local, Addr: &conn.remote, Err: err}
+}
+
+// Read reads data from the connection. It returns [io.EOF] once the peer has closed
+// the connection or shut down its write end.
+func (conn *Conn) Read(b []byte) (int, error) {
+	n, err := conn.r.Read(b)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return n, conn.opErr("read", err)
+	}
+	return n, err
+}
+
+// Write writes data to the connection, blocking until the peer reads it.
+func (conn *Conn) Write(b []byte) (int, error) {
+	n, err := conn.w.Write(b)
+	if err != nil {
+		return n, conn.opErr("write", err)
+	}
+	return n, nil
+}
+
+// Close closes the connection, failing any pending read or write calls.
+func (conn *Conn) Close() error {
+	// closing a net.Pipe end always succeeds
+	conn.r.Close()
+	return conn.w.Close()
+}
+
+// CloseRead shuts down the read end of the connection, preventing future read operations.
+// Writes from the peer will fail.
+func (conn *Conn) CloseRead() error {
+	if err := conn.r.Close(); err != nil {
+		return conn.opErr("closeread", err)
+	}
+	return nil
+}
+
+// CloseWrite shuts down the write end of the connection, preventing future write operations and
+// notifying the peer that no more data will be written.
+func (conn *Conn) CloseWrite() error {
+	if err := conn.w.Close(); err != nil {
+		return conn.opErr("closewrite", err)
+	}
+	return nil
+}
+
+// LocalAddr returns the local address of the connection.
+func (conn *Conn) LocalAddr() net.Addr {
+	return &conn.local
+}
+
+// RemoteAddr returns the remote address of the connection.
+func (conn *Conn) RemoteAddr() net.Addr {
+	return &conn.remote
+}
+
+// SetDeadline implements the net.Conn SetDeadline method.
+func (conn *Conn) SetDeadline(t time.Time) error {
+	if err := conn.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return conn.SetWriteDeadline(t)
+}
+
+// SetReadDeadline implements the net.Conn SetReadDeadline method.
+func (conn *Conn) SetReadDeadline(t time.Time) error {
+	if err := conn.r.SetReadDeadline(t); err != nil {
+		return conn.opErr("set", err)
+	}
+	return nil
+}
+
+// SetWriteDeadline implements the net.Conn SetWriteDeadline method.
+func (conn *Conn) SetWriteDeadline(t time.Time) error {
+	if err := conn.w.SetWriteDeadline(t); err != nil {
+		return conn.opErr("set", err)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package hvsocktest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func mustGUID(t *testing.T) guid.GUID {
+	t.Helper()
+
+	g, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func newTestNetwork(t *testing.T) (*Network, *Listener) {
+	t.Helper()
+
+	n := NewNetwork(mustGUID(t))
+	l, err := n.Listen(&Addr{VMID: mustGUID(t), ServiceID: mustGUID(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	return n, l
+}
+
+func TestDialAccept(t *testing.T) {
+	n, l := newTestNetwork(t)
+	addr := l.Addr().(*Addr)
+
+	ch := make(chan *Conn, 1)
+	go func() {
+		c, err := l.AcceptHvsock()
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- c
+	}()
+
+	client, err := n.Dial(context.Background(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server := <-ch
+	if server == nil {
+		t.FailNow()
+	}
+	defer server.Close()
+
+	if *client.RemoteAddr().(*Addr) != *addr || *server.LocalAddr().(*Addr) != *addr {
+		t.Fatalf("expected connection to %v, got %v -> %v", addr, client.RemoteAddr(), server.LocalAddr())
+	}
+	if *client.LocalAddr().(*Addr) != *server.RemoteAddr().(*Addr) {
+		t.Fatalf("client address %v does not match %v", client.LocalAddr(), server.RemoteAddr())
+	}
+	if client.LocalAddr().(*Addr).VMID != n.vmID {
+		t.Fatalf("client VM ID is %v, want %v", client.LocalAddr().(*Addr).VMID, n.vmID)
+	}
+
+	go func() {
+		_, _ = client.Write([]byte("hello"))
+		_ = client.CloseWrite()
+	}()
+	b, err := io.ReadAll(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("got %q, want %q", b, "hello")
+	}
+
+	// the other direction is still open after CloseWrite
+	go func() {
+		_, _ = server.Write([]byte("world"))
+		_ = server.Close()
+	}()
+	b, err = io.ReadAll(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "world" {
+		t.Fatalf("got %q, want %q", b, "world")
+	}
+
+	if _, err := client.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected write after CloseWrite to fail with %v, got %v", net.ErrClosed, err)
+	}
+}
+
+func TestDialWildcard(t *testing.T) {
+	n := NewNetwork(mustGUID(t))
+	svc := mustGUID(t)
+	l, err := n.Listen(&Addr{ServiceID: svc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c, err := n.Dial(context.Background(), &Addr{VMID: mustGUID(t), ServiceID: svc})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+}
+
+func TestDialRefused(t *testing.T) {
+	n, l := newTestNetwork(t)
+
+	if _, err := n.Dial(context.Background(), &Addr{VMID: mustGUID(t), ServiceID: mustGUID(t)}); !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("expected %v, got %v", ErrConnectionRefused, err)
+	}
+	if _, err := n.Listen(l.Addr().(*Addr)); !errors.Is(err, ErrAddressInUse) {
+		t.Fatalf("expected %v, got %v", ErrAddressInUse, err)
+	}
+
+	addr := l.Addr().(*Addr)
+	l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected %v, got %v", net.ErrClosed, err)
+	}
+	if _, err := n.Dial(context.Background(), addr); !errors.Is(err, ErrConnectionRefused) {
+		t.Fatalf("expected %v, got %v", ErrConnectionRefused, err)
+	}
+}
+
+func TestDialBacklogFull(t *testing.T) {
+	n, l := newTestNetwork(t)
+
+	for i := 0; i < acceptBacklog; i++ {
+		c, err := n.Dial(context.Background(), l.Addr().(*Addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := n.Dial(ctx, l.Addr().(*Addr)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestReadDeadline(t *testing.T) {
+	n, l := newTestNetwork(t)
+
+	c, err := n.Dial(context.Background(), l.Addr().(*Addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.SetReadDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Read(make([]byte, 1))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", os.ErrDeadlineExceeded, err)
+	}
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
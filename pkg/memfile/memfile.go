@@ -0,0 +1,219 @@
+//go:build windows
+// +build windows
+
+// Package memfile provides shared memory backed by section objects (file mappings) in the
+// system paging file.
+//
+// A [Section] can be shared with other processes by name (see [Options.Name] and [Open]), or
+// by duplicating its handle into another process (see [Section.DuplicateTo] and
+// [FromHandle]). This pairs well with named pipes for transferring large payloads without
+// copying them through the pipe: the payload is written into a shared view, and only its
+// offset and length are sent over the pipe.
+package memfile
+
+import (
+	"errors"
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go ./memfile.go
+
+//sys createFileMapping(file windows.Handle, sa *windows.SecurityAttributes, protect uint32, maxSizeHigh uint32, maxSizeLow uint32, name *uint16) (h windows.Handle, err error) [failretval==0||e1==windows.ERROR_ALREADY_EXISTS] = CreateFileMappingW
+//sys openFileMapping(access uint32, inheritHandle bool, name string) (h windows.Handle, err error) = OpenFileMappingW
+
+// allocationGranularity is the alignment required for the offset of a view, which is 64KB on
+// all Windows platforms.
+const allocationGranularity = 64 << 10
+
+// ErrInvalidRange is returned by [Section.Map] when the range to map is not within the section.
+var ErrInvalidRange = errors.New("invalid section range")
+
+// Options contains options for [Create].
+type Options struct {
+	// Name is the name of the section object, such as `Local\myapp-buffer`, which other
+	// processes can pass to [Open]. If empty, the section is anonymous, and can only be
+	// shared by duplicating or inheriting its handle.
+	Name string
+
+	// SecurityDescriptor is the security descriptor of the section, in SDDL format. If
+	// empty, the default security descriptor of the caller's token is used.
+	SecurityDescriptor string
+
+	// Inheritable allows the section's handle to be inherited by child processes.
+	Inheritable bool
+}
+
+// Section is a section object backed by the system paging file.
+type Section struct {
+	h        windows.Handle
+	size     int64
+	writable bool
+}
+
+// Create creates a new section of size bytes, which are initially zero. If opts.Name is set
+// and a section with that name already exists, Create fails with windows.ERROR_ALREADY_EXISTS.
+func Create(size int64, opts *Options) (*Section, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if size <= 0 {
+		return nil, &os.PathError{Op: "CreateFileMapping", Path: opts.Name, Err: ErrInvalidRange}
+	}
+
+	sa := &windows.SecurityAttributes{InheritHandle: boolToUint32(opts.Inheritable)}
+	sa.Length = uint32(unsafe.Sizeof(*sa))
+	if opts.SecurityDescriptor != "" {
+		sd, err := windows.SecurityDescriptorFromString(opts.SecurityDescriptor)
+		if err != nil {
+			return nil, err
+		}
+		sa.SecurityDescriptor = sd
+	}
+
+	var name *uint16
+	if opts.Name != "" {
+		var err error
+		if name, err = windows.UTF16PtrFromString(opts.Name); err != nil {
+			return nil, &os.PathError{Op: "CreateFileMapping", Path: opts.Name, Err: err}
+		}
+	}
+	h, err := createFileMapping(windows.InvalidHandle, sa, windows.PAGE_READWRITE,
+		uint32(size>>32), uint32(size), name)
+	if err != nil {
+		if h != 0 {
+			// the section already exists, and CreateFileMapping opened it
+			windows.CloseHandle(h) //nolint:errcheck
+		}
+		return nil, &os.PathError{Op: "CreateFileMapping", Path: opts.Name, Err: err}
+	}
+	return &Section{h: h, size: size, writable: true}, nil
+}
+
+// Open opens the existing section with the specified name, for reading and (if writable is
+// true) writing.
+func Open(name string, writable bool) (*Section, error) {
+	h, err := openFileMapping(mapAccess(writable), false, name)
+	if err != nil {
+		return nil, &os.PathError{Op: "OpenFileMapping", Path: name, Err: err}
+	}
+	s, err := FromHandle(h, writable)
+	if err != nil {
+		windows.CloseHandle(h) //nolint:errcheck
+		return nil, err
+	}
+	return s, nil
+}
+
+// FromHandle returns a Section for the section handle h, which was typically duplicated
+// into this process with [Section.DuplicateTo]. If it succeeds, the Section takes ownership
+// of h.
+func FromHandle(h windows.Handle, writable bool) (*Section, error) {
+	// determine the size of the section by mapping all of it
+	addr, err := windows.MapViewOfFile(h, mapAccess(writable), 0, 0, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	defer windows.UnmapViewOfFile(addr) //nolint:errcheck
+
+	var info windows.MemoryBasicInformation
+	if err := windows.VirtualQuery(addr, &info, unsafe.Sizeof(info)); err != nil {
+		return nil, os.NewSyscallError("VirtualQuery", err)
+	}
+	return &Section{h: h, size: int64(info.RegionSize), writable: writable}, nil
+}
+
+// Handle returns the section's handle. It remains owned by the Section.
+func (s *Section) Handle() windows.Handle {
+	return s.h
+}
+
+// Size returns the size of the section. For sections that were not created by this process,
+// the size is rounded up to a multiple of the page size.
+func (s *Section) Size() int64 {
+	return s.size
+}
+
+// DuplicateTo duplicates the section's handle into process, which must have been opened with
+// PROCESS_DUP_HANDLE access, and returns the handle's value in that process. The other
+// process can pass the value (sent over a pipe, for instance) to [FromHandle]. If writable is
+// false, the duplicated handle only allows read-only views.
+func (s *Section) DuplicateTo(process windows.Handle, writable bool) (windows.Handle, error) {
+	var h windows.Handle
+	if err := windows.DuplicateHandle(windows.CurrentProcess(), s.h, process, &h,
+		mapAccess(writable), false, 0); err != nil {
+		return 0, os.NewSyscallError("DuplicateHandle", err)
+	}
+	return h, nil
+}
+
+// Map maps length bytes of the section, starting at offset, into memory. offset does not need
+// to be aligned. If writable is false, the view is read-only, and writing to it faults.
+func (s *Section) Map(offset int64, length int, writable bool) (*View, error) {
+	if offset < 0 || length <= 0 || offset+int64(length) > s.size {
+		return nil, ErrInvalidRange
+	}
+	if writable && !s.writable {
+		return nil, os.NewSyscallError("MapViewOfFile", windows.ERROR_ACCESS_DENIED)
+	}
+
+	// views must start at a multiple of the allocation granularity
+	base := offset &^ (allocationGranularity - 1)
+	n := uintptr(offset - base + int64(length))
+	addr, err := windows.MapViewOfFile(s.h, mapAccess(writable), uint32(base>>32), uint32(base), n)
+	if err != nil {
+		return nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// convert addr without a uintptr-to-pointer conversion, since it points outside the Go heap
+	b := unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), int(n))
+	return &View{b: b[offset-base:], addr: addr}, nil
+}
+
+// Close closes the section's handle. Views that are still mapped remain valid until they are
+// closed, and the section is destroyed once all of its handles and views are closed.
+func (s *Section) Close() error {
+	if s.h == 0 {
+		return nil
+	}
+	err := windows.CloseHandle(s.h)
+	s.h = 0
+	return err
+}
+
+// View is a range of a section mapped into memory.
+type View struct {
+	b    []byte
+	addr uintptr
+}
+
+// Bytes returns the mapped memory. It must not be used after the view is closed.
+func (v *View) Bytes() []byte {
+	return v.b
+}
+
+// Close unmaps the view.
+func (v *View) Close() error {
+	if v.addr == 0 {
+		return nil
+	}
+	err := windows.UnmapViewOfFile(v.addr)
+	v.addr = 0
+	v.b = nil
+	return os.NewSyscallError("UnmapViewOfFile", err)
+}
+
+func mapAccess(writable bool) uint32 {
+	if writable {
+		return windows.FILE_MAP_READ | windows.FILE_MAP_WRITE
+	}
+	return windows.FILE_MAP_READ
+}
+
+func boolToUint32(b bool) uint32 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,108 @@
+//go:build windows
+// +build windows
+
+package memfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func testSectionName() string {
+	return fmt.Sprintf(`Local\go-winio-memfile-test-%d`, time.Now().UnixNano())
+}
+
+func TestCreateOpenShare(t *testing.T) {
+	name := testSectionName()
+	s, err := Create(3*allocationGranularity, &Options{Name: name, SecurityDescriptor: "D:P(A;;GA;;;OW)"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// an unaligned view that spans an allocation granularity boundary
+	data := []byte("hello, shared memory")
+	off := int64(allocationGranularity - 5)
+	v, err := s.Map(off, len(data), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	copy(v.Bytes(), data)
+
+	o, err := Open(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+	if o.Size() != s.Size() {
+		t.Fatalf("got size %d, expected %d", o.Size(), s.Size())
+	}
+	if _, err := o.Map(0, 1, true); err == nil {
+		t.Fatal("mapped a writable view of a read-only section")
+	}
+	rv, err := o.Map(off, len(data), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rv.Close()
+	if !bytes.Equal(rv.Bytes(), data) {
+		t.Fatalf("got %q, expected %q", rv.Bytes(), data)
+	}
+
+	if _, err := Create(1, &Options{Name: name}); !errors.Is(err, windows.ERROR_ALREADY_EXISTS) {
+		t.Fatalf("expected ERROR_ALREADY_EXISTS, got %v", err)
+	}
+}
+
+func TestDuplicate(t *testing.T) {
+	s, err := Create(4096, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	h, err := s.DuplicateTo(windows.CurrentProcess(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := FromHandle(h, true)
+	if err != nil {
+		windows.CloseHandle(h) //nolint:errcheck
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	v1, err := s.Map(0, 4096, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v1.Close()
+	v2, err := d.Map(0, 4096, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v2.Close()
+	v1.Bytes()[100] = 42
+	if v2.Bytes()[100] != 42 {
+		t.Fatal("write was not visible through the duplicated handle")
+	}
+}
+
+func TestMapInvalidRange(t *testing.T) {
+	s, err := Create(4096, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, r := range [][2]int64{{-1, 1}, {0, 0}, {4000, 100}} {
+		if _, err := s.Map(r[0], int(r[1]), false); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("Map(%d, %d): expected ErrInvalidRange, got %v", r[0], r[1], err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+//go:build windows
+
+// Code generated by 'go generate' using "github.com/Microsoft/go-winio/tools/mkwinsyscall"; DO NOT EDIT.
+
+package memfile
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ unsafe.Pointer
+
+// Do the interface allocations only once for common
+// Errno values.
+const (
+	errnoERROR_IO_PENDING = 997
+)
+
+var (
+	errERROR_IO_PENDING error = syscall.Errno(errnoERROR_IO_PENDING)
+	errERROR_EINVAL     error = syscall.EINVAL
+)
+
+// errnoErr returns common boxed Errno values, to prevent
+// allocations at runtime.
+func errnoErr(e syscall.Errno) error {
+	switch e {
+	case 0:
+		return errERROR_EINVAL
+	case errnoERROR_IO_PENDING:
+		return errERROR_IO_PENDING
+	}
+	// TODO: add more here, after collecting data on the common
+	// error values see on Windows. (perhaps when running
+	// all.bat?)
+	return e
+}
+
+var (
+	modkernel32 = windows.NewLazySystemDLL("kernel32.dll")
+
+	procCreateFileMappingW = modkernel32.NewProc("CreateFileMappingW")
+	procOpenFileMappingW   = modkernel32.NewProc("OpenFileMappingW")
+)
+
+func createFileMapping(file windows.Handle, sa *windows.SecurityAttributes, protect uint32, maxSizeHigh uint32, maxSizeLow uint32, name *uint16) (h windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall6(procCreateFileMappingW.Addr(), 6, uintptr(file), uintptr(unsafe.Pointer(sa)), uintptr(protect), uintptr(maxSizeHigh), uintptr(maxSizeLow), uintptr(unsafe.Pointer(name)))
+	h = windows.Handle(r0)
+	if h == 0 || e1 == windows.ERROR_ALREADY_EXISTS {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func openFileMapping(access uint32, inheritHandle bool, name string) (h windows.Handle, err error) {
+	var _p0 *uint16
+	_p0, err = syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return
+	}
+	return _openFileMapping(access, inheritHandle, _p0)
+}
+
+func _openFileMapping(access uint32, inheritHandle bool, name *uint16) (h windows.Handle, err error) {
+	var _p1 uint32
+	if inheritHandle {
+		_p1 = 1
+	}
+	r0, _, e1 := syscall.Syscall(procOpenFileMappingW.Addr(), 3, uintptr(access), uintptr(_p1), uintptr(unsafe.Pointer(name)))
+	h = windows.Handle(r0)
+	if h == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
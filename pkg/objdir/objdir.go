@@ -0,0 +1,167 @@
+//go:build windows
+// +build windows
+
+// Package objdir enumerates the NT object manager namespace, such as the objects in
+// \BaseNamedObjects or \Device, and the named pipes that currently exist.
+package objdir
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go ./objdir.go
+
+//sys ntOpenDirectoryObject(handle *windows.Handle, access uint32, oa *windows.OBJECT_ATTRIBUTES) (ntstatus error) = ntdll.NtOpenDirectoryObject
+//sys ntQueryDirectoryObject(handle windows.Handle, buffer *byte, length uint32, singleEntry bool, restartScan bool, context *uint32, returnLength *uint32) (ntstatus error) = ntdll.NtQueryDirectoryObject
+
+// Directory object access rights.
+//
+//nolint:revive // SNAKE_CASE is not idiomatic in Go, but aligned with Win32 API.
+const (
+	DIRECTORY_QUERY    = 0x0001
+	DIRECTORY_TRAVERSE = 0x0002
+)
+
+// pipeRoot is the directory of the named pipe file system.
+const pipeRoot = `\\.\pipe\`
+
+// Entry is an object in an object directory.
+type Entry struct {
+	// Name is the name of the object, relative to the directory.
+	Name string
+	// Type is the name of the object's type, such as "Directory", "SymbolicLink", "Event",
+	// "Section", or "Device".
+	Type string
+}
+
+// OBJECT_DIRECTORY_INFORMATION
+type objectDirectoryInformation struct {
+	Name     windows.NTUnicodeString
+	TypeName windows.NTUnicodeString
+}
+
+// List returns the objects in the object directory at path, which is an NT object path,
+// such as `\` or `\BaseNamedObjects`.
+func List(path string) ([]Entry, error) {
+	name, err := windows.NewNTUnicodeString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "NtOpenDirectoryObject", Path: path, Err: err}
+	}
+	oa := windows.OBJECT_ATTRIBUTES{
+		ObjectName: name,
+		Attributes: windows.OBJ_CASE_INSENSITIVE,
+	}
+	oa.Length = uint32(unsafe.Sizeof(oa))
+
+	var h windows.Handle
+	if err := ntOpenDirectoryObject(&h, DIRECTORY_QUERY, &oa); err != nil {
+		return nil, &os.PathError{Op: "NtOpenDirectoryObject", Path: path, Err: ntStatusErr(err)}
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	var entries []Entry
+	b := make([]byte, 4096)
+	var ctx uint32
+	restart := true
+	for {
+		var n uint32
+		err := ntQueryDirectoryObject(h, &b[0], uint32(len(b)), false, restart, &ctx, &n)
+		switch err { //nolint:errorlint // err is NTStatus
+		case nil:
+			return append(entries, parseEntries(b)...), nil
+		case windows.STATUS_MORE_ENTRIES:
+			entries = append(entries, parseEntries(b)...)
+			restart = false
+		case windows.STATUS_NO_MORE_ENTRIES:
+			return entries, nil
+		case windows.STATUS_BUFFER_TOO_SMALL:
+			// a single entry does not fit in the buffer; retry from the last context with a
+			// larger one
+			if n <= uint32(len(b)) {
+				n = uint32(2 * len(b))
+			}
+			b = make([]byte, n)
+		default:
+			return nil, &os.PathError{Op: "NtQueryDirectoryObject", Path: path, Err: ntStatusErr(err)}
+		}
+	}
+}
+
+// parseEntries parses an array of OBJECT_DIRECTORY_INFORMATION structures, terminated by
+// an empty entry. The strings in the structures point into b.
+func parseEntries(b []byte) []Entry {
+	var entries []Entry
+	sz := int(unsafe.Sizeof(objectDirectoryInformation{}))
+	for off := 0; off+sz <= len(b); off += sz {
+		info := (*objectDirectoryInformation)(unsafe.Pointer(&b[off]))
+		if info.Name.Buffer == nil {
+			break
+		}
+		entries = append(entries, Entry{
+			Name: info.Name.String(),
+			Type: info.TypeName.String(),
+		})
+	}
+	return entries
+}
+
+// ListPipes returns the names of the named pipes that currently exist on the local machine,
+// without the `\\.\pipe\` prefix.
+func ListPipes() ([]string, error) {
+	p16, err := windows.UTF16PtrFromString(pipeRoot + "*")
+	if err != nil {
+		return nil, err
+	}
+	var d windows.Win32finddata
+	h, err := windows.FindFirstFile(p16, &d)
+	if errors.Is(err, windows.ERROR_FILE_NOT_FOUND) {
+		return nil, nil
+	} else if err != nil {
+		return nil, &os.PathError{Op: "FindFirstFile", Path: pipeRoot, Err: err}
+	}
+	defer windows.FindClose(h) //nolint:errcheck
+
+	var pipes []string
+	for {
+		pipes = append(pipes, windows.UTF16ToString(d.FileName[:]))
+		if err := windows.FindNextFile(h, &d); err != nil {
+			if errors.Is(err, windows.ERROR_NO_MORE_FILES) {
+				return pipes, nil
+			}
+			return nil, &os.PathError{Op: "FindNextFile", Path: pipeRoot, Err: err}
+		}
+	}
+}
+
+// PipeExists returns true if a named pipe with the specified name exists. name can be
+// either a full pipe path (`\\.\pipe\name`) or only the pipe's name.
+//
+// This allows detecting pipe name conflicts before calling winio.ListenPipe.
+func PipeExists(name string) (bool, error) {
+	if len(name) >= len(pipeRoot) && strings.EqualFold(name[:len(pipeRoot)], pipeRoot) {
+		name = name[len(pipeRoot):]
+	}
+	pipes, err := ListPipes()
+	if err != nil {
+		return false, err
+	}
+	for _, p := range pipes {
+		if strings.EqualFold(p, name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ntStatusErr converts an NTSTATUS error into the equivalent Win32 error.
+func ntStatusErr(err error) error {
+	if st, ok := err.(windows.NTStatus); ok { //nolint:errorlint // err is NTStatus
+		return st.Errno()
+	}
+	return err
+}
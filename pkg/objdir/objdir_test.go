@@ -0,0 +1,81 @@
+//go:build windows
+// +build windows
+
+package objdir
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/pkg/guid"
+)
+
+func TestListRoot(t *testing.T) {
+	entries, err := List(`\`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if strings.EqualFold(e.Name, "BaseNamedObjects") {
+			if e.Type != "Directory" {
+				t.Fatalf("BaseNamedObjects has type %q, expected Directory", e.Type)
+			}
+			return
+		}
+	}
+	t.Fatalf("BaseNamedObjects not found in %v", entries)
+}
+
+func TestListNotExist(t *testing.T) {
+	_, err := List(`\ThisDirectoryDoesNotExist`)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist, got %v", err)
+	}
+}
+
+func TestPipeExists(t *testing.T) {
+	g, err := guid.NewV4()
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := `\\.\pipe\objdir-test-` + g.String()
+
+	exists, err := PipeExists(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Fatalf("pipe %s exists before listening", name)
+	}
+
+	l, err := winio.ListenPipe(name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	exists, err = PipeExists(strings.ToUpper(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists {
+		t.Fatalf("pipe %s does not exist after listening", name)
+	}
+
+	pipes, err := ListPipes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, p := range pipes {
+		if strings.EqualFold(`\\.\pipe\`+p, name) {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("pipe %s not found in ListPipes", name)
+	}
+}
@@ -0,0 +1,248 @@
+//go:build windows
+// +build windows
+
+// Package oplock provides helpers for requesting opportunistic locks (oplocks) on files,
+// and being notified when they are broken.
+//
+// An oplock lets a process cache a file's data or handle while no other process is
+// accessing it in a conflicting way. When another process opens the file, the oplock is
+// broken: the holder is notified, and (depending on the oplock) the other open is blocked
+// until the break is acknowledged.
+//
+// https://learn.microsoft.com/en-us/windows/win32/fileio/opportunistic-locks
+package oplock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// FSCTL_REQUEST_OPLOCK
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/winioctl/ni-winioctl-fsctl_request_oplock
+const fsctlRequestOplock = 0x00090240
+
+const requestOplockVersion = 1 // REQUEST_OPLOCK_CURRENT_VERSION
+
+// REQUEST_OPLOCK_INPUT_FLAG_*
+const (
+	inputFlagRequest = 0x1
+	inputFlagAck     = 0x2
+)
+
+// REQUEST_OPLOCK_OUTPUT_FLAG_ACK_REQUIRED
+const outputFlagAckRequired = 0x1
+
+// Level is a combination of the caching levels of an oplock.
+type Level uint32
+
+const (
+	// LevelNone is the level of an oplock that has been broken completely.
+	LevelNone Level = 0
+	// LevelRead allows caching reads of the file.
+	LevelRead Level = 0x1 // OPLOCK_LEVEL_CACHE_READ
+	// LevelHandle allows caching the open handle to the file.
+	LevelHandle Level = 0x2 // OPLOCK_LEVEL_CACHE_HANDLE
+	// LevelWrite allows caching writes to the file.
+	LevelWrite Level = 0x4 // OPLOCK_LEVEL_CACHE_WRITE
+)
+
+func (l Level) String() string {
+	if l == LevelNone {
+		return "None"
+	}
+	s := ""
+	for _, x := range []struct {
+		l Level
+		s string
+	}{{LevelRead, "R"}, {LevelWrite, "W"}, {LevelHandle, "H"}} {
+		if l&x.l != 0 {
+			s += x.s
+		}
+	}
+	if rest := l &^ (LevelRead | LevelWrite | LevelHandle); rest != 0 {
+		s += fmt.Sprintf("|0x%x", uint32(rest))
+	}
+	return s
+}
+
+// ErrNoBreak is returned by [Oplock.Acknowledge] if the oplock has not been broken, or the
+// break does not need to be acknowledged.
+var ErrNoBreak = errors.New("oplock break does not require acknowledgement")
+
+// REQUEST_OPLOCK_INPUT_BUFFER
+type requestInput struct {
+	StructureVersion     uint16
+	StructureLength      uint16
+	RequestedOplockLevel Level
+	Flags                uint32
+}
+
+// REQUEST_OPLOCK_OUTPUT_BUFFER
+type requestOutput struct {
+	StructureVersion    uint16
+	StructureLength     uint16
+	OriginalOplockLevel Level
+	NewOplockLevel      Level
+	Flags               uint32
+	AccessMode          uint32
+	ShareMode           uint16
+	_                   uint16
+}
+
+// Break describes an oplock break.
+type Break struct {
+	// OldLevel is the level of the oplock before it was broken.
+	OldLevel Level
+	// NewLevel is the level the oplock was broken to.
+	NewLevel Level
+	// AckRequired is true if the break must be acknowledged, with [Oplock.Acknowledge] or by
+	// closing the file handle, before the operation that caused the break can proceed.
+	AckRequired bool
+}
+
+// Oplock is an oplock granted on a file.
+type Oplock struct {
+	f     winio.DeviceIoController
+	level Level
+
+	// the request buffers must remain valid until the request completes
+	in     requestInput
+	out    requestOutput
+	cancel func()
+
+	ch   chan Break
+	done chan struct{}
+
+	mu  sync.Mutex
+	brk *Break
+	err error
+}
+
+// Request requests an oplock at the specified level on f, a file returned by
+// [winio.NewOpenFile].
+//
+// Valid levels are LevelRead, LevelRead|LevelHandle, LevelRead|LevelWrite, and
+// LevelRead|LevelWrite|LevelHandle. If the oplock cannot be granted, the returned error
+// wraps windows.ERROR_OPLOCK_NOT_GRANTED.
+//
+// The request completes through the IO completion port of go-winio, so no goroutine is
+// blocked while the oplock is held. Closing f releases the oplock.
+func Request(f winio.DeviceIoController, level Level) (*Oplock, error) {
+	return request(f, level, inputFlagRequest)
+}
+
+func request(f winio.DeviceIoController, level Level, flags uint32) (*Oplock, error) {
+	op := &Oplock{
+		f:     f,
+		level: level,
+		ch:    make(chan Break, 1),
+		done:  make(chan struct{}),
+	}
+	op.in = requestInput{
+		StructureVersion:     requestOplockVersion,
+		StructureLength:      uint16(unsafe.Sizeof(op.in)),
+		RequestedOplockLevel: level,
+		Flags:                flags,
+	}
+	op.out = requestOutput{
+		StructureVersion: requestOplockVersion,
+		StructureLength:  uint16(unsafe.Sizeof(op.out)),
+	}
+
+	// The request remains pending while the oplock is granted, and completes when it is
+	// broken. It completes immediately when acknowledging a break to LevelNone, or if the
+	// oplock was broken while it was being granted.
+	cancel, err := f.DeviceIoControl(fsctlRequestOplock,
+		(*[unsafe.Sizeof(requestInput{})]byte)(unsafe.Pointer(&op.in))[:],
+		(*[unsafe.Sizeof(requestOutput{})]byte)(unsafe.Pointer(&op.out))[:],
+		op.complete)
+	if err != nil {
+		return nil, os.NewSyscallError("DeviceIoControl", err)
+	}
+	op.cancel = cancel
+	return op, nil
+}
+
+// complete records the result of the oplock request, and notifies the break channel. It is
+// called when the request completes, and must not block.
+func (op *Oplock) complete(_ uint32, err error) {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+
+	switch {
+	case err == nil:
+		op.brk = &Break{
+			OldLevel:    op.out.OriginalOplockLevel,
+			NewLevel:    op.out.NewOplockLevel,
+			AckRequired: op.out.Flags&outputFlagAckRequired != 0,
+		}
+		op.ch <- *op.brk
+	case err == windows.ERROR_OPERATION_ABORTED || errors.Is(err, winio.ErrFileClosed): //nolint:errorlint // err is Errno
+		err = os.ErrClosed
+	default:
+		err = os.NewSyscallError("DeviceIoControl", err)
+	}
+	op.err = err
+	close(op.ch)
+	close(op.done)
+}
+
+// Level returns the level the oplock was granted at.
+func (op *Oplock) Level() Level {
+	return op.level
+}
+
+// Breaks returns a channel that receives a value when the oplock is broken. The channel
+// is closed after the break, or when the oplock is closed or fails.
+func (op *Oplock) Breaks() <-chan Break {
+	return op.ch
+}
+
+// Err returns the error that ended the oplock request without a break, if any. It returns
+// os.ErrClosed if the oplock was closed.
+func (op *Oplock) Err() error {
+	op.mu.Lock()
+	defer op.mu.Unlock()
+	return op.err
+}
+
+// Acknowledge acknowledges a break that requires acknowledgement, allowing the operation
+// that caused the break to proceed. The returned oplock is held at the break's new level,
+// and is nil if the oplock was broken to LevelNone.
+func (op *Oplock) Acknowledge() (*Oplock, error) {
+	op.mu.Lock()
+	brk := op.brk
+	op.mu.Unlock()
+	if brk == nil || !brk.AckRequired {
+		return nil, ErrNoBreak
+	}
+
+	ack, err := request(op.f, brk.NewLevel, inputFlagAck)
+	if err != nil || brk.NewLevel != LevelNone {
+		return ack, err
+	}
+	// there is no oplock left to hold
+	return nil, ack.Close()
+}
+
+// Close releases the oplock request, if it has not been broken, and waits for it to complete.
+// It does not close the file. A break that requires acknowledgement and has not been
+// acknowledged remains outstanding until the file is closed.
+func (op *Oplock) Close() error {
+	select {
+	case <-op.done:
+		return nil
+	default:
+	}
+	op.cancel()
+	<-op.done
+	return nil
+}
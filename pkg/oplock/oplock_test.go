@@ -0,0 +1,169 @@
+//go:build windows
+// +build windows
+
+package oplock
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio"
+)
+
+// openFile opens p for overlapped IO, as a file that can be passed to Request.
+func openFile(t *testing.T, p string) winio.DeviceIoController {
+	t.Helper()
+
+	p16, err := windows.UTF16PtrFromString(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := windows.CreateFile(p16,
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_OVERLAPPED,
+		0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := winio.NewOpenFile(h)
+	if err != nil {
+		windows.CloseHandle(h) //nolint:errcheck
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f.(winio.DeviceIoController)
+}
+
+func testFile(t *testing.T) string {
+	t.Helper()
+
+	p := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(p, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOplockBreakAcknowledge(t *testing.T) {
+	p := testFile(t)
+	f := openFile(t, p)
+
+	op, err := Request(f, LevelRead|LevelWrite|LevelHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer op.Close()
+
+	// opening the file for write breaks the oplock, and blocks until the break is acknowledged
+	opened := make(chan error, 1)
+	go func() {
+		f, err := os.OpenFile(p, os.O_WRONLY, 0)
+		if err == nil {
+			f.Close()
+		}
+		opened <- err
+	}()
+
+	var brk Break
+	select {
+	case b, ok := <-op.Breaks():
+		if !ok {
+			t.Fatalf("oplock failed: %v", op.Err())
+		}
+		brk = b
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for oplock break")
+	}
+	t.Logf("oplock broken from %v to %v", brk.OldLevel, brk.NewLevel)
+	if brk.OldLevel != LevelRead|LevelWrite|LevelHandle || !brk.AckRequired {
+		t.Fatalf("unexpected break %+v", brk)
+	}
+
+	ack, err := op.Acknowledge()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ack != nil {
+		defer ack.Close()
+		if ack.Level() != brk.NewLevel {
+			t.Fatalf("acknowledged oplock level is %v, want %v", ack.Level(), brk.NewLevel)
+		}
+	}
+
+	select {
+	case err := <-opened:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for conflicting open")
+	}
+}
+
+func TestOplockClose(t *testing.T) {
+	f := openFile(t, testFile(t))
+
+	op, err := Request(f, LevelRead|LevelHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := op.Acknowledge(); !errors.Is(err, ErrNoBreak) {
+		t.Fatalf("expected %v, got %v", ErrNoBreak, err)
+	}
+	if err := op.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-op.Breaks(); ok {
+		t.Fatal("closed oplock reported a break")
+	}
+	if !errors.Is(op.Err(), os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, op.Err())
+	}
+}
+
+func TestOplockFileClosed(t *testing.T) {
+	f := openFile(t, testFile(t))
+
+	op, err := Request(f, LevelRead|LevelHandle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-op.Breaks():
+		if ok {
+			t.Fatal("oplock on a closed file reported a break")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the oplock request to complete")
+	}
+	if !errors.Is(op.Err(), os.ErrClosed) {
+		t.Fatalf("expected %v, got %v", os.ErrClosed, op.Err())
+	}
+	if err := op.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLevelString(t *testing.T) {
+	for l, s := range map[Level]string{
+		LevelNone:                            "None",
+		LevelRead | LevelHandle:              "RH",
+		LevelRead | LevelWrite | LevelHandle: "RWH",
+	} {
+		if l.String() != s {
+			t.Errorf("%d: got %q, want %q", l, l.String(), s)
+		}
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/pkg/stringbuffer"
 )
 
 // EnumProcesses returns a slice containing the process IDs of all processes
@@ -74,17 +76,12 @@ const (
 // PROCESS_QUERY_LIMITED_INFORMATION access right. The flags can be either
 // `ImageNameFormatWin32Path` or `ImageNameFormatNTPath`.
 func QueryFullProcessImageName(process windows.Handle, flags uint32) (string, error) {
-	bufferSize := uint32(256)
-	for {
-		b := make([]uint16, bufferSize)
-		err := queryFullProcessImageName(process, flags, &b[0], &bufferSize)
+	return stringbuffer.Fill(func(p *uint16, n uint32) (uint32, error) {
+		size := n
+		err := queryFullProcessImageName(process, flags, p, &size)
 		if err == windows.ERROR_INSUFFICIENT_BUFFER { //nolint:errorlint // err is Errno
-			bufferSize = bufferSize * 2
-			continue
+			return n + 1, nil
 		}
-		if err != nil {
-			return "", err
-		}
-		return windows.UTF16ToString(b[:bufferSize]), nil
-	}
+		return size, err
+	})
 }
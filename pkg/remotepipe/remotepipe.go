@@ -0,0 +1,240 @@
+//go:build windows
+// +build windows
+
+// Package remotepipe dials named pipes on remote hosts over SMB, establishing an SMB session
+// with explicit credentials when needed, so that named pipe RPC can be used across machines
+// without WinRM or other remoting infrastructure.
+package remotepipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio"
+)
+
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go ./remotepipe.go
+
+//sys wNetAddConnection2(resource *netResource, password *uint16, username *uint16, flags uint32) (win32err error) = mpr.WNetAddConnection2W
+//sys wNetCancelConnection2(name *uint16, flags uint32, force bool) (win32err error) = mpr.WNetCancelConnection2W
+
+const (
+	resourceTypeAny  = 0x0 // RESOURCETYPE_ANY
+	connectTemporary = 0x4 // CONNECT_TEMPORARY
+)
+
+// netResource is NETRESOURCEW.
+type netResource struct {
+	scope       uint32
+	typ         uint32
+	displayType uint32
+	usage       uint32
+	localName   *uint16
+	remoteName  *uint16
+	comment     *uint16
+	provider    *uint16
+}
+
+var (
+	// ErrInvalidName is returned by [DialPipeRemote] if the host or pipe name is empty or
+	// contains a path separator.
+	ErrInvalidName = errors.New("invalid remote pipe host or name")
+
+	// ErrCredentialConflict is returned by [DialPipeRemote] if the process already has an SMB
+	// session with the host using different credentials. Windows allows only one set of
+	// credentials per host in a logon session.
+	ErrCredentialConflict = errors.New("SMB session to host already uses different credentials")
+)
+
+// Credentials are the credentials used to establish the SMB session with a remote host.
+type Credentials struct {
+	// Username is the name of the user, such as `DOMAIN\user` or `user@domain`.
+	Username string
+	Password string
+}
+
+// session is an SMB session established by this package, shared by the connections to a host.
+type session struct {
+	username string
+	refs     int
+	closing  bool          // the last reference was released, and the session is being removed
+	ready    chan struct{} // closed once the session is established, or failed to be
+	err      error         // the error establishing the session, set before ready is closed
+	closed   chan struct{} // closed once the session has been removed
+}
+
+var (
+	sessionsLock sync.Mutex
+	sessions     = make(map[string]*session) // keyed by the lowercase IPC$ share name
+)
+
+// DialPipeRemote connects to the named pipe pipeName (such as "mypipe", for \\host\pipe\mypipe)
+// on host.
+//
+// If creds is nil, the connection uses the caller's credentials, or any SMB session that
+// already exists to the host. Otherwise, DialPipeRemote first establishes an SMB session with
+// the host's IPC$ share using creds, unless one it established earlier is still in use. The
+// session is shared by the connections to the host that DialPipeRemote returns, and removed
+// when the last of them is closed.
+//
+// The returned connection implements [winio.PipeConn].
+func DialPipeRemote(ctx context.Context, host, pipeName string, creds *Credentials) (net.Conn, error) {
+	if !validName(host) || !validName(pipeName) {
+		return nil, ErrInvalidName
+	}
+	path := `\\` + host + `\pipe\` + pipeName
+	if creds == nil {
+		return winio.DialPipeContext(ctx, path)
+	}
+
+	release, err := acquireSession(ctx, host, creds)
+	if err != nil {
+		return nil, err
+	}
+	c, err := winio.DialPipeContext(ctx, path)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &sessionConn{PipeConn: c.(winio.PipeConn), release: release}, nil
+}
+
+func validName(s string) bool {
+	return s != "" && !strings.ContainsAny(s, `\/`)
+}
+
+// acquireSession returns a function that releases a reference on the SMB session with host,
+// establishing the session if necessary.
+//
+// The session is established without holding sessionsLock, so that dialing other hosts is not
+// blocked by a slow or unreachable host; concurrent callers for the same host wait for the
+// first one to establish it.
+func acquireSession(ctx context.Context, host string, creds *Credentials) (func(), error) {
+	share := `\\` + host + `\IPC$`
+	key := strings.ToLower(share)
+
+	for {
+		sessionsLock.Lock()
+		s, ok := sessions[key]
+		if ok && s.closing {
+			// wait for the session to be removed before establishing a new one, so that
+			// removing it does not cancel the new connection
+			sessionsLock.Unlock()
+			select {
+			case <-s.closed:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		if ok && !strings.EqualFold(s.username, creds.Username) {
+			sessionsLock.Unlock()
+			return nil, fmt.Errorf("connect %s: %w", share, ErrCredentialConflict)
+		}
+		if !ok {
+			s = &session{
+				username: creds.Username,
+				ready:    make(chan struct{}),
+				closed:   make(chan struct{}),
+			}
+			sessions[key] = s
+		}
+		s.refs++
+		sessionsLock.Unlock()
+
+		var once sync.Once
+		release := func() { once.Do(func() { releaseSession(key, share, s) }) }
+		if !ok {
+			s.err = addConnection(share, creds)
+			close(s.ready)
+		} else {
+			select {
+			case <-s.ready:
+			case <-ctx.Done():
+				release()
+				return nil, ctx.Err()
+			}
+		}
+		if s.err != nil {
+			release()
+			return nil, s.err
+		}
+		return release, nil
+	}
+}
+
+// releaseSession releases a reference on the session s, removing it once it is no longer used.
+func releaseSession(key, share string, s *session) {
+	sessionsLock.Lock()
+	s.refs--
+	if s.refs != 0 {
+		sessionsLock.Unlock()
+		return
+	}
+	s.closing = true
+	sessionsLock.Unlock()
+
+	// the session was established if there is no error, since its creator held a reference
+	// until then
+	if s.err == nil {
+		cancelConnection(share)
+	}
+	sessionsLock.Lock()
+	delete(sessions, key)
+	sessionsLock.Unlock()
+	close(s.closed)
+}
+
+func addConnection(share string, creds *Credentials) error {
+	share16, err := windows.UTF16PtrFromString(share)
+	if err != nil {
+		return err
+	}
+	user16, err := windows.UTF16PtrFromString(creds.Username)
+	if err != nil {
+		return err
+	}
+	password16, err := windows.UTF16PtrFromString(creds.Password)
+	if err != nil {
+		return err
+	}
+	r := netResource{typ: resourceTypeAny, remoteName: share16}
+	err = wNetAddConnection2(&r, password16, user16, connectTemporary)
+	if errors.Is(err, windows.ERROR_SESSION_CREDENTIAL_CONFLICT) {
+		err = fmt.Errorf("%w: %v", ErrCredentialConflict, err) //nolint:errorlint // only wrap the sentinel
+	}
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", share, err)
+	}
+	return nil
+}
+
+func cancelConnection(share string) {
+	share16, err := windows.UTF16PtrFromString(share)
+	if err != nil {
+		return
+	}
+	// connections opened through the session keep it alive, so do not force it closed
+	_ = wNetCancelConnection2(share16, 0, false)
+}
+
+// sessionConn is a connection that holds a reference on an SMB session.
+type sessionConn struct {
+	winio.PipeConn
+	release func()
+}
+
+var _ winio.PipeConn = (*sessionConn)(nil)
+
+// Close closes the connection, and removes the SMB session if no other connection uses it.
+func (c *sessionConn) Close() error {
+	err := c.PipeConn.Close()
+	c.release()
+	return err
+}
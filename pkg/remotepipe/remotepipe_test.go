@@ -0,0 +1,69 @@
+//go:build windows
+// +build windows
+
+package remotepipe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Microsoft/go-winio"
+)
+
+func TestDialPipeRemoteLocal(t *testing.T) {
+	name := fmt.Sprintf("go-winio-remotepipe-test-%d", time.Now().UnixNano())
+	l, err := winio.ListenPipe(`\\.\pipe\`+name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer c.Close()
+		_, err = c.Write([]byte("hi"))
+		ch <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := DialPipeRemote(ctx, ".", name, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	b := make([]byte, 2)
+	if _, err := io.ReadFull(c, b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hi" {
+		t.Fatalf("read %q", b)
+	}
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDialPipeRemoteInvalidName(t *testing.T) {
+	for _, tc := range []struct{ host, name string }{
+		{"", "pipe"},
+		{"host", ""},
+		{`host\share`, "pipe"},
+		{"host", "a/b"},
+	} {
+		_, err := DialPipeRemote(context.Background(), tc.host, tc.name, &Credentials{Username: "user"})
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("DialPipeRemote(%q, %q): expected %v, got %v", tc.host, tc.name, ErrInvalidName, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+//go:build windows
+
+// Code generated by 'go generate' using "github.com/Microsoft/go-winio/tools/mkwinsyscall"; DO NOT EDIT.
+
+package remotepipe
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var _ unsafe.Pointer
+
+// Do the interface allocations only once for common
+// Errno values.
+const (
+	errnoERROR_IO_PENDING = 997
+)
+
+var (
+	errERROR_IO_PENDING error = syscall.Errno(errnoERROR_IO_PENDING)
+	errERROR_EINVAL     error = syscall.EINVAL
+)
+
+// errnoErr returns common boxed Errno values, to prevent
+// allocations at runtime.
+func errnoErr(e syscall.Errno) error {
+	switch e {
+	case 0:
+		return errERROR_EINVAL
+	case errnoERROR_IO_PENDING:
+		return errERROR_IO_PENDING
+	}
+	// TODO: add more here, after collecting data on the common
+	// error values see on Windows. (perhaps when running
+	// all.bat?)
+	return e
+}
+
+var (
+	modmpr = windows.NewLazySystemDLL("mpr.dll")
+
+	procWNetAddConnection2W    = modmpr.NewProc("WNetAddConnection2W")
+	procWNetCancelConnection2W = modmpr.NewProc("WNetCancelConnection2W")
+)
+
+func wNetAddConnection2(resource *netResource, password *uint16, username *uint16, flags uint32) (win32err error) {
+	r0, _, _ := syscall.Syscall6(procWNetAddConnection2W.Addr(), 4, uintptr(unsafe.Pointer(resource)), uintptr(unsafe.Pointer(password)), uintptr(unsafe.Pointer(username)), uintptr(flags), 0, 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func wNetCancelConnection2(name *uint16, flags uint32, force bool) (win32err error) {
+	var _p0 uint32
+	if force {
+		_p0 = 1
+	}
+	r0, _, _ := syscall.Syscall(procWNetCancelConnection2W.Addr(), 3, uintptr(unsafe.Pointer(name)), uintptr(flags), uintptr(_p0))
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
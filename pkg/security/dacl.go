@@ -0,0 +1,141 @@
+//go:build windows
+// +build windows
+
+package security
+
+import (
+	"encoding/binary"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/internal/fs"
+)
+
+const (
+	//cspell:disable-next-line
+	vvmga = "VerifyVmGroupAccess:"
+
+	aceTypeAccessAllowed = 0x0 // ACCESS_ALLOWED_ACE_TYPE
+	aceTypeAccessDenied  = 0x1 // ACCESS_DENIED_ACE_TYPE
+
+	inheritFlags = windows.OBJECT_INHERIT_ACE | windows.CONTAINER_INHERIT_ACE
+)
+
+// AceInfo describes an access allowed or access denied entry of a DACL, as returned by
+// [DumpDACL].
+type AceInfo struct {
+	// SID is the string SID of the trustee, such as "S-1-5-83-0".
+	SID string
+	// Allowed is true for an access allowed ACE, and false for an access denied ACE.
+	Allowed bool
+	// Flags are the ACE's inheritance flags, such as windows.OBJECT_INHERIT_ACE and
+	// windows.INHERITED_ACE.
+	Flags uint8
+	// Mask is the access mask of the ACE, which may contain generic rights.
+	Mask uint32
+}
+
+// aclHeader is the layout of windows.ACL, whose fields are not exported.
+type aclHeader struct {
+	AclRevision uint8
+	Sbz1        uint8
+	AclSize     uint16
+	AceCount    uint16
+	Sbz2        uint16
+}
+
+// DumpDACL returns the access allowed and access denied entries of the DACL of the file or
+// directory name, in order. Entries of other types, such as object ACEs, are skipped. A nil
+// DACL, which grants everyone full access, returns nil.
+func DumpDACL(name string) ([]AceInfo, error) {
+	sd, err := windows.GetNamedSecurityInfo(name, windows.SE_FILE_OBJECT, windows.DACL_SECURITY_INFORMATION)
+	if err != nil {
+		return nil, fmt.Errorf("GetNamedSecurityInfo %s: %w", name, err)
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return nil, fmt.Errorf("get DACL of %s: %w", name, err)
+	}
+	if dacl == nil {
+		return nil, nil
+	}
+
+	hdr := *(*aclHeader)(unsafe.Pointer(dacl))
+	b := unsafe.Slice((*byte)(unsafe.Pointer(dacl)), hdr.AclSize)
+	var aces []AceInfo
+	off := int(unsafe.Sizeof(hdr))
+	for i := 0; i < int(hdr.AceCount) && off+4 <= len(b); i++ {
+		// ACE_HEADER: AceType, AceFlags, AceSize
+		typ, flags := b[off], b[off+1]
+		size := int(binary.LittleEndian.Uint16(b[off+2:]))
+		if size < 4 || off+size > len(b) {
+			return nil, fmt.Errorf("DACL of %s is malformed", name)
+		}
+		ace := b[off : off+size]
+		off += size
+		if (typ != aceTypeAccessAllowed && typ != aceTypeAccessDenied) || size < 8+8 {
+			continue
+		}
+		// The mask follows the header, and the SID follows the mask.
+		sid := (*windows.SID)(unsafe.Pointer(&ace[8]))
+		aces = append(aces, AceInfo{
+			SID:     sid.String(),
+			Allowed: typ == aceTypeAccessAllowed,
+			Flags:   flags,
+			Mask:    binary.LittleEndian.Uint32(ace[4:]),
+		})
+	}
+	return aces, nil
+}
+
+// VerifyVmGroupAccess reports whether the DACL of the file or directory name grants the VM
+// Group SID read access, as set by [GrantVmGroupAccess]: through an explicit or inherited ACE
+// on the file or directory itself, and, for a directory, through an ACE inherited by its
+// files and subdirectories. ACEs that deny the VM Group read access fail the check.
+//
+//revive:disable-next-line:var-naming VM, not Vm
+func VerifyVmGroupAccess(name string) (bool, error) {
+	dir, err := isDir(name)
+	if err != nil {
+		return false, fmt.Errorf("%s %w", vvmga, err)
+	}
+	aces, err := DumpDACL(name)
+	if err != nil {
+		return false, fmt.Errorf("%s %w", vvmga, err)
+	}
+
+	var applies, inherits bool
+	for _, ace := range aces {
+		if ace.SID != sidVMGroup || fs.MapGenericFileRights(fs.AccessMask(ace.Mask))&windows.FILE_GENERIC_READ == 0 {
+			continue
+		}
+		if !ace.Allowed {
+			if ace.Flags&windows.INHERIT_ONLY_ACE == 0 {
+				return false, nil
+			}
+			continue
+		}
+		grantsRead := fs.MapGenericFileRights(fs.AccessMask(ace.Mask))&windows.FILE_GENERIC_READ == windows.FILE_GENERIC_READ
+		if grantsRead && ace.Flags&windows.INHERIT_ONLY_ACE == 0 {
+			applies = true
+		}
+		if grantsRead && ace.Flags&inheritFlags == inheritFlags {
+			inherits = true
+		}
+	}
+	return applies && (!dir || inherits), nil
+}
+
+func isDir(name string) (bool, error) {
+	name16, err := windows.UTF16PtrFromString(name)
+	if err != nil {
+		return false, err
+	}
+	attrs, err := windows.GetFileAttributes(name16)
+	if err != nil {
+		return false, fmt.Errorf("GetFileAttributes %s: %w", name, err)
+	}
+	return attrs&windows.FILE_ATTRIBUTE_DIRECTORY != 0, nil
+}
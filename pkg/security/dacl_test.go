@@ -0,0 +1,83 @@
+//go:build windows
+// +build windows
+
+package security
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestDumpDACL(t *testing.T) {
+	d := t.TempDir()
+	f := filepath.Join(d, "f.txt")
+	if err := os.WriteFile(f, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{f, d} {
+		if ok, err := VerifyVmGroupAccess(name); err != nil {
+			t.Fatal(err)
+		} else if ok {
+			t.Fatalf("%s grants VM group access before GrantVmGroupAccess", name)
+		}
+	}
+
+	if err := GrantVmGroupAccess(d); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(d, "sub.txt")
+	if err := os.WriteFile(sub, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := GrantVmGroupAccess(f); err != nil {
+		t.Fatal(err)
+	}
+
+	testVMGroupACEs(t, f, []AceInfo{{Flags: 0, Mask: windows.FILE_GENERIC_READ}})
+	testVMGroupACEs(t, d, []AceInfo{
+		{Flags: 0, Mask: windows.FILE_GENERIC_READ},
+		{Flags: windows.OBJECT_INHERIT_ACE | windows.CONTAINER_INHERIT_ACE | windows.INHERIT_ONLY_ACE, Mask: windows.GENERIC_READ},
+	})
+	testVMGroupACEs(t, sub, []AceInfo{{Flags: windows.INHERITED_ACE, Mask: windows.FILE_GENERIC_READ}})
+}
+
+func TestDumpDACLNotFound(t *testing.T) {
+	if _, err := DumpDACL(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+// testVMGroupACEs checks that the DACL returned by DumpDACL for name has exactly one access
+// allowed ACE for the VM Group SID with each of the flags and masks in want, and that
+// VerifyVmGroupAccess succeeds.
+func testVMGroupACEs(t *testing.T, name string, want []AceInfo) {
+	t.Helper()
+
+	aces, err := DumpDACL(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, w := range want {
+		w.SID = vmAccountSID
+		w.Allowed = true
+		n := 0
+		for _, ace := range aces {
+			if ace == w {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Fatalf("expected one ACE %+v for %s, got %+v", w, name, aces)
+		}
+	}
+
+	if ok, err := VerifyVmGroupAccess(name); err != nil {
+		t.Fatal(err)
+	} else if !ok {
+		t.Fatalf("VerifyVmGroupAccess(%s) returned false for DACL %+v", name, aces)
+	}
+}
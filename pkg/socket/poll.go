@@ -0,0 +1,85 @@
+//go:build windows
+// +build windows
+
+package socket
+
+import (
+	"math"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+//sys wsaPoll(fds *PollFd, nfds uint32, timeout int32) (n int32, err error) [failretval==-1] = ws2_32.WSAPoll
+
+// Poll events, for [PollFd].Events and [PollFd].Revents.
+//
+//nolint:revive // SNAKE_CASE is not idiomatic in Go, but aligned with Win32 API.
+const (
+	POLLRDNORM = 0x0100
+	POLLRDBAND = 0x0200
+	POLLIN     = POLLRDNORM | POLLRDBAND
+	POLLPRI    = 0x0400
+
+	POLLWRNORM = 0x0010
+	POLLOUT    = POLLWRNORM
+	POLLWRBAND = 0x0020
+
+	// POLLERR, POLLHUP, and POLLNVAL are only returned in Revents.
+	POLLERR  = 0x0001
+	POLLHUP  = 0x0002
+	POLLNVAL = 0x0004
+)
+
+// PollFd is a socket to wait on with [Poll], along with the events to wait for.
+//
+// It has the same layout as WSAPOLLFD.
+type PollFd struct {
+	// Fd is the socket handle. Negative values are ignored.
+	Fd windows.Handle
+	// Events are the events to wait for.
+	Events int16
+	// Revents are the events that occurred, set by Poll.
+	Revents int16
+}
+
+// Poll waits until at least one of the sockets in fds is ready for one of its requested
+// events, or until timeout elapses, and returns the number of sockets with events. The
+// events that occurred are returned in each element's Revents field.
+//
+// A negative timeout waits indefinitely, and a zero timeout returns immediately.
+//
+// Poll uses WSAPoll, so it blocks an OS thread while waiting. It does not interact with
+// pending overlapped operations on the sockets; for instance, a socket with a pending
+// read may still be reported as readable.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/winsock2/nf-winsock2-wsapoll
+func Poll(fds []PollFd, timeout time.Duration) (int, error) {
+	if len(fds) == 0 {
+		// WSAPoll fails with an empty array
+		if timeout > 0 {
+			time.Sleep(timeout)
+		}
+		return 0, nil
+	}
+
+	ms := int32(-1)
+	if timeout >= 0 {
+		// round up, so that short timeouts do not busy-wait
+		d := (timeout + time.Millisecond - 1) / time.Millisecond
+		if d > math.MaxInt32 {
+			d = math.MaxInt32
+		}
+		ms = int32(d)
+	}
+
+	for i := range fds {
+		fds[i].Revents = 0
+	}
+	n, err := wsaPoll(&fds[0], uint32(len(fds)), ms)
+	if err != nil {
+		return 0, os.NewSyscallError("WSAPoll", err)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,124 @@
+//go:build windows
+// +build windows
+
+package socket
+
+import (
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+func socketHandle(t *testing.T, c syscall.Conn) windows.Handle {
+	t.Helper()
+
+	rc, err := c.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h windows.Handle
+	if err := rc.Control(func(fd uintptr) { h = windows.Handle(fd) }); err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestPollListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	fds := []PollFd{{Fd: socketHandle(t, l.(*net.TCPListener)), Events: POLLIN}}
+	n, err := Poll(fds, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || fds[0].Revents != 0 {
+		t.Fatalf("listener ready with no pending connections: %d, 0x%x", n, fds[0].Revents)
+	}
+
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	n, err = Poll(fds, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || fds[0].Revents&POLLIN == 0 {
+		t.Fatalf("listener not ready with a pending connection: %d, 0x%x", n, fds[0].Revents)
+	}
+}
+
+func TestPollMultiple(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	c1, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c1.Close()
+	s1, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s1.Close()
+	c2, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	s2, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+
+	if _, err := c2.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	fds := []PollFd{
+		{Fd: socketHandle(t, s1.(*net.TCPConn)), Events: POLLIN},
+		{Fd: socketHandle(t, s2.(*net.TCPConn)), Events: POLLIN},
+	}
+	n, err := Poll(fds, 10*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || fds[0].Revents != 0 || fds[1].Revents&POLLRDNORM == 0 {
+		t.Fatalf("expected only the second socket to be readable: %d, %+v", n, fds)
+	}
+}
+
+func TestPollTimeout(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	fds := []PollFd{{Fd: socketHandle(t, l.(*net.TCPListener)), Events: POLLIN}}
+	start := time.Now()
+	n, err := Poll(fds, 50*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Fatalf("expected timeout, got %d ready", n)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond {
+		t.Fatalf("Poll returned after %v", d)
+	}
+}
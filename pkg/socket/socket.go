@@ -1,5 +1,9 @@
 //go:build windows
 
+// Package socket provides the Winsock primitives needed to implement socket address families
+// that the Go standard library and golang.org/x/sys/windows do not support, such as Hyper-V
+// sockets: binding, connecting, and querying sockets using arbitrary sockaddr structures (see
+// [RawSockaddr]), and waiting on the readiness of multiple sockets (see [Poll]).
 package socket
 
 import (
@@ -14,7 +18,7 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go socket.go
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zsyscall_windows.go socket.go poll.go
 
 //sys getsockname(s windows.Handle, name unsafe.Pointer, namelen *int32) (err error) [failretval==socketError] = ws2_32.getsockname
 //sys getpeername(s windows.Handle, name unsafe.Pointer, namelen *int32) (err error) [failretval==socketError] = ws2_32.getpeername
@@ -25,10 +29,17 @@ const socketError = uintptr(^uint32(0))
 var (
 	// todo(helsaawy): create custom error types to store the desired vs actual size and addr family?
 
-	ErrBufferSize     = errors.New("buffer size")
-	ErrAddrFamily     = errors.New("address family")
+	// ErrBufferSize is wrapped by errors returned when a buffer is too small to hold a
+	// sockaddr structure.
+	ErrBufferSize = errors.New("buffer size")
+	// ErrAddrFamily is wrapped by errors returned when a sockaddr structure has an
+	// unexpected address family.
+	ErrAddrFamily = errors.New("address family")
+	// ErrInvalidPointer is wrapped by errors returned when a [RawSockaddr] returns an
+	// invalid pointer.
 	ErrInvalidPointer = errors.New("invalid pointer")
-	ErrSocketClosed   = fmt.Errorf("socket closed: %w", net.ErrClosed)
+	// ErrSocketClosed is returned for operations on closed sockets. It wraps net.ErrClosed.
+	ErrSocketClosed = fmt.Errorf("socket closed: %w", net.ErrClosed)
 )
 
 // todo(helsaawy): replace these with generics, ie: GetSockName[S RawSockaddr](s windows.Handle) (S, error)
@@ -58,6 +69,7 @@ func GetPeerName(s windows.Handle, rsa RawSockaddr) error {
 	return getpeername(s, ptr, &l)
 }
 
+// Bind associates the local address rsa with socket s.
 func Bind(s windows.Handle, rsa RawSockaddr) (err error) {
 	ptr, l, err := rsa.Sockaddr()
 	if err != nil {
@@ -109,6 +121,8 @@ func (f *runtimeFunc) Load() error {
 
 var (
 	// todo: add `AcceptEx` and `GetAcceptExSockaddrs`
+
+	// WSAID_CONNECTEX is the GUID of the ConnectEx extension function.
 	WSAID_CONNECTEX = guid.GUID{ //revive:disable-line:var-naming ALL_CAPS
 		Data1: 0x25a207b9,
 		Data2: 0xddf3,
@@ -119,6 +133,11 @@ var (
 	connectExFunc = runtimeFunc{id: WSAID_CONNECTEX}
 )
 
+// ConnectEx connects socket fd, which must already be bound, to the remote address rsa,
+// using the ConnectEx extension function. The operation completes asynchronously if fd was
+// opened for overlapped IO and overlapped is not nil.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mswsock/nc-mswsock-lpfn_connectex
 func ConnectEx(
 	fd windows.Handle,
 	rsa RawSockaddr,
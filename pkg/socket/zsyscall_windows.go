@@ -42,11 +42,21 @@ func errnoErr(e syscall.Errno) error {
 var (
 	modws2_32 = windows.NewLazySystemDLL("ws2_32.dll")
 
+	procWSAPoll     = modws2_32.NewProc("WSAPoll")
 	procbind        = modws2_32.NewProc("bind")
 	procgetpeername = modws2_32.NewProc("getpeername")
 	procgetsockname = modws2_32.NewProc("getsockname")
 )
 
+func wsaPoll(fds *PollFd, nfds uint32, timeout int32) (n int32, err error) {
+	r0, _, e1 := syscall.Syscall(procWSAPoll.Addr(), 3, uintptr(unsafe.Pointer(fds)), uintptr(nfds), uintptr(timeout))
+	n = int32(r0)
+	if n == -1 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func bind(s windows.Handle, name unsafe.Pointer, namelen int32) (err error) {
 	r1, _, e1 := syscall.Syscall(procbind.Addr(), 3, uintptr(s), uintptr(name), uintptr(namelen))
 	if r1 == socketError {
@@ -1,17 +1,28 @@
+// Package stringbuffer provides pooled, growable UTF-16 buffers for calling Win32 APIs that
+// return strings, such as the many APIs that are called once to find the required buffer size,
+// and again with a buffer of that size.
 package stringbuffer
 
 import (
+	"errors"
 	"sync"
 	"unicode/utf16"
 )
 
-// TODO: worth exporting and using in mkwinsyscall?
-
-// Uint16BufferSize is the buffer size in the pool, chosen somewhat arbitrarily to accommodate
+// MinWStringCap is the buffer size in the pool, chosen somewhat arbitrarily to accommodate
 // large path strings:
 // MAX_PATH (260) + size of volume GUID prefix (49) + null terminator = 310.
 const MinWStringCap = 310
 
+// MaxWStringCap is the largest buffer that [WString.Fill] grows to. It is large enough for the
+// longest strings used by Win32, such as paths of up to 32,767 characters and environment
+// blocks.
+const MaxWStringCap = 1 << 20
+
+// ErrBufferTooLarge is returned by [WString.Fill] if the string does not fit in a buffer of
+// [MaxWStringCap] uint16s.
+var ErrBufferTooLarge = errors.New("string buffer exceeds maximum size")
+
 // use *[]uint16 since []uint16 creates an extra allocation where the slice header
 // is copied to heap and then referenced via pointer in the interface header that sync.Pool
 // stores.
@@ -109,8 +120,11 @@ func (b *WString) String() string {
 	// and would make this code Windows-only, which makes no sense.
 	// So copy UTF16ToString code into here.
 	// If other windows-specific code is added, switch to [windows.UTF16ToString]
+	return decode(b.b)
+}
 
-	s := b.b
+// decode returns the UTF-8 encoding of the null-terminated UTF-16 string in s.
+func decode(s []uint16) string {
 	for i, v := range s {
 		if v == 0 {
 			s = s[:i]
@@ -120,6 +134,45 @@ func (b *WString) String() string {
 	return string(utf16.Decode(s))
 }
 
+// FillFunc is called by [WString.Fill] with a pointer to the buffer and its capacity, in
+// uint16s. It returns the length of the string written to the buffer, or, if the buffer is too
+// small, a size larger than the capacity: the size required, if the API reports it, or any
+// larger value (such as n+1) otherwise.
+type FillFunc func(p *uint16, n uint32) (uint32, error)
+
+// Fill calls fn, growing the buffer and calling fn again for as long as it reports that the
+// buffer is too small, and returns the UTF-8 encoding of the resulting string, which ends at
+// the first null terminator or at the length returned by fn.
+//
+// This implements the common Win32 pattern of calling an API with a buffer, and calling it
+// again with a larger one if the first was too small. Errors from fn are returned unchanged.
+func (b *WString) Fill(fn FillFunc) (string, error) {
+	if b.empty() {
+		b.b = newBuffer()
+	}
+	for {
+		n, err := fn(b.Pointer(), b.Cap())
+		if err != nil {
+			return "", err
+		}
+		if n <= b.Cap() {
+			return decode(b.b[:n]), nil
+		}
+		if n > MaxWStringCap {
+			return "", ErrBufferTooLarge
+		}
+		b.ResizeTo(n)
+	}
+}
+
+// Fill calls fn with a buffer from the pool, as [WString.Fill], and frees the buffer before
+// returning.
+func Fill(fn FillFunc) (string, error) {
+	b := NewWString()
+	defer b.Free()
+	return b.Fill(fn)
+}
+
 // Cap returns the underlying buffer capacity.
 func (b *WString) Cap() uint32 {
 	if b.empty() {
@@ -2,7 +2,11 @@
 
 package stringbuffer
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"unsafe"
+)
 
 func Test_BufferCapacity(t *testing.T) {
 	b := NewWString()
@@ -43,3 +47,37 @@ func Test_BufferFree(t *testing.T) {
 		}
 	}
 }
+
+func Test_BufferFill(t *testing.T) {
+	const want = "hello"
+	s16 := []uint16{'h', 'e', 'l', 'l', 'o'}
+	required := uint32(2*MinWStringCap + 1)
+
+	calls := 0
+	s, err := Fill(func(p *uint16, n uint32) (uint32, error) {
+		calls++
+		if n < required {
+			return required, nil
+		}
+		copy(unsafe.Slice(p, n), s16)
+		return uint32(len(s16)), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != want {
+		t.Fatalf("expected %q, got %q", want, s)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 calls, got %d", calls)
+	}
+}
+
+func Test_BufferFillTooLarge(t *testing.T) {
+	_, err := Fill(func(p *uint16, n uint32) (uint32, error) {
+		return n + 1, nil
+	})
+	if !errors.Is(err, ErrBufferTooLarge) {
+		t.Fatalf("expected %v, got %v", ErrBufferTooLarge, err)
+	}
+}
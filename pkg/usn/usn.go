@@ -0,0 +1,337 @@
+//go:build windows
+// +build windows
+
+// Package usn reads the update sequence number (USN) change journal of an NTFS or ReFS volume,
+// which records changes made to the files on the volume.
+//
+// https://learn.microsoft.com/en-us/windows/win32/fileio/change-journals
+package usn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//nolint:revive // SNAKE_CASE is not idiomatic in Go, but aligned with Win32 API.
+const (
+	FSCTL_QUERY_USN_JOURNAL = 0x000900f4
+	FSCTL_READ_USN_JOURNAL  = 0x000900bb
+)
+
+// readBufferSize is the size of the buffer records are read into.
+const readBufferSize = 64 * 1024
+
+// ErrCursorExpired is returned when reading from a [Cursor] that is no longer valid, because
+// the journal was deleted or recreated, or the records after the cursor were purged from
+// the journal. The caller should fall back to a full scan of the volume, and start again
+// from the cursor returned by [Journal.Cursor].
+var ErrCursorExpired = errors.New("USN journal cursor has expired")
+
+// USN is an update sequence number, the offset of a record in the change journal.
+type USN int64
+
+// Reason is a bitmask of the changes made to a file.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/winioctl/ns-winioctl-usn_record_v2
+type Reason uint32
+
+const (
+	ReasonDataOverwrite        Reason = 0x00000001
+	ReasonDataExtend           Reason = 0x00000002
+	ReasonDataTruncation       Reason = 0x00000004
+	ReasonNamedDataOverwrite   Reason = 0x00000010
+	ReasonNamedDataExtend      Reason = 0x00000020
+	ReasonNamedDataTruncation  Reason = 0x00000040
+	ReasonFileCreate           Reason = 0x00000100
+	ReasonFileDelete           Reason = 0x00000200
+	ReasonEAChange             Reason = 0x00000400
+	ReasonSecurityChange       Reason = 0x00000800
+	ReasonRenameOldName        Reason = 0x00001000
+	ReasonRenameNewName        Reason = 0x00002000
+	ReasonIndexableChange      Reason = 0x00004000
+	ReasonBasicInfoChange      Reason = 0x00008000
+	ReasonHardLinkChange       Reason = 0x00010000
+	ReasonCompressionChange    Reason = 0x00020000
+	ReasonEncryptionChange     Reason = 0x00040000
+	ReasonObjectIDChange       Reason = 0x00080000
+	ReasonReparsePointChange   Reason = 0x00100000
+	ReasonStreamChange         Reason = 0x00200000
+	ReasonTransactedChange     Reason = 0x00400000
+	ReasonIntegrityChange      Reason = 0x00800000
+	ReasonDesiredStorageChange Reason = 0x01000000
+	ReasonClose                Reason = 0x80000000
+
+	// ReasonAll matches records for any change.
+	ReasonAll Reason = 0xffffffff
+)
+
+// FileID is a 128-bit file ID. Records with 64-bit file reference numbers (from NTFS volumes)
+// are stored in the low eight bytes.
+type FileID [16]byte
+
+// JournalData describes the state of a volume's change journal.
+type JournalData struct {
+	ID              uint64
+	FirstUSN        USN
+	NextUSN         USN
+	LowestValidUSN  USN
+	MaxUSN          USN
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// USN_JOURNAL_DATA_V0
+type usnJournalData struct {
+	UsnJournalID    uint64
+	FirstUsn        int64
+	NextUsn         int64
+	LowestValidUsn  int64
+	MaxUsn          int64
+	MaximumSize     uint64
+	AllocationDelta uint64
+}
+
+// READ_USN_JOURNAL_DATA_V1
+type readUsnJournalData struct {
+	StartUsn          int64
+	ReasonMask        uint32
+	ReturnOnlyOnClose uint32
+	Timeout           uint64
+	BytesToWaitFor    uint64
+	UsnJournalID      uint64
+	MinMajorVersion   uint16
+	MaxMajorVersion   uint16
+}
+
+// Record is a single change journal record, describing changes made to a file.
+type Record struct {
+	USN          USN
+	FileID       FileID
+	ParentFileID FileID
+	Timestamp    time.Time
+	// Reason is the accumulation of changes made to the file since it was opened.
+	Reason     Reason
+	SourceInfo uint32
+	SecurityID uint32
+	// FileAttributes are the FILE_ATTRIBUTE_* flags of the file.
+	FileAttributes uint32
+	// FileName is the name of the file, relative to its parent directory.
+	FileName string
+}
+
+// Cursor is a position in a change journal. It can be persisted and used to resume reading
+// the journal later, including after a reboot.
+type Cursor struct {
+	JournalID uint64
+	USN       USN
+}
+
+// Journal is an open handle to a volume's change journal.
+type Journal struct {
+	h      windows.Handle
+	volume string
+	buf    []byte
+}
+
+// Open opens the change journal of the volume, specified by a drive letter (eg, "C:"),
+// or a volume device path (eg, `\\.\C:` or `\\?\Volume{...}`). Opening a volume
+// typically requires administrator privileges.
+func Open(volume string) (*Journal, error) {
+	p := volume
+	if !strings.HasPrefix(p, `\\`) {
+		p = `\\.\` + p
+	}
+	p = strings.TrimSuffix(p, `\`)
+	p16, err := windows.UTF16PtrFromString(p)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: volume, Err: err}
+	}
+	h, err := windows.CreateFile(p16,
+		windows.GENERIC_READ,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+		nil,
+		windows.OPEN_EXISTING,
+		0,
+		0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: volume, Err: err}
+	}
+	return &Journal{h: h, volume: volume}, nil
+}
+
+// Close closes the journal handle.
+func (j *Journal) Close() error {
+	return windows.CloseHandle(j.h)
+}
+
+// Query returns the current state of the journal.
+func (j *Journal) Query() (*JournalData, error) {
+	var d usnJournalData
+	var n uint32
+	if err := windows.DeviceIoControl(j.h,
+		FSCTL_QUERY_USN_JOURNAL,
+		nil,
+		0,
+		(*byte)(unsafe.Pointer(&d)),
+		uint32(unsafe.Sizeof(d)),
+		&n,
+		nil,
+	); err != nil {
+		return nil, &os.PathError{Op: "FSCTL_QUERY_USN_JOURNAL", Path: j.volume, Err: err}
+	}
+	return &JournalData{
+		ID:              d.UsnJournalID,
+		FirstUSN:        USN(d.FirstUsn),
+		NextUSN:         USN(d.NextUsn),
+		LowestValidUSN:  USN(d.LowestValidUsn),
+		MaxUSN:          USN(d.MaxUsn),
+		MaximumSize:     d.MaximumSize,
+		AllocationDelta: d.AllocationDelta,
+	}, nil
+}
+
+// Cursor returns a cursor positioned at the end of the journal, so that reading from it returns
+// only changes made after Cursor was called.
+func (j *Journal) Cursor() (Cursor, error) {
+	d, err := j.Query()
+	if err != nil {
+		return Cursor{}, err
+	}
+	return Cursor{JournalID: d.ID, USN: d.NextUSN}, nil
+}
+
+// Read returns the records after c whose reason matches mask, and the cursor to continue
+// reading from. It does not wait for new records: when the end of the journal is reached,
+// it returns no records and the cursor is unchanged.
+//
+// It returns an error wrapping [ErrCursorExpired] if c is no longer valid.
+func (j *Journal) Read(c Cursor, mask Reason) ([]Record, Cursor, error) {
+	if j.buf == nil {
+		j.buf = make([]byte, readBufferSize)
+	}
+	in := readUsnJournalData{
+		StartUsn:        int64(c.USN),
+		ReasonMask:      uint32(mask),
+		UsnJournalID:    c.JournalID,
+		MinMajorVersion: 2,
+		MaxMajorVersion: 3,
+	}
+	var n uint32
+	err := windows.DeviceIoControl(j.h,
+		FSCTL_READ_USN_JOURNAL,
+		(*byte)(unsafe.Pointer(&in)),
+		uint32(unsafe.Sizeof(in)),
+		&j.buf[0],
+		uint32(len(j.buf)),
+		&n,
+		nil,
+	)
+	if err != nil {
+		if j.expired(c, err) {
+			return nil, c, fmt.Errorf("%s: %w: %v", j.volume, ErrCursorExpired, err) //nolint:errorlint // only one error can be wrapped
+		}
+		return nil, c, &os.PathError{Op: "FSCTL_READ_USN_JOURNAL", Path: j.volume, Err: err}
+	}
+
+	next, records, err := parseRecords(j.buf[:n])
+	if err != nil {
+		return nil, c, fmt.Errorf("%s: %w", j.volume, err)
+	}
+	return records, Cursor{JournalID: c.JournalID, USN: next}, nil
+}
+
+// expired returns true if err from reading the journal at c means that c is no longer valid.
+func (j *Journal) expired(c Cursor, err error) bool {
+	switch err { //nolint:errorlint // err is Errno
+	case windows.ERROR_JOURNAL_ENTRY_DELETED, windows.ERROR_JOURNAL_DELETE_IN_PROGRESS, windows.ERROR_JOURNAL_NOT_ACTIVE:
+		return true
+	case windows.ERROR_INVALID_PARAMETER:
+		// the journal ID does not match the current journal
+		d, qerr := j.Query()
+		return qerr == nil && (d.ID != c.JournalID || c.USN < d.LowestValidUSN)
+	}
+	return false
+}
+
+// parseRecords parses the output of FSCTL_READ_USN_JOURNAL: the next USN to read from,
+// followed by USN_RECORD_V2 or USN_RECORD_V3 structures.
+func parseRecords(b []byte) (USN, []Record, error) {
+	if len(b) < 8 {
+		return 0, nil, fmt.Errorf("USN journal output is too short (%d bytes)", len(b))
+	}
+	next := USN(binary.LittleEndian.Uint64(b))
+	var records []Record
+	for b = b[8:]; len(b) > 0; {
+		if len(b) < 8 {
+			return 0, nil, fmt.Errorf("truncated USN record header (%d bytes)", len(b))
+		}
+		l := binary.LittleEndian.Uint32(b)
+		if l < 8 || int(l) > len(b) {
+			return 0, nil, fmt.Errorf("invalid USN record length %d", l)
+		}
+		r, err := parseRecord(b[:l])
+		if err != nil {
+			return 0, nil, err
+		}
+		records = append(records, r)
+		b = b[l:]
+	}
+	return next, records, nil
+}
+
+// parseRecord parses a USN_RECORD_V2 or USN_RECORD_V3 structure.
+func parseRecord(b []byte) (Record, error) {
+	var r Record
+	major := binary.LittleEndian.Uint16(b[4:])
+	off := 8
+	switch major {
+	case 2:
+		// 64-bit file reference numbers
+		if len(b) < 60 {
+			return r, fmt.Errorf("USN_RECORD_V2 is too short (%d bytes)", len(b))
+		}
+		copy(r.FileID[:8], b[off:off+8])
+		copy(r.ParentFileID[:8], b[off+8:off+16])
+		off += 16
+	case 3:
+		// 128-bit file IDs
+		if len(b) < 76 {
+			return r, fmt.Errorf("USN_RECORD_V3 is too short (%d bytes)", len(b))
+		}
+		copy(r.FileID[:], b[off:off+16])
+		copy(r.ParentFileID[:], b[off+16:off+32])
+		off += 32
+	default:
+		return r, fmt.Errorf("unsupported USN record version %d", major)
+	}
+
+	r.USN = USN(binary.LittleEndian.Uint64(b[off:]))
+	ft := windows.Filetime{
+		LowDateTime:  binary.LittleEndian.Uint32(b[off+8:]),
+		HighDateTime: binary.LittleEndian.Uint32(b[off+12:]),
+	}
+	r.Timestamp = time.Unix(0, ft.Nanoseconds())
+	r.Reason = Reason(binary.LittleEndian.Uint32(b[off+16:]))
+	r.SourceInfo = binary.LittleEndian.Uint32(b[off+20:])
+	r.SecurityID = binary.LittleEndian.Uint32(b[off+24:])
+	r.FileAttributes = binary.LittleEndian.Uint32(b[off+28:])
+	nameLen := int(binary.LittleEndian.Uint16(b[off+32:]))
+	nameOff := int(binary.LittleEndian.Uint16(b[off+34:]))
+	if nameOff+nameLen > len(b) || nameLen%2 != 0 {
+		return r, fmt.Errorf("invalid USN record file name (offset %d, length %d)", nameOff, nameLen)
+	}
+	name := make([]uint16, nameLen/2)
+	for i := range name {
+		name[i] = binary.LittleEndian.Uint16(b[nameOff+2*i:])
+	}
+	r.FileName = string(utf16.Decode(name))
+	return r, nil
+}
@@ -0,0 +1,102 @@
+//go:build windows
+// +build windows
+
+package usn
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"unicode/utf16"
+
+	"golang.org/x/sys/windows"
+)
+
+// encodeRecordV2 encodes a USN_RECORD_V2 for the specified file.
+func encodeRecordV2(usn USN, frn uint64, reason Reason, name string) []byte {
+	n := utf16.Encode([]rune(name))
+	l := 60 + 2*len(n)
+	l += (8 - l%8) % 8 // records are 8-byte aligned
+	b := make([]byte, l)
+	binary.LittleEndian.PutUint32(b[0:], uint32(l))
+	binary.LittleEndian.PutUint16(b[4:], 2)
+	binary.LittleEndian.PutUint64(b[8:], frn)
+	binary.LittleEndian.PutUint64(b[24:], uint64(usn))
+	binary.LittleEndian.PutUint32(b[40:], uint32(reason))
+	binary.LittleEndian.PutUint16(b[56:], uint16(2*len(n)))
+	binary.LittleEndian.PutUint16(b[58:], 60)
+	for i, c := range n {
+		binary.LittleEndian.PutUint16(b[60+2*i:], c)
+	}
+	return b
+}
+
+func TestParseRecords(t *testing.T) {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, 300)
+	b = append(b, encodeRecordV2(100, 5, ReasonFileCreate, "a.txt")...)
+	b = append(b, encodeRecordV2(200, 6, ReasonFileDelete|ReasonClose, "bcd")...)
+
+	next, records, err := parseRecords(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 300 || len(records) != 2 {
+		t.Fatalf("unexpected next USN %d and records %+v", next, records)
+	}
+	if r := records[0]; r.USN != 100 || r.FileID[0] != 5 || r.Reason != ReasonFileCreate || r.FileName != "a.txt" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+	if r := records[1]; r.USN != 200 || r.FileID[0] != 6 || r.Reason != ReasonFileDelete|ReasonClose || r.FileName != "bcd" {
+		t.Fatalf("unexpected record %+v", r)
+	}
+
+	if _, _, err := parseRecords(b[:len(b)-4]); err == nil {
+		t.Fatal("expected truncated records to fail")
+	}
+}
+
+func TestJournalRead(t *testing.T) {
+	d := t.TempDir()
+	vol := filepath.VolumeName(d)
+	j, err := Open(vol)
+	if errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+		t.Skipf("opening volume %s requires administrator privileges", vol)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer j.Close()
+
+	c, err := j.Cursor()
+	if errors.Is(err, windows.ERROR_JOURNAL_NOT_ACTIVE) {
+		t.Skipf("volume %s does not have a change journal", vol)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(d, "usn-test.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for found := false; !found; {
+		records, next, err := j.Read(c, ReasonFileCreate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) == 0 {
+			t.Fatal("reached end of journal without finding file creation")
+		}
+		for _, r := range records {
+			found = found || r.FileName == "usn-test.txt"
+		}
+		c = next
+	}
+
+	if _, _, err := j.Read(Cursor{JournalID: c.JournalID + 1, USN: c.USN}, ReasonAll); !errors.Is(err, ErrCursorExpired) {
+		t.Fatalf("expected %v, got %v", ErrCursorExpired, err)
+	}
+}
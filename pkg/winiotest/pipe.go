@@ -0,0 +1,255 @@
+// Package winiotest provides in-memory fakes of the named pipe functionality in go-winio,
+// for testing pipe servers and clients on any platform without creating kernel objects.
+//
+// See the hvsocktest package for the equivalent for Hyper-V sockets.
+package winiotest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pipePrefix is the prefix of local named pipe paths.
+const pipePrefix = `\\.\pipe\`
+
+// defaultDialTimeout is the timeout used by DialPipe if none is specified, and matches winio.DialPipe.
+const defaultDialTimeout = 2 * time.Second
+
+// ErrInvalidPipeName is returned when listening on or dialing a path that is not a local named pipe.
+var ErrInvalidPipeName = errors.New("invalid pipe name")
+
+// pipes is the namespace of listening pipes, keyed by their normalized path.
+var pipes = struct {
+	mu        sync.Mutex
+	listeners map[string]*pipeListener
+}{
+	listeners: make(map[string]*pipeListener),
+}
+
+type pipeAddress string
+
+func (pipeAddress) Network() string {
+	return "pipe"
+}
+
+func (s pipeAddress) String() string {
+	return string(s)
+}
+
+// normalizePipePath validates path and returns the key identifying it in the pipe namespace.
+// As on Windows, pipe names are case-insensitive.
+func normalizePipePath(path string) (string, error) {
+	key := strings.ToLower(path)
+	if !strings.HasPrefix(key, pipePrefix) || len(key) == len(pipePrefix) {
+		return "", ErrInvalidPipeName
+	}
+	return key, nil
+}
+
+// ListenPipe creates a listener on the in-memory named pipe path, which must be of the form
+// \\.\pipe\name. Only connections dialed with [DialPipe] or [DialPipeContext] are accepted.
+//
+// Like winio.ListenPipe, it fails if another listener exists for path, and allows
+// one client to connect before Accept is first called.
+func ListenPipe(path string) (net.Listener, error) {
+	key, err := normalizePipePath(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	pipes.mu.Lock()
+	defer pipes.mu.Unlock()
+
+	if _, ok := pipes.listeners[key]; ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+	}
+	l := &pipeListener{
+		key:    key,
+		path:   path,
+		ch:     make(chan *pipeConn, 1),
+		closed: make(chan struct{}),
+	}
+	pipes.listeners[key] = l
+	return l, nil
+}
+
+// DialPipe connects to an in-memory named pipe by path, timing out if the connection
+// takes longer than the specified duration. If timeout is nil, then a default timeout
+// of 2 seconds is used.
+//
+// On timeout, it returns os.ErrDeadlineExceeded, which winio.ErrTimeout (returned by
+// winio.DialPipe) also matches, so errors.Is(err, os.ErrDeadlineExceeded) detects the
+// timeout with either implementation.
+func DialPipe(path string, timeout *time.Duration) (net.Conn, error) {
+	d := defaultDialTimeout
+	if timeout != nil {
+		d = *timeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	conn, err := DialPipeContext(ctx, path)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, os.ErrDeadlineExceeded
+	}
+	return conn, err
+}
+
+// DialPipeContext attempts to connect to an in-memory named pipe by path until ctx
+// cancellation or timeout.
+//
+// It returns an error wrapping [os.ErrNotExist] if nothing is listening on path, and waits
+// while the pipe is busy (ie, the server is not accepting connections).
+func DialPipeContext(ctx context.Context, path string) (net.Conn, error) {
+	key, err := normalizePipePath(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+
+	pipes.mu.Lock()
+	l := pipes.listeners[key]
+	pipes.mu.Unlock()
+	if l == nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	client, server := newPipeConnPair(pipeAddress(path))
+	select {
+	case l.ch <- server:
+		return client, nil
+	case <-l.closed:
+		err = os.ErrNotExist
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	client.Close()
+	server.Close()
+	if errors.Is(err, os.ErrNotExist) {
+		err = &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return nil, err
+}
+
+type pipeListener struct {
+	key  string
+	path string
+	ch   chan *pipeConn
+
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+	}
+
+	select {
+	case c := <-l.ch:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops listening and removes the pipe from the namespace.
+// A connection that has not been accepted is closed.
+func (l *pipeListener) Close() error {
+	l.once.Do(func() {
+		pipes.mu.Lock()
+		if pipes.listeners[l.key] == l {
+			delete(pipes.listeners, l.key)
+		}
+		pipes.mu.Unlock()
+
+		close(l.closed)
+		select {
+		case c := <-l.ch:
+			c.Close()
+		default:
+		}
+	})
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return pipeAddress(l.path)
+}
+
+// pipeConn is one end of an in-memory pipe connection.
+//
+// Data is sent over a separate [net.Pipe] for each direction, so that CloseWrite can signal
+// the end of the stream to the peer, as it does for message-mode pipes.
+type pipeConn struct {
+	addr pipeAddress
+
+	r net.Conn // read end of the pipe carrying data from the peer
+	w net.Conn // write end of the pipe carrying data to the peer
+}
+
+func newPipeConnPair(addr pipeAddress) (*pipeConn, *pipeConn) {
+	toServerR, toServerW := net.Pipe()
+	toClientR, toClientW := net.Pipe()
+	client := &pipeConn{addr: addr, r: toClientR, w: toServerW}
+	server := &pipeConn{addr: addr, r: toServerR, w: toClientW}
+	return client, server
+}
+
+// translateErr converts net.Pipe errors into the errors returned by winio pipes.
+func translateErr(err error) error {
+	if errors.Is(err, io.ErrClosedPipe) {
+		return net.ErrClosed
+	}
+	return err
+}
+
+func (c *pipeConn) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	return n, translateErr(err)
+}
+
+func (c *pipeConn) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	return n, translateErr(err)
+}
+
+func (c *pipeConn) Close() error {
+	// closing a net.Pipe end always succeeds
+	c.r.Close()
+	return c.w.Close()
+}
+
+// CloseWrite closes the write side of the pipe, so that the peer reads io.EOF.
+func (c *pipeConn) CloseWrite() error {
+	return translateErr(c.w.Close())
+}
+
+func (c *pipeConn) LocalAddr() net.Addr {
+	return c.addr
+}
+
+func (c *pipeConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	if err := c.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(t)
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return translateErr(c.r.SetReadDeadline(t))
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return translateErr(c.w.SetWriteDeadline(t))
+}
@@ -0,0 +1,134 @@
+package winiotest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+const testPipeName = `\\.\pipe\winiotesttestpipe`
+
+func TestPipeDialAccept(t *testing.T) {
+	l, err := ListenPipe(testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if _, err := ListenPipe(`\\.\PIPE\WinioTestTestPipe`); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected %v, got %v", os.ErrExist, err)
+	}
+
+	ch := make(chan error, 1)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			ch <- err
+			return
+		}
+		defer s.Close()
+		b, err := io.ReadAll(s)
+		if err != nil {
+			ch <- err
+			return
+		}
+		_, err = s.Write(b)
+		ch <- err
+	}()
+
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.RemoteAddr().String() != testPipeName || c.RemoteAddr().Network() != "pipe" {
+		t.Fatalf("unexpected remote address %v", c.RemoteAddr())
+	}
+
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.(interface{ CloseWrite() error }).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "hello" {
+		t.Fatalf("got %q, want %q", b, "hello")
+	}
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPipeDialNotExist(t *testing.T) {
+	if _, err := DialPipe(testPipeName, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %v, got %v", os.ErrNotExist, err)
+	}
+	if _, err := ListenPipe(`\\.\notapipe\foo`); !errors.Is(err, ErrInvalidPipeName) {
+		t.Fatalf("expected %v, got %v", ErrInvalidPipeName, err)
+	}
+}
+
+func TestPipeDialBusy(t *testing.T) {
+	l, err := ListenPipe(testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// the first client connects without the server accepting
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	d := 20 * time.Millisecond
+	if _, err := DialPipe(testPipeName, &d); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected %v, got %v", os.ErrDeadlineExceeded, err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		if s, err := l.Accept(); err == nil {
+			s.Close()
+		}
+	}()
+	c2, err := DialPipeContext(context.Background(), testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2.Close()
+}
+
+func TestPipeListenerClose(t *testing.T) {
+	l, err := ListenPipe(testPipeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	l.Close()
+	if _, err := l.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected %v, got %v", net.ErrClosed, err)
+	}
+	// the pending connection was closed with the listener
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected %v, got %v", io.EOF, err)
+	}
+	if _, err := DialPipe(testPipeName, nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %v, got %v", os.ErrNotExist, err)
+	}
+}
@@ -4,17 +4,28 @@
 package winio
 
 import (
+	"bytes"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"os"
+	"runtime"
+	"sort"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio/internal/fs"
 )
 
 //sys lookupAccountName(systemName *uint16, accountName string, sid *byte, sidSize *uint32, refDomain *uint16, refDomainSize *uint32, sidNameUse *uint32) (err error) = advapi32.LookupAccountNameW
 //sys lookupAccountSid(systemName *uint16, sid *byte, name *uint16, nameSize *uint32, refDomain *uint16, refDomainSize *uint32, sidNameUse *uint32) (err error) = advapi32.LookupAccountSidW
 //sys convertSidToStringSid(sid *byte, str **uint16) (err error) = advapi32.ConvertSidToStringSidW
 //sys convertStringSidToSid(str *uint16, sid **byte) (err error) = advapi32.ConvertStringSidToSidW
+//sys getEffectiveRightsFromAcl(acl *windows.ACL, trustee *windows.TRUSTEE, rights *uint32) (win32err error) = advapi32.GetEffectiveRightsFromAclW
+
+// AccessMask is a set of access rights, such as windows.GENERIC_READ or windows.FILE_WRITE_DATA.
+type AccessMask = windows.ACCESS_MASK
 
 type AccountLookupError struct {
 	Name string
@@ -125,9 +136,317 @@ func SddlToSecurityDescriptor(sddl string) ([]byte, error) {
 }
 
 func SecurityDescriptorToSddl(sd []byte) (string, error) {
+	s, err := securityDescriptorFromBytes(sd)
+	if err != nil {
+		return "", err
+	}
+	sddl := s.String()
+	runtime.KeepAlive(sd)
+	return sddl, nil
+}
+
+// The security descriptor conversion functions below convert between the three
+// representations of security descriptors used by this package and x/sys/windows: SDDL
+// strings, self-relative security descriptors stored in a []byte (as returned by
+// [SddlToSecurityDescriptor] and stored in backup streams), and *windows.SECURITY_DESCRIPTOR,
+// which may be absolute or self-relative. None of the conversions lose information.
+
+// ValidateSecurityDescriptor returns an error if sd is not a valid self-relative security
+// descriptor.
+func ValidateSecurityDescriptor(sd []byte) error {
+	_, err := securityDescriptorFromBytes(sd)
+	runtime.KeepAlive(sd)
+	return err
+}
+
+// SecurityDescriptorFromBytes returns a copy of the self-relative security descriptor sd as a
+// *windows.SECURITY_DESCRIPTOR.
+func SecurityDescriptorFromBytes(sd []byte) (*windows.SECURITY_DESCRIPTOR, error) {
+	s, err := securityDescriptorFromBytes(sd)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, s.Length())
+	copy(b, sd)
+	return (*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&b[0])), nil
+}
+
+// SecurityDescriptorToBytes returns the security descriptor sd, which may be absolute or
+// self-relative, as a self-relative security descriptor in a []byte.
+func SecurityDescriptorToBytes(sd *windows.SECURITY_DESCRIPTOR) ([]byte, error) {
+	rel, err := MakeSelfRelative(sd)
+	if err != nil {
+		return nil, err
+	}
+	b := make([]byte, rel.Length())
+	copy(b, unsafe.Slice((*byte)(unsafe.Pointer(rel)), len(b)))
+	return b, nil
+}
+
+// MakeSelfRelative returns sd as a self-relative security descriptor, which is stored in a
+// single buffer and can be copied, such as to pass to APIs that only accept self-relative
+// security descriptors. If sd is already self-relative, it is returned unchanged.
+func MakeSelfRelative(sd *windows.SECURITY_DESCRIPTOR) (*windows.SECURITY_DESCRIPTOR, error) {
+	if sd == nil || !sd.IsValid() {
+		return nil, windows.ERROR_INVALID_SECURITY_DESCR
+	}
+	control, _, err := sd.Control()
+	if err != nil {
+		return nil, fmt.Errorf("get security descriptor control: %w", err)
+	}
+	if control&windows.SE_SELF_RELATIVE != 0 {
+		return sd, nil
+	}
+	rel, err := sd.ToSelfRelative()
+	if err != nil {
+		return nil, fmt.Errorf("convert to self-relative security descriptor: %w", err)
+	}
+	return rel, nil
+}
+
+// securityDescriptorFromBytes returns sd as a *windows.SECURITY_DESCRIPTOR, without copying it,
+// after checking that it is a valid self-relative security descriptor.
+func securityDescriptorFromBytes(sd []byte) (*windows.SECURITY_DESCRIPTOR, error) {
 	if l := int(unsafe.Sizeof(windows.SECURITY_DESCRIPTOR{})); len(sd) < l {
-		return "", fmt.Errorf("SecurityDescriptor (%d) smaller than expected (%d): %w", len(sd), l, windows.ERROR_INCORRECT_SIZE)
+		return nil, fmt.Errorf("SecurityDescriptor (%d) smaller than expected (%d): %w", len(sd), l, windows.ERROR_INCORRECT_SIZE)
 	}
 	s := (*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&sd[0]))
-	return s.String(), nil
+	if !s.IsValid() {
+		return nil, windows.ERROR_INVALID_SECURITY_DESCR
+	}
+	if control, _, err := s.Control(); err != nil || control&windows.SE_SELF_RELATIVE == 0 {
+		// an absolute security descriptor holds pointers, which cannot be stored in a []byte
+		return nil, windows.ERROR_INVALID_SECURITY_DESCR
+	}
+	if l := int(s.Length()); len(sd) < l {
+		return nil, fmt.Errorf("SecurityDescriptor (%d) smaller than its length (%d): %w", len(sd), l, windows.ERROR_INCORRECT_SIZE)
+	}
+	return s, nil
+}
+
+// EffectiveAccess returns the access rights that the self-relative security descriptor sd grants
+// to the account or group with the SID string sid (such as "S-1-5-32-545"), including the rights
+// granted to the groups it is a member of. For example, a service can check whether a client
+// account would be able to open a pipe before creating it with that security descriptor.
+//
+// Generic rights in the DACL are mapped to the specific rights for files and pipes. If sd has no
+// DACL, all access is granted.
+//
+// The rights are computed from the DACL only: privileges, ownership, and integrity levels are
+// not taken into account.
+//
+//revive:disable-next-line:var-naming SID, not Sid
+func EffectiveAccess(sd []byte, sid string) (AccessMask, error) {
+	s, err := securityDescriptorFromBytes(sd)
+	if err != nil {
+		return 0, err
+	}
+	psid, err := windows.StringToSid(sid)
+	if err != nil {
+		return 0, &AccountLookupError{sid, err}
+	}
+
+	dacl, _, err := s.DACL()
+	if errors.Is(err, windows.ERROR_OBJECT_NOT_FOUND) || (err == nil && dacl == nil) {
+		// no DACL (or a NULL DACL) grants full access
+		return fileAllAccess, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("get DACL: %w", err)
+	}
+
+	trustee := windows.TRUSTEE{
+		TrusteeForm:  windows.TRUSTEE_IS_SID,
+		TrusteeType:  windows.TRUSTEE_IS_UNKNOWN,
+		TrusteeValue: windows.TrusteeValueFromSID(psid),
+	}
+	// Generic rights must be mapped in each ACE, before allowed and denied rights are combined:
+	// otherwise a deny ACE for specific rights would not remove them from a generic allow ACE.
+	mapped := mapGenericACL(dacl)
+	runtime.KeepAlive(sd)
+	var rights uint32
+	err = getEffectiveRightsFromAcl((*windows.ACL)(unsafe.Pointer(&mapped[0])), &trustee, &rights)
+	runtime.KeepAlive(mapped)
+	if err != nil {
+		return 0, os.NewSyscallError("GetEffectiveRightsFromAcl", err)
+	}
+	return AccessMask(rights), nil
+}
+
+// fileAllAccess is FILE_ALL_ACCESS.
+const fileAllAccess = windows.STANDARD_RIGHTS_REQUIRED | windows.SYNCHRONIZE | 0x1ff
+
+// ACE types.
+const (
+	aceTypeAccessAllowed        = 0x0  // ACCESS_ALLOWED_ACE_TYPE
+	aceTypeAccessDenied         = 0x1  // ACCESS_DENIED_ACE_TYPE
+	aceTypeAccessAllowedObject  = 0x5  // ACCESS_ALLOWED_OBJECT_ACE_TYPE
+	aceTypeAccessDeniedObject   = 0x6  // ACCESS_DENIED_OBJECT_ACE_TYPE
+	aceTypeSystemMandatoryLabel = 0x11 // SYSTEM_MANDATORY_LABEL_ACE_TYPE
+)
+
+// aclHeader is the layout of an ACL structure.
+type aclHeader struct {
+	AclRevision byte
+	Sbz1        byte
+	AclSize     uint16
+	AceCount    uint16
+	Sbz2        uint16
+}
+
+// CanonicalizeSddl returns a canonical form of a security descriptor in SDDL format, such
+// that two security descriptors that grant the same access have the same canonical form.
+//
+// The canonical form uses the SDDL aliases produced by Windows for SIDs and access rights, maps
+// generic access rights to the specific rights for files, and sorts each run of consecutive
+// explicit deny ACEs, explicit allow ACEs, or other explicit ACEs by their binary
+// representation. ACEs are not moved between runs, as the order in which ACEs are evaluated
+// can change the access granted, so security descriptors whose ACLs are not in the same
+// order (such as an ACL that is not in canonical order and its canonical equivalent) are
+// not considered equal. Inherited ACEs keep their original order.
+func CanonicalizeSddl(sddl string) (string, error) {
+	sd, err := SddlToSecurityDescriptor(sddl)
+	if err != nil {
+		return "", err
+	}
+	c, err := canonicalizeSecurityDescriptor(sd)
+	if err != nil {
+		return "", &SddlConversionError{Sddl: sddl, Err: err}
+	}
+	return c.String(), nil
+}
+
+// EqualSecurityDescriptors returns true if the self-relative security descriptors a and b have
+// the same canonical form (see [CanonicalizeSddl]).
+func EqualSecurityDescriptors(a, b []byte) (bool, error) {
+	ca, err := canonicalizeSecurityDescriptor(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := canonicalizeSecurityDescriptor(b)
+	if err != nil {
+		return false, err
+	}
+	return ca.String() == cb.String(), nil
+}
+
+func canonicalizeSecurityDescriptor(sd []byte) (*windows.SECURITY_DESCRIPTOR, error) {
+	s, err := securityDescriptorFromBytes(sd)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := s.ToAbsolute()
+	if err != nil {
+		return nil, fmt.Errorf("convert to absolute security descriptor: %w", err)
+	}
+
+	var acls [][]byte // keep the new ACLs alive until the descriptor is made self-relative
+	if dacl, defaulted, err := abs.DACL(); err == nil && dacl != nil {
+		b := canonicalizeACL(dacl)
+		acls = append(acls, b)
+		if err := abs.SetDACL((*windows.ACL)(unsafe.Pointer(&b[0])), true, defaulted); err != nil {
+			return nil, fmt.Errorf("set DACL: %w", err)
+		}
+	}
+	if sacl, defaulted, err := abs.SACL(); err == nil && sacl != nil {
+		b := canonicalizeACL(sacl)
+		acls = append(acls, b)
+		if err := abs.SetSACL((*windows.ACL)(unsafe.Pointer(&b[0])), true, defaulted); err != nil {
+			return nil, fmt.Errorf("set SACL: %w", err)
+		}
+	}
+	rel, err := abs.ToSelfRelative()
+	runtime.KeepAlive(acls)
+	if err != nil {
+		return nil, fmt.Errorf("convert to self-relative security descriptor: %w", err)
+	}
+	return rel, nil
+}
+
+// mapGenericACL returns a copy of acl with the generic rights in each ACE mapped to the
+// specific rights for files.
+func mapGenericACL(acl *windows.ACL) []byte {
+	hdr := *(*aclHeader)(unsafe.Pointer(acl))
+	b := append([]byte(nil), unsafe.Slice((*byte)(unsafe.Pointer(acl)), hdr.AclSize)...)
+	off := int(unsafe.Sizeof(hdr))
+	for i := 0; i < int(hdr.AceCount) && off+4 <= len(b); i++ {
+		size := int(binary.LittleEndian.Uint16(b[off+2:]))
+		if size < 4 || off+size > len(b) {
+			break
+		}
+		mapGenericACERights(b[off : off+size])
+		off += size
+	}
+	return b
+}
+
+// mapGenericACERights maps the generic rights in the access mask of the ACE a to the specific
+// rights for files, in place.
+func mapGenericACERights(a []byte) {
+	// The standard ACE types up to SYSTEM_MANDATORY_LABEL_ACE_TYPE have an access mask
+	// following the header.
+	if a[0] < aceTypeSystemMandatoryLabel && len(a) >= 8 {
+		m := binary.LittleEndian.Uint32(a[4:])
+		binary.LittleEndian.PutUint32(a[4:], uint32(fs.MapGenericFileRights(AccessMask(m))))
+	}
+}
+
+// canonicalizeACL returns a copy of acl with generic rights mapped and runs of ACEs sorted, as
+// described in [CanonicalizeSddl].
+func canonicalizeACL(acl *windows.ACL) []byte {
+	hdr := *(*aclHeader)(unsafe.Pointer(acl))
+	b := unsafe.Slice((*byte)(unsafe.Pointer(acl)), hdr.AclSize)
+
+	type ace struct {
+		group int
+		b     []byte
+	}
+	aces := make([]ace, 0, hdr.AceCount)
+	off := int(unsafe.Sizeof(hdr))
+	for i := 0; i < int(hdr.AceCount) && off+4 <= len(b); i++ {
+		// ACE_HEADER: AceType, AceFlags, AceSize
+		typ, flags := b[off], b[off+1]
+		size := int(binary.LittleEndian.Uint16(b[off+2:]))
+		if size < 4 || off+size > len(b) {
+			break
+		}
+		a := ace{b: append([]byte(nil), b[off:off+size]...)}
+		off += size
+
+		mapGenericACERights(a.b)
+
+		switch {
+		case flags&windows.INHERITED_ACE != 0:
+			a.group = 3
+		case typ == aceTypeAccessDenied || typ == aceTypeAccessDeniedObject:
+			a.group = 0
+		case typ == aceTypeAccessAllowed || typ == aceTypeAccessAllowedObject:
+			a.group = 1
+		default:
+			a.group = 2
+		}
+		aces = append(aces, a)
+	}
+
+	// ACEs are evaluated in order, so only the ACEs within a run of consecutive explicit ACEs
+	// of the same group can be reordered without changing the access granted.
+	for i := 0; i < len(aces); {
+		j := i + 1
+		for j < len(aces) && aces[j].group == aces[i].group {
+			j++
+		}
+		if aces[i].group != 3 {
+			run := aces[i:j]
+			sort.Slice(run, func(x, y int) bool { return bytes.Compare(run[x].b, run[y].b) < 0 })
+		}
+		i = j
+	}
+
+	out := make([]byte, unsafe.Sizeof(hdr), hdr.AclSize)
+	for _, a := range aces {
+		out = append(out, a.b...)
+	}
+	binary.LittleEndian.PutUint16(out[2:], uint16(len(out)))
+	binary.LittleEndian.PutUint16(out[4:], uint16(len(aces)))
+	out[0] = hdr.AclRevision
+	return out
 }
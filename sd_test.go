@@ -46,3 +46,146 @@ func TestLookupEmptyNameFails(t *testing.T) {
 		t.Fatalf("expected AccountLookupError with ERROR_NONE_MAPPED, got %s", err)
 	}
 }
+
+func TestCanonicalizeSddl(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		// ACE order
+		{"D:(D;;FA;;;AN)(A;;FA;;;SY)(A;;FR;;;BU)", "D:(D;;FA;;;AN)(A;;FR;;;BU)(A;;FA;;;SY)", true},
+		// ACEs are not moved past ACEs of another type, as that changes the access granted
+		{"D:(A;;FA;;;WD)(D;;FA;;;WD)", "D:(D;;FA;;;WD)(A;;FA;;;WD)", false},
+		{"D:(A;;FA;;;SY)(D;;FA;;;AN)(A;;FR;;;BU)", "D:(D;;FA;;;AN)(A;;FR;;;BU)(A;;FA;;;SY)", false},
+		// generic rights
+		{"D:(A;;GA;;;SY)(A;;GR;;;BU)", "D:(A;;FA;;;SY)(A;;FR;;;BU)", true},
+		// SID aliases
+		{"O:S-1-5-18D:(A;;FA;;;S-1-5-32-544)", "O:SYD:(A;;FA;;;BA)", true},
+		// different rights
+		{"D:(A;;FA;;;SY)", "D:(A;;FR;;;SY)", false},
+		// inherited ACEs keep their order
+		{"D:(A;ID;FA;;;SY)(D;ID;FA;;;AN)", "D:(D;ID;FA;;;AN)(A;ID;FA;;;SY)", false},
+	}
+	for _, tt := range tests {
+		ca, err := CanonicalizeSddl(tt.a)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.a, err)
+		}
+		cb, err := CanonicalizeSddl(tt.b)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.b, err)
+		}
+		if (ca == cb) != tt.same {
+			t.Errorf("%s => %s, %s => %s: want same %t", tt.a, ca, tt.b, cb, tt.same)
+		}
+
+		sda, err := SddlToSecurityDescriptor(tt.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sdb, err := SddlToSecurityDescriptor(tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		eq, err := EqualSecurityDescriptors(sda, sdb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eq != tt.same {
+			t.Errorf("EqualSecurityDescriptors(%s, %s) = %t; want %t", tt.a, tt.b, eq, tt.same)
+		}
+	}
+}
+
+func TestCanonicalizeSddlInvalid(t *testing.T) {
+	var serr *SddlConversionError
+	if _, err := CanonicalizeSddl("not an sddl"); !errors.As(err, &serr) {
+		t.Fatalf("expected SddlConversionError, got %v", err)
+	}
+}
+
+func TestEffectiveAccess(t *testing.T) {
+	const (
+		system = "S-1-5-18"
+		users  = "S-1-5-32-545"
+	)
+	for _, tc := range []struct {
+		sddl string
+		sid  string
+		want AccessMask
+	}{
+		{"D:P(A;;GR;;;WD)(A;;GA;;;SY)", system, fileAllAccess},
+		{"D:P(A;;GR;;;WD)(A;;GA;;;SY)", users, windows.FILE_GENERIC_READ},
+		{"D:P(D;;FA;;;SY)(A;;FA;;;WD)", system, 0},
+		{"D:P(D;;FW;;;BU)(A;;GA;;;BU)", users, fileAllAccess &^ windows.FILE_GENERIC_WRITE},
+		{"D:P", users, 0},
+		{"D:NO_ACCESS_CONTROL", users, fileAllAccess},
+	} {
+		sd, err := SddlToSecurityDescriptor(tc.sddl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := EffectiveAccess(sd, tc.sid)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.sddl, err)
+		}
+		if got != tc.want {
+			t.Errorf("%s for %s: got %#x, want %#x", tc.sddl, tc.sid, got, tc.want)
+		}
+	}
+}
+
+func TestEffectiveAccessInvalidSid(t *testing.T) {
+	sd, err := SddlToSecurityDescriptor("D:P(A;;GA;;;WD)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var aerr *AccountLookupError
+	if _, err := EffectiveAccess(sd, "not a sid"); !errors.As(err, &aerr) {
+		t.Fatalf("expected AccountLookupError, got %v", err)
+	}
+}
+
+func TestSecurityDescriptorConversions(t *testing.T) {
+	const sddl = "O:BAG:SYD:(A;;GA;;;SY)(A;;GR;;;BU)S:(ML;;NW;;;LW)"
+	b, err := SddlToSecurityDescriptor(sddl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSecurityDescriptor(b); err != nil {
+		t.Fatal(err)
+	}
+
+	sd, err := SecurityDescriptorFromBytes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, err := sd.ToAbsolute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := SecurityDescriptorToBytes(abs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateSecurityDescriptor(rel); err != nil {
+		t.Fatal(err)
+	}
+	s, err := SecurityDescriptorToSddl(rel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := sd.String(); s != want {
+		t.Fatalf("round trip through absolute security descriptor: got %q, want %q", s, want)
+	}
+
+	if _, err := MakeSelfRelative(nil); !errors.Is(err, windows.ERROR_INVALID_SECURITY_DESCR) {
+		t.Fatalf("expected ERROR_INVALID_SECURITY_DESCR, got %v", err)
+	}
+	if err := ValidateSecurityDescriptor(make([]byte, 64)); err == nil {
+		t.Fatal("zeroed security descriptor is valid")
+	}
+	if err := ValidateSecurityDescriptor(b[:len(b)-1]); !errors.Is(err, windows.ERROR_INCORRECT_SIZE) {
+		t.Fatalf("expected ERROR_INCORRECT_SIZE for a truncated security descriptor, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxAllocatedRanges is the number of ranges returned by each FSCTL_QUERY_ALLOCATED_RANGES call
+// in AllocatedRanges.
+const maxAllocatedRanges = 64
+
+// AllocatedRange is a range of a sparse file that is backed by disk space, as returned by
+// [AllocatedRanges]. It has the layout of FILE_ALLOCATED_RANGE_BUFFER.
+type AllocatedRange struct {
+	Offset int64
+	Length int64
+}
+
+// fileZeroDataInformation is FILE_ZERO_DATA_INFORMATION.
+type fileZeroDataInformation struct {
+	FileOffset      int64
+	BeyondFinalZero int64
+}
+
+// SetSparse marks a file as sparse, so that ranges of zeros written with [PunchHole] are not
+// backed by disk space. The file must have been opened for writing.
+func SetSparse(f *os.File) error {
+	var n uint32
+	if err := windows.DeviceIoControl(
+		windows.Handle(f.Fd()),
+		windows.FSCTL_SET_SPARSE,
+		nil,
+		0,
+		nil,
+		0,
+		&n,
+		nil,
+	); err != nil {
+		return &os.PathError{Op: "FSCTL_SET_SPARSE", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// PunchHole zeroes length bytes of a file starting at off. If the file is sparse (see
+// [SetSparse]), the disk space backing the range is released; otherwise, zeros are written.
+// The file must have been opened for writing.
+func PunchHole(f *os.File, off, length int64) error {
+	zi := fileZeroDataInformation{FileOffset: off, BeyondFinalZero: off + length}
+	var n uint32
+	if err := windows.DeviceIoControl(
+		windows.Handle(f.Fd()),
+		windows.FSCTL_SET_ZERO_DATA,
+		(*byte)(unsafe.Pointer(&zi)),
+		uint32(unsafe.Sizeof(zi)),
+		nil,
+		0,
+		&n,
+		nil,
+	); err != nil {
+		return &os.PathError{Op: "FSCTL_SET_ZERO_DATA", Path: f.Name(), Err: err}
+	}
+	runtime.KeepAlive(f)
+	return nil
+}
+
+// AllocatedRanges returns the ranges of a file that are backed by disk space, in order. The
+// rest of the file reads as zeros. For files that are not sparse, this is the entire file.
+func AllocatedRanges(f *os.File) ([]AllocatedRange, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges []AllocatedRange
+	q := AllocatedRange{Length: fi.Size()}
+	buf := make([]AllocatedRange, maxAllocatedRanges)
+	for q.Length > 0 {
+		var n uint32
+		err := windows.DeviceIoControl(
+			windows.Handle(f.Fd()),
+			windows.FSCTL_QUERY_ALLOCATED_RANGES,
+			(*byte)(unsafe.Pointer(&q)),
+			uint32(unsafe.Sizeof(q)),
+			(*byte)(unsafe.Pointer(&buf[0])),
+			uint32(len(buf))*uint32(unsafe.Sizeof(buf[0])),
+			&n,
+			nil,
+		)
+		if err != nil && err != windows.ERROR_MORE_DATA { //nolint:errorlint // err is Errno
+			return nil, &os.PathError{Op: "FSCTL_QUERY_ALLOCATED_RANGES", Path: f.Name(), Err: err}
+		}
+		got := buf[:n/uint32(unsafe.Sizeof(buf[0]))]
+		ranges = append(ranges, got...)
+		if err == nil || len(got) == 0 {
+			break
+		}
+		// continue the query after the last range returned
+		last := got[len(got)-1]
+		end := q.Offset + q.Length
+		q.Offset = last.Offset + last.Length
+		q.Length = end - q.Offset
+	}
+	runtime.KeepAlive(f)
+	return ranges, nil
+}
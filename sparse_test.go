@@ -0,0 +1,63 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSparseFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "sparse"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := SetSparse(f); err != nil {
+		t.Fatal(err)
+	}
+	const size = 1 << 20
+	if err := f.Truncate(size); err != nil {
+		t.Fatal(err)
+	}
+	ranges, err := AllocatedRanges(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 0 {
+		t.Fatalf("expected no allocated ranges, got %v", ranges)
+	}
+
+	b := make([]byte, size)
+	for i := range b {
+		b[i] = 1
+	}
+	if _, err := f.WriteAt(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	// the hole must be aligned to the allocation unit to be released, so use a large one
+	if err := PunchHole(f, size/4, size/2); err != nil {
+		t.Fatal(err)
+	}
+
+	ranges, err = AllocatedRanges(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 ||
+		ranges[0].Offset != 0 || ranges[0].Offset+ranges[0].Length < size/4 ||
+		ranges[1].Offset > 3*size/4 || ranges[1].Offset+ranges[1].Length != size {
+		t.Fatalf("unexpected allocated ranges %v", ranges)
+	}
+
+	var c [1]byte
+	if _, err := f.ReadAt(c[:], size/2); err != nil {
+		t.Fatal(err)
+	}
+	if c[0] != 0 {
+		t.Fatalf("expected the hole to read as zero, got %d", c[0])
+	}
+}
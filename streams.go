@@ -0,0 +1,67 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+//sys findFirstStream(name string, infoLevel uint32, data *win32FindStreamData, flags uint32) (h windows.Handle, err error) [failretval==windows.InvalidHandle] = FindFirstStreamW
+//sys findNextStream(h windows.Handle, data *win32FindStreamData) (err error) = FindNextStreamW
+
+// findStreamInfoStandard is the FindStreamInfoStandard information level.
+const findStreamInfoStandard = 0
+
+// WIN32_FIND_STREAM_DATA
+type win32FindStreamData struct {
+	StreamSize int64
+	StreamName [windows.MAX_PATH + 36]uint16
+}
+
+// StreamInfo describes an alternate data stream of a file, as returned by
+// [ListAlternateDataStreams].
+type StreamInfo struct {
+	// Name is the name of the stream, without the surrounding colons and stream type: the
+	// stream can be opened as path + ":" + Name.
+	Name string
+	// Size is the size of the stream, in bytes.
+	Size int64
+}
+
+// ListAlternateDataStreams returns the alternate data streams of the file or directory at path,
+// using FindFirstStreamW. The file's unnamed data stream is not included.
+//
+// This is much cheaper than finding the streams by reading the whole file with [BackupFileReader].
+func ListAlternateDataStreams(path string) ([]StreamInfo, error) {
+	var d win32FindStreamData
+	h, err := findFirstStream(path, findStreamInfoStandard, &d, 0)
+	if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+		// there are no streams, which happens for directories without alternate data streams
+		return nil, nil
+	} else if err != nil {
+		return nil, &os.PathError{Op: "FindFirstStreamW", Path: path, Err: err}
+	}
+	defer windows.FindClose(h) //nolint:errcheck
+
+	var streams []StreamInfo
+	for {
+		// the names have the form ":name:$DATA", and the unnamed stream is "::$DATA"
+		name := strings.TrimSuffix(windows.UTF16ToString(d.StreamName[:]), ":$DATA")
+		name = strings.TrimPrefix(name, ":")
+		if name != "" {
+			streams = append(streams, StreamInfo{Name: name, Size: d.StreamSize})
+		}
+
+		if err := findNextStream(h, &d); err != nil {
+			if errors.Is(err, windows.ERROR_HANDLE_EOF) {
+				return streams, nil
+			}
+			return nil, &os.PathError{Op: "FindNextStreamW", Path: path, Err: err}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestListAlternateDataStreams(t *testing.T) {
+	if err := makeTestFile(true); err != nil {
+		t.Fatal(err)
+	}
+	streams, err := ListAlternateDataStreams(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StreamInfo{{Name: "ads.txt", Size: int64(len("alternate data stream\n"))}}
+	if !reflect.DeepEqual(streams, want) {
+		t.Fatalf("got %+v, expected %+v", streams, want)
+	}
+}
+
+func TestListAlternateDataStreamsNone(t *testing.T) {
+	if err := makeTestFile(false); err != nil {
+		t.Fatal(err)
+	}
+	streams, err := ListAlternateDataStreams(testFileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 0 {
+		t.Fatalf("expected no streams, got %+v", streams)
+	}
+
+	streams, err = ListAlternateDataStreams(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streams) != 0 {
+		t.Fatalf("expected no streams for a directory, got %+v", streams)
+	}
+}
@@ -0,0 +1,190 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"os"
+	"runtime"
+
+	"golang.org/x/sys/windows"
+)
+
+//sys createRestrictedToken(existing windows.Token, flags uint32, disableSidCount uint32, sidsToDisable *windows.SIDAndAttributes, deletePrivilegeCount uint32, privilegesToDelete *windows.LUIDAndAttributes, restrictedSidCount uint32, sidsToRestrict *windows.SIDAndAttributes, newToken *windows.Token) (err error) = advapi32.CreateRestrictedToken
+
+// CreateRestrictedToken flags.
+const (
+	disableMaxPrivilege = 0x1 // DISABLE_MAX_PRIVILEGE
+	writeRestricted     = 0x8 // WRITE_RESTRICTED
+)
+
+// DuplicateCurrentToken duplicates the token of the calling thread: the impersonation token,
+// if the thread is impersonating (for example, in a [PipeClientImpersonator.RunAsClient] callback), or the
+// process token otherwise. tokenType is windows.TokenPrimary, for a token that can be used to
+// create processes, or windows.TokenImpersonation, for a token that can be passed to
+// [RunWithToken].
+//
+// The duplicated token has all the access the caller is allowed, and must be closed by the
+// caller.
+func DuplicateCurrentToken(tokenType uint32) (windows.Token, error) {
+	var token windows.Token
+	err := openThreadToken(getCurrentThread(), windows.TOKEN_DUPLICATE|windows.TOKEN_QUERY, true, &token)
+	if errors.Is(err, windows.ERROR_NO_TOKEN) {
+		err = windows.OpenProcessToken(windows.CurrentProcess(), windows.TOKEN_DUPLICATE|windows.TOKEN_QUERY, &token)
+	}
+	if err != nil {
+		return 0, os.NewSyscallError("OpenToken", err)
+	}
+	defer token.Close()
+
+	var dup windows.Token
+	if err := windows.DuplicateTokenEx(token, windows.MAXIMUM_ALLOWED, nil,
+		windows.SecurityImpersonation, tokenType, &dup); err != nil {
+		return 0, os.NewSyscallError("DuplicateTokenEx", err)
+	}
+	return dup, nil
+}
+
+// TokenUserSID returns the SID string of the user of token, such as "S-1-5-18". token must have
+// TOKEN_QUERY access.
+//
+//revive:disable-next-line:var-naming SID, not Sid
+func TokenUserSID(token windows.Token) (string, error) {
+	u, err := token.GetTokenUser()
+	if err != nil {
+		return "", os.NewSyscallError("GetTokenInformation", err)
+	}
+	return u.User.Sid.String(), nil
+}
+
+// TokenGroupSIDs returns the SID strings of the groups in token that are used for access
+// checks: the enabled groups, including deny-only groups, but not the logon session. token
+// must have TOKEN_QUERY access.
+//
+//revive:disable-next-line:var-naming SID, not Sid
+func TokenGroupSIDs(token windows.Token) ([]string, error) {
+	g, err := token.GetTokenGroups()
+	if err != nil {
+		return nil, os.NewSyscallError("GetTokenInformation", err)
+	}
+	var sids []string
+	for _, sa := range g.AllGroups() {
+		if sa.Attributes&(windows.SE_GROUP_ENABLED|windows.SE_GROUP_USE_FOR_DENY_ONLY) == 0 ||
+			sa.Attributes&windows.SE_GROUP_LOGON_ID == windows.SE_GROUP_LOGON_ID {
+			continue
+		}
+		sids = append(sids, sa.Sid.String())
+	}
+	return sids, nil
+}
+
+// RestrictedTokenOptions describes the restrictions applied by [CreateRestrictedToken].
+type RestrictedTokenOptions struct {
+	// DenyOnlySIDs are the SID strings of groups in the token that are changed to deny-only:
+	// access denied to them still applies, but access granted to them does not.
+	DenyOnlySIDs []string
+
+	// RestrictingSIDs are the SID strings of the restricting SIDs. If any are specified, an
+	// access check succeeds only if access is granted to both the token's enabled SIDs and to
+	// the restricting SIDs.
+	RestrictingSIDs []string
+
+	// WriteRestricted applies the restricting SIDs only to write access.
+	WriteRestricted bool
+
+	// DeletePrivileges are the names of the privileges to remove from the token, such as
+	// [SeBackupPrivilege].
+	DeletePrivileges []string
+
+	// DisableMaxPrivilege removes all privileges from the token except SeChangeNotifyPrivilege,
+	// instead of only those in DeletePrivileges.
+	DisableMaxPrivilege bool
+}
+
+// CreateRestrictedToken creates a restricted copy of token, which must have TOKEN_DUPLICATE
+// access and be a primary token or an impersonation token. A server can use it, with
+// [DuplicateCurrentToken], to drop access before running untrusted work on behalf of a client.
+// The new token must be closed by the caller.
+func CreateRestrictedToken(token windows.Token, opts *RestrictedTokenOptions) (windows.Token, error) {
+	if opts == nil {
+		opts = &RestrictedTokenOptions{}
+	}
+	var flags uint32
+	if opts.DisableMaxPrivilege {
+		flags |= disableMaxPrivilege
+	}
+	if opts.WriteRestricted {
+		flags |= writeRestricted
+	}
+
+	deny, err := sidsAndAttributes(opts.DenyOnlySIDs)
+	if err != nil {
+		return 0, err
+	}
+	restrict, err := sidsAndAttributes(opts.RestrictingSIDs)
+	if err != nil {
+		return 0, err
+	}
+	privileges, err := mapPrivileges(opts.DeletePrivileges)
+	if err != nil {
+		return 0, err
+	}
+	privs := make([]windows.LUIDAndAttributes, 0, len(privileges))
+	for _, p := range privileges {
+		privs = append(privs, windows.LUIDAndAttributes{Luid: windows.LUID{LowPart: uint32(p), HighPart: int32(p >> 32)}})
+	}
+
+	var (
+		denyp     *windows.SIDAndAttributes
+		restrictp *windows.SIDAndAttributes
+		privsp    *windows.LUIDAndAttributes
+	)
+	if len(deny) > 0 {
+		denyp = &deny[0]
+	}
+	if len(restrict) > 0 {
+		restrictp = &restrict[0]
+	}
+	if len(privs) > 0 {
+		privsp = &privs[0]
+	}
+	var newToken windows.Token
+	err = createRestrictedToken(token, flags, uint32(len(deny)), denyp, uint32(len(privs)), privsp,
+		uint32(len(restrict)), restrictp, &newToken)
+	if err != nil {
+		return 0, os.NewSyscallError("CreateRestrictedToken", err)
+	}
+	return newToken, nil
+}
+
+// RunWithToken runs fn on a thread that impersonates token, which must be an impersonation
+// token with TOKEN_IMPERSONATE and TOKEN_QUERY access, such as one returned by
+// [DuplicateCurrentToken] or [CreateRestrictedToken]. The thread reverts to the process's
+// security context when fn returns.
+func RunWithToken(token windows.Token, fn func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := windows.SetThreadToken(nil, token); err != nil {
+		return os.NewSyscallError("SetThreadToken", err)
+	}
+	defer func() {
+		if err := revertToSelf(); err != nil {
+			// the thread cannot be returned to the scheduler while it impersonates the token
+			panic(err)
+		}
+	}()
+	return fn()
+}
+
+func sidsAndAttributes(sids []string) ([]windows.SIDAndAttributes, error) {
+	sas := make([]windows.SIDAndAttributes, 0, len(sids))
+	for _, s := range sids {
+		sid, err := windows.StringToSid(s)
+		if err != nil {
+			return nil, &AccountLookupError{s, err}
+		}
+		sas = append(sas, windows.SIDAndAttributes{Sid: sid})
+	}
+	return sas, nil
+}
@@ -0,0 +1,100 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"testing"
+
+	"golang.org/x/sys/windows"
+)
+
+func TestTokenUserAndGroups(t *testing.T) {
+	token, err := DuplicateCurrentToken(windows.TokenPrimary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+
+	want, err := windows.GetCurrentProcessToken().GetTokenUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid, err := TokenUserSID(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sid != want.User.Sid.String() {
+		t.Errorf("got user %s, want %s", sid, want.User.Sid)
+	}
+
+	groups, err := TokenGroupSIDs(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const everyone = "S-1-1-0"
+	found := false
+	for _, g := range groups {
+		found = found || g == everyone
+	}
+	if !found {
+		t.Errorf("%s not found in groups %v", everyone, groups)
+	}
+}
+
+func TestCreateRestrictedToken(t *testing.T) {
+	token, err := DuplicateCurrentToken(windows.TokenImpersonation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+	user, err := TokenUserSID(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rtoken, err := CreateRestrictedToken(token, &RestrictedTokenOptions{
+		RestrictingSIDs:     []string{user},
+		DisableMaxPrivilege: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rtoken.Close()
+
+	err = RunWithToken(rtoken, func() error {
+		// the thread's token is now the restricted token
+		dup, err := DuplicateCurrentToken(windows.TokenImpersonation)
+		if err != nil {
+			return err
+		}
+		defer dup.Close()
+		restricted, err := dup.IsRestricted()
+		if err != nil {
+			return err
+		}
+		if !restricted {
+			t.Error("expected the thread token to be restricted")
+		}
+		if sid, err := TokenUserSID(dup); err != nil {
+			return err
+		} else if sid != user {
+			t.Errorf("got user %s, want %s", sid, user)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCreateRestrictedTokenInvalidSid(t *testing.T) {
+	token, err := DuplicateCurrentToken(windows.TokenImpersonation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer token.Close()
+	if _, err := CreateRestrictedToken(token, &RestrictedTokenOptions{DenyOnlySIDs: []string{"not a sid"}}); err == nil {
+		t.Fatal("expected an error for an invalid SID")
+	}
+}
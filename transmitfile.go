@@ -0,0 +1,123 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/sys/windows"
+)
+
+// maxTransmitFileSize is the largest number of bytes that can be sent by a single TransmitFile call.
+//
+// https://learn.microsoft.com/en-us/windows/win32/api/mswsock/nf-mswsock-transmitfile
+const maxTransmitFileSize = 1<<31 - 2
+
+// transmitBufferSize is the size of the buffers used to copy files to connections that
+// do not support TransmitFile.
+const transmitBufferSize = 64 * 1024
+
+var transmitBufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, transmitBufferSize)
+		return &b
+	},
+}
+
+// TransmitFile sends count bytes of f, starting at offset, over conn, and returns the number
+// of bytes sent. If count is zero or negative, f is sent from offset until the end of the file.
+// Fewer than count bytes are sent if the end of the file is reached first.
+//
+// For Hyper-V socket connections ([*HvsockConn]), the data is sent with the TransmitFile
+// Win32 API, so that it is read from the file and sent over the socket by the kernel, without
+// being copied through user space. For other connections (such as named pipes), the file
+// is copied with a pooled buffer.
+//
+// The file's current offset is ignored.
+func TransmitFile(conn net.Conn, f *os.File, offset, count int64) (int64, error) {
+	if offset < 0 {
+		return 0, &os.PathError{Op: "transmitfile", Path: f.Name(), Err: os.ErrInvalid}
+	}
+	if count <= 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		count = fi.Size() - offset
+		if count <= 0 {
+			return 0, nil
+		}
+	}
+
+	if c, ok := conn.(*HvsockConn); ok {
+		return c.transmitFile(f, offset, count, maxTransmitFileSize)
+	}
+
+	bp := transmitBufferPool.Get().(*[]byte)
+	defer transmitBufferPool.Put(bp)
+	// hide any ReadFrom method on conn, so that the pooled buffer is used
+	return io.CopyBuffer(struct{ io.Writer }{conn}, io.NewSectionReader(f, offset, count), *bp)
+}
+
+// transmitFile sends count bytes of f, starting at offset, over the socket, with TransmitFile
+// calls of up to chunk bytes.
+func (conn *HvsockConn) transmitFile(f *os.File, offset, count, chunk int64) (int64, error) {
+	// TransmitFile does not report the bytes sent if it completes synchronously, so it must
+	// not be asked to send past the end of the file.
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if remaining := fi.Size() - offset; count > remaining {
+		count = remaining
+	}
+
+	var t int64
+	for t < count {
+		n := count - t
+		if n > chunk {
+			n = chunk
+		}
+		m, err := conn.transmitFileChunk(f, offset+t, uint32(n))
+		t += int64(m)
+		if err != nil {
+			return t, err
+		}
+		if m == 0 {
+			// reached the end of the file
+			break
+		}
+	}
+	return t, nil
+}
+
+func (conn *HvsockConn) transmitFileChunk(f *os.File, offset int64, n uint32) (int, error) {
+	c, err := conn.sock.prepareIO()
+	if err != nil {
+		return 0, conn.opErr("transmitfile", err)
+	}
+	defer conn.sock.wg.Done()
+
+	// the file offset is specified via the overlapped structure
+	c.o.Offset = uint32(offset)
+	c.o.OffsetHigh = uint32(offset >> 32)
+	err = windows.TransmitFile(conn.sock.handle, windows.Handle(f.Fd()), n, 0, &c.o, nil, 0)
+	// TransmitFile does not report the bytes sent if it completes synchronously
+	m, err := conn.sock.asyncIO(c, &conn.sock.writeDeadline, n, err)
+	if err != nil {
+		if isConnReset(err) {
+			conn.broken.setTrue()
+		}
+		var eno windows.Errno
+		if errors.As(err, &eno) {
+			err = os.NewSyscallError("transmitfile", eno)
+		}
+		return 0, conn.opErr("transmitfile", err)
+	}
+	return m, nil
+}
@@ -0,0 +1,134 @@
+//go:build windows
+// +build windows
+
+package winio
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func makeTransmitTestFile(t *testing.T) (*os.File, []byte) {
+	t.Helper()
+
+	data := bytes.Repeat([]byte("0123456789abcdef"), 3*transmitBufferSize/16+7)
+	p := filepath.Join(t.TempDir(), "transmit")
+	if err := os.WriteFile(p, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f, data
+}
+
+// testTransmitFile sends the file over w, and checks the data read from r.
+func testTransmitFile(t *testing.T, w net.Conn, r io.Reader, f *os.File, data []byte, offset, count int64) {
+	t.Helper()
+
+	want := data[offset:]
+	if count > 0 && count < int64(len(want)) {
+		want = want[:count]
+	}
+	ch := make(chan error, 1)
+	go func() {
+		n, err := TransmitFile(w, f, offset, count)
+		if err == nil && n != int64(len(want)) {
+			t.Errorf("sent %d bytes, want %d", n, len(want))
+		}
+		ch <- err
+	}()
+
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-ch; err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("received data does not match file contents")
+	}
+}
+
+func TestTransmitFilePipe(t *testing.T) {
+	f, data := makeTransmitTestFile(t)
+
+	l, err := ListenPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ch := make(chan net.Conn, 1)
+	go func() {
+		s, err := l.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		ch <- s
+	}()
+	c, err := DialPipe(testPipeName, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := <-ch
+	if s == nil {
+		t.FailNow()
+	}
+	defer s.Close()
+
+	testTransmitFile(t, s, c, f, data, 0, 0)
+	testTransmitFile(t, s, c, f, data, 100, transmitBufferSize+5)
+}
+
+func TestTransmitFileHvsock(t *testing.T) {
+	f, data := makeTransmitTestFile(t)
+	u := newUtil(t)
+	cl, sv, _ := clientServer(u)
+
+	u.Must(cl.SetWriteDeadline(time.Now().Add(10*time.Second)), "set write deadline")
+	testTransmitFile(t, cl, sv, f, data, 0, 0)
+	testTransmitFile(t, cl, sv, f, data, 1<<10+3, 1<<16)
+	// sending past the end of the file stops at the end
+	testTransmitFile(t, cl, sv, f, data, int64(len(data)-10), 0)
+	// more than the rest of the file
+	testTransmitFile(t, cl, sv, f, data, 7, int64(len(data)))
+}
+
+func TestTransmitFileHvsockChunks(t *testing.T) {
+	f, data := makeTransmitTestFile(t)
+	u := newUtil(t)
+	cl, sv, _ := clientServer(u)
+
+	u.Must(cl.SetWriteDeadline(time.Now().Add(10*time.Second)), "set write deadline")
+	// the file size is not a multiple of the chunk size, so the last chunk must be
+	// shortened to the end of the file
+	const chunk = 1000
+	if len(data)%chunk == 0 {
+		t.Fatalf("file size %d is a multiple of %d", len(data), chunk)
+	}
+	ch := u.Go(func() error {
+		n, err := cl.transmitFile(f, 0, int64(len(data))+chunk, chunk)
+		if err == nil && n != int64(len(data)) {
+			return fmt.Errorf("sent %d bytes, want %d", n, len(data))
+		}
+		return err
+	})
+	got := make([]byte, len(data))
+	_, err := io.ReadFull(sv, got)
+	u.Must(err, "read")
+	u.WaitErr(ch, 10*time.Second)
+	if !bytes.Equal(got, data) {
+		t.Fatal("received data does not match file contents")
+	}
+}
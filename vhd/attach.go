@@ -0,0 +1,103 @@
+//go:build windows
+// +build windows
+
+package vhd
+
+import (
+	"errors"
+	"syscall"
+)
+
+// AttachOptions describes how a virtual disk is attached by [AttachVirtualDiskWithOptions] and
+// [WithAttached], in place of raw [AttachVirtualDiskFlag] values.
+type AttachOptions struct {
+	// ReadOnly attaches the disk read-only, and opens it read-only in [WithAttached].
+	ReadOnly bool
+
+	// NoDriveLetter attaches the disk without assigning drive letters to its volumes.
+	NoDriveLetter bool
+
+	// AutoDetachOnClose detaches the disk when the last handle to it is closed. Otherwise, the
+	// disk remains attached until it is detached, or the system restarts.
+	AutoDetachOnClose bool
+
+	// AtBoot keeps the disk attached across system restarts. It cannot be combined with
+	// AutoDetachOnClose, or used with WithAttached.
+	AtBoot bool
+}
+
+// flags validates o and returns the equivalent attach flags.
+func (o *AttachOptions) flags() (AttachVirtualDiskFlag, error) {
+	if o == nil {
+		return AttachVirtualDiskFlagPermanentLifetime, nil
+	}
+	if o.AtBoot && o.AutoDetachOnClose {
+		return 0, errors.New("attach options AtBoot and AutoDetachOnClose cannot be combined")
+	}
+	var flags AttachVirtualDiskFlag
+	if o.ReadOnly {
+		flags |= AttachVirtualDiskFlagReadOnly
+	}
+	if o.NoDriveLetter {
+		flags |= AttachVirtualDiskFlagNoDriveLetter
+	}
+	if !o.AutoDetachOnClose {
+		flags |= AttachVirtualDiskFlagPermanentLifetime
+	}
+	if o.AtBoot {
+		flags |= AttachVirtualDiskFlagAtBoot
+	}
+	return flags, nil
+}
+
+// AttachVirtualDiskWithOptions attaches a virtual hard disk, opened with [OpenVirtualDisk], as
+// described by opts, using version 2 of the ATTACH_VIRTUAL_DISK_PARAMETERS. A nil opts attaches
+// the disk read-write until it is detached.
+func AttachVirtualDiskWithOptions(handle syscall.Handle, opts *AttachOptions) error {
+	flags, err := opts.flags()
+	if err != nil {
+		return err
+	}
+	params := AttachVirtualDiskParameters{Version: 2}
+	return AttachVirtualDisk(handle, flags, &params)
+}
+
+// WithAttached attaches the virtual hard disk at path as described by opts, calls fn with its
+// physical path (see [GetVirtualDiskPhysicalPath]), and detaches it, even if fn panics. It
+// returns the error from fn, or else any error detaching the disk.
+func WithAttached(path string, opts *AttachOptions, fn func(diskPath string) error) (err error) {
+	if opts == nil {
+		opts = &AttachOptions{}
+	}
+	if opts.AtBoot {
+		return errors.New("attach option AtBoot cannot be used with WithAttached")
+	}
+
+	params := OpenVirtualDiskParameters{Version: 2}
+	params.Version2.ReadOnly = opts.ReadOnly
+	handle, err := OpenVirtualDiskWithParameters(
+		path,
+		VirtualDiskAccessNone,
+		OpenVirtualDiskFlagCachedIO|OpenVirtualDiskFlagIgnoreRelativeParentLocator,
+		&params,
+	)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle) //nolint:errcheck
+
+	if err := AttachVirtualDiskWithOptions(handle, opts); err != nil {
+		return err
+	}
+	defer func() {
+		if derr := DetachVirtualDisk(handle); derr != nil && err == nil {
+			err = derr
+		}
+	}()
+
+	diskPath, err := GetVirtualDiskPhysicalPath(handle)
+	if err != nil {
+		return err
+	}
+	return fn(diskPath)
+}
@@ -0,0 +1,151 @@
+//go:build windows
+// +build windows
+
+package vhd
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+//sys getStorageDependencyInformation(handle syscall.Handle, flags uint32, infoSize uint32, info *byte, sizeUsed *uint32) (win32err error) = virtdisk.GetStorageDependencyInformation
+//sys getVirtualDiskInformation(handle syscall.Handle, infoSize *uint32, info *getVirtualDiskInfo, sizeUsed *uint32) (win32err error) = virtdisk.GetVirtualDiskInformation
+
+const (
+	storageDependencyInfoVersion2 = 2  // STORAGE_DEPENDENCY_INFO_VERSION_2
+	getVirtualDiskInfoIsLoaded    = 13 // GET_VIRTUAL_DISK_INFO_IS_LOADED
+)
+
+// storageDependencyInfoType2 is STORAGE_DEPENDENCY_INFO_TYPE_2.
+type storageDependencyInfoType2 struct {
+	DependencyTypeFlags         uint32
+	ProviderSpecificFlags       uint32
+	VirtualStorageType          VirtualStorageType
+	AncestorLevel               uint32
+	DependencyDeviceName        *uint16
+	HostVolumeName              *uint16
+	DependentVolumeName         *uint16
+	DependentVolumeRelativePath *uint16
+}
+
+// storageDependencyInfo is the header of STORAGE_DEPENDENCY_INFO, which is followed by
+// NumberEntries entries, aligned to a pointer.
+type storageDependencyInfo struct {
+	Version       uint32
+	NumberEntries uint32
+}
+
+// getVirtualDiskInfo is GET_VIRTUAL_DISK_INFO. The union is sized for its largest member,
+// three uint64s.
+type getVirtualDiskInfo struct {
+	version uint32
+	_       uint32
+	data    [3]uint64
+}
+
+// GetAttachedVHDPath returns the path of the virtual disk file that backs the volume at
+// volumePath, such as `C:`, `\\.\C:`, or a volume GUID path. If the virtual disk is a
+// differencing disk, the path of the differencing disk (rather than its parents) is returned.
+//
+// The path is returned on a drive letter or mount point of the host volume, if it has one,
+// and as a volume GUID path otherwise. It returns an error matching [ErrNotVirtualDisk] if the
+// volume is not backed by a virtual disk.
+func GetAttachedVHDPath(volumePath string) (string, error) {
+	h, err := openVolume(volumePath)
+	if err != nil {
+		return "", err
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	size := uint32(unsafe.Sizeof(storageDependencyInfo{}) + 4*unsafe.Sizeof(storageDependencyInfoType2{}))
+	for {
+		// allocate as pointers, for the alignment of the entries
+		buf := make([]uintptr, (uintptr(size)+unsafe.Sizeof(uintptr(0))-1)/unsafe.Sizeof(uintptr(0)))
+		hdr := (*storageDependencyInfo)(unsafe.Pointer(&buf[0]))
+		hdr.Version = storageDependencyInfoVersion2
+		var used uint32
+		err := getStorageDependencyInformation(syscall.Handle(h), 0, size, (*byte)(unsafe.Pointer(hdr)), &used)
+		if err == windows.ERROR_INSUFFICIENT_BUFFER && used > size { //nolint:errorlint // err is Errno
+			size = used
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to get storage dependency information for %s: %w", volumePath, classifyError(err))
+		}
+		entries := unsafe.Slice(
+			(*storageDependencyInfoType2)(unsafe.Pointer(&buf[unsafe.Sizeof(*hdr)/unsafe.Sizeof(uintptr(0))])),
+			hdr.NumberEntries)
+		// the virtual disk the volume is on has the lowest ancestor level; its parents follow
+		var e *storageDependencyInfoType2
+		for i := range entries {
+			if e == nil || entries[i].AncestorLevel < e.AncestorLevel {
+				e = &entries[i]
+			}
+		}
+		if e == nil {
+			return "", fmt.Errorf("volume %s: %w", volumePath, &vhdError{kind: ErrNotVirtualDisk, err: windows.ERROR_VIRTDISK_NOT_VIRTUAL_DISK})
+		}
+		host := windows.UTF16PtrToString(e.HostVolumeName)
+		rel := strings.TrimPrefix(windows.UTF16PtrToString(e.DependentVolumeRelativePath), `\`)
+		return hostVolumePath(host) + rel, nil
+	}
+}
+
+// openVolume opens a volume, such as `C:` or a volume GUID path, to query its properties.
+func openVolume(volumePath string) (windows.Handle, error) {
+	p := strings.TrimSuffix(volumePath, `\`)
+	if len(p) == 2 && p[1] == ':' {
+		p = `\\.\` + p
+	}
+	p16, err := windows.UTF16PtrFromString(p)
+	if err != nil {
+		return 0, err
+	}
+	h, err := windows.CreateFile(p16, 0, windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE, nil, windows.OPEN_EXISTING, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open volume %s: %w", volumePath, err)
+	}
+	return h, nil
+}
+
+// hostVolumePath returns the first mount point of the volume with the volume GUID path volume,
+// or volume itself if it is not mounted. The result ends with a backslash.
+func hostVolumePath(volume string) string {
+	if !strings.HasSuffix(volume, `\`) {
+		volume += `\`
+	}
+	volume16, err := windows.UTF16PtrFromString(volume)
+	if err != nil {
+		return volume
+	}
+	buf := make([]uint16, windows.MAX_PATH+1)
+	var n uint32
+	if err := windows.GetVolumePathNamesForVolumeName(volume16, &buf[0], uint32(len(buf)), &n); err != nil || buf[0] == 0 {
+		return volume
+	}
+	// the mount points are a list of null-terminated strings
+	return windows.UTF16ToString(buf)
+}
+
+// IsAttached reports whether the virtual disk at vhdPath is attached, by this or any other
+// process.
+func IsAttached(vhdPath string) (bool, error) {
+	handle, err := OpenVirtualDiskWithParameters(vhdPath, VirtualDiskAccessNone, OpenVirtualDiskFlagNone,
+		&OpenVirtualDiskParameters{Version: 2, Version2: OpenVersion2{GetInfoOnly: true}})
+	if err != nil {
+		return false, err
+	}
+	defer syscall.CloseHandle(handle) //nolint:errcheck
+
+	info := getVirtualDiskInfo{version: getVirtualDiskInfoIsLoaded}
+	size := uint32(unsafe.Sizeof(info))
+	if err := getVirtualDiskInformation(handle, &size, &info, nil); err != nil {
+		return false, fmt.Errorf("failed to get virtual disk information for %s: %w", vhdPath, classifyError(err))
+	}
+	// IsLoaded is a BOOL at the start of the union
+	return *(*int32)(unsafe.Pointer(&info.data[0])) != 0, nil
+}
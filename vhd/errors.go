@@ -0,0 +1,59 @@
+//go:build windows
+// +build windows
+
+package vhd
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows"
+)
+
+// Classified virtual disk errors.
+//
+// Errors returned by the functions in this package wrap these when the underlying virtdisk
+// error is recognized, so callers can use errors.Is instead of comparing against raw Win32
+// error codes. The returned errors also continue to match the underlying error: for example,
+// an error that matches [ErrNotVirtualDisk] also matches windows.ERROR_VIRTDISK_NOT_VIRTUAL_DISK.
+var (
+	// ErrNotVirtualDisk is returned when the file is not a virtual disk, or is a virtual disk
+	// of a type that the operation does not support.
+	ErrNotVirtualDisk = errors.New("file is not a virtual disk")
+
+	// ErrSharingViolation is returned when the virtual disk is in use, for example because it
+	// is already attached or opened by another process.
+	ErrSharingViolation = errors.New("virtual disk is in use")
+
+	// ErrFileCorrupt is returned when the virtual disk file is corrupt.
+	ErrFileCorrupt = errors.New("virtual disk file is corrupt")
+)
+
+// vhdError is a virtdisk error that has been classified as one of the sentinel errors above.
+// It matches both the sentinel and the underlying error.
+type vhdError struct {
+	kind error
+	err  error
+}
+
+func (e *vhdError) Error() string { return e.err.Error() }
+func (e *vhdError) Unwrap() error { return e.err }
+
+func (e *vhdError) Is(target error) bool {
+	return target == e.kind //nolint:errorlint // comparing sentinel values
+}
+
+// classifyError wraps err with the sentinel error describing it, if there is one.
+func classifyError(err error) error {
+	var kind error
+	switch {
+	case errors.Is(err, windows.ERROR_VIRTDISK_NOT_VIRTUAL_DISK):
+		kind = ErrNotVirtualDisk
+	case errors.Is(err, windows.ERROR_SHARING_VIOLATION):
+		kind = ErrSharingViolation
+	case errors.Is(err, windows.ERROR_FILE_CORRUPT):
+		kind = ErrFileCorrupt
+	default:
+		return err
+	}
+	return &vhdError{kind: kind, err: err}
+}
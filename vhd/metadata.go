@@ -0,0 +1,83 @@
+//go:build windows
+// +build windows
+
+package vhd
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/Microsoft/go-winio/pkg/guid"
+	"golang.org/x/sys/windows"
+)
+
+//sys getVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID, metaDataSize *uint32, metaData *byte) (win32err error) = virtdisk.GetVirtualDiskMetadata
+//sys setVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID, metaDataSize uint32, metaData *byte) (win32err error) = virtdisk.SetVirtualDiskMetadata
+//sys deleteVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID) (win32err error) = virtdisk.DeleteVirtualDiskMetadata
+//sys enumerateVirtualDiskMetadata(handle syscall.Handle, numberOfItems *uint32, items *windows.GUID) (win32err error) = virtdisk.EnumerateVirtualDiskMetadata
+
+// The user metadata functions below operate on the user metadata region of a VHDX, in which
+// each item is an arbitrary blob identified by a GUID. They require a handle to a VHDX
+// opened with version 2 of the open parameters (see [OpenVirtualDisk]); VHDs do not have
+// user metadata.
+
+// GetVirtualDiskMetadata returns the user metadata stored in the VHDX under item.
+func GetVirtualDiskMetadata(handle syscall.Handle, item guid.GUID) ([]byte, error) {
+	var size uint32 = 256
+	for {
+		b := make([]byte, size)
+		err := getVirtualDiskMetadata(handle, (*windows.GUID)(&item), &size, &b[0])
+		switch err { //nolint:errorlint // err is Errno
+		case nil:
+			return b[:size], nil
+		case windows.ERROR_INSUFFICIENT_BUFFER, windows.ERROR_MORE_DATA:
+			if size <= uint32(len(b)) {
+				size = uint32(2 * len(b))
+			}
+		default:
+			return nil, fmt.Errorf("failed to get virtual disk metadata %s: %w", item, classifyError(err))
+		}
+	}
+}
+
+// SetVirtualDiskMetadata stores data as the user metadata item in the VHDX, replacing any
+// existing data for that item.
+func SetVirtualDiskMetadata(handle syscall.Handle, item guid.GUID, data []byte) error {
+	var p *byte
+	if len(data) > 0 {
+		p = &data[0]
+	}
+	if err := setVirtualDiskMetadata(handle, (*windows.GUID)(&item), uint32(len(data)), p); err != nil {
+		return fmt.Errorf("failed to set virtual disk metadata %s: %w", item, classifyError(err))
+	}
+	return nil
+}
+
+// DeleteVirtualDiskMetadata removes the user metadata item from the VHDX.
+func DeleteVirtualDiskMetadata(handle syscall.Handle, item guid.GUID) error {
+	if err := deleteVirtualDiskMetadata(handle, (*windows.GUID)(&item)); err != nil {
+		return fmt.Errorf("failed to delete virtual disk metadata %s: %w", item, classifyError(err))
+	}
+	return nil
+}
+
+// EnumerateVirtualDiskMetadata returns the GUIDs of the user metadata items in the VHDX.
+func EnumerateVirtualDiskMetadata(handle syscall.Handle) ([]guid.GUID, error) {
+	var n uint32
+	for {
+		items := make([]guid.GUID, n+1)
+		count := uint32(len(items))
+		err := enumerateVirtualDiskMetadata(handle, &count, (*windows.GUID)(&items[0]))
+		switch err { //nolint:errorlint // err is Errno
+		case nil:
+			return items[:count], nil
+		case windows.ERROR_INSUFFICIENT_BUFFER, windows.ERROR_MORE_DATA:
+			if count <= n {
+				count = 2 * (n + 1)
+			}
+			n = count
+		default:
+			return nil, fmt.Errorf("failed to enumerate virtual disk metadata: %w", classifyError(err))
+		}
+	}
+}
@@ -0,0 +1,269 @@
+//go:build windows
+// +build windows
+
+package vhd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/Microsoft/go-winio/internal/computestorage"
+)
+
+const (
+	// defaultPackSizeInGB is the default maximum size of VHDs created by PackDirectory.
+	defaultPackSizeInGB = 20
+	// defaultPackBlockSizeInMB is the default block size of VHDs created by PackDirectory.
+	defaultPackBlockSizeInMB = 1
+)
+
+// PackOptions specifies how [PackDirectory] creates a VHD.
+type PackOptions struct {
+	// MaxSizeInGB is the maximum size of the dynamically expanding VHD. If zero, 20GB is used.
+	MaxSizeInGB uint32
+	// BlockSizeInMB is the block size of the VHD. If zero, 1MB is used.
+	BlockSizeInMB uint32
+}
+
+// PackDirectory creates a VHDX at vhdPath containing a single NTFS volume, and copies
+// the contents of the directory src into the root of the volume.
+//
+// Files are copied with backup streams, so security descriptors, alternate data streams,
+// extended attributes, and reparse points are preserved, along with file times and
+// attributes. Hard links are copied as separate files.
+//
+// The caller must be an administrator, since this attaches the VHD and uses the backup
+// and restore privileges. If packing fails, the VHD is removed.
+func PackDirectory(src, vhdPath string, opts *PackOptions) (err error) {
+	if opts == nil {
+		opts = &PackOptions{}
+	}
+	size := opts.MaxSizeInGB
+	if size == 0 {
+		size = defaultPackSizeInGB
+	}
+	blockSize := opts.BlockSizeInMB
+	if blockSize == 0 {
+		blockSize = defaultPackBlockSizeInMB
+	}
+
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+
+	params := CreateVirtualDiskParameters{
+		Version: 2,
+		Version2: CreateVersion2{
+			MaximumSize:      uint64(size) * 1024 * 1024 * 1024,
+			BlockSizeInBytes: blockSize * 1024 * 1024,
+		},
+	}
+	handle, err := CreateVirtualDisk(vhdPath, VirtualDiskAccessNone, CreateVirtualDiskFlagNone, &params)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(vhdPath)
+		}
+	}()
+	defer syscall.CloseHandle(handle) //nolint:errcheck
+
+	return withVolume(handle, AttachVirtualDiskFlagNone, true, func(volume string) error {
+		if err := removeVolumeContents(volume); err != nil {
+			return err
+		}
+		if err := copyTree(src, volume); err != nil {
+			return fmt.Errorf("failed to copy %s to virtual disk: %w", src, err)
+		}
+		return nil
+	})
+}
+
+// UnpackToDirectory copies the contents of the volume on the VHD at vhdPath into the
+// directory dst, which is created if it does not exist. The VHD is attached read-only
+// for the duration of the copy.
+//
+// Files are copied with backup streams, as in [PackDirectory].
+func UnpackToDirectory(vhdPath, dst string) error {
+	handle, err := OpenVirtualDiskWithParameters(
+		vhdPath,
+		VirtualDiskAccessNone,
+		OpenVirtualDiskFlagCachedIO|OpenVirtualDiskFlagIgnoreRelativeParentLocator,
+		&OpenVirtualDiskParameters{
+			Version:  2,
+			Version2: OpenVersion2{ReadOnly: true},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	defer syscall.CloseHandle(handle) //nolint:errcheck
+
+	if err := os.MkdirAll(dst, 0777); err != nil {
+		return err
+	}
+	return withVolume(handle, AttachVirtualDiskFlagReadOnly, false, func(volume string) error {
+		if err := copyTree(volume, dst); err != nil {
+			return fmt.Errorf("failed to copy virtual disk to %s: %w", dst, err)
+		}
+		return nil
+	})
+}
+
+// withVolume attaches the virtual disk, optionally formats it, and calls fn with the path of
+// its volume. The disk is detached when fn returns.
+func withVolume(handle syscall.Handle, flags AttachVirtualDiskFlag, format bool, fn func(volume string) error) (err error) {
+	if err := AttachVirtualDisk(handle, flags|AttachVirtualDiskFlagNoDriveLetter, &AttachVirtualDiskParameters{Version: 1}); err != nil {
+		return err
+	}
+	defer func() {
+		if derr := DetachVirtualDisk(handle); err == nil {
+			err = derr
+		}
+	}()
+
+	if format {
+		if err := formatVirtualDisk(handle); err != nil {
+			return err
+		}
+	}
+	volume, err := computestorage.GetLayerVHDMountPath(windows.Handle(handle))
+	if err != nil {
+		return err
+	}
+	return fn(volume)
+}
+
+// formatVirtualDisk formats the attached virtual disk with a single NTFS volume.
+func formatVirtualDisk(handle syscall.Handle) error {
+	h := windows.Handle(handle)
+	// Before Windows 10 1903 (19H1), HcsFormatWritableLayerVhd expects a disk handle,
+	// rather than a VHD handle.
+	if windows.RtlGetVersion().BuildNumber < 18362 {
+		diskPath, err := GetVirtualDiskPhysicalPath(handle)
+		if err != nil {
+			return err
+		}
+		p16, err := windows.UTF16PtrFromString(diskPath)
+		if err != nil {
+			return err
+		}
+		disk, err := windows.CreateFile(p16,
+			windows.GENERIC_READ|windows.GENERIC_WRITE,
+			windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE,
+			nil,
+			windows.OPEN_EXISTING,
+			windows.FILE_ATTRIBUTE_NORMAL|windows.FILE_FLAG_NO_BUFFERING,
+			0)
+		if err != nil {
+			return &os.PathError{Op: "CreateFile", Path: diskPath, Err: err}
+		}
+		defer windows.CloseHandle(disk) //nolint:errcheck
+		h = disk
+	}
+	return computestorage.FormatWritableLayerVHD(h)
+}
+
+// isVolumeSystemEntry returns true if name, in the root of a volume, is maintained by the
+// system rather than being part of the volume's contents.
+func isVolumeSystemEntry(name string) bool {
+	return strings.EqualFold(name, "System Volume Information") || strings.EqualFold(name, "$Recycle.Bin")
+}
+
+// removeVolumeContents removes the files created in the root of a newly formatted volume,
+// such as the sandbox state written by HcsFormatWritableLayerVhd.
+func removeVolumeContents(volume string) error {
+	entries, err := os.ReadDir(volume)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if isVolumeSystemEntry(e.Name()) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(volume, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyTree copies the contents of the directory src into the existing directory dst.
+func copyTree(src, dst string) error {
+	return winio.RunWithPrivileges([]string{winio.SeBackupPrivilege, winio.SeRestorePrivilege}, func() error {
+		return copyDirContents(src, dst, true)
+	})
+}
+
+func copyDirContents(src, dst string, root bool) error {
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if root && isVolumeSystemEntry(e.Name()) {
+			continue
+		}
+		if err := copyEntry(filepath.Join(src, e.Name()), filepath.Join(dst, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyEntry copies the file or directory at src to dst, including its backup streams, times,
+// and attributes. Directories are copied recursively, unless they are reparse points.
+func copyEntry(src, dst string) error {
+	sf, err := winio.OpenForBackup(src, windows.GENERIC_READ, windows.FILE_SHARE_READ, windows.OPEN_EXISTING)
+	if err != nil {
+		return err
+	}
+	defer sf.Close()
+
+	bi, err := winio.GetFileBasicInfo(sf)
+	if err != nil {
+		return err
+	}
+
+	createMode := uint32(windows.CREATE_NEW)
+	if bi.FileAttributes&windows.FILE_ATTRIBUTE_DIRECTORY != 0 {
+		if err := os.Mkdir(dst, 0777); err != nil {
+			return err
+		}
+		// Copy the children first, so that the directory's security descriptor and attributes
+		// (such as read-only) are applied after they are created.
+		if bi.FileAttributes&windows.FILE_ATTRIBUTE_REPARSE_POINT == 0 {
+			if err := copyDirContents(src, dst, false); err != nil {
+				return err
+			}
+		}
+		createMode = windows.OPEN_EXISTING
+	}
+
+	df, err := winio.OpenForBackup(dst, windows.GENERIC_WRITE|windows.WRITE_DAC|windows.WRITE_OWNER, 0, createMode)
+	if err != nil {
+		return err
+	}
+	defer df.Close()
+
+	br := winio.NewBackupFileReader(sf, true)
+	defer br.Close()
+	bw := winio.NewBackupFileWriter(df, true)
+	defer bw.Close()
+	if _, err := io.Copy(bw, br); err != nil {
+		return err
+	}
+
+	// These attributes are set by the backup streams, and cannot be set directly.
+	bi.FileAttributes &^= windows.FILE_ATTRIBUTE_REPARSE_POINT | windows.FILE_ATTRIBUTE_SPARSE_FILE |
+		windows.FILE_ATTRIBUTE_COMPRESSED | windows.FILE_ATTRIBUTE_ENCRYPTED
+	return winio.SetFileBasicInfo(df, bi)
+}
@@ -11,7 +11,7 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zvhd_windows.go vhd.go
+//go:generate go run github.com/Microsoft/go-winio/tools/mkwinsyscall -output zvhd_windows.go vhd.go metadata.go cache.go dependency.go
 
 //sys createVirtualDisk(virtualStorageType *VirtualStorageType, path string, virtualDiskAccessMask uint32, securityDescriptor *uintptr, createVirtualDiskFlags uint32, providerSpecificFlags uint32, parameters *CreateVirtualDiskParameters, overlapped *syscall.Overlapped, handle *syscall.Handle) (win32err error) = virtdisk.CreateVirtualDisk
 //sys openVirtualDisk(virtualStorageType *VirtualStorageType, path string, virtualDiskAccessMask uint32, openVirtualDiskFlags uint32, parameters *openVirtualDiskParameters, handle *syscall.Handle) (win32err error) = virtdisk.OpenVirtualDisk
@@ -140,6 +140,7 @@ const (
 	AttachVirtualDiskFlagRestrictedRange               AttachVirtualDiskFlag = 0x00000080
 	AttachVirtualDiskFlagSinglePartition               AttachVirtualDiskFlag = 0x00000100
 	AttachVirtualDiskFlagRegisterVolume                AttachVirtualDiskFlag = 0x00000200
+	AttachVirtualDiskFlagAtBoot                        AttachVirtualDiskFlag = 0x00000400
 
 	// Flags for detaching a VHD.
 	DetachVirtualDiskFlagNone DetachVirtualDiskFlag = 0x0
@@ -169,7 +170,7 @@ func CreateVhdx(path string, maxSizeInGb, blockSizeInMb uint32) error {
 // DetachVirtualDisk detaches a virtual hard disk by handle.
 func DetachVirtualDisk(handle syscall.Handle) (err error) {
 	if err := detachVirtualDisk(handle, 0, 0); err != nil {
-		return fmt.Errorf("failed to detach virtual disk: %w", err)
+		return fmt.Errorf("failed to detach virtual disk: %w", classifyError(err))
 	}
 	return nil
 }
@@ -205,7 +206,7 @@ func AttachVirtualDisk(
 		parameters,
 		nil,
 	); err != nil {
-		return fmt.Errorf("failed to attach virtual disk: %w", err)
+		return fmt.Errorf("failed to attach virtual disk: %w", classifyError(err))
 	}
 	return nil
 }
@@ -293,7 +294,7 @@ func OpenVirtualDiskWithParameters(
 		params,
 		&handle,
 	); err != nil {
-		return 0, fmt.Errorf("failed to open virtual disk: %w", err)
+		return 0, fmt.Errorf("failed to open virtual disk: %w", classifyError(err))
 	}
 	return handle, nil
 }
@@ -324,7 +325,7 @@ func CreateVirtualDisk(
 		nil,
 		&handle,
 	); err != nil {
-		return handle, fmt.Errorf("failed to create virtual disk: %w", err)
+		return handle, fmt.Errorf("failed to create virtual disk: %w", classifyError(err))
 	}
 	return handle, nil
 }
@@ -342,7 +343,7 @@ func GetVirtualDiskPhysicalPath(handle syscall.Handle) (_ string, err error) {
 		&diskPathSizeInBytes,
 		&diskPhysicalPathBuf[0],
 	); err != nil {
-		return "", fmt.Errorf("failed to get disk physical path: %w", err)
+		return "", fmt.Errorf("failed to get disk physical path: %w", classifyError(err))
 	}
 	return windows.UTF16ToString(diskPhysicalPathBuf[:]), nil
 }
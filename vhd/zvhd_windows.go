@@ -42,11 +42,18 @@ func errnoErr(e syscall.Errno) error {
 var (
 	modvirtdisk = windows.NewLazySystemDLL("virtdisk.dll")
 
-	procAttachVirtualDisk          = modvirtdisk.NewProc("AttachVirtualDisk")
-	procCreateVirtualDisk          = modvirtdisk.NewProc("CreateVirtualDisk")
-	procDetachVirtualDisk          = modvirtdisk.NewProc("DetachVirtualDisk")
-	procGetVirtualDiskPhysicalPath = modvirtdisk.NewProc("GetVirtualDiskPhysicalPath")
-	procOpenVirtualDisk            = modvirtdisk.NewProc("OpenVirtualDisk")
+	procAttachVirtualDisk               = modvirtdisk.NewProc("AttachVirtualDisk")
+	procCreateVirtualDisk               = modvirtdisk.NewProc("CreateVirtualDisk")
+	procDeleteVirtualDiskMetadata       = modvirtdisk.NewProc("DeleteVirtualDiskMetadata")
+	procDetachVirtualDisk               = modvirtdisk.NewProc("DetachVirtualDisk")
+	procEnumerateVirtualDiskMetadata    = modvirtdisk.NewProc("EnumerateVirtualDiskMetadata")
+	procGetStorageDependencyInformation = modvirtdisk.NewProc("GetStorageDependencyInformation")
+	procGetVirtualDiskInformation       = modvirtdisk.NewProc("GetVirtualDiskInformation")
+	procGetVirtualDiskMetadata          = modvirtdisk.NewProc("GetVirtualDiskMetadata")
+	procGetVirtualDiskPhysicalPath      = modvirtdisk.NewProc("GetVirtualDiskPhysicalPath")
+	procOpenVirtualDisk                 = modvirtdisk.NewProc("OpenVirtualDisk")
+	procSetVirtualDiskInformation       = modvirtdisk.NewProc("SetVirtualDiskInformation")
+	procSetVirtualDiskMetadata          = modvirtdisk.NewProc("SetVirtualDiskMetadata")
 )
 
 func attachVirtualDisk(handle syscall.Handle, securityDescriptor *uintptr, attachVirtualDiskFlag uint32, providerSpecificFlags uint32, parameters *AttachVirtualDiskParameters, overlapped *syscall.Overlapped) (win32err error) {
@@ -74,6 +81,14 @@ func _createVirtualDisk(virtualStorageType *VirtualStorageType, path *uint16, vi
 	return
 }
 
+func deleteVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID) (win32err error) {
+	r0, _, _ := syscall.Syscall(procDeleteVirtualDiskMetadata.Addr(), 2, uintptr(handle), uintptr(unsafe.Pointer(item)), 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
 func detachVirtualDisk(handle syscall.Handle, detachVirtualDiskFlags uint32, providerSpecificFlags uint32) (win32err error) {
 	r0, _, _ := syscall.Syscall(procDetachVirtualDisk.Addr(), 3, uintptr(handle), uintptr(detachVirtualDiskFlags), uintptr(providerSpecificFlags))
 	if r0 != 0 {
@@ -82,6 +97,38 @@ func detachVirtualDisk(handle syscall.Handle, detachVirtualDiskFlags uint32, pro
 	return
 }
 
+func enumerateVirtualDiskMetadata(handle syscall.Handle, numberOfItems *uint32, items *windows.GUID) (win32err error) {
+	r0, _, _ := syscall.Syscall(procEnumerateVirtualDiskMetadata.Addr(), 3, uintptr(handle), uintptr(unsafe.Pointer(numberOfItems)), uintptr(unsafe.Pointer(items)))
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func getStorageDependencyInformation(handle syscall.Handle, flags uint32, infoSize uint32, info *byte, sizeUsed *uint32) (win32err error) {
+	r0, _, _ := syscall.Syscall6(procGetStorageDependencyInformation.Addr(), 5, uintptr(handle), uintptr(flags), uintptr(infoSize), uintptr(unsafe.Pointer(info)), uintptr(unsafe.Pointer(sizeUsed)), 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func getVirtualDiskInformation(handle syscall.Handle, infoSize *uint32, info *getVirtualDiskInfo, sizeUsed *uint32) (win32err error) {
+	r0, _, _ := syscall.Syscall6(procGetVirtualDiskInformation.Addr(), 4, uintptr(handle), uintptr(unsafe.Pointer(infoSize)), uintptr(unsafe.Pointer(info)), uintptr(unsafe.Pointer(sizeUsed)), 0, 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func getVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID, metaDataSize *uint32, metaData *byte) (win32err error) {
+	r0, _, _ := syscall.Syscall6(procGetVirtualDiskMetadata.Addr(), 4, uintptr(handle), uintptr(unsafe.Pointer(item)), uintptr(unsafe.Pointer(metaDataSize)), uintptr(unsafe.Pointer(metaData)), 0, 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
 func getVirtualDiskPhysicalPath(handle syscall.Handle, diskPathSizeInBytes *uint32, buffer *uint16) (win32err error) {
 	r0, _, _ := syscall.Syscall(procGetVirtualDiskPhysicalPath.Addr(), 3, uintptr(handle), uintptr(unsafe.Pointer(diskPathSizeInBytes)), uintptr(unsafe.Pointer(buffer)))
 	if r0 != 0 {
@@ -106,3 +153,19 @@ func _openVirtualDisk(virtualStorageType *VirtualStorageType, path *uint16, virt
 	}
 	return
 }
+
+func setVirtualDiskInformation(handle syscall.Handle, info *setVirtualDiskInfo) (win32err error) {
+	r0, _, _ := syscall.Syscall(procSetVirtualDiskInformation.Addr(), 2, uintptr(handle), uintptr(unsafe.Pointer(info)), 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func setVirtualDiskMetadata(handle syscall.Handle, item *windows.GUID, metaDataSize uint32, metaData *byte) (win32err error) {
+	r0, _, _ := syscall.Syscall6(procSetVirtualDiskMetadata.Addr(), 4, uintptr(handle), uintptr(unsafe.Pointer(item)), uintptr(metaDataSize), uintptr(unsafe.Pointer(metaData)), 0, 0)
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
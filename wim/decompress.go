@@ -5,23 +5,51 @@ package wim
 
 import (
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
 
+	"github.com/Microsoft/go-winio/wim/lzms"
 	"github.com/Microsoft/go-winio/wim/lzx"
 )
 
-const chunkSize = 32768 // Compressed resource chunk size
+const lzxChunkSize = 32768 // Compressed resource chunk size for LZX
+
+type compressionFormat uint32
+
+// Compression formats, as stored in solid resource headers.
+//
+//nolint:deadcode,varcheck // need unused variables for iota to work
+const (
+	formatNone compressionFormat = iota
+	formatXpress
+	formatLzx
+	formatLzms
+)
+
+// newChunkReader returns a reader for the decompressed data of a compressed chunk.
+func newChunkReader(format compressionFormat, r io.Reader, uncompressedSize int) (io.ReadCloser, error) {
+	switch format {
+	case formatLzx:
+		return lzx.NewReader(r, uncompressedSize)
+	case formatLzms:
+		return lzms.NewReader(r, uncompressedSize)
+	}
+	return nil, fmt.Errorf("unsupported compression format %d", format)
+}
 
 type compressedReader struct {
 	r            *io.SectionReader
 	d            io.ReadCloser
+	format       compressionFormat
+	chunkLen     int64
 	chunks       []int64
 	curChunk     int
 	originalSize int64
 }
 
-func newCompressedReader(r *io.SectionReader, originalSize int64, offset int64) (*compressedReader, error) {
-	nchunks := (originalSize + chunkSize - 1) / chunkSize
+func newCompressedReader(r *io.SectionReader, originalSize int64, offset int64, format compressionFormat, chunkLen int64) (*compressedReader, error) {
+	nchunks := (originalSize + chunkLen - 1) / chunkLen
 	var base int64
 	chunks := make([]int64, nchunks)
 	if originalSize <= 0xffffffff {
@@ -50,16 +78,18 @@ func newCompressedReader(r *io.SectionReader, originalSize int64, offset int64)
 
 	cr := &compressedReader{
 		r:            r,
+		format:       format,
+		chunkLen:     chunkLen,
 		chunks:       chunks,
 		originalSize: originalSize,
 	}
 
-	err := cr.reset(int(offset / chunkSize))
+	err := cr.reset(int(offset / chunkLen))
 	if err != nil {
 		return nil, err
 	}
 
-	suboff := offset % chunkSize
+	suboff := offset % chunkLen
 	if suboff != 0 {
 		_, err := io.CopyN(io.Discard, cr.d, suboff)
 		if err != nil {
@@ -82,11 +112,11 @@ func (r *compressedReader) chunkSize(n int) int {
 
 func (r *compressedReader) uncompressedSize(n int) int {
 	if n < len(r.chunks)-1 {
-		return chunkSize
+		return int(r.chunkLen)
 	}
-	size := int(r.originalSize % chunkSize)
+	size := int(r.originalSize % r.chunkLen)
 	if size == 0 {
-		size = chunkSize
+		size = int(r.chunkLen)
 	}
 	return size
 }
@@ -103,7 +133,7 @@ func (r *compressedReader) reset(n int) error {
 	uncompressedSize := r.uncompressedSize(n)
 	section := io.NewSectionReader(r.r, r.chunkOffset(n), int64(size))
 	if size != uncompressedSize {
-		d, err := lzx.NewReader(section, uncompressedSize)
+		d, err := newChunkReader(r.format, section, uncompressedSize)
 		if err != nil {
 			return err
 		}
@@ -137,3 +167,149 @@ func (r *compressedReader) Close() error {
 	}
 	return err
 }
+
+// solidResourceMagic is the original size in the offset table of solid resources, whose
+// actual size is in the solid resource header.
+const solidResourceMagic = 0x100000000
+
+// solidResourceHeader precedes the chunk table of a solid resource.
+type solidResourceHeader struct {
+	OriginalSize uint64
+	ChunkSize    uint32
+	Format       compressionFormat
+}
+
+// solidResource is a resource that compresses the data of many streams together, as in
+// ESD files. Unlike other resources, its chunk size and compression format are stored in
+// the resource itself.
+type solidResource struct {
+	r            *io.SectionReader
+	format       compressionFormat
+	chunkLen     int64
+	chunks       []int64 // chunk offsets, followed by the end of the last chunk
+	originalSize int64
+}
+
+func newSolidResource(r *io.SectionReader) (*solidResource, error) {
+	var hdr solidResourceHeader
+	err := binary.Read(r, binary.LittleEndian, &hdr)
+	if err != nil {
+		return nil, err
+	}
+	chunkLen := int64(hdr.ChunkSize)
+	if chunkLen == 0 || chunkLen&(chunkLen-1) != 0 || chunkLen > lzms.MaxSize {
+		return nil, fmt.Errorf("unsupported solid resource chunk size %d", chunkLen)
+	}
+
+	// Unlike other resources, the chunk table holds the compressed size of every chunk.
+	// The number of chunks comes from the header, so check that the table fits in the
+	// resource before allocating it.
+	hdrSize := int64(binary.Size(hdr))
+	if r.Size() < hdrSize {
+		return nil, errors.New("invalid solid resource chunk table")
+	}
+	nchunks := hdr.OriginalSize / uint64(chunkLen)
+	if hdr.OriginalSize%uint64(chunkLen) != 0 {
+		nchunks++
+	}
+	if nchunks > uint64(r.Size()-hdrSize)/4 {
+		return nil, errors.New("invalid solid resource chunk table")
+	}
+	base := hdrSize + int64(nchunks)*4
+	sizes := make([]uint32, nchunks)
+	err = binary.Read(r, binary.LittleEndian, sizes)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]int64, nchunks+1)
+	chunks[0] = base
+	for i, n := range sizes {
+		// Chunks that do not compress are stored uncompressed.
+		if int64(n) > chunkLen {
+			return nil, errors.New("invalid solid resource chunk table")
+		}
+		chunks[i+1] = chunks[i] + int64(n)
+	}
+	if chunks[len(sizes)] > r.Size() {
+		return nil, errors.New("invalid solid resource chunk table")
+	}
+
+	return &solidResource{
+		r:            r,
+		format:       hdr.Format,
+		chunkLen:     chunkLen,
+		chunks:       chunks,
+		originalSize: int64(hdr.OriginalSize),
+	}, nil
+}
+
+// readChunk reads and decompresses chunk n.
+func (s *solidResource) readChunk(n int) ([]byte, error) {
+	size := s.chunkLen
+	if n == len(s.chunks)-2 {
+		size = s.originalSize - int64(n)*s.chunkLen
+	}
+	section := io.NewSectionReader(s.r, s.chunks[n], s.chunks[n+1]-s.chunks[n])
+	d := io.NopCloser(section)
+	if section.Size() != size {
+		var err error
+		d, err = newChunkReader(s.format, section, int(size))
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer d.Close()
+	b := make([]byte, size)
+	_, err := io.ReadFull(d, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// solidChunk returns chunk n of res, decompressing it unless it was the last one used.
+// Streams in a solid resource are usually read in order, so this avoids decompressing
+// the same (large) chunk for each stream in it.
+func (r *Reader) solidChunk(res *solidResource, n int) ([]byte, error) {
+	r.chunkMu.Lock()
+	defer r.chunkMu.Unlock()
+	if r.chunkRes == res && r.chunkN == n {
+		return r.chunkData, nil
+	}
+	b, err := res.readChunk(n)
+	if err != nil {
+		return nil, err
+	}
+	r.chunkRes, r.chunkN, r.chunkData = res, n, b
+	return b, nil
+}
+
+// solidReader reads a stream stored in a solid resource.
+type solidReader struct {
+	wim    *Reader
+	res    *solidResource
+	offset int64
+	end    int64
+}
+
+func (r *solidReader) Read(b []byte) (int, error) {
+	if r.offset >= r.end {
+		return 0, io.EOF
+	}
+	n := int(r.offset / r.res.chunkLen)
+	chunk, err := r.wim.solidChunk(r.res, n)
+	if err != nil {
+		return 0, err
+	}
+	data := chunk[r.offset-int64(n)*r.res.chunkLen:]
+	if rem := r.end - r.offset; int64(len(data)) > rem {
+		data = data[:rem]
+	}
+	k := copy(b, data)
+	r.offset += int64(k)
+	return k, nil
+}
+
+func (*solidReader) Close() error {
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build windows || linux
+// +build windows linux
+
+package wim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+func solidResourceData(t *testing.T, hdr solidResourceHeader, sizes []uint32, data []byte) *io.SectionReader {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, binary.LittleEndian, hdr); err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, sizes); err != nil {
+		t.Fatal(err)
+	}
+	buf.Write(data)
+	return io.NewSectionReader(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len()))
+}
+
+func TestNewSolidResource(t *testing.T) {
+	data := []byte("winiowinio")
+	r := solidResourceData(t, solidResourceHeader{OriginalSize: 10, ChunkSize: 8, Format: formatLzms}, []uint32{8, 2}, data)
+	s, err := newSolidResource(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// chunks that are not compressed are read as is
+	for i, want := range []string{"winiowin", "io"} {
+		b, err := s.readChunk(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != want {
+			t.Fatalf("chunk %d: got %q, want %q", i, b, want)
+		}
+	}
+}
+
+func TestNewSolidResourceInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		hdr   solidResourceHeader
+		sizes []uint32
+	}{
+		// the chunk table would not fit in the resource, and must not be allocated
+		{"huge original size", solidResourceHeader{OriginalSize: 1 << 62, ChunkSize: 1 << 15, Format: formatLzms}, nil},
+		{"max original size", solidResourceHeader{OriginalSize: ^uint64(0), ChunkSize: 1, Format: formatLzms}, nil},
+		{"chunk larger than chunk size", solidResourceHeader{OriginalSize: 8, ChunkSize: 8, Format: formatLzms}, []uint32{9}},
+		{"chunks past end", solidResourceHeader{OriginalSize: 16, ChunkSize: 8, Format: formatLzms}, []uint32{8, 8}},
+		{"invalid chunk size", solidResourceHeader{OriginalSize: 8, ChunkSize: 3, Format: formatLzms}, []uint32{3}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newSolidResource(solidResourceData(t, tt.hdr, tt.sizes, make([]byte, 8))); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+//go:build windows || linux
+// +build windows linux
+
+package wim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// IterateFunc is the type of the function called by [Image.Iterate] for each file and
+// directory. path is the `\`-separated path of the file relative to the image's root, which is
+// `\` for the root directory itself.
+//
+// If the function returns fs.SkipDir when called for a directory, Iterate does not report the
+// directory's contents; when called for a file, Iterate skips the remaining files in its
+// directory. Any other error stops the iteration, and is returned by Iterate.
+type IterateFunc func(hdr *FileHeader, path string) error
+
+// pendingDir is a directory reported by [Image.Iterate] whose entries have not been read yet.
+type pendingDir struct {
+	path string // the directory's path, without a trailing separator
+	skip bool   // the entries are not reported
+}
+
+// Iterate calls fn for every file and directory in the image, decoding the directory entries
+// sequentially from the image's metadata resource. Unlike [Image.Walk], it does not build a
+// tree of [File] structures, keep the directories it reads, or load the image's security
+// descriptors: only the paths of the directories whose entries have not been reached yet are
+// kept, so memory use does not grow with the number of files in the image. This is suited to
+// inventory scans of very large images.
+//
+// The headers passed to fn are not retained by Iterate.
+// Directories are reported before their contents, but, as the entries are reported in the
+// order they are stored, the contents of a directory are not necessarily reported before
+// those of its next sibling.
+//
+// Iterate requires each directory's entries to be stored after the directory's own entry, as
+// done by all known WIM writers; otherwise, it returns a [ParseError].
+func (img *Image) Iterate(fn IterateFunc) error {
+	r, err := img.wim.resourceReader(&img.offset)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	off, err := skipSecurityData(r)
+	if err != nil {
+		return err
+	}
+
+	// The root directory entry is the only entry of the first list. live counts the pending
+	// directories that are not skipped: once there are none, nothing else will be reported.
+	pending := map[int64]pendingDir{off: {}}
+	live := 1
+	for live > 0 {
+		dir, ok := pending[off]
+		if !ok {
+			return &ParseError{
+				Oper: "directory entry",
+				Err:  fmt.Errorf("no directory refers to the entries at offset %d", off),
+			}
+		}
+		delete(pending, off)
+		if !dir.skip {
+			live--
+		}
+
+		skip := dir.skip
+		for {
+			f, n, err := img.readNextEntry(r, nil)
+			off += n
+			if err == io.EOF { //nolint:errorlint
+				break
+			}
+			if err != nil {
+				return err
+			}
+
+			path := dir.path + `\` + f.Name
+			skipDir := skip
+			if !skip {
+				if err := fn(&f.FileHeader, path); errors.Is(err, fs.SkipDir) {
+					if f.IsDir() {
+						skipDir = true
+					} else {
+						skip, skipDir = true, true
+					}
+				} else if err != nil {
+					return err
+				}
+			}
+			if f.IsDir() {
+				// empty directories may share their (empty) list of entries
+				if prev, ok := pending[f.subdirOffset]; ok && !prev.skip {
+					live--
+				}
+				pending[f.subdirOffset] = pendingDir{path: strings.TrimSuffix(path, `\`), skip: skipDir}
+				if !skipDir {
+					live++
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// skipSecurityData discards the security descriptor table at the start of an image's metadata
+// resource, and returns its size.
+func skipSecurityData(r io.Reader) (int64, error) {
+	var secBlock securityblockDisk
+	if err := binary.Read(r, binary.LittleEndian, &secBlock); err != nil {
+		return 0, &ParseError{Oper: "security table", Err: err}
+	}
+	secsize := int64((secBlock.TotalLength + 7) &^ 7)
+	if secsize < securityblockDiskSize {
+		return 0, &ParseError{Oper: "security table", Err: errors.New("security descriptor table too small")}
+	}
+	if _, err := io.CopyN(io.Discard, r, secsize-securityblockDiskSize); err != nil {
+		if err == io.EOF { //nolint:errorlint
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, &ParseError{Oper: "security table", Err: err}
+	}
+	return secsize, nil
+}
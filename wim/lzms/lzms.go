@@ -0,0 +1,718 @@
+// Package lzms implements a decompressor for the LZMS compression algorithm, which
+// is used by the solid resources of ESD files.
+//
+// LZMS is not publicly documented. It combines LZ77 and delta matches with a range
+// coder for match decisions and adaptive Huffman codes for literals, offsets, and
+// lengths, followed by a filter that undoes the translation of relative addresses in
+// x86 machine code. This implementation follows the description of the format in
+// wimlib.
+package lzms
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+const (
+	// MaxSize is the maximum uncompressed size of LZMS data.
+	MaxSize = 1 << 30
+
+	numLZReps    = 3
+	numDeltaReps = 3
+
+	numLiteralSyms    = 256
+	numLengthSyms     = 54
+	numDeltaPowerSyms = 8
+	maxNumOffsetSyms  = 799
+
+	numMainProbs     = 16
+	numMatchProbs    = 32
+	numLZProbs       = 64
+	numLZRepProbs    = 64
+	numDeltaProbs    = 64
+	numDeltaRepProbs = 64
+
+	probabilityBits        = 6
+	probabilityDenominator = 1 << probabilityBits
+	initialProbability     = 48
+	initialRecentBits      = 0x0000000055555555
+
+	literalRebuildFreq     = 1024
+	lzOffsetRebuildFreq    = 1024
+	lengthRebuildFreq      = 512
+	deltaOffsetRebuildFreq = 1024
+	deltaPowerRebuildFreq  = 512
+
+	maxCodewordLen = 15
+	tableBits      = 10
+
+	// Huffman codes are built from symbols packed with their frequencies, as
+	// sym | freq<<numSymbolBits.
+	numSymbolBits = 10
+	symbolMask    = 1<<numSymbolBits - 1
+
+	x86IDWindowSize         = 65535
+	x86MaxTranslationOffset = 1023
+)
+
+var errCorrupt = errors.New("LZMS data corrupt")
+
+// The offset and length slot bases are run-length encoded as the number of
+// consecutive slots whose bases differ by each successive power of two.
+var (
+	offsetSlotRunLens = [...]uint8{
+		9, 0, 9, 7, 10, 15, 15, 20,
+		20, 30, 33, 40, 42, 45, 60, 73,
+		80, 85, 95, 105, 6,
+	}
+	lengthSlotRunLens = [...]uint8{
+		27, 4, 6, 4, 5, 2, 1, 1,
+		1, 1, 1, 0, 0, 0, 0, 0,
+		1,
+	}
+)
+
+var (
+	offsetSlotBase  [maxNumOffsetSyms + 1]uint32
+	offsetExtraBits [maxNumOffsetSyms]uint8
+	lengthSlotBase  [numLengthSyms + 1]uint32
+	lengthExtraBits [numLengthSyms]uint8
+)
+
+func init() {
+	makeSlots(offsetSlotBase[:], offsetExtraBits[:], offsetSlotRunLens[:], 0x7fffffff)
+	makeSlots(lengthSlotBase[:], lengthExtraBits[:], lengthSlotRunLens[:], 0x400108ab)
+}
+
+func makeSlots(base []uint32, extraBits []uint8, runLens []uint8, final uint32) {
+	var b uint32
+	delta := uint32(1)
+	slot := 0
+	for _, n := range runLens {
+		for ; n > 0; n-- {
+			b += delta
+			base[slot] = b
+			slot++
+		}
+		delta <<= 1
+	}
+	base[slot] = final
+	for i := range extraBits {
+		extraBits[i] = uint8(bits.Len32(base[i+1]-base[i]) - 1)
+	}
+}
+
+// numOffsetSlots returns the number of offset slots needed for offsets in a buffer
+// of the specified size.
+func numOffsetSlots(size int) int {
+	if size < 2 {
+		return 0
+	}
+	off := uint32(size - 1)
+	return 1 + sort.Search(maxNumOffsetSyms, func(i int) bool { return offsetSlotBase[i+1] > off })
+}
+
+// probabilityEntry tracks the last 64 bits decoded in a context, to estimate the
+// probability that the next one is zero.
+type probabilityEntry struct {
+	numRecentZeroBits int32
+	recentBits        uint64
+}
+
+func (p *probabilityEntry) probability() uint32 {
+	prob := uint32(p.numRecentZeroBits)
+	// 0% and 100% probabilities are not allowed.
+	if prob == 0 {
+		prob++
+	}
+	if prob == probabilityDenominator {
+		prob--
+	}
+	return prob
+}
+
+func (p *probabilityEntry) update(bit uint32) {
+	p.numRecentZeroBits += int32(p.recentBits>>(probabilityDenominator-1)) - int32(bit)
+	p.recentBits = p.recentBits<<1 | uint64(bit)
+}
+
+func initProbabilities(probs []probabilityEntry) {
+	for i := range probs {
+		probs[i] = probabilityEntry{initialProbability, initialRecentBits}
+	}
+}
+
+// rangeDecoder decodes bits from the 16-bit words at the start of the compressed
+// data, reading forward.
+type rangeDecoder struct {
+	rng  uint32
+	code uint32
+	in   []byte
+	next int
+}
+
+func (rd *rangeDecoder) init(in []byte) {
+	rd.rng = 0xffffffff
+	rd.code = uint32(binary.LittleEndian.Uint16(in))<<16 | uint32(binary.LittleEndian.Uint16(in[2:]))
+	rd.in = in
+	rd.next = 4
+}
+
+// decodeBit decodes a bit using the probability entry selected by *state, which
+// holds the previous bits decoded in the same context.
+func (rd *rangeDecoder) decodeBit(state *uint32, numStates uint32, probs []probabilityEntry) uint32 {
+	p := &probs[*state]
+	*state = (*state << 1) & (numStates - 1)
+	prob := p.probability()
+
+	if rd.rng&0xffff0000 == 0 {
+		rd.rng <<= 16
+		rd.code <<= 16
+		if rd.next < len(rd.in) {
+			rd.code |= uint32(binary.LittleEndian.Uint16(rd.in[rd.next:]))
+			rd.next += 2
+		}
+	}
+
+	bound := (rd.rng >> probabilityBits) * prob
+	if rd.code < bound {
+		rd.rng = bound
+		p.update(0)
+		return 0
+	}
+	rd.rng -= bound
+	rd.code -= bound
+	p.update(1)
+	*state |= 1
+	return 1
+}
+
+// inputBitstream reads bits from the 16-bit words at the end of the compressed data,
+// reading backward. Each word is read from its most significant bit. Zeroes are
+// read once the words are exhausted.
+type inputBitstream struct {
+	buf   uint64
+	nbits uint
+	in    []byte
+	next  int
+}
+
+func (is *inputBitstream) init(in []byte) {
+	is.buf = 0
+	is.nbits = 0
+	is.in = in
+	is.next = len(in)
+}
+
+// ensure makes at least n bits, up to 32, available in the buffer.
+func (is *inputBitstream) ensure(n uint) {
+	for is.nbits < n {
+		var w uint64
+		if is.next >= 2 {
+			is.next -= 2
+			w = uint64(binary.LittleEndian.Uint16(is.in[is.next:]))
+		}
+		is.buf |= w << (64 - 16 - is.nbits)
+		is.nbits += 16
+	}
+}
+
+func (is *inputBitstream) peek(n uint) uint32 {
+	return uint32(is.buf >> (64 - n))
+}
+
+func (is *inputBitstream) remove(n uint) {
+	is.buf <<= n
+	is.nbits -= n
+}
+
+func (is *inputBitstream) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	is.ensure(n)
+	v := is.peek(n)
+	is.remove(n)
+	return v
+}
+
+// huffmanCode is an adaptive canonical Huffman code, which is rebuilt from the symbol
+// frequencies every rebuildFreq symbols.
+type huffmanCode struct {
+	rebuildFreq  int
+	untilRebuild int
+	freqs        []uint32
+	lens         []uint8
+	scratch      []uint32
+
+	// table maps the first tableBits bits of a codeword to sym<<4 | len, or zero for
+	// codewords longer than tableBits.
+	table [1 << tableBits]uint16
+	// first, count, and index describe the codewords of each length: the first
+	// codeword, the number of codewords, and the index in sorted of the first symbol.
+	first  [maxCodewordLen + 1]uint32
+	count  [maxCodewordLen + 1]uint32
+	index  [maxCodewordLen + 1]uint32
+	sorted []uint16
+}
+
+func (h *huffmanCode) init(numSyms int, rebuildFreq int) {
+	h.rebuildFreq = rebuildFreq
+	if cap(h.freqs) < numSyms {
+		h.freqs = make([]uint32, numSyms)
+		h.lens = make([]uint8, numSyms)
+		h.scratch = make([]uint32, numSyms)
+		h.sorted = make([]uint16, numSyms)
+	}
+	h.freqs = h.freqs[:numSyms]
+	h.lens = h.lens[:numSyms]
+	h.scratch = h.scratch[:numSyms]
+	h.sorted = h.sorted[:numSyms]
+	for i := range h.freqs {
+		h.freqs[i] = 1
+	}
+	h.rebuild()
+}
+
+func (h *huffmanCode) rebuild() {
+	makeCanonicalCode(h.freqs, h.lens, h.scratch)
+	h.buildDecodeTable()
+	// Halve the frequencies so that the code adapts to recent symbols.
+	for i, f := range h.freqs {
+		h.freqs[i] = f>>1 + 1
+	}
+	h.untilRebuild = h.rebuildFreq
+}
+
+// makeCanonicalCode computes the codeword lengths, limited to maxCodewordLen, of a
+// Huffman code for symbols with the nonzero frequencies freqs. The codes must match
+// the ones built by the compressor exactly, including how ties are broken and how
+// long codewords are shortened. a is scratch space of the same length as freqs.
+func makeCanonicalCode(freqs []uint32, lens []uint8, a []uint32) {
+	n := len(freqs)
+	switch n {
+	case 0:
+		return
+	case 1:
+		lens[0] = 1
+		return
+	}
+
+	// Sort the symbols primarily by frequency and secondarily by symbol value.
+	for sym, f := range freqs {
+		a[sym] = uint32(sym) | f<<numSymbolBits
+	}
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+
+	// Build the non-leaf nodes of the Huffman tree in place: each of the first n-1
+	// entries becomes a non-leaf node, holding its frequency and then the index of
+	// its parent, while the low bits keep the sorted symbols.
+	i, b, e := 0, 0, 0
+	for {
+		var m, k int
+		if i != n && (b == e || a[i]>>numSymbolBits <= a[b]>>numSymbolBits) {
+			m = i
+			i++
+		} else {
+			m = b
+			b++
+		}
+		if i != n && (b == e || a[i]>>numSymbolBits <= a[b]>>numSymbolBits) {
+			k = i
+			i++
+		} else {
+			k = b
+			b++
+		}
+		freq := a[m]&^symbolMask + a[k]&^symbolMask
+		a[m] = a[m]&symbolMask | uint32(e)<<numSymbolBits
+		a[k] = a[k]&symbolMask | uint32(e)<<numSymbolBits
+		a[e] = a[e]&symbolMask | freq
+		e++
+		if n-e <= 1 {
+			break
+		}
+	}
+
+	// Count the codewords of each length by visiting the non-leaf nodes from the root
+	// down. Each node at depth d replaces a codeword of length d with two of length
+	// d+1. Nodes deeper than allowed are moved up to the deepest length available.
+	var lenCounts [maxCodewordLen + 1]uint32
+	lenCounts[1] = 2
+	root := n - 2
+	a[root] &= symbolMask
+	for node := root - 1; node >= 0; node-- {
+		parent := a[node] >> numSymbolBits
+		depth := a[parent]>>numSymbolBits + 1
+		a[node] = a[node]&symbolMask | depth<<numSymbolBits
+		l := depth
+		if l >= maxCodewordLen {
+			l = maxCodewordLen
+			for {
+				l--
+				if lenCounts[l] != 0 {
+					break
+				}
+			}
+		}
+		lenCounts[l]--
+		lenCounts[l+1] += 2
+	}
+
+	// Assign the lengths in decreasing order to the symbols in increasing order of
+	// frequency.
+	i = 0
+	for l := maxCodewordLen; l >= 1; l-- {
+		for c := lenCounts[l]; c > 0; c-- {
+			lens[a[i]&symbolMask] = uint8(l)
+			i++
+		}
+	}
+}
+
+func (h *huffmanCode) buildDecodeTable() {
+	h.count = [maxCodewordLen + 1]uint32{}
+	for _, l := range h.lens {
+		h.count[l]++
+	}
+	h.count[0] = 0
+	var code, index uint32
+	for l := 1; l <= maxCodewordLen; l++ {
+		code = (code + h.count[l-1]) << 1
+		h.first[l] = code
+		h.index[l] = index
+		index += h.count[l]
+	}
+
+	h.table = [1 << tableBits]uint16{}
+	next := h.first
+	offs := h.index
+	for sym, l := range h.lens {
+		if l == 0 {
+			continue
+		}
+		c := next[l]
+		next[l]++
+		h.sorted[offs[l]] = uint16(sym)
+		offs[l]++
+		if l <= tableBits {
+			e := uint16(sym)<<4 | uint16(l)
+			for j := c << (tableBits - l); j < (c+1)<<(tableBits-l); j++ {
+				h.table[j] = e
+			}
+		}
+	}
+}
+
+// decode decodes a symbol from is and updates the code.
+func (h *huffmanCode) decode(is *inputBitstream) (uint32, error) {
+	is.ensure(maxCodewordLen)
+	v := is.peek(maxCodewordLen)
+	var sym uint32
+	if e := h.table[v>>(maxCodewordLen-tableBits)]; e != 0 {
+		is.remove(uint(e & 0xf))
+		sym = uint32(e >> 4)
+	} else {
+		l := uint(tableBits + 1)
+		for ; l <= maxCodewordLen; l++ {
+			if c := v>>(maxCodewordLen-l) - h.first[l]; c < h.count[l] {
+				sym = uint32(h.sorted[h.index[l]+c])
+				break
+			}
+		}
+		if l > maxCodewordLen {
+			return 0, errCorrupt
+		}
+		is.remove(l)
+	}
+	h.freqs[sym]++
+	h.untilRebuild--
+	if h.untilRebuild == 0 {
+		h.rebuild()
+	}
+	return sym, nil
+}
+
+type decompressor struct {
+	rd rangeDecoder
+	is inputBitstream
+
+	mainProbs     [numMainProbs]probabilityEntry
+	matchProbs    [numMatchProbs]probabilityEntry
+	lzProbs       [numLZProbs]probabilityEntry
+	lzRepProbs    [numLZReps - 1][numLZRepProbs]probabilityEntry
+	deltaProbs    [numDeltaProbs]probabilityEntry
+	deltaRepProbs [numDeltaReps - 1][numDeltaRepProbs]probabilityEntry
+
+	literalCode     huffmanCode
+	lzOffsetCode    huffmanCode
+	lengthCode      huffmanCode
+	deltaOffsetCode huffmanCode
+	deltaPowerCode  huffmanCode
+
+	lastTargetUsages [65536]int32
+}
+
+var decompressors = sync.Pool{
+	New: func() interface{} { return new(decompressor) },
+}
+
+// Decompress decompresses the LZMS data in src into dst, which must have the exact
+// uncompressed size.
+func Decompress(dst, src []byte) error {
+	if len(src)%2 != 0 || len(src) < 4 || len(dst) > MaxSize {
+		return errCorrupt
+	}
+	d := decompressors.Get().(*decompressor)
+	defer decompressors.Put(d)
+	if err := d.decompress(dst, src); err != nil {
+		return err
+	}
+	d.undoX86Filter(dst)
+	return nil
+}
+
+func (d *decompressor) decompress(dst, src []byte) error {
+	d.rd.init(src)
+	d.is.init(src)
+
+	initProbabilities(d.mainProbs[:])
+	initProbabilities(d.matchProbs[:])
+	initProbabilities(d.lzProbs[:])
+	for i := range d.lzRepProbs {
+		initProbabilities(d.lzRepProbs[i][:])
+	}
+	initProbabilities(d.deltaProbs[:])
+	for i := range d.deltaRepProbs {
+		initProbabilities(d.deltaRepProbs[i][:])
+	}
+
+	numOffsetSyms := numOffsetSlots(len(dst))
+	d.literalCode.init(numLiteralSyms, literalRebuildFreq)
+	d.lzOffsetCode.init(numOffsetSyms, lzOffsetRebuildFreq)
+	d.lengthCode.init(numLengthSyms, lengthRebuildFreq)
+	d.deltaOffsetCode.init(numOffsetSyms, deltaOffsetRebuildFreq)
+	d.deltaPowerCode.init(numDeltaPowerSyms, deltaPowerRebuildFreq)
+
+	var (
+		mainState, matchState, lzState, deltaState uint32
+		lzRepStates                                [numLZReps - 1]uint32
+		deltaRepStates                             [numDeltaReps - 1]uint32
+
+		// The most recent match offsets, plus an extra slot for the shifts. A
+		// match's offset only becomes available for repeat matches after the next
+		// item has been decoded, so it is held in prev until then.
+		lzReps         = [numLZReps + 1]uint32{1, 2, 3, 4}
+		deltaReps      = [numDeltaReps + 1]uint32{1, 2, 3, 4}
+		deltaRepPowers [numDeltaReps + 1]uint32
+		prevLZ         uint32
+		prevDelta      uint32
+		prevDeltaPower uint32
+	)
+
+	out := 0
+	for out < len(dst) {
+		var upcomingLZ, upcomingDelta, upcomingDeltaPower uint32
+		if d.rd.decodeBit(&mainState, numMainProbs, d.mainProbs[:]) == 0 {
+			// Literal
+			sym, err := d.literalCode.decode(&d.is)
+			if err != nil {
+				return err
+			}
+			dst[out] = byte(sym)
+			out++
+		} else if d.rd.decodeBit(&matchState, numMatchProbs, d.matchProbs[:]) == 0 {
+			// LZ match
+			var offset uint32
+			if d.rd.decodeBit(&lzState, numLZProbs, d.lzProbs[:]) == 0 {
+				var err error
+				if offset, err = d.decodeValue(&d.lzOffsetCode, offsetSlotBase[:], offsetExtraBits[:]); err != nil {
+					return err
+				}
+			} else {
+				i := 0
+				for ; i < numLZReps-1; i++ {
+					if d.rd.decodeBit(&lzRepStates[i], numLZRepProbs, d.lzRepProbs[i][:]) == 0 {
+						break
+					}
+				}
+				offset = lzReps[i]
+				for ; i < numLZReps; i++ {
+					lzReps[i] = lzReps[i+1]
+				}
+			}
+			length, err := d.decodeValue(&d.lengthCode, lengthSlotBase[:], lengthExtraBits[:])
+			if err != nil {
+				return err
+			}
+			if int(offset) > out || int(length) > len(dst)-out {
+				return errCorrupt
+			}
+			// The source and destination may overlap.
+			for end := out + int(length); out < end; out++ {
+				dst[out] = dst[out-int(offset)]
+			}
+			upcomingLZ = offset
+		} else {
+			// Delta match
+			var power, rawOffset uint32
+			if d.rd.decodeBit(&deltaState, numDeltaProbs, d.deltaProbs[:]) == 0 {
+				var err error
+				if power, err = d.deltaPowerCode.decode(&d.is); err != nil {
+					return err
+				}
+				if rawOffset, err = d.decodeValue(&d.deltaOffsetCode, offsetSlotBase[:], offsetExtraBits[:]); err != nil {
+					return err
+				}
+			} else {
+				i := 0
+				for ; i < numDeltaReps-1; i++ {
+					if d.rd.decodeBit(&deltaRepStates[i], numDeltaRepProbs, d.deltaRepProbs[i][:]) == 0 {
+						break
+					}
+				}
+				rawOffset = deltaReps[i]
+				power = deltaRepPowers[i]
+				for ; i < numDeltaReps; i++ {
+					deltaReps[i] = deltaReps[i+1]
+					deltaRepPowers[i] = deltaRepPowers[i+1]
+				}
+			}
+			length, err := d.decodeValue(&d.lengthCode, lengthSlotBase[:], lengthExtraBits[:])
+			if err != nil {
+				return err
+			}
+
+			// Each byte is predicted from the bytes offset1 and offset2 back, and the
+			// difference to the prediction is taken from the byte offset1+offset2 back.
+			offset1 := uint32(1) << power
+			offset2 := rawOffset << power
+			offset := offset1 + offset2
+			if offset2>>power != rawOffset || offset < offset2 ||
+				int64(offset) > int64(out) || int(length) > len(dst)-out {
+				return errCorrupt
+			}
+			for end := out + int(length); out < end; out++ {
+				dst[out] = dst[out-int(offset1)] + dst[out-int(offset2)] - dst[out-int(offset)]
+			}
+			upcomingDelta = rawOffset
+			upcomingDeltaPower = power
+		}
+
+		if prevLZ != 0 {
+			for i := numLZReps - 1; i >= 0; i-- {
+				lzReps[i+1] = lzReps[i]
+			}
+			lzReps[0] = prevLZ
+		}
+		prevLZ = upcomingLZ
+
+		if prevDelta != 0 {
+			for i := numDeltaReps - 1; i >= 0; i-- {
+				deltaReps[i+1] = deltaReps[i]
+				deltaRepPowers[i+1] = deltaRepPowers[i]
+			}
+			deltaReps[0] = prevDelta
+			deltaRepPowers[0] = prevDeltaPower
+		}
+		prevDelta = upcomingDelta
+		prevDeltaPower = upcomingDeltaPower
+	}
+	return nil
+}
+
+// decodeValue decodes a slot with h, followed by the extra bits for the value within
+// the slot.
+func (d *decompressor) decodeValue(h *huffmanCode, base []uint32, extraBits []uint8) (uint32, error) {
+	slot, err := h.decode(&d.is)
+	if err != nil {
+		return 0, err
+	}
+	return base[slot] + d.is.read(uint(extraBits[slot])), nil
+}
+
+// undoX86Filter converts the absolute addresses in likely x86 instructions, which the
+// compressor translated from relative ones to make them more compressible, back to
+// relative addresses.
+//
+// An instruction is only translated within x86MaxTranslationOffset bytes (or half that
+// for calls) of the last instruction that referenced the same target, identified by
+// the low 16 bits of its address, as another instruction within x86IDWindowSize bytes.
+func (d *decompressor) undoX86Filter(data []byte) {
+	for i := range d.lastTargetUsages {
+		d.lastTargetUsages[i] = -x86IDWindowSize - 1
+	}
+	closestTargetUsage := int32(-x86MaxTranslationOffset - 1)
+
+	for i := 0; i < len(data)-16; {
+		maxTranslationOffset := int32(x86MaxTranslationOffset)
+		opcodeLen := 0
+		switch data[i] {
+		case 0x48, 0x4c:
+			// Load (x86-64 only), or load effective address, relative
+			if data[i+2]&0x07 == 0x05 &&
+				(data[i+1] == 0x8d || (data[i+1] == 0x8b && data[i]&0x04 == 0 && data[i+2]&0xf0 == 0)) {
+				opcodeLen = 3
+			}
+		case 0xe8:
+			// Call relative, which is common enough to require more confidence.
+			opcodeLen = 1
+			maxTranslationOffset >>= 1
+		case 0xe9:
+			// Jump relative, which is never translated.
+			i += 5
+			continue
+		case 0xf0:
+			// Lock add relative
+			if data[i+1] == 0x83 && data[i+2] == 0x05 {
+				opcodeLen = 3
+			}
+		case 0xff:
+			// Call indirect relative
+			if data[i+1] == 0x15 {
+				opcodeLen = 2
+			}
+		}
+		if opcodeLen == 0 {
+			i++
+			continue
+		}
+
+		pos := int32(i)
+		p := data[i+opcodeLen:]
+		if pos-closestTargetUsage <= maxTranslationOffset {
+			binary.LittleEndian.PutUint32(p, binary.LittleEndian.Uint32(p)-uint32(pos))
+		}
+		target := uint16(pos) + binary.LittleEndian.Uint16(p)
+
+		pos += int32(opcodeLen) + 4 - 1
+		if pos-d.lastTargetUsages[target] <= x86IDWindowSize {
+			closestTargetUsage = pos
+		}
+		d.lastTargetUsages[target] = pos
+		i += opcodeLen + 4
+	}
+}
+
+// NewReader returns a new io.ReadCloser that decompresses the LZMS data read from r,
+// which is uncompressedSize bytes when decompressed. Since LZMS data is read from both
+// ends, all of r is read and decompressed immediately.
+func NewReader(r io.Reader, uncompressedSize int) (io.ReadCloser, error) {
+	if uncompressedSize > MaxSize {
+		return nil, errors.New("uncompressed size is limited to 1GB")
+	}
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	dst := make([]byte, uncompressedSize)
+	if err := Decompress(dst, src); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(dst)), nil
+}
@@ -0,0 +1,33 @@
+//go:build go1.18
+
+package lzms
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// FuzzDecompress checks that the decompressor rejects arbitrary data without panicking.
+// The first two bytes of the input are the uncompressed size.
+func FuzzDecompress(f *testing.F) {
+	seed := func(size int, src []byte) {
+		f.Add(append([]byte{byte(size), byte(size >> 8)}, src...))
+	}
+	seed(0, []byte{0, 0, 0, 0})
+	seed(5, compressLiterals([]byte("winio")))
+	seed(100, compressLiterals(noX86(100)))
+	e := newEncoder(20)
+	for _, c := range []byte("winio") {
+		e.literal(c)
+	}
+	e.lzMatch(5, 15)
+	seed(20, e.finish())
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		if len(b) < 2 {
+			return
+		}
+		dst := make([]byte, binary.LittleEndian.Uint16(b))
+		_ = Decompress(dst, b[2:])
+	})
+}
@@ -0,0 +1,348 @@
+package lzms
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// The encoder below produces the simplest valid LZMS streams: literals, and LZ and delta
+// matches with explicit offsets. It follows the compressor in wimlib, and shares the
+// adaptive Huffman codes and probabilities with the decompressor, so that they stay in
+// sync.
+
+// rangeEncoder is the counterpart of rangeDecoder, writing 16-bit words forward.
+type rangeEncoder struct {
+	low       uint64
+	rng       uint32
+	cache     uint16
+	cacheSize int
+	out       []uint16
+}
+
+func (rc *rangeEncoder) init() {
+	*rc = rangeEncoder{rng: 0xffffffff, cacheSize: 1}
+}
+
+func (rc *rangeEncoder) shiftLow() {
+	if uint32(rc.low) < 0xffff0000 || rc.low>>32 != 0 {
+		carry := uint16(rc.low >> 32)
+		c := rc.cache
+		for {
+			rc.out = append(rc.out, c+carry)
+			c = 0xffff
+			rc.cacheSize--
+			if rc.cacheSize == 0 {
+				break
+			}
+		}
+		rc.cache = uint16(rc.low >> 16)
+	}
+	rc.cacheSize++
+	rc.low = (rc.low & 0xffff) << 16
+}
+
+func (rc *rangeEncoder) encodeBit(bit uint32, state *uint32, numStates uint32, probs []probabilityEntry) {
+	p := &probs[*state]
+	*state = (*state << 1) & (numStates - 1)
+	prob := p.probability()
+
+	if rc.rng <= 0xffff {
+		rc.rng <<= 16
+		rc.shiftLow()
+	}
+	bound := (rc.rng >> probabilityBits) * prob
+	if bit == 0 {
+		rc.rng = bound
+	} else {
+		rc.low += uint64(bound)
+		rc.rng -= bound
+	}
+	p.update(bit)
+	*state |= bit
+}
+
+// finish flushes the encoder and returns its words, without the first word written,
+// which is always zero.
+func (rc *rangeEncoder) finish() []uint16 {
+	for i := 0; i < 4; i++ {
+		rc.shiftLow()
+	}
+	return rc.out[1:]
+}
+
+// outputBitstream is the counterpart of inputBitstream. Its words are stored in the order
+// they are written, which is the reverse of their order in the compressed data.
+type outputBitstream struct {
+	buf   uint64
+	nbits uint
+	out   []uint16
+}
+
+func (bs *outputBitstream) write(v uint32, n uint) {
+	bs.buf = bs.buf<<n | uint64(v)
+	bs.nbits += n
+	for bs.nbits >= 16 {
+		bs.nbits -= 16
+		bs.out = append(bs.out, uint16(bs.buf>>bs.nbits))
+	}
+}
+
+func (bs *outputBitstream) finish() []uint16 {
+	if bs.nbits != 0 {
+		bs.out = append(bs.out, uint16(bs.buf<<(16-bs.nbits)))
+	}
+	return bs.out
+}
+
+// encode writes the codeword of sym and updates the code, as decode does.
+func (h *huffmanCode) encode(bs *outputBitstream, sym uint32) {
+	l := h.lens[sym]
+	code := h.first[l]
+	for _, sl := range h.lens[:sym] {
+		if sl == l {
+			code++
+		}
+	}
+	bs.write(code, uint(l))
+	h.freqs[sym]++
+	h.untilRebuild--
+	if h.untilRebuild == 0 {
+		h.rebuild()
+	}
+}
+
+type encoder struct {
+	d  decompressor // for its probabilities and codes
+	rc rangeEncoder
+	bs outputBitstream
+
+	mainState, matchState, lzState, deltaState uint32
+}
+
+func newEncoder(size int) *encoder {
+	e := &encoder{}
+	e.rc.init()
+	d := &e.d
+	initProbabilities(d.mainProbs[:])
+	initProbabilities(d.matchProbs[:])
+	initProbabilities(d.lzProbs[:])
+	initProbabilities(d.deltaProbs[:])
+	numOffsetSyms := numOffsetSlots(size)
+	d.literalCode.init(numLiteralSyms, literalRebuildFreq)
+	d.lzOffsetCode.init(numOffsetSyms, lzOffsetRebuildFreq)
+	d.lengthCode.init(numLengthSyms, lengthRebuildFreq)
+	d.deltaOffsetCode.init(numOffsetSyms, deltaOffsetRebuildFreq)
+	d.deltaPowerCode.init(numDeltaPowerSyms, deltaPowerRebuildFreq)
+	return e
+}
+
+func (e *encoder) value(h *huffmanCode, base []uint32, extraBits []uint8, v uint32) {
+	slot := sort.Search(len(extraBits), func(i int) bool { return base[i+1] > v })
+	h.encode(&e.bs, uint32(slot))
+	e.bs.write(v-base[slot], uint(extraBits[slot]))
+}
+
+func (e *encoder) literal(b byte) {
+	e.rc.encodeBit(0, &e.mainState, numMainProbs, e.d.mainProbs[:])
+	e.d.literalCode.encode(&e.bs, uint32(b))
+}
+
+func (e *encoder) lzMatch(offset, length uint32) {
+	e.rc.encodeBit(1, &e.mainState, numMainProbs, e.d.mainProbs[:])
+	e.rc.encodeBit(0, &e.matchState, numMatchProbs, e.d.matchProbs[:])
+	e.rc.encodeBit(0, &e.lzState, numLZProbs, e.d.lzProbs[:])
+	e.value(&e.d.lzOffsetCode, offsetSlotBase[:], offsetExtraBits[:], offset)
+	e.value(&e.d.lengthCode, lengthSlotBase[:], lengthExtraBits[:], length)
+}
+
+func (e *encoder) deltaMatch(power, rawOffset, length uint32) {
+	e.rc.encodeBit(1, &e.mainState, numMainProbs, e.d.mainProbs[:])
+	e.rc.encodeBit(1, &e.matchState, numMatchProbs, e.d.matchProbs[:])
+	e.rc.encodeBit(0, &e.deltaState, numDeltaProbs, e.d.deltaProbs[:])
+	e.d.deltaPowerCode.encode(&e.bs, power)
+	e.value(&e.d.deltaOffsetCode, offsetSlotBase[:], offsetExtraBits[:], rawOffset)
+	e.value(&e.d.lengthCode, lengthSlotBase[:], lengthExtraBits[:], length)
+}
+
+func (e *encoder) finish() []byte {
+	rw := e.rc.finish()
+	bw := e.bs.finish()
+	b := make([]byte, 0, 2*(len(rw)+len(bw)))
+	for _, w := range rw {
+		b = append(b, byte(w), byte(w>>8))
+	}
+	for i := len(bw) - 1; i >= 0; i-- {
+		b = append(b, byte(bw[i]), byte(bw[i]>>8))
+	}
+	return b
+}
+
+// compressLiterals compresses b as literals only.
+func compressLiterals(b []byte) []byte {
+	e := newEncoder(len(b))
+	for _, c := range b {
+		e.literal(c)
+	}
+	return e.finish()
+}
+
+// noX86 returns n pseudo-random bytes that do not contain the opcodes translated by the x86
+// filter, which the encoder does not apply.
+func noX86(n int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(rng.Intn(0x40))
+	}
+	return b
+}
+
+func mustDecodeHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestDecompressKnownAnswer decompresses fixed streams, to detect changes to how the
+// range decoder, bitstream, and Huffman codes interpret the compressed data.
+func TestDecompressKnownAnswer(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		compressed string
+		want       []byte
+	}{
+		{
+			name:       "empty",
+			compressed: "000000000000",
+			want:       []byte{},
+		},
+		{
+			name:       "literals",
+			compressed: "000000000000006f696e6977",
+			want:       []byte("winio"),
+		},
+		{
+			name:       "lz match",
+			compressed: "8f2dd0ff00000080e86f696e6977",
+			want:       []byte("winiowiniowiniowinio"),
+		},
+		{
+			name:       "delta match",
+			compressed: "b62ad0ff0000c81c050403020100",
+			want:       []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			src := mustDecodeHex(t, tt.compressed)
+			dst := make([]byte, len(tt.want))
+			if err := Decompress(dst, src); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(dst, tt.want) {
+				t.Fatalf("got %x, want %x", dst, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecompressLiterals(t *testing.T) {
+	// long enough for the literal code to be rebuilt several times
+	want := noX86(5000)
+	dst := make([]byte, len(want))
+	if err := Decompress(dst, compressLiterals(want)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, want) {
+		t.Fatal("unexpected decompressed data")
+	}
+}
+
+func TestDecompressMatches(t *testing.T) {
+	want := noX86(3000)
+	e := newEncoder(len(want))
+	i := 0
+	for i < len(want) {
+		switch {
+		case i >= 1000 && i%250 == 0:
+			// copy a run from earlier in the buffer
+			copy(want[i:i+100], want[i-900:])
+			e.lzMatch(900, 100)
+			i += 100
+		case i >= 1000 && i%250 == 150:
+			// a delta match with power 1, so each byte is predicted from the bytes 2
+			// and 4 back, and the one 6 back
+			for j := i; j < i+50; j++ {
+				want[j] = want[j-2] + want[j-4] - want[j-6]
+			}
+			e.deltaMatch(1, 2, 50)
+			i += 50
+		default:
+			e.literal(want[i])
+			i++
+		}
+	}
+	dst := make([]byte, len(want))
+	if err := Decompress(dst, e.finish()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, want) {
+		t.Fatal("unexpected decompressed data")
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	valid := compressLiterals([]byte("winio"))
+
+	badOffset := newEncoder(8)
+	badOffset.literal('a')
+	badOffset.lzMatch(2, 7)
+
+	longMatch := newEncoder(4)
+	longMatch.literal('a')
+	longMatch.lzMatch(1, 7)
+
+	for _, tt := range []struct {
+		name string
+		src  []byte
+		size int
+	}{
+		{"too short", []byte{0, 0}, 0},
+		{"odd length", valid[:len(valid)-1], 5},
+		{"offset before start", badOffset.finish(), 8},
+		{"match past end", longMatch.finish(), 4},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Decompress(make([]byte, tt.size), tt.src); !errors.Is(err, errCorrupt) {
+				t.Fatalf("expected %v, got %v", errCorrupt, err)
+			}
+		})
+	}
+}
+
+func TestNewReader(t *testing.T) {
+	want := noX86(100)
+	r, err := NewReader(bytes.NewReader(compressLiterals(want)), len(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, want) {
+		t.Fatal("unexpected decompressed data")
+	}
+
+	if _, err := NewReader(bytes.NewReader(nil), MaxSize+1); err == nil {
+		t.Fatal("expected an error for a size larger than MaxSize")
+	}
+}
@@ -0,0 +1,20 @@
+//go:build linux
+// +build linux
+
+package wim
+
+import (
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of f into memory for reading.
+func mapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	b, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("mmap", err)
+	}
+	return b, func() error {
+		return os.NewSyscallError("munmap", syscall.Munmap(b))
+	}, nil
+}
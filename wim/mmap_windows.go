@@ -0,0 +1,31 @@
+//go:build windows
+// +build windows
+
+package wim
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// mapFile maps the first size bytes of f into memory for reading.
+func mapFile(f *os.File, size int64) ([]byte, func() error, error) {
+	m, err := windows.CreateFileMapping(windows.Handle(f.Fd()), nil, windows.PAGE_READONLY, uint32(size>>32), uint32(size), nil)
+	if err != nil {
+		return nil, nil, os.NewSyscallError("CreateFileMapping", err)
+	}
+	// the view keeps the mapping object alive
+	defer windows.CloseHandle(m) //nolint:errcheck
+
+	addr, err := windows.MapViewOfFile(m, windows.FILE_MAP_READ, 0, 0, uintptr(size))
+	if err != nil {
+		return nil, nil, os.NewSyscallError("MapViewOfFile", err)
+	}
+	// convert addr without a uintptr-to-pointer conversion, since it points outside the Go heap
+	b := unsafe.Slice(*(**byte)(unsafe.Pointer(&addr)), int(size))
+	return b, func() error {
+		return os.NewSyscallError("UnmapViewOfFile", windows.UnmapViewOfFile(addr))
+	}, nil
+}
@@ -0,0 +1,407 @@
+//go:build windows || linux
+// +build windows linux
+
+package wim
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// noSecurityID is the security ID of directory entries without a security descriptor.
+const noSecurityID = 0xffffffff
+
+// securityDescriptors returns the image's security descriptor table, loading it on first use.
+func (img *Image) securityDescriptors() ([][]byte, error) {
+	img.sdLock.Lock()
+	defer img.sdLock.Unlock()
+
+	if img.sds == nil {
+		rsrc, err := img.wim.resourceReader(&img.offset)
+		if err != nil {
+			return nil, err
+		}
+		defer rsrc.Close()
+		sds, _, err := img.wim.readSecurityDescriptors(rsrc)
+		if err != nil {
+			return nil, err
+		}
+		img.sds = sds
+	}
+	return img.sds, nil
+}
+
+// RawSecurityDescriptor returns the file's security descriptor, in self-relative format, or nil
+// if the file has none. With [ReaderOptions.LazySecurityDescriptors], the image's security
+// descriptors are read the first time this, or [File.SecurityDescriptorSDDL], is called for
+// one of its files.
+func (f *File) RawSecurityDescriptor() ([]byte, error) {
+	if f.securityID == noSecurityID {
+		return nil, nil
+	}
+	sds, err := f.img.securityDescriptors()
+	if err != nil {
+		return nil, err
+	}
+	if int64(f.securityID) >= int64(len(sds)) {
+		return nil, &ParseError{Oper: "security descriptor", Path: f.Name, Err: fmt.Errorf("invalid security ID %d", f.securityID)}
+	}
+	return sds[f.securityID], nil
+}
+
+// SecurityDescriptorSDDL returns the file's security descriptor in the Security Descriptor
+// Definition Language, or "" if the file has none.
+//
+// The descriptor is formatted without Windows APIs, so the result is the same on all
+// platforms. Well-known SIDs and common access masks are written with their SDDL aliases,
+// and others numerically, which Windows accepts when parsing the string.
+func (f *File) SecurityDescriptorSDDL() (string, error) {
+	sd, err := f.RawSecurityDescriptor()
+	if err != nil || sd == nil {
+		return "", err
+	}
+	s, err := formatSDDL(sd)
+	if err != nil {
+		return "", &ParseError{Oper: "security descriptor", Path: f.Name, Err: err}
+	}
+	return s, nil
+}
+
+// Security descriptor control flags.
+const (
+	seDACLPresent          = 0x0004
+	seSACLPresent          = 0x0010
+	seDACLAutoInheritReq   = 0x0100
+	seSACLAutoInheritReq   = 0x0200
+	seDACLAutoInherited    = 0x0400
+	seSACLAutoInherited    = 0x0800
+	seDACLProtected        = 0x1000
+	seSACLProtected        = 0x2000
+	securityDescriptorSize = 20
+	aclHeaderSize          = 8
+)
+
+var errMalformedSD = errors.New("malformed security descriptor")
+
+// formatSDDL converts a self-relative security descriptor to SDDL.
+func formatSDDL(sd []byte) (string, error) {
+	if len(sd) < securityDescriptorSize || sd[0] != 1 {
+		return "", errMalformedSD
+	}
+	control := binary.LittleEndian.Uint16(sd[2:])
+	owner := binary.LittleEndian.Uint32(sd[4:])
+	group := binary.LittleEndian.Uint32(sd[8:])
+	sacl := binary.LittleEndian.Uint32(sd[12:])
+	dacl := binary.LittleEndian.Uint32(sd[16:])
+
+	var b strings.Builder
+	if owner != 0 {
+		sid, err := formatSIDAt(sd, owner)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString("O:" + sid)
+	}
+	if group != 0 {
+		sid, err := formatSIDAt(sd, group)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString("G:" + sid)
+	}
+	if control&seDACLPresent != 0 {
+		b.WriteString("D:")
+		err := formatACL(&b, sd, dacl,
+			control&seDACLProtected != 0, control&seDACLAutoInheritReq != 0, control&seDACLAutoInherited != 0)
+		if err != nil {
+			return "", err
+		}
+	}
+	if control&seSACLPresent != 0 {
+		b.WriteString("S:")
+		err := formatACL(&b, sd, sacl,
+			control&seSACLProtected != 0, control&seSACLAutoInheritReq != 0, control&seSACLAutoInherited != 0)
+		if err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
+
+// ACE types.
+const (
+	aceTypeAccessAllowed       = 0x0
+	aceTypeAccessDenied        = 0x1
+	aceTypeSystemAudit         = 0x2
+	aceTypeSystemAlarm         = 0x3
+	aceTypeAccessAllowedObject = 0x5
+	aceTypeAccessDeniedObject  = 0x6
+	aceTypeSystemAuditObject   = 0x7
+	aceTypeSystemAlarmObject   = 0x8
+	aceTypeMandatoryLabel      = 0x11
+	aceTypeScopedPolicyID      = 0x13
+)
+
+var aceTypeNames = map[byte]string{
+	aceTypeAccessAllowed:       "A",
+	aceTypeAccessDenied:        "D",
+	aceTypeSystemAudit:         "AU",
+	aceTypeSystemAlarm:         "AL",
+	aceTypeAccessAllowedObject: "OA",
+	aceTypeAccessDeniedObject:  "OD",
+	aceTypeSystemAuditObject:   "OU",
+	aceTypeSystemAlarmObject:   "OL",
+	aceTypeMandatoryLabel:      "ML",
+	aceTypeScopedPolicyID:      "SP",
+}
+
+// aceFlagNames are the SDDL names of the ACE flags, in the order Windows writes them.
+var aceFlagNames = []struct {
+	flag byte
+	name string
+}{
+	{0x01, "OI"},
+	{0x02, "CI"},
+	{0x04, "NP"},
+	{0x08, "IO"},
+	{0x10, "ID"},
+	{0x40, "SA"},
+	{0x80, "FA"},
+}
+
+// formatACL writes the ACL at offset off of sd, preceded by its SDDL flags. An offset of zero
+// is a NULL ACL.
+func formatACL(b *strings.Builder, sd []byte, off uint32, protected, autoInheritReq, autoInherited bool) error {
+	if protected {
+		b.WriteString("P")
+	}
+	if autoInheritReq {
+		b.WriteString("AR")
+	}
+	if autoInherited {
+		b.WriteString("AI")
+	}
+	if off == 0 {
+		b.WriteString("NO_ACCESS_CONTROL")
+		return nil
+	}
+	if uint64(off)+aclHeaderSize > uint64(len(sd)) {
+		return errMalformedSD
+	}
+	size := int(binary.LittleEndian.Uint16(sd[off+2:]))
+	count := int(binary.LittleEndian.Uint16(sd[off+4:]))
+	if int(off)+size > len(sd) {
+		return errMalformedSD
+	}
+	acl := sd[off : int(off)+size]
+	p := aclHeaderSize
+	for i := 0; i < count; i++ {
+		if p+4 > len(acl) {
+			return errMalformedSD
+		}
+		n := int(binary.LittleEndian.Uint16(acl[p+2:]))
+		if n < 8 || p+n > len(acl) {
+			return errMalformedSD
+		}
+		if err := formatACE(b, acl[p:p+n]); err != nil {
+			return err
+		}
+		p += n
+	}
+	return nil
+}
+
+// formatACE writes an ACE as "(type;flags;rights;object_guid;inherit_object_guid;account_sid)".
+func formatACE(b *strings.Builder, ace []byte) error {
+	typ, flags := ace[0], ace[1]
+	name, ok := aceTypeNames[typ]
+	if !ok {
+		return fmt.Errorf("unsupported ACE type %#x", typ)
+	}
+	mask := binary.LittleEndian.Uint32(ace[4:])
+	body := ace[8:]
+
+	var objectType, inheritedObjectType string
+	switch typ {
+	case aceTypeAccessAllowedObject, aceTypeAccessDeniedObject, aceTypeSystemAuditObject, aceTypeSystemAlarmObject:
+		if len(body) < 4 {
+			return errMalformedSD
+		}
+		objFlags := binary.LittleEndian.Uint32(body)
+		body = body[4:]
+		for _, x := range []struct {
+			flag uint32
+			s    *string
+		}{{1, &objectType}, {2, &inheritedObjectType}} {
+			if objFlags&x.flag == 0 {
+				continue
+			}
+			if len(body) < 16 {
+				return errMalformedSD
+			}
+			g := guid{
+				Data1: binary.LittleEndian.Uint32(body),
+				Data2: binary.LittleEndian.Uint16(body[4:]),
+				Data3: binary.LittleEndian.Uint16(body[6:]),
+			}
+			copy(g.Data4[:], body[8:16])
+			*x.s = g.String()
+			body = body[16:]
+		}
+	}
+	sid, err := formatSID(body)
+	if err != nil {
+		return err
+	}
+
+	b.WriteString("(" + name + ";")
+	for _, f := range aceFlagNames {
+		if flags&f.flag != 0 {
+			b.WriteString(f.name)
+		}
+	}
+	b.WriteString(";" + formatAccessMask(typ, mask) + ";")
+	b.WriteString(objectType + ";" + inheritedObjectType + ";" + sid + ")")
+	return nil
+}
+
+// accessMaskAliases are the SDDL aliases of complete file access masks.
+var accessMaskAliases = map[uint32]string{
+	0x1f01ff: "FA",
+	0x120089: "FR",
+	0x120116: "FW",
+	0x1200a0: "FX",
+}
+
+// accessRightNames are the SDDL names of individual access rights.
+var accessRightNames = []struct {
+	right uint32
+	name  string
+}{
+	{0x10000000, "GA"},
+	{0x80000000, "GR"},
+	{0x40000000, "GW"},
+	{0x20000000, "GX"},
+	{0x00020000, "RC"},
+	{0x00010000, "SD"},
+	{0x00040000, "WD"},
+	{0x00080000, "WO"},
+	{0x00000001, "CC"},
+	{0x00000002, "DC"},
+	{0x00000004, "LC"},
+	{0x00000008, "SW"},
+	{0x00000010, "RP"},
+	{0x00000020, "WP"},
+	{0x00000040, "DT"},
+	{0x00000080, "LO"},
+	{0x00000100, "CR"},
+}
+
+// mandatoryLabelRightNames are the SDDL names of the mandatory label policies.
+var mandatoryLabelRightNames = []struct {
+	right uint32
+	name  string
+}{
+	{0x1, "NW"},
+	{0x2, "NR"},
+	{0x4, "NX"},
+}
+
+// formatAccessMask formats mask as an alias or a combination of access right names, if
+// possible, and as a hexadecimal number otherwise.
+func formatAccessMask(typ byte, mask uint32) string {
+	names := accessRightNames
+	if typ == aceTypeMandatoryLabel {
+		names = mandatoryLabelRightNames
+	} else if s, ok := accessMaskAliases[mask]; ok {
+		return s
+	}
+	var s string
+	left := mask
+	for _, r := range names {
+		if left&r.right != 0 {
+			s += r.name
+			left &^= r.right
+		}
+	}
+	if left != 0 || mask == 0 {
+		return fmt.Sprintf("%#x", mask)
+	}
+	return s
+}
+
+// sidAliases are the SDDL aliases of well-known SIDs.
+var sidAliases = map[string]string{
+	"S-1-1-0":      "WD",
+	"S-1-3-0":      "CO",
+	"S-1-3-1":      "CG",
+	"S-1-3-4":      "OW",
+	"S-1-5-2":      "NU",
+	"S-1-5-4":      "IU",
+	"S-1-5-6":      "SU",
+	"S-1-5-7":      "AN",
+	"S-1-5-9":      "ED",
+	"S-1-5-10":     "PS",
+	"S-1-5-11":     "AU",
+	"S-1-5-12":     "RC",
+	"S-1-5-18":     "SY",
+	"S-1-5-19":     "LS",
+	"S-1-5-20":     "NS",
+	"S-1-5-32-544": "BA",
+	"S-1-5-32-545": "BU",
+	"S-1-5-32-546": "BG",
+	"S-1-5-32-547": "PU",
+	"S-1-5-32-548": "AO",
+	"S-1-5-32-549": "SO",
+	"S-1-5-32-550": "PO",
+	"S-1-5-32-551": "BO",
+	"S-1-5-32-552": "RE",
+	"S-1-5-32-554": "RU",
+	"S-1-5-32-555": "RD",
+	"S-1-5-32-556": "NO",
+	"S-1-15-2-1":   "AC",
+	"S-1-16-4096":  "LW",
+	"S-1-16-8192":  "ME",
+	"S-1-16-8448":  "MP",
+	"S-1-16-12288": "HI",
+	"S-1-16-16384": "SI",
+}
+
+// formatSIDAt formats the SID at offset off of sd.
+func formatSIDAt(sd []byte, off uint32) (string, error) {
+	if uint64(off) >= uint64(len(sd)) {
+		return "", errMalformedSD
+	}
+	return formatSID(sd[off:])
+}
+
+// formatSID formats the SID at the start of b, using its SDDL alias if it has one.
+func formatSID(b []byte) (string, error) {
+	if len(b) < 8 || b[0] != 1 {
+		return "", errMalformedSD
+	}
+	count := int(b[1])
+	if len(b) < 8+4*count {
+		return "", errMalformedSD
+	}
+	var auth uint64
+	for _, c := range b[2:8] {
+		auth = auth<<8 | uint64(c)
+	}
+	s := "S-1-"
+	if auth >= 1<<32 {
+		s += fmt.Sprintf("0x%012X", auth)
+	} else {
+		s += strconv.FormatUint(auth, 10)
+	}
+	for i := 0; i < count; i++ {
+		s += "-" + strconv.FormatUint(uint64(binary.LittleEndian.Uint32(b[8+4*i:])), 10)
+	}
+	if alias, ok := sidAliases[s]; ok {
+		s = alias
+	}
+	return s, nil
+}
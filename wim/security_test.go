@@ -0,0 +1,186 @@
+//go:build windows || linux
+// +build windows linux
+
+package wim
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testSID returns a binary SID with the specified identifier authority and sub-authorities.
+func testSID(auth uint64, subs ...uint32) []byte {
+	b := []byte{1, byte(len(subs)), byte(auth >> 40), byte(auth >> 32), byte(auth >> 24), byte(auth >> 16), byte(auth >> 8), byte(auth)}
+	for _, s := range subs {
+		b = appendUint32(b, s)
+	}
+	return b
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var x [4]byte
+	binary.LittleEndian.PutUint32(x[:], v)
+	return append(b, x[:]...)
+}
+
+func testACE(typ, flags byte, mask uint32, body ...[]byte) []byte {
+	b := []byte{typ, flags, 0, 0}
+	b = appendUint32(b, mask)
+	for _, x := range body {
+		b = append(b, x...)
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	return b
+}
+
+func testACL(aces ...[]byte) []byte {
+	b := make([]byte, aclHeaderSize)
+	b[0] = 2
+	for _, a := range aces {
+		b = append(b, a...)
+	}
+	binary.LittleEndian.PutUint16(b[2:], uint16(len(b)))
+	binary.LittleEndian.PutUint16(b[4:], uint16(len(aces)))
+	return b
+}
+
+// testSD returns a self-relative security descriptor. Nil parts are not present.
+func testSD(control uint16, owner, group, sacl, dacl []byte) []byte {
+	b := make([]byte, securityDescriptorSize)
+	b[0] = 1
+	if sacl != nil {
+		control |= seSACLPresent
+	}
+	if dacl != nil {
+		control |= seDACLPresent
+	}
+	binary.LittleEndian.PutUint16(b[2:], control|0x8000) // SE_SELF_RELATIVE
+	for i, part := range [][]byte{owner, group, sacl, dacl} {
+		if part != nil {
+			binary.LittleEndian.PutUint32(b[4+4*i:], uint32(len(b)))
+			b = append(b, part...)
+		}
+	}
+	return b
+}
+
+var (
+	sidEveryone = testSID(1, 0)
+	sidSystem   = testSID(5, 18)
+	sidAdmins   = testSID(5, 32, 544)
+	sidUsers    = testSID(5, 32, 545)
+	sidUser     = testSID(5, 21, 1, 2, 3, 1001)
+	sidHigh     = testSID(16, 12288)
+)
+
+var formatSDDLTests = []struct {
+	name string
+	sd   []byte
+	want string
+}{
+	{
+		name: "owner and group",
+		sd:   testSD(0, sidAdmins, sidSystem, nil, nil),
+		want: "O:BAG:SY",
+	},
+	{
+		name: "unknown SID",
+		sd:   testSD(0, sidUser, nil, nil, nil),
+		want: "O:S-1-5-21-1-2-3-1001",
+	},
+	{
+		name: "large identifier authority",
+		sd:   testSD(0, testSID(1<<40, 7), nil, nil, nil),
+		want: "O:S-1-0x010000000000-7",
+	},
+	{
+		name: "NULL DACL",
+		sd:   testSD(seDACLPresent, nil, nil, nil, nil),
+		want: "D:NO_ACCESS_CONTROL",
+	},
+	{
+		name: "empty protected DACL",
+		sd:   testSD(seDACLProtected, nil, nil, nil, testACL()),
+		want: "D:P",
+	},
+	{
+		name: "file DACL",
+		sd: testSD(seDACLAutoInherited|seDACLAutoInheritReq, sidAdmins, nil, nil, testACL(
+			testACE(aceTypeAccessDenied, 0, 0x1f01ff, sidEveryone),
+			testACE(aceTypeAccessAllowed, 0x03, 0x1f01ff, sidSystem),
+			testACE(aceTypeAccessAllowed, 0x13, 0x1200a9, sidUsers),
+			testACE(aceTypeAccessAllowed, 0x10, 0x120089, sidUser),
+		)),
+		want: "O:BAD:ARAI(D;;FA;;;WD)(A;OICI;FA;;;SY)(A;OICIID;0x1200a9;;;BU)(A;ID;FR;;;S-1-5-21-1-2-3-1001)",
+	},
+	{
+		name: "access right names",
+		sd: testSD(0, nil, nil, nil, testACL(
+			testACE(aceTypeAccessAllowed, 0x0b, 0x10000000, testSID(3, 0)),
+			testACE(aceTypeAccessAllowed, 0, 0xa0000000|0x00020000, sidUsers),
+		)),
+		want: "D:(A;OICIIO;GA;;;CO)(A;;GRGXRC;;;BU)",
+	},
+	{
+		name: "object ACE",
+		sd: testSD(0, nil, nil, nil, testACL(
+			testACE(aceTypeAccessAllowedObject, 0, 0x100, []byte{1, 0, 0, 0},
+				[]byte{0x78, 0x56, 0x34, 0x12, 0x34, 0x12, 0x34, 0x12, 1, 2, 3, 4, 5, 6, 7, 8}, sidEveryone),
+		)),
+		want: "D:(OA;;CR;12345678-1234-1234-0102-030405060708;;WD)",
+	},
+	{
+		name: "mandatory label",
+		sd: testSD(0, nil, nil, testACL(
+			testACE(aceTypeMandatoryLabel, 0x03, 0x1, sidHigh),
+		), nil),
+		want: "S:(ML;OICI;NW;;;HI)",
+	},
+	{
+		name: "audit",
+		sd: testSD(seSACLProtected, nil, nil, testACL(
+			testACE(aceTypeSystemAudit, 0xc0, 0x120116, sidEveryone),
+		), nil),
+		want: "S:P(AU;SAFA;FW;;;WD)",
+	},
+}
+
+func TestFormatSDDL(t *testing.T) {
+	for _, tt := range formatSDDLTests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := formatSDDL(tt.sd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s != tt.want {
+				t.Fatalf("got %q, want %q", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatSDDLMalformed(t *testing.T) {
+	valid := testSD(0, sidAdmins, nil, nil, testACL(testACE(aceTypeAccessAllowed, 0, 0x1f01ff, sidSystem)))
+	badACE := testSD(0, nil, nil, nil, testACL(testACE(aceTypeAccessAllowed, 0, 0x1f01ff, sidSystem)))
+	badACE[len(badACE)-len(sidSystem)-6] = 0xff // ACE size past the end of the ACL
+	ownerPastEnd := testSD(0, nil, nil, nil, nil)
+	binary.LittleEndian.PutUint32(ownerPastEnd[4:], 100)
+
+	for _, tt := range []struct {
+		name string
+		sd   []byte
+	}{
+		{"too short", valid[:securityDescriptorSize-1]},
+		{"bad revision", append([]byte{2}, valid[1:]...)},
+		{"truncated", valid[:len(valid)-1]},
+		{"bad ACE size", badACE},
+		{"owner past end", ownerPastEnd},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := formatSDDL(tt.sd); !errors.Is(err, errMalformedSD) {
+				t.Fatalf("expected %v, got %v", errMalformedSD, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+//go:build windows
+// +build windows
+
+package wim
+
+import (
+	"testing"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// windowsSDDL converts sd to SDDL with ConvertSecurityDescriptorToStringSecurityDescriptor.
+func windowsSDDL(sd []byte) string {
+	return (*windows.SECURITY_DESCRIPTOR)(unsafe.Pointer(&sd[0])).String()
+}
+
+// testSameAsWindows checks that Windows parses the SDDL formatted for sd to an equivalent
+// security descriptor, as the aliases used by Windows can depend on the system.
+func testSameAsWindows(t *testing.T, sd []byte) {
+	t.Helper()
+	s, err := formatSDDL(sd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := windows.SecurityDescriptorFromString(s)
+	if err != nil {
+		t.Fatalf("parse %q: %s", s, err)
+	}
+	if got, want := parsed.String(), windowsSDDL(sd); got != want {
+		t.Fatalf("%q is parsed as %q, want %q", s, got, want)
+	}
+}
+
+func TestFormatSDDLSameAsWindows(t *testing.T) {
+	for _, tt := range formatSDDLTests {
+		t.Run(tt.name, func(t *testing.T) {
+			testSameAsWindows(t, tt.sd)
+		})
+	}
+}
+
+func TestFormatSDDLFromWindows(t *testing.T) {
+	for _, s := range []string{
+		"O:BAG:SYD:PAI(A;OICI;FA;;;SY)(A;OICIIO;GA;;;CO)(A;;FR;;;BU)(D;;FX;;;WD)",
+		"O:SYG:SYD:AI(A;ID;FA;;;BA)(A;OICIID;0x1200a9;;;BU)(A;OICIID;GXGR;;;AU)",
+		"D:(A;;CCDCLCSWRPWPDTLOCRSDRCWDWO;;;SY)(OA;;CR;12345678-1234-1234-0102-030405060708;;WD)",
+		"S:(ML;OICI;NWNR;;;HI)",
+		"S:PAI(AU;SAFA;FW;;;WD)",
+	} {
+		t.Run(s, func(t *testing.T) {
+			sd, err := windows.SecurityDescriptorFromString(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			testSameAsWindows(t, unsafe.Slice((*byte)(unsafe.Pointer(sd)), sd.Length()))
+		})
+	}
+}
@@ -12,13 +12,14 @@ import (
 )
 
 func main() {
+	mmap := flag.Bool("mmap", false, "memory-map the WIM file")
 	flag.Parse()
 	f, err := os.Open(flag.Arg(0))
 	if err != nil {
 		panic(err)
 	}
 
-	w, err := wim.NewReader(f)
+	w, err := wim.NewReaderWithOptions(f, &wim.ReaderOptions{MemoryMap: *mmap})
 	if err != nil {
 		panic(err)
 	}
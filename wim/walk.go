@@ -0,0 +1,164 @@
+//go:build windows || linux
+// +build windows linux
+
+package wim
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// dirIndex holds the entries of a directory that has been read by [Image.OpenFile] or
+// [Image.Walk].
+type dirIndex struct {
+	files  []*File
+	byName map[string]*File // keyed by the upper-cased long and short names
+}
+
+// lookup returns the entry with the specified name, which is matched case-insensitively
+// against the long and short names of the entries.
+func (d *dirIndex) lookup(name string) *File {
+	return d.byName[strings.ToUpper(name)]
+}
+
+// rootFile returns the image's root directory, opening it on first use.
+func (img *Image) rootFile() (*File, error) {
+	img.indexLock.Lock()
+	root := img.root
+	img.indexLock.Unlock()
+	if root != nil {
+		return root, nil
+	}
+
+	root, err := img.Open()
+	if err != nil {
+		return nil, err
+	}
+	img.indexLock.Lock()
+	if img.root == nil {
+		img.root = root
+	}
+	root = img.root
+	img.indexLock.Unlock()
+	return root, nil
+}
+
+// dir returns the index of the directory f, reading it on first use.
+func (img *Image) dir(f *File) (*dirIndex, error) {
+	img.indexLock.Lock()
+	d := img.index[f.subdirOffset]
+	img.indexLock.Unlock()
+	if d != nil {
+		return d, nil
+	}
+
+	files, err := f.Readdir()
+	if err != nil {
+		return nil, err
+	}
+	d = &dirIndex{files: files, byName: make(map[string]*File, len(files))}
+	for _, e := range files {
+		d.byName[strings.ToUpper(e.Name)] = e
+	}
+	for _, e := range files {
+		// long names take precedence over short names that collide with them
+		if k := strings.ToUpper(e.ShortName); k != "" && d.byName[k] == nil {
+			d.byName[k] = e
+		}
+	}
+
+	img.indexLock.Lock()
+	if img.index == nil {
+		img.index = make(map[int64]*dirIndex)
+	}
+	if existing := img.index[f.subdirOffset]; existing != nil {
+		d = existing
+	} else {
+		img.index[f.subdirOffset] = d
+	}
+	img.indexLock.Unlock()
+	return d, nil
+}
+
+// OpenFile returns the file or directory at path in the image. The path is relative to the
+// image's root, uses either `\` or `/` as the separator, and its components are matched
+// case-insensitively against both the long and short names of the files. The root directory is
+// returned for an empty path or `\`.
+//
+// The directories traversed are indexed as they are read, so subsequent lookups in the same
+// directories do not read the image metadata again.
+//
+// If the file does not exist, the returned error satisfies errors.Is(err, fs.ErrNotExist).
+func (img *Image) OpenFile(path string) (*File, error) {
+	f, err := img.rootFile()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.FieldsFunc(path, isPathSeparator) {
+		if !f.IsDir() {
+			return nil, &os.PathError{Op: "open", Path: path, Err: errNotDir}
+		}
+		d, err := img.dir(f)
+		if err != nil {
+			return nil, err
+		}
+		if f = d.lookup(name); f == nil {
+			return nil, &os.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		}
+	}
+	return f, nil
+}
+
+// WalkFunc is the type of the function called by [Image.Walk] for each file and directory.
+// path is the `\`-separated path of the file relative to the image's root, which is `\`
+// for the root directory itself.
+//
+// If the function returns fs.SkipDir when called for a directory, Walk does not visit the
+// directory's contents; when called for a file, Walk skips the remaining files in its
+// directory. Any other error stops the walk, and is returned by Walk.
+type WalkFunc func(path string, f *File) error
+
+// Walk calls fn for every file and directory in the image, in depth-first order, starting
+// with the root directory. Directories are visited before their contents, and the contents
+// are visited in the order they are stored in the image. Reparse points are not followed.
+//
+// Walk indexes the directories it reads, the same as [Image.OpenFile].
+func (img *Image) Walk(fn WalkFunc) error {
+	root, err := img.rootFile()
+	if err != nil {
+		return err
+	}
+	err = img.walk(`\`, root, fn)
+	if errors.Is(err, fs.SkipDir) {
+		return nil
+	}
+	return err
+}
+
+func (img *Image) walk(path string, f *File, fn WalkFunc) error {
+	if err := fn(path, f); err != nil || !f.IsDir() {
+		return err
+	}
+	d, err := img.dir(f)
+	if err != nil {
+		return err
+	}
+	for _, e := range d.files {
+		err := img.walk(strings.TrimSuffix(path, `\`)+`\`+e.Name, e, fn)
+		if errors.Is(err, fs.SkipDir) {
+			if e.IsDir() {
+				continue
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isPathSeparator(r rune) bool {
+	return r == '\\' || r == '/'
+}
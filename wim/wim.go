@@ -15,10 +15,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 	"unicode/utf16"
+
+	"github.com/Microsoft/go-winio/wim/lzms"
 )
 
 // File attribute constants from Windows.
@@ -104,11 +108,12 @@ const (
 	resFlagMetadata
 	resFlagCompressed
 	resFlagSpanned
+	resFlagSolid
 )
 
 const validate = false
 
-const supportedResFlags = resFlagMetadata | resFlagCompressed
+const supportedResFlags = resFlagMetadata | resFlagCompressed | resFlagSolid
 
 func (r *resourceDescriptor) Flags() resFlag {
 	return resFlag(r.FlagsAndCompressedSize >> 56)
@@ -155,9 +160,10 @@ const (
 	hdrFlagCompressReserved hdrFlag = 1 << (iota + 16)
 	hdrFlagCompressXpress
 	hdrFlagCompressLzx
+	hdrFlagCompressLzms
 )
 
-const supportedHdrFlags = hdrFlagRpFix | hdrFlagReadOnly | hdrFlagCompressed | hdrFlagCompressLzx
+const supportedHdrFlags = hdrFlagRpFix | hdrFlagReadOnly | hdrFlagCompressed | hdrFlagCompressLzx | hdrFlagCompressLzms
 
 type wimHeader struct {
 	ImageTag        [8]byte
@@ -310,6 +316,17 @@ type Reader struct {
 	hdr      wimHeader
 	r        io.ReaderAt
 	fileData map[SHA1Hash]resourceDescriptor
+	format   compressionFormat
+	solid    []*solidResource
+	unmap    func() error // releases the memory mapping, if any
+
+	lazySecurityDescriptors bool
+
+	// the most recently used solid resource chunk
+	chunkMu   sync.Mutex
+	chunkRes  *solidResource
+	chunkN    int
+	chunkData []byte
 
 	XMLInfo string   // The XML information about the WIM.
 	Image   []*Image // The WIM's images.
@@ -319,12 +336,18 @@ type Reader struct {
 type Image struct {
 	wim        *Reader
 	offset     resourceDescriptor
-	sds        [][]byte
 	rootOffset int64
 	r          io.ReadCloser
 	curOffset  int64
 	m          sync.Mutex
 
+	indexLock sync.Mutex
+	root      *File               // the root directory, once opened by OpenFile or Walk
+	index     map[int64]*dirIndex // the directories read by OpenFile or Walk, by offset
+
+	sdLock sync.Mutex
+	sds    [][]byte // the security descriptor table, once loaded by securityDescriptor
+
 	ImageInfo
 }
 
@@ -344,9 +367,13 @@ type Stream struct {
 
 // FileHeader contains file metadata.
 type FileHeader struct {
-	Name               string
-	ShortName          string
-	Attributes         uint32
+	Name       string
+	ShortName  string
+	Attributes uint32
+
+	// SecurityDescriptor is not set if the WIM was opened with
+	// [ReaderOptions.LazySecurityDescriptors]; use [File.RawSecurityDescriptor] or
+	// [File.SecurityDescriptorSDDL] instead.
 	SecurityDescriptor []byte
 	CreationTime       Filetime
 	LastAccessTime     Filetime
@@ -365,47 +392,116 @@ type File struct {
 	offset       resourceDescriptor
 	img          *Image
 	subdirOffset int64
+	securityID   uint32
+}
+
+// ReaderOptions contains options for [NewReaderWithOptions].
+type ReaderOptions struct {
+	// MemoryMap maps the WIM file into memory when the reader is an *os.File, and serves
+	// all reads from the mapping instead of with ReadAt calls. Pages are loaded lazily by
+	// the OS as they are accessed, which makes random access to the metadata of very large
+	// WIMs much cheaper.
+	//
+	// If the file cannot be mapped (for instance, if it is too large for the address space
+	// of a 32-bit process), it is read normally. The mapping is released by [Reader.Close],
+	// after which no files or streams of the WIM can be read.
+	MemoryMap bool
+
+	// LazySecurityDescriptors defers loading an image's security descriptor table until
+	// [File.RawSecurityDescriptor] or [File.SecurityDescriptorSDDL] is called for one of
+	// its files, instead of loading it when the image is opened. The SecurityDescriptor
+	// field of the files' headers is then not set. This saves memory and time when the
+	// security descriptors of a large image are not needed.
+	LazySecurityDescriptors bool
 }
 
 // NewReader returns a Reader that can be used to read WIM file data.
 func NewReader(f io.ReaderAt) (*Reader, error) {
+	return NewReaderWithOptions(f, nil)
+}
+
+// NewReaderWithOptions returns a Reader that can be used to read WIM file data, configured by
+// opts. A nil opts is equivalent to [NewReader].
+func NewReaderWithOptions(f io.ReaderAt, opts *ReaderOptions) (*Reader, error) {
 	r := &Reader{r: f}
-	section := io.NewSectionReader(f, 0, 0xffff)
+	if opts != nil {
+		if file, ok := f.(*os.File); ok && opts.MemoryMap {
+			r.mapFile(file)
+		}
+		r.lazySecurityDescriptors = opts.LazySecurityDescriptors
+	}
+	if err := r.init(); err != nil {
+		if r.unmap != nil {
+			_ = r.unmap()
+		}
+		return nil, err
+	}
+	return r, nil
+}
+
+// mapFile replaces r.r with a memory mapping of f, if it can be mapped.
+func (r *Reader) mapFile(f *os.File) {
+	fi, err := f.Stat()
+	if err != nil || fi.Size() == 0 || int64(int(fi.Size())) != fi.Size() {
+		return
+	}
+	b, unmap, err := mapFile(f, fi.Size())
+	if err != nil {
+		return
+	}
+	r.r = bytes.NewReader(b)
+	r.unmap = unmap
+}
+
+func (r *Reader) init() error {
+	section := io.NewSectionReader(r.r, 0, 0xffff)
 	err := binary.Read(section, binary.LittleEndian, &r.hdr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if r.hdr.ImageTag != wimImageTag {
-		return nil, &ParseError{Oper: "image tag", Err: errors.New("not a WIM file")}
+		return &ParseError{Oper: "image tag", Err: errors.New("not a WIM file")}
 	}
 
 	if r.hdr.Flags&^supportedHdrFlags != 0 {
-		return nil, fmt.Errorf("unsupported WIM flags %x", r.hdr.Flags&^supportedHdrFlags)
+		return fmt.Errorf("unsupported WIM flags %x", r.hdr.Flags&^supportedHdrFlags)
 	}
 
-	if r.hdr.CompressionSize != 0x8000 {
-		return nil, fmt.Errorf("unsupported compression size %d", r.hdr.CompressionSize)
+	switch r.hdr.Flags & (hdrFlagCompressLzx | hdrFlagCompressLzms) {
+	case hdrFlagCompressLzms:
+		// ESD files use larger chunks.
+		r.format = formatLzms
+		if size := r.hdr.CompressionSize; size < lzxChunkSize || size > lzms.MaxSize || size&(size-1) != 0 {
+			return fmt.Errorf("unsupported compression size %d", size)
+		}
+	case hdrFlagCompressLzms | hdrFlagCompressLzx:
+		return errors.New("conflicting WIM compression flags")
+	default:
+		r.format = formatLzx
+		if r.hdr.CompressionSize != lzxChunkSize {
+			return fmt.Errorf("unsupported compression size %d", r.hdr.CompressionSize)
+		}
 	}
 
 	if r.hdr.TotalParts != 1 {
-		return nil, errors.New("multi-part WIM not supported")
+		return errors.New("multi-part WIM not supported")
 	}
 
 	fileData, images, err := r.readOffsetTable(&r.hdr.OffsetTable)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	xmlinfo, err := r.readXML()
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	var inf info
 	err = xml.Unmarshal([]byte(xmlinfo), &inf)
 	if err != nil {
-		return nil, &ParseError{Oper: "XML info", Err: err}
+		return &ParseError{Oper: "XML info", Err: err}
 	}
 
 	for i, img := range images {
@@ -420,7 +516,7 @@ func NewReader(f io.ReaderAt) (*Reader, error) {
 	r.fileData = fileData
 	r.Image = images
 	r.XMLInfo = xmlinfo
-	return r, nil
+	return nil
 }
 
 // Close releases resources associated with the Reader.
@@ -428,6 +524,11 @@ func (r *Reader) Close() error {
 	for _, img := range r.Image {
 		img.reset()
 	}
+	if r.unmap != nil {
+		unmap := r.unmap
+		r.unmap = nil
+		return unmap()
+	}
 	return nil
 }
 
@@ -436,13 +537,22 @@ func (r *Reader) resourceReader(hdr *resourceDescriptor) (io.ReadCloser, error)
 }
 
 func (r *Reader) resourceReaderWithOffset(hdr *resourceDescriptor, offset int64) (io.ReadCloser, error) {
+	if hdr.Flags()&resFlagSolid != 0 {
+		return &solidReader{
+			wim:    r,
+			res:    r.solid[hdr.CompressedSize()],
+			offset: hdr.Offset + offset,
+			end:    hdr.Offset + hdr.OriginalSize,
+		}, nil
+	}
+
 	var sr io.ReadCloser
 	section := io.NewSectionReader(r.r, hdr.Offset, hdr.CompressedSize())
 	if hdr.Flags()&resFlagCompressed == 0 {
 		_, _ = section.Seek(offset, 0)
 		sr = io.NopCloser(section)
 	} else {
-		cr, err := newCompressedReader(section, hdr.OriginalSize, offset)
+		cr, err := newCompressedReader(section, hdr.OriginalSize, offset, r.format, int64(r.hdr.CompressionSize))
 		if err != nil {
 			return nil, err
 		}
@@ -493,8 +603,9 @@ func (r *Reader) readOffsetTable(res *resourceDescriptor) (map[SHA1Hash]resource
 		return nil, nil, &ParseError{Oper: "offset table", Err: err}
 	}
 
+	var entries []streamDescriptor
 	br := bytes.NewReader(offsetTable)
-	for i := 0; ; i++ {
+	for {
 		var res streamDescriptor
 		err := binary.Read(br, binary.LittleEndian, &res)
 		if err == io.EOF { //nolint:errorlint
@@ -506,6 +617,45 @@ func (r *Reader) readOffsetTable(res *resourceDescriptor) (map[SHA1Hash]resource
 		if res.Flags()&^supportedResFlags != 0 {
 			return nil, nil, &ParseError{Oper: "offset table", Err: errors.New("unsupported resource flag")}
 		}
+		entries = append(entries, res)
+	}
+
+	// solid is the run of solid resources that the current run of solid entries refers to.
+	var solid []int
+	for i, res := range entries {
+		if res.Flags()&resFlagSolid != 0 {
+			if solid == nil {
+				var err error
+				solid, err = r.readSolidResources(entries[i:])
+				if err != nil {
+					return nil, nil, &ParseError{Oper: "offset table", Err: err}
+				}
+			}
+			if res.OriginalSize == solidResourceMagic {
+				continue
+			}
+			if res.Flags()&resFlagMetadata != 0 {
+				return nil, nil, &ParseError{Oper: "offset table", Err: errors.New("solid metadata resource")}
+			}
+			// The stream's offset is within the concatenated data of the run of solid
+			// resources, but each stream is in a single resource. The descriptor is
+			// changed to hold the offset within that resource, and its index in r.solid
+			// in place of the compressed size.
+			found := false
+			for _, j := range solid {
+				if res.Offset >= 0 && res.Offset+res.OriginalSize <= r.solid[j].originalSize {
+					res.FlagsAndCompressedSize = uint64(resFlagSolid)<<56 | uint64(j)
+					found = true
+					break
+				}
+				res.Offset -= r.solid[j].originalSize
+			}
+			if !found {
+				return nil, nil, &ParseError{Oper: "offset table", Err: errors.New("stream not in solid resource")}
+			}
+		} else {
+			solid = nil
+		}
 
 		// Validation for ad-hoc testing
 		if validate {
@@ -544,6 +694,27 @@ func (r *Reader) readOffsetTable(res *resourceDescriptor) (map[SHA1Hash]resource
 	return fileData, images, nil
 }
 
+// readSolidResources reads the headers of the solid resources in the run of solid entries at
+// the start of entries, and returns their indexes in r.solid.
+func (r *Reader) readSolidResources(entries []streamDescriptor) ([]int, error) {
+	solid := []int{}
+	for _, res := range entries {
+		if res.Flags()&resFlagSolid == 0 {
+			break
+		}
+		if res.OriginalSize != solidResourceMagic {
+			continue
+		}
+		s, err := newSolidResource(io.NewSectionReader(r.r, res.Offset, res.CompressedSize()))
+		if err != nil {
+			return nil, err
+		}
+		solid = append(solid, len(r.solid))
+		r.solid = append(r.solid, s)
+	}
+	return solid, nil
+}
+
 func (*Reader) readSecurityDescriptors(rsrc io.Reader) (sds [][]byte, n int64, err error) {
 	var secBlock securityblockDisk
 	err = binary.Read(rsrc, binary.LittleEndian, &secBlock)
@@ -588,17 +759,27 @@ func (*Reader) readSecurityDescriptors(rsrc io.Reader) (sds [][]byte, n int64, e
 
 // Open parses the image and returns the root directory.
 func (img *Image) Open() (*File, error) {
-	if img.sds == nil {
-		rsrc, err := img.wim.resourceReaderWithOffset(&img.offset, img.rootOffset)
+	if img.rootOffset == 0 {
+		rsrc, err := img.wim.resourceReader(&img.offset)
 		if err != nil {
 			return nil, err
 		}
-		sds, n, err := img.wim.readSecurityDescriptors(rsrc)
+		var n int64
+		if img.wim.lazySecurityDescriptors {
+			// The security descriptors are loaded separately, when first needed.
+			n, err = skipSecurityData(rsrc)
+		} else {
+			var sds [][]byte
+			if sds, n, err = img.wim.readSecurityDescriptors(rsrc); err == nil {
+				img.sdLock.Lock()
+				img.sds = sds
+				img.sdLock.Unlock()
+			}
+		}
 		if err != nil {
 			rsrc.Close()
 			return nil, err
 		}
-		img.sds = sds
 		img.r = rsrc
 		img.rootOffset = n
 		img.curOffset = n
@@ -626,7 +807,7 @@ func (img *Image) readdir(offset int64) ([]*File, error) {
 	img.m.Lock()
 	defer img.m.Unlock()
 
-	if offset < img.curOffset || offset > img.curOffset+chunkSize {
+	if offset < img.curOffset || offset > img.curOffset+int64(img.wim.hdr.CompressionSize) {
 		// Reset to seek backward or to seek forward very far.
 		img.reset()
 	}
@@ -649,9 +830,16 @@ func (img *Image) readdir(offset int64) ([]*File, error) {
 		}
 	}
 
+	var sds [][]byte
+	if !img.wim.lazySecurityDescriptors {
+		img.sdLock.Lock()
+		sds = img.sds
+		img.sdLock.Unlock()
+	}
+
 	var entries []*File
 	for {
-		e, n, err := img.readNextEntry(img.r)
+		e, n, err := img.readNextEntry(img.r, sds)
 		img.curOffset += n
 		if err == io.EOF { //nolint:errorlint
 			break
@@ -665,7 +853,9 @@ func (img *Image) readdir(offset int64) ([]*File, error) {
 	return entries, nil
 }
 
-func (img *Image) readNextEntry(r io.Reader) (*File, int64, error) {
+// readNextEntry reads a directory entry. The entry's security descriptor is looked up in sds,
+// unless sds is nil.
+func (img *Image) readNextEntry(r io.Reader, sds [][]byte) (*File, int64, error) {
 	var length int64
 	err := binary.Read(r, binary.LittleEndian, &length)
 	if err != nil {
@@ -740,6 +930,7 @@ func (img *Image) readNextEntry(r io.Reader) (*File, int64, error) {
 		offset:       offset,
 		img:          img,
 		subdirOffset: dentry.SubdirOffset,
+		securityID:   dentry.SecurityID,
 	}
 
 	isDir := false
@@ -760,8 +951,11 @@ func (img *Image) readNextEntry(r io.Reader) (*File, int64, error) {
 		return nil, 0, &ParseError{Oper: "directory entry", Path: name, Err: errors.New("unexpected subdirectory data for non-directory")}
 	}
 
-	if dentry.SecurityID != 0xffffffff {
-		f.SecurityDescriptor = img.sds[dentry.SecurityID]
+	if dentry.SecurityID != noSecurityID && sds != nil {
+		if int64(dentry.SecurityID) >= int64(len(sds)) {
+			return nil, 0, &ParseError{Oper: "directory entry", Path: name, Err: fmt.Errorf("invalid security ID %d", dentry.SecurityID)}
+		}
+		f.SecurityDescriptor = sds[dentry.SecurityID]
 	}
 
 	_, err = io.CopyN(io.Discard, r, left)
@@ -873,6 +1067,55 @@ func (img *Image) readNextStream(r io.Reader) (*Stream, int64, error) {
 	return s, length, nil
 }
 
+// ErrResourceNotFound is returned by [Reader.OpenResourceByHash] when the WIM does not
+// contain a resource with the requested hash.
+var ErrResourceNotFound = errors.New("WIM resource not found")
+
+var errNotDir = errors.New("not a directory")
+
+// Resource describes a file data resource in the WIM. Each resource is stored once,
+// no matter how many files or streams in the WIM's images share its contents.
+type Resource struct {
+	// Hash is the SHA1 hash of the resource's uncompressed contents.
+	Hash SHA1Hash
+	// Size is the uncompressed size of the resource.
+	Size int64
+	// CompressedSize is the size of the resource as stored in the WIM. It is zero for
+	// resources in the solid resources of ESD files, which are compressed together.
+	CompressedSize int64
+}
+
+// Resources returns the file data resources in the WIM, sorted by hash.
+func (r *Reader) Resources() []Resource {
+	res := make([]Resource, 0, len(r.fileData))
+	for h, d := range r.fileData {
+		var compressedSize int64
+		if d.Flags()&resFlagSolid == 0 {
+			compressedSize = d.CompressedSize()
+		}
+		res = append(res, Resource{
+			Hash:           h,
+			Size:           d.OriginalSize,
+			CompressedSize: compressedSize,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return bytes.Compare(res[i].Hash[:], res[j].Hash[:]) < 0
+	})
+	return res
+}
+
+// OpenResourceByHash returns an io.ReadCloser that can be used to read the contents of
+// the resource with hash h, which is the same as the hash of any file or stream with those
+// contents. It returns [ErrResourceNotFound] if there is no such resource.
+func (r *Reader) OpenResourceByHash(h SHA1Hash) (io.ReadCloser, error) {
+	d, ok := r.fileData[h]
+	if !ok {
+		return nil, ErrResourceNotFound
+	}
+	return r.resourceReader(&d)
+}
+
 // Open returns an io.ReadCloser that can be used to read the stream's contents.
 func (s *Stream) Open() (io.ReadCloser, error) {
 	return s.wim.resourceReader(&s.offset)
@@ -886,7 +1129,7 @@ func (f *File) Open() (io.ReadCloser, error) {
 // Readdir reads the directory entries.
 func (f *File) Readdir() ([]*File, error) {
 	if !f.IsDir() {
-		return nil, errors.New("not a directory")
+		return nil, errNotDir
 	}
 	return f.img.readdir(f.subdirOffset)
 }
@@ -48,6 +48,9 @@ var (
 	procAdjustTokenPrivileges              = modadvapi32.NewProc("AdjustTokenPrivileges")
 	procConvertSidToStringSidW             = modadvapi32.NewProc("ConvertSidToStringSidW")
 	procConvertStringSidToSidW             = modadvapi32.NewProc("ConvertStringSidToSidW")
+	procCreateRestrictedToken              = modadvapi32.NewProc("CreateRestrictedToken")
+	procGetEffectiveRightsFromAclW         = modadvapi32.NewProc("GetEffectiveRightsFromAclW")
+	procImpersonateNamedPipeClient         = modadvapi32.NewProc("ImpersonateNamedPipeClient")
 	procImpersonateSelf                    = modadvapi32.NewProc("ImpersonateSelf")
 	procLookupAccountNameW                 = modadvapi32.NewProc("LookupAccountNameW")
 	procLookupAccountSidW                  = modadvapi32.NewProc("LookupAccountSidW")
@@ -63,12 +66,19 @@ var (
 	procCreateIoCompletionPort             = modkernel32.NewProc("CreateIoCompletionPort")
 	procCreateNamedPipeW                   = modkernel32.NewProc("CreateNamedPipeW")
 	procDisconnectNamedPipe                = modkernel32.NewProc("DisconnectNamedPipe")
+	procFindFirstStreamW                   = modkernel32.NewProc("FindFirstStreamW")
+	procFindNextStreamW                    = modkernel32.NewProc("FindNextStreamW")
 	procGetCurrentThread                   = modkernel32.NewProc("GetCurrentThread")
+	procGetHandleInformation               = modkernel32.NewProc("GetHandleInformation")
 	procGetNamedPipeHandleStateW           = modkernel32.NewProc("GetNamedPipeHandleStateW")
 	procGetNamedPipeInfo                   = modkernel32.NewProc("GetNamedPipeInfo")
 	procGetQueuedCompletionStatus          = modkernel32.NewProc("GetQueuedCompletionStatus")
+	procPeekNamedPipe                      = modkernel32.NewProc("PeekNamedPipe")
 	procSetFileCompletionNotificationModes = modkernel32.NewProc("SetFileCompletionNotificationModes")
+	procWaitNamedPipeW                     = modkernel32.NewProc("WaitNamedPipeW")
 	procNtCreateNamedPipeFile              = modntdll.NewProc("NtCreateNamedPipeFile")
+	procNtOpenSymbolicLinkObject           = modntdll.NewProc("NtOpenSymbolicLinkObject")
+	procNtQuerySymbolicLinkObject          = modntdll.NewProc("NtQuerySymbolicLinkObject")
 	procRtlDefaultNpAcl                    = modntdll.NewProc("RtlDefaultNpAcl")
 	procRtlDosPathNameToNtPathName_U       = modntdll.NewProc("RtlDosPathNameToNtPathName_U")
 	procRtlNtStatusToDosErrorNoTeb         = modntdll.NewProc("RtlNtStatusToDosErrorNoTeb")
@@ -104,6 +114,30 @@ func convertStringSidToSid(str *uint16, sid **byte) (err error) {
 	return
 }
 
+func createRestrictedToken(existing windows.Token, flags uint32, disableSidCount uint32, sidsToDisable *windows.SIDAndAttributes, deletePrivilegeCount uint32, privilegesToDelete *windows.LUIDAndAttributes, restrictedSidCount uint32, sidsToRestrict *windows.SIDAndAttributes, newToken *windows.Token) (err error) {
+	r1, _, e1 := syscall.Syscall9(procCreateRestrictedToken.Addr(), 9, uintptr(existing), uintptr(flags), uintptr(disableSidCount), uintptr(unsafe.Pointer(sidsToDisable)), uintptr(deletePrivilegeCount), uintptr(unsafe.Pointer(privilegesToDelete)), uintptr(restrictedSidCount), uintptr(unsafe.Pointer(sidsToRestrict)), uintptr(unsafe.Pointer(newToken)))
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func getEffectiveRightsFromAcl(acl *windows.ACL, trustee *windows.TRUSTEE, rights *uint32) (win32err error) {
+	r0, _, _ := syscall.Syscall(procGetEffectiveRightsFromAclW.Addr(), 3, uintptr(unsafe.Pointer(acl)), uintptr(unsafe.Pointer(trustee)), uintptr(unsafe.Pointer(rights)))
+	if r0 != 0 {
+		win32err = syscall.Errno(r0)
+	}
+	return
+}
+
+func impersonateNamedPipeClient(pipe windows.Handle) (err error) {
+	r1, _, e1 := syscall.Syscall(procImpersonateNamedPipeClient.Addr(), 1, uintptr(pipe), 0, 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func impersonateSelf(level uint32) (err error) {
 	r1, _, e1 := syscall.Syscall(procImpersonateSelf.Addr(), 1, uintptr(level), 0, 0)
 	if r1 == 0 {
@@ -304,12 +338,46 @@ func disconnectNamedPipe(pipe windows.Handle) (err error) {
 	return
 }
 
+func findFirstStream(name string, infoLevel uint32, data *win32FindStreamData, flags uint32) (h windows.Handle, err error) {
+	var _p0 *uint16
+	_p0, err = syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return
+	}
+	return _findFirstStream(_p0, infoLevel, data, flags)
+}
+
+func _findFirstStream(name *uint16, infoLevel uint32, data *win32FindStreamData, flags uint32) (h windows.Handle, err error) {
+	r0, _, e1 := syscall.Syscall6(procFindFirstStreamW.Addr(), 4, uintptr(unsafe.Pointer(name)), uintptr(infoLevel), uintptr(unsafe.Pointer(data)), uintptr(flags), 0, 0)
+	h = windows.Handle(r0)
+	if h == windows.InvalidHandle {
+		err = errnoErr(e1)
+	}
+	return
+}
+
+func findNextStream(h windows.Handle, data *win32FindStreamData) (err error) {
+	r1, _, e1 := syscall.Syscall(procFindNextStreamW.Addr(), 2, uintptr(h), uintptr(unsafe.Pointer(data)), 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func getCurrentThread() (h windows.Handle) {
 	r0, _, _ := syscall.Syscall(procGetCurrentThread.Addr(), 0, 0, 0, 0)
 	h = windows.Handle(r0)
 	return
 }
 
+func getHandleInformation(h windows.Handle, flags *uint32) (err error) {
+	r1, _, e1 := syscall.Syscall(procGetHandleInformation.Addr(), 2, uintptr(h), uintptr(unsafe.Pointer(flags)), 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func getNamedPipeHandleState(pipe windows.Handle, state *uint32, curInstances *uint32, maxCollectionCount *uint32, collectDataTimeout *uint32, userName *uint16, maxUserNameSize uint32) (err error) {
 	r1, _, e1 := syscall.Syscall9(procGetNamedPipeHandleStateW.Addr(), 7, uintptr(pipe), uintptr(unsafe.Pointer(state)), uintptr(unsafe.Pointer(curInstances)), uintptr(unsafe.Pointer(maxCollectionCount)), uintptr(unsafe.Pointer(collectDataTimeout)), uintptr(unsafe.Pointer(userName)), uintptr(maxUserNameSize), 0, 0)
 	if r1 == 0 {
@@ -334,6 +402,14 @@ func getQueuedCompletionStatus(port windows.Handle, bytes *uint32, key *uintptr,
 	return
 }
 
+func peekNamedPipe(pipe windows.Handle, buf *byte, bufSize uint32, bytesRead *uint32, bytesAvail *uint32, bytesLeftThisMessage *uint32) (err error) {
+	r1, _, e1 := syscall.Syscall6(procPeekNamedPipe.Addr(), 6, uintptr(pipe), uintptr(unsafe.Pointer(buf)), uintptr(bufSize), uintptr(unsafe.Pointer(bytesRead)), uintptr(unsafe.Pointer(bytesAvail)), uintptr(unsafe.Pointer(bytesLeftThisMessage)))
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func setFileCompletionNotificationModes(h windows.Handle, flags uint8) (err error) {
 	r1, _, e1 := syscall.Syscall(procSetFileCompletionNotificationModes.Addr(), 2, uintptr(h), uintptr(flags), 0)
 	if r1 == 0 {
@@ -342,12 +418,41 @@ func setFileCompletionNotificationModes(h windows.Handle, flags uint8) (err erro
 	return
 }
 
+func waitNamedPipe(name string, timeout uint32) (err error) {
+	var _p0 *uint16
+	_p0, err = syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return
+	}
+	return _waitNamedPipe(_p0, timeout)
+}
+
+func _waitNamedPipe(name *uint16, timeout uint32) (err error) {
+	r1, _, e1 := syscall.Syscall(procWaitNamedPipeW.Addr(), 2, uintptr(unsafe.Pointer(name)), uintptr(timeout), 0)
+	if r1 == 0 {
+		err = errnoErr(e1)
+	}
+	return
+}
+
 func ntCreateNamedPipeFile(pipe *windows.Handle, access ntAccessMask, oa *objectAttributes, iosb *ioStatusBlock, share ntFileShareMode, disposition ntFileCreationDisposition, options ntFileOptions, typ uint32, readMode uint32, completionMode uint32, maxInstances uint32, inboundQuota uint32, outputQuota uint32, timeout *int64) (status ntStatus) {
 	r0, _, _ := syscall.Syscall15(procNtCreateNamedPipeFile.Addr(), 14, uintptr(unsafe.Pointer(pipe)), uintptr(access), uintptr(unsafe.Pointer(oa)), uintptr(unsafe.Pointer(iosb)), uintptr(share), uintptr(disposition), uintptr(options), uintptr(typ), uintptr(readMode), uintptr(completionMode), uintptr(maxInstances), uintptr(inboundQuota), uintptr(outputQuota), uintptr(unsafe.Pointer(timeout)), 0)
 	status = ntStatus(r0)
 	return
 }
 
+func ntOpenSymbolicLinkObject(handle *windows.Handle, access uint32, oa *objectAttributes) (status ntStatus) {
+	r0, _, _ := syscall.Syscall(procNtOpenSymbolicLinkObject.Addr(), 3, uintptr(unsafe.Pointer(handle)), uintptr(access), uintptr(unsafe.Pointer(oa)))
+	status = ntStatus(r0)
+	return
+}
+
+func ntQuerySymbolicLinkObject(handle windows.Handle, target *unicodeString, length *uint32) (status ntStatus) {
+	r0, _, _ := syscall.Syscall(procNtQuerySymbolicLinkObject.Addr(), 3, uintptr(handle), uintptr(unsafe.Pointer(target)), uintptr(unsafe.Pointer(length)))
+	status = ntStatus(r0)
+	return
+}
+
 func rtlDefaultNpAcl(dacl *uintptr) (status ntStatus) {
 	r0, _, _ := syscall.Syscall(procRtlDefaultNpAcl.Addr(), 1, uintptr(unsafe.Pointer(dacl)), 0, 0)
 	status = ntStatus(r0)